@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+
+	"github.com/IBM/integrity-enforcer/cmd/pkg/policytest"
+)
+
+// BlastRadiusCommand lists resources in cluster which would be protected by a new profile
+type BlastRadiusCommand struct {
+	Namespace        string
+	ConfigPath       string
+	ShieldNamespace  string
+	ShieldConfigName string
+	KeyDir           string
+	Output           string
+	FailOnInvalid    bool
+	Debug            bool
+}
+
+// BlastRadius builds and returns an ffcli command
+func BlastRadius() *ffcli.Command {
+	cmd := BlastRadiusCommand{}
+	flagset := flag.NewFlagSet("ishieldctl blast-radius", flag.ExitOnError)
+
+	flagset.StringVar(&cmd.Namespace, "namespace", "default", "namespace of ResourceSigningProfile which does not have metadata.namespace. Default default.")
+	flagset.StringVar(&cmd.ConfigPath, "config", "", "path to the ShieldConfig CR or the ShieldConfig (spec.shieldConfig) yaml file. The one in cluster is used if not specified.")
+	flagset.StringVar(&cmd.ShieldNamespace, "shield-namespace", "integrity-shield-operator-system", "namespace of Integrity Shield")
+	flagset.StringVar(&cmd.ShieldConfigName, "shield-config", "ishield-config", "name of ShieldConfig CR in cluster")
+	flagset.StringVar(&cmd.KeyDir, "keys", "", "directory of verification keys in the layout <keyConfig>/<secret>/<pgp|x509|sigstore>/<file>")
+	flagset.StringVar(&cmd.Output, "output", "text", "output format, text or json. Default text.")
+	flagset.BoolVar(&cmd.FailOnInvalid, "fail-on-invalid", false, "exit with an error if any protected resource does not have a valid signature")
+	flagset.BoolVar(&cmd.Debug, "debug", false, "print the console log of the checks")
+
+	return &ffcli.Command{
+		Name:       "blast-radius",
+		ShortUsage: "ishieldctl blast-radius -keys <key dir> <RSP yaml file>",
+		ShortHelp:  "List resources in cluster which would be protected by the supplied RSP",
+		LongHelp: `List existing resources in cluster which would be protected by the supplied ResourceSigningProfile
+(or ClusterResourceSigningProfile) before it is applied, and check whether each of them currently has
+a valid signature with the profile. Resources marked as NG would be denied when they are changed without
+a new signature after the profile is applied.
+
+The profile is not applied to cluster. Image signatures are not checked in this command.
+
+EXAMPLES
+  # list resources protected by the new RSP, and check their signatures with the local keys
+  ishieldctl blast-radius -namespace secure-ns -keys ./keys ./new-rsp.yaml`,
+		FlagSet: flagset,
+		Exec:    cmd.Exec,
+	}
+}
+
+// Exec runs the blast-radius command
+func (c *BlastRadiusCommand) Exec(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return flag.ErrHelp
+	}
+	opts := &policytest.BlastRadiusOptions{
+		ProfilePath:      args[0],
+		Namespace:        c.Namespace,
+		ConfigPath:       c.ConfigPath,
+		ShieldNamespace:  c.ShieldNamespace,
+		ShieldConfigName: c.ShieldConfigName,
+		KeyDir:           c.KeyDir,
+		Debug:            c.Debug,
+	}
+	results, err := policytest.BlastRadius(opts)
+	if err != nil {
+		return err
+	}
+
+	switch c.Output {
+	case "json":
+		out, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(out))
+	default:
+		policytest.PrintBlastRadius(os.Stdout, results)
+	}
+
+	if invalid := policytest.NotAllowed(results); c.FailOnInvalid && len(invalid) > 0 {
+		return fmt.Errorf("%d protected resources do not have a valid signature", len(invalid))
+	}
+	return nil
+}
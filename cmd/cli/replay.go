@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+
+	"github.com/IBM/integrity-enforcer/cmd/pkg/policytest"
+)
+
+// ReplayCommand re-runs captured admission requests against a candidate configuration
+type ReplayCommand struct {
+	ConfigPath       string
+	SignerConfigPath string
+	ProfilePaths     string
+	KeyDir           string
+	Output           string
+	All              bool
+	FailOnFlip       bool
+	Debug            bool
+}
+
+// Replay builds and returns an ffcli command
+func Replay() *ffcli.Command {
+	cmd := ReplayCommand{}
+	flagset := flag.NewFlagSet("ishieldctl replay", flag.ExitOnError)
+
+	flagset.StringVar(&cmd.ConfigPath, "config", "", "path to the ShieldConfig CR or the ShieldConfig (spec.shieldConfig) yaml file")
+	flagset.StringVar(&cmd.SignerConfigPath, "signer-config", "", "path to the candidate SignerConfig yaml file")
+	flagset.StringVar(&cmd.ProfilePaths, "profiles", "", "comma separated yaml files or directories of candidate ResourceSigningProfiles. Namespaces, ResourceSignatures and break glass ConfigMaps in them are also used.")
+	flagset.StringVar(&cmd.KeyDir, "keys", "", "directory of verification keys in the layout <keyConfig>/<secret>/<pgp|x509|sigstore>/<file>")
+	flagset.StringVar(&cmd.Output, "output", "text", "output format, text or json. Default text.")
+	flagset.BoolVar(&cmd.All, "all", false, "print all requests, not only the flipped ones")
+	flagset.BoolVar(&cmd.FailOnFlip, "fail-on-flip", false, "exit with an error if any decision is flipped")
+	flagset.BoolVar(&cmd.Debug, "debug", false, "print the console log of the checks")
+
+	return &ffcli.Command{
+		Name:       "replay",
+		ShortUsage: "ishieldctl replay -config <ShieldConfig> -signer-config <SignerConfig> -profiles <RSPs> -keys <key dir> <capture log file or dir>...",
+		ShortHelp:  "Replay captured admission requests against candidate RSPs and SignerConfig",
+		LongHelp: `Re-run admission requests captured by Integrity Shield (log.captureLog in ShieldConfig) against
+a candidate RSP / SignerConfig change without a cluster, and report every request whose decision would flip.
+
+Captured Secrets and ResourceSignatures are redacted, so they are skipped in replay.
+Image verification and side effects (events, RSP status) are disabled in this command.
+
+EXAMPLES
+  # copy the capture log (and its backups captured-requests.txt.1, .2, ...) from the server container
+  kubectl cp <ishield namespace>/<integrity-shield-server pod>:/ishield-app/public/captured-requests.txt ./captures/captured-requests.txt -c <server container>
+
+  # report requests whose decision would flip with the candidate RSPs
+  ishieldctl replay -config shieldconfig.yaml -signer-config signerconfig.yaml -profiles ./new-profiles -keys ./keys ./captures`,
+		FlagSet: flagset,
+		Exec:    cmd.Exec,
+	}
+}
+
+// Exec runs the replay command
+func (c *ReplayCommand) Exec(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return flag.ErrHelp
+	}
+	profilePaths := []string{}
+	for _, p := range strings.Split(c.ProfilePaths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			profilePaths = append(profilePaths, p)
+		}
+	}
+	opts := &policytest.Options{
+		ConfigPath:       c.ConfigPath,
+		SignerConfigPath: c.SignerConfigPath,
+		ProfilePaths:     profilePaths,
+		KeyDir:           c.KeyDir,
+		Debug:            c.Debug,
+	}
+	results, err := policytest.Replay(opts, args)
+	if err != nil {
+		return err
+	}
+
+	switch c.Output {
+	case "json":
+		printed := results
+		if !c.All {
+			printed = policytest.Flipped(results)
+		}
+		out, _ := json.MarshalIndent(printed, "", "  ")
+		fmt.Println(string(out))
+	default:
+		policytest.PrintReplayResults(os.Stdout, results, c.All)
+	}
+
+	if flipped := policytest.Flipped(results); c.FailOnFlip && len(flipped) > 0 {
+		return fmt.Errorf("decisions of %d requests are flipped", len(flipped))
+	}
+	return nil
+}
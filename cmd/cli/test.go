@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+
+	"github.com/IBM/integrity-enforcer/cmd/pkg/policytest"
+)
+
+// TestCommand runs admission checks offline against local configurations
+type TestCommand struct {
+	ConfigPath       string
+	SignerConfigPath string
+	ProfilePaths     string
+	KeyDir           string
+	ExpectPath       string
+	Output           string
+	Debug            bool
+}
+
+// Test builds and returns an ffcli command
+func Test() *ffcli.Command {
+	cmd := TestCommand{}
+	flagset := flag.NewFlagSet("ishieldctl test", flag.ExitOnError)
+
+	flagset.StringVar(&cmd.ConfigPath, "config", "", "path to the ShieldConfig CR or the ShieldConfig (spec.shieldConfig) yaml file")
+	flagset.StringVar(&cmd.SignerConfigPath, "signer-config", "", "path to the SignerConfig yaml file")
+	flagset.StringVar(&cmd.ProfilePaths, "profiles", "", "comma separated yaml files or directories of ResourceSigningProfiles. Namespaces, ResourceSignatures and break glass ConfigMaps in them are also used.")
+	flagset.StringVar(&cmd.KeyDir, "keys", "", "directory of verification keys in the layout <keyConfig>/<secret>/<pgp|x509|sigstore>/<file>")
+	flagset.StringVar(&cmd.ExpectPath, "expect", "", "path to the yaml file of expected decisions, a list of {file, allowed, reason}")
+	flagset.StringVar(&cmd.Output, "output", "text", "output format, text or json. Default text.")
+	flagset.BoolVar(&cmd.Debug, "debug", false, "print the console log of the checks")
+
+	return &ffcli.Command{
+		Name:       "test",
+		ShortUsage: "ishieldctl test -config <ShieldConfig> -signer-config <SignerConfig> -profiles <RSPs> -keys <key dir> [-expect <expected decisions>] <AdmissionReview dir>",
+		ShortHelp:  "Replay AdmissionReviews against local configurations without a cluster",
+		LongHelp: `Run the admission checks of Integrity Shield for AdmissionReview (or AdmissionRequest) json files
+without a cluster, and print the decision for each.
+
+If an AdmissionReview file has "response.allowed" (e.g. captured in cluster), it is used as the expected
+decision unless it is specified in the expect file. The command fails if any decision is not the expected one.
+
+Image verification and side effects (events, RSP status) are disabled in this command.
+
+EXAMPLES
+  # print decisions for the requests in ./requests
+  ishieldctl test -config shieldconfig.yaml -signer-config signerconfig.yaml -profiles ./profiles -keys ./keys ./requests
+
+  # check decisions in CI
+  ishieldctl test -config shieldconfig.yaml -signer-config signerconfig.yaml -profiles ./profiles -keys ./keys -expect expected.yaml ./requests
+
+  # expected.yaml
+  - file: create-configmap-signed.json
+    allowed: true
+  - file: create-configmap-unsigned.json
+    allowed: false
+    reason: no-signature`,
+		FlagSet: flagset,
+		Exec:    cmd.Exec,
+	}
+}
+
+// Exec runs the test command
+func (c *TestCommand) Exec(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return flag.ErrHelp
+	}
+	profilePaths := []string{}
+	for _, p := range strings.Split(c.ProfilePaths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			profilePaths = append(profilePaths, p)
+		}
+	}
+	opts := &policytest.Options{
+		ConfigPath:       c.ConfigPath,
+		SignerConfigPath: c.SignerConfigPath,
+		ProfilePaths:     profilePaths,
+		KeyDir:           c.KeyDir,
+		ExpectPath:       c.ExpectPath,
+		Debug:            c.Debug,
+	}
+	results, err := policytest.Run(opts, args[0])
+	if err != nil {
+		return err
+	}
+
+	switch c.Output {
+	case "json":
+		out, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(out))
+	default:
+		policytest.PrintResults(os.Stdout, results)
+	}
+
+	if failed := policytest.Failed(results); failed > 0 {
+		return fmt.Errorf("%d of %d requests did not get the expected decision", failed, len(results))
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ require (
 	github.com/sigstore/sigstore v0.0.0-20210516171352-bee6a385d4af
 	github.com/sirupsen/logrus v1.7.0
 	gopkg.in/yaml.v2 v2.4.0
+	k8s.io/api v0.20.2
 	k8s.io/apimachinery v0.20.2
 )
 
@@ -26,7 +26,7 @@ func main() {
 		ShortUsage: "ishieldctl [flags] <subcommand>",
 		FlagSet:    rootFlagSet,
 		Subcommands: []*ffcli.Command{
-			cli.SignYaml(), cli.VerifyYaml(), cli.AuditYaml()},
+			cli.SignYaml(), cli.VerifyYaml(), cli.AuditYaml(), cli.Test(), cli.Replay(), cli.BlastRadius()},
 		Exec: func(context.Context, []string) error {
 			return flag.ErrHelp
 		},
@@ -0,0 +1,214 @@
+package policytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	crspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/clusterresourcesigningprofile/v1alpha1"
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
+	sconfloder "github.com/IBM/integrity-enforcer/shield/pkg/config/loader"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+)
+
+// BlastRadiusOptions is a set of inputs for listing resources in cluster which would be protected by a new profile
+type BlastRadiusOptions struct {
+	ProfilePath string
+	// namespace of RSP which does not have metadata.namespace
+	Namespace string
+	// ShieldConfig is loaded from this file if specified, otherwise from cluster
+	ConfigPath       string
+	ShieldNamespace  string
+	ShieldConfigName string
+	KeyDir           string
+	Debug            bool
+}
+
+// ProtectedResource is an existing resource which would be protected by the profile, and its current signature status
+type ProtectedResource struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Allowed    bool   `json:"allowed"`
+	Reason     string `json:"reason"`
+	Message    string `json:"message"`
+}
+
+// BlastRadius lists resources in cluster protected by the profiles in opts.ProfilePath,
+// and runs ResourceCheckHandler for each of them with the profiles instead of the ones in cluster.
+// Resources are sorted by kind, namespace and name.
+func BlastRadius(opts *BlastRadiusOptions) ([]*ProtectedResource, error) {
+	cfg, err := loadBlastRadiusConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := loadProfiles(opts.ProfilePath, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	nsList, _ := shield.NewNamespaceLoader().GetData(true)
+	ruleTable := shield.NewRuleTable(profiles, nsList, cfg.CommonProfile, cfg.Namespace)
+
+	apiResources, err := kubeutil.GetAPIResources()
+	if err != nil {
+		return nil, err
+	}
+	metaLogger := logger.NewLogger(cfg.LoggerConfig())
+
+	results := []*ProtectedResource{}
+	for _, apiResource := range apiResources {
+		if !listable(apiResource) {
+			continue
+		}
+		objs, err := kubeutil.ListResources(apiResource, "")
+		if err != nil {
+			// some resources cannot be listed with the current user (e.g. no RBAC), then they are skipped
+			if opts.Debug {
+				logger.Warn(fmt.Sprintf("failed to list %s; %s", apiResource.Name, err.Error()))
+			}
+			continue
+		}
+		for i := range objs {
+			obj := objs[i]
+			resc := common.NewResourceContext(&obj)
+			if protected, _, _ := ruleTable.CheckIfProtected(resc.Map(), resc.Objects()); !protected {
+				continue
+			}
+			// a new handler for each resource, because RunData is loaded for the namespace of the first resource
+			handler := shield.NewResourceCheckHandlerWithProfiles(cfg, metaLogger, profiles)
+			dr := handler.Run(&obj)
+			results = append(results, &ProtectedResource{
+				ApiVersion: obj.GetAPIVersion(),
+				Kind:       obj.GetKind(),
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+				Allowed:    dr.Type == common.DecisionAllow,
+				Reason:     common.ReasonCodeMap[dr.ReasonCode].Code,
+				Message:    dr.Message,
+			})
+		}
+	}
+	sortProtectedResources(results)
+	return results, nil
+}
+
+// sortProtectedResources sorts resources by kind, namespace and name
+func sortProtectedResources(results []*ProtectedResource) {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
+
+// NotAllowed returns resources which would be denied if they are changed after the profile is applied
+func NotAllowed(results []*ProtectedResource) []*ProtectedResource {
+	denied := []*ProtectedResource{}
+	for _, r := range results {
+		if !r.Allowed {
+			denied = append(denied, r)
+		}
+	}
+	return denied
+}
+
+// PrintBlastRadius writes a line for each protected resource and a summary
+func PrintBlastRadius(w io.Writer, results []*ProtectedResource) {
+	for _, r := range results {
+		status := "OK"
+		if !r.Allowed {
+			status = "NG"
+		}
+		resource := r.Name
+		if r.Namespace != "" {
+			resource = r.Namespace + "/" + r.Name
+		}
+		fmt.Fprintf(w, "[%s] %s %s %s (%s) %s\n", status, r.ApiVersion, r.Kind, resource, r.Reason, r.Message)
+	}
+	fmt.Fprintf(w, "%d resources would be protected, %d of them do not have a valid signature\n", len(results), len(NotAllowed(results)))
+}
+
+func loadBlastRadiusConfig(opts *BlastRadiusOptions) (*config.ShieldConfig, error) {
+	var cfg *config.ShieldConfig
+	var err error
+	if opts.ConfigPath != "" {
+		cfg, err = loadShieldConfig(opts.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cfg = sconfloder.LoadShieldConfig(opts.ShieldNamespace, opts.ShieldConfigName)
+		if cfg == nil {
+			return nil, fmt.Errorf("failed to load ShieldConfig %s in %s", opts.ShieldConfigName, opts.ShieldNamespace)
+		}
+	}
+	// keys in ShieldConfig are the paths in the server container
+	if opts.KeyDir != "" {
+		keyPathList, err := findKeyPaths(opts.KeyDir)
+		if err != nil {
+			return nil, err
+		}
+		cfg.KeyPathList = keyPathList
+	}
+	// no event / status update for this check; resources are read from the cluster
+	setReadOnlyConfig(cfg, opts.Debug)
+	return cfg, nil
+}
+
+// loadProfiles returns RSPs and CRSPs (converted to RSP) in the file; other resources in the file are ignored
+func loadProfiles(fpath, namespace string) ([]rspapi.ResourceSigningProfile, error) {
+	docs, err := splitDocuments(fpath)
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		namespace = defaultProfileNamespace
+	}
+	profiles := []rspapi.ResourceSigningProfile{}
+	for _, doc := range docs {
+		var meta metav1.TypeMeta
+		if err := json.Unmarshal(doc, &meta); err != nil {
+			return nil, err
+		}
+		switch meta.Kind {
+		case common.ProfileCustomResourceKind:
+			var rsp rspapi.ResourceSigningProfile
+			if err := json.Unmarshal(doc, &rsp); err != nil {
+				return nil, fmt.Errorf("failed to load a profile in %s; %s", fpath, err.Error())
+			}
+			if rsp.GetNamespace() == "" {
+				rsp.SetNamespace(namespace)
+			}
+			profiles = append(profiles, rsp)
+		case common.ClusterProfileCustomResourceKind:
+			var crsp crspapi.ClusterResourceSigningProfile
+			if err := json.Unmarshal(doc, &crsp); err != nil {
+				return nil, fmt.Errorf("failed to load a profile in %s; %s", fpath, err.Error())
+			}
+			profiles = append(profiles, crsp.ToResourceSigningProfile())
+		}
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no %s or %s is found in %s", common.ProfileCustomResourceKind, common.ClusterProfileCustomResourceKind, fpath)
+	}
+	return profiles, nil
+}
+
+// listable returns false for subresources and resources which do not support `list`
+func listable(apiResource metav1.APIResource) bool {
+	return kubeutil.IsListable(apiResource)
+}
@@ -0,0 +1,132 @@
+package policytest
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	profilePath := filepath.Join(testDataDir, "blastradius", "profiles.yaml")
+	testCases := []struct {
+		name              string
+		fpath             string
+		namespace         string
+		expectedError     bool
+		expectedNamespace map[string]string
+	}{
+		{
+			name:  "default namespace",
+			fpath: profilePath,
+			expectedNamespace: map[string]string{
+				"rsp-without-namespace": defaultProfileNamespace,
+				"rsp-with-namespace":    "secure-ns",
+				"sample-crsp":           "",
+			},
+		},
+		{
+			name:      "namespace specified",
+			fpath:     profilePath,
+			namespace: "sample-ns",
+			expectedNamespace: map[string]string{
+				"rsp-without-namespace": "sample-ns",
+				"rsp-with-namespace":    "secure-ns",
+				"sample-crsp":           "",
+			},
+		},
+		{
+			name:          "no profile in file",
+			fpath:         filepath.Join(testDataDir, "blastradius", "no-profile.yaml"),
+			expectedError: true,
+		},
+		{
+			name:          "file not found",
+			fpath:         filepath.Join(testDataDir, "blastradius", "not-found.yaml"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		profiles, err := loadProfiles(tc.fpath, tc.namespace)
+		if (err != nil) != tc.expectedError {
+			t.Errorf("[%s] expected error: %v, actual: %v", tc.name, tc.expectedError, err)
+			continue
+		}
+		if tc.expectedError {
+			continue
+		}
+		actualNamespace := map[string]string{}
+		for _, p := range profiles {
+			actualNamespace[p.GetName()] = p.GetNamespace()
+			if p.GetName() == "sample-crsp" && p.Kind != common.ClusterProfileCustomResourceKind {
+				t.Errorf("[%s] CRSP should be converted with kind %s, but got %s", tc.name, common.ClusterProfileCustomResourceKind, p.Kind)
+			}
+		}
+		if !reflect.DeepEqual(actualNamespace, tc.expectedNamespace) {
+			t.Errorf("[%s] expected profiles %v, actual %v", tc.name, tc.expectedNamespace, actualNamespace)
+		}
+	}
+}
+
+func TestListable(t *testing.T) {
+	testCases := []struct {
+		name        string
+		apiResource metav1.APIResource
+		expected    bool
+	}{
+		{
+			name:        "resource",
+			apiResource: metav1.APIResource{Name: "configmaps", Verbs: []string{"create", "get", "list", "watch"}},
+			expected:    true,
+		},
+		{
+			name:        "subresource",
+			apiResource: metav1.APIResource{Name: "deployments/status", Verbs: []string{"get", "list", "patch"}},
+		},
+		{
+			name:        "not listable",
+			apiResource: metav1.APIResource{Name: "tokenreviews", Verbs: []string{"create"}},
+		},
+		{
+			name:        "no verbs",
+			apiResource: metav1.APIResource{Name: "bindings"},
+		},
+	}
+	for _, tc := range testCases {
+		if actual := listable(tc.apiResource); actual != tc.expected {
+			t.Errorf("[%s] expected listable: %v, actual: %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestSortProtectedResources(t *testing.T) {
+	results := []*ProtectedResource{
+		{Kind: "Secret", Namespace: "ns1", Name: "a"},
+		{Kind: "ConfigMap", Namespace: "ns2", Name: "a"},
+		{Kind: "ClusterRole", Name: "b"},
+		{Kind: "ConfigMap", Namespace: "ns1", Name: "b"},
+		{Kind: "ConfigMap", Namespace: "ns1", Name: "a"},
+		{Kind: "ClusterRole", Name: "a"},
+	}
+	sortProtectedResources(results)
+
+	expected := []string{
+		"ClusterRole//a",
+		"ClusterRole//b",
+		"ConfigMap/ns1/a",
+		"ConfigMap/ns1/b",
+		"ConfigMap/ns2/a",
+		"Secret/ns1/a",
+	}
+	actual := []string{}
+	for _, r := range results {
+		actual = append(actual, r.Kind+"/"+r.Namespace+"/"+r.Name)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Test failed for sortProtectedResources(); expected %v, actual %v", expected, actual)
+	}
+}
@@ -0,0 +1,542 @@
+package policytest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	admv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	crspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/clusterresourcesigningprofile/v1alpha1"
+	rsigapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	ecfgapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/shieldconfig/v1alpha1"
+	sigconfapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+)
+
+const defaultProfileNamespace = "default"
+
+// Options is a set of inputs for running admission checks offline
+type Options struct {
+	ConfigPath       string
+	SignerConfigPath string
+	ProfilePaths     []string
+	KeyDir           string
+	ExpectPath       string
+	Debug            bool
+}
+
+// Expectation is an expected decision for a single AdmissionReview file.
+// Reason is a reason code like "no-signature" (see common.ReasonCodeMap)
+type Expectation struct {
+	File    string `json:"file"`
+	Allowed *bool  `json:"allowed,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Result is a decision for a single AdmissionReview file and the assertion result
+type Result struct {
+	File      string       `json:"file"`
+	Operation string       `json:"operation"`
+	Kind      string       `json:"kind"`
+	Namespace string       `json:"namespace,omitempty"`
+	Name      string       `json:"name"`
+	Allowed   bool         `json:"allowed"`
+	Reason    string       `json:"reason"`
+	Message   string       `json:"message"`
+	Expected  *Expectation `json:"expected,omitempty"`
+	Passed    bool         `json:"passed"`
+	Failure   string       `json:"failure,omitempty"`
+}
+
+// Run loads all inputs, runs Handler.Check() for each admission request in reviewPath and returns the results
+func Run(opts *Options, reviewPath string) ([]*Result, error) {
+	cfg, localData, err := loadInputs(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	expectations := map[string]*Expectation{}
+	if opts.ExpectPath != "" {
+		expectations, err = loadExpectations(opts.ExpectPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reviewFiles, err := listFiles(reviewPath, []string{".json"})
+	if err != nil {
+		return nil, err
+	}
+	reviews := map[string]*admv1.AdmissionReview{}
+	for _, fpath := range reviewFiles {
+		review, err := loadAdmissionReview(fpath)
+		if err != nil {
+			return nil, err
+		}
+		reviews[fpath] = review
+	}
+	requests := []*admv1.AdmissionRequest{}
+	for _, review := range reviews {
+		requests = append(requests, review.Request)
+	}
+	localData.NSList = completeNamespaceList(localData.NSList, localData.RSPList, requests)
+
+	metaLogger := logger.NewLogger(cfg.LoggerConfig())
+	handler := shield.NewHandlerWithLocalData(cfg, metaLogger, localData)
+
+	results := []*Result{}
+	for _, fpath := range reviewFiles {
+		review := reviews[fpath]
+		req := review.Request
+		_, dr := handler.Check(req)
+		result := &Result{
+			File:      fpath,
+			Operation: string(req.Operation),
+			Kind:      req.Kind.Kind,
+			Namespace: req.Namespace,
+			Name:      req.Name,
+			Allowed:   dr.Type == common.DecisionAllow,
+			Reason:    common.ReasonCodeMap[dr.ReasonCode].Code,
+			Message:   dr.Message,
+		}
+		expected := findExpectation(expectations, reviewPath, fpath)
+		if expected == nil && review.Response != nil {
+			// a captured AdmissionReview has the actual decision in cluster
+			allowed := review.Response.Allowed
+			expected = &Expectation{File: fpath, Allowed: &allowed}
+		}
+		result.Expected = expected
+		result.Passed, result.Failure = assert(result, expected)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// loadInputs loads ShieldConfig, SignerConfig, RSPs and keys; NSList is not completed yet
+func loadInputs(opts *Options) (*config.ShieldConfig, *shield.LocalData, error) {
+	cfg, err := loadShieldConfig(opts.ConfigPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.KeyDir != "" {
+		keyPathList, err := findKeyPaths(opts.KeyDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.KeyPathList = keyPathList
+	}
+	setOfflineConfig(cfg, opts.Debug)
+
+	signerConfig, err := loadSignerConfig(opts.SignerConfigPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	localData, err := loadResources(opts.ProfilePaths)
+	if err != nil {
+		return nil, nil, err
+	}
+	localData.SignerConfig = signerConfig
+	return cfg, localData, nil
+}
+
+// Failed returns the number of results whose assertion failed
+func Failed(results []*Result) int {
+	count := 0
+	for _, r := range results {
+		if !r.Passed {
+			count++
+		}
+	}
+	return count
+}
+
+// PrintResults writes a line for each result and a summary
+func PrintResults(w io.Writer, results []*Result) {
+	for _, r := range results {
+		status := "PASS"
+		if !r.Passed {
+			status = "FAIL"
+		} else if r.Expected == nil {
+			status = "----"
+		}
+		decision := "deny"
+		if r.Allowed {
+			decision = "allow"
+		}
+		resource := r.Name
+		if r.Namespace != "" {
+			resource = r.Namespace + "/" + r.Name
+		}
+		fmt.Fprintf(w, "[%s] %s: %s %s %s -> %s (%s) %s\n", status, r.File, r.Operation, r.Kind, resource, decision, r.Reason, r.Message)
+		if !r.Passed {
+			fmt.Fprintf(w, "       %s\n", r.Failure)
+		}
+	}
+	fmt.Fprintf(w, "%d requests, %d failed\n", len(results), Failed(results))
+}
+
+func assert(result *Result, expected *Expectation) (bool, string) {
+	if expected == nil {
+		return true, ""
+	}
+	failures := []string{}
+	if expected.Allowed != nil && *expected.Allowed != result.Allowed {
+		failures = append(failures, fmt.Sprintf("allowed: expected %v, actual %v", *expected.Allowed, result.Allowed))
+	}
+	if expected.Reason != "" && expected.Reason != result.Reason {
+		failures = append(failures, fmt.Sprintf("reason: expected %s, actual %s", expected.Reason, result.Reason))
+	}
+	if len(failures) > 0 {
+		return false, strings.Join(failures, "; ")
+	}
+	return true, ""
+}
+
+// setOfflineConfig disables all features which need a cluster, so that no request is sent to the cluster in the current kubeconfig
+func setOfflineConfig(cfg *config.ShieldConfig, debug bool) {
+	setReadOnlyConfig(cfg, debug)
+	// dry-run creates the signed object in the cluster, and helm plugin lists release secrets
+	cfg.DryRunFallback = &config.DryRunFallbackConfig{Enabled: false}
+	cfg.Plugin = []config.PluginConfig{}
+}
+
+// setReadOnlyConfig disables features which change anything in a cluster or need other than a cluster
+func setReadOnlyConfig(cfg *config.ShieldConfig, debug bool) {
+	if cfg.Log == nil {
+		cfg.Log = &config.LoggingScopeConfig{}
+	}
+	cfg.Log.ConsoleLog = &config.LogScopeConfig{Enabled: debug}
+	cfg.Log.ContextLog = &config.LogScopeConfig{Enabled: false}
+	cfg.Log.CaptureLog = &config.LogScopeConfig{Enabled: false}
+	if !debug {
+		cfg.Log.LogLevel = "fatal"
+	}
+	cfg.SideEffect = &config.SideEffectConfig{}
+	// image verification needs registry access and the configmap in cluster
+	cfg.ImageVerificationConfig.Enabled = false
+}
+
+// loadShieldConfig accepts either a ShieldConfig CR or a bare ShieldConfig (`spec.shieldConfig` of the CR)
+func loadShieldConfig(fpath string) (*config.ShieldConfig, error) {
+	if fpath == "" {
+		return nil, fmt.Errorf("ShieldConfig must be specified")
+	}
+	cfgBytes, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ShieldConfig; %s", err.Error())
+	}
+	var meta metav1.TypeMeta
+	_ = yaml.Unmarshal(cfgBytes, &meta)
+	if meta.Kind == "ShieldConfig" {
+		var ecfg *ecfgapi.ShieldConfig
+		if err := yaml.Unmarshal(cfgBytes, &ecfg); err != nil {
+			return nil, fmt.Errorf("failed to load ShieldConfig CR; %s", err.Error())
+		}
+		if ecfg.Spec.ShieldConfig == nil {
+			return nil, fmt.Errorf("ShieldConfig CR %s does not have spec.shieldConfig", fpath)
+		}
+		return ecfg.Spec.ShieldConfig, nil
+	}
+	var cfg *config.ShieldConfig
+	if err := yaml.Unmarshal(cfgBytes, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to load ShieldConfig; %s", err.Error())
+	}
+	return cfg, nil
+}
+
+// loadSignerConfig returns an empty SignerConfig if fpath is empty, so that all signatures are treated as invalid
+func loadSignerConfig(fpath string) (*sigconfapi.SignerConfig, error) {
+	if fpath == "" {
+		return &sigconfapi.SignerConfig{Spec: sigconfapi.SignerConfigSpec{Config: &common.SignerConfig{}}}, nil
+	}
+	scBytes, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SignerConfig; %s", err.Error())
+	}
+	var sc *sigconfapi.SignerConfig
+	if err := yaml.Unmarshal(scBytes, &sc); err != nil {
+		return nil, fmt.Errorf("failed to load SignerConfig; %s", err.Error())
+	}
+	if sc == nil || sc.Spec.Config == nil {
+		return nil, fmt.Errorf("SignerConfig %s does not have spec.config", fpath)
+	}
+	return sc, nil
+}
+
+// loadResources reads ResourceSigningProfiles, ClusterResourceSigningProfiles, Namespaces, ResourceSignatures and break glass ConfigMaps from yaml files
+func loadResources(paths []string) (*shield.LocalData, error) {
+	data := &shield.LocalData{
+		RSPList:            []rspapi.ResourceSigningProfile{},
+		CRSPList:           []crspapi.ClusterResourceSigningProfile{},
+		NSList:             []v1.Namespace{},
+		ResSigList:         []*rsigapi.ResourceSignature{},
+		BreakGlassRequests: []v1.ConfigMap{},
+	}
+	for _, p := range paths {
+		files, err := listFiles(p, []string{".yaml", ".yml", ".json"})
+		if err != nil {
+			return nil, err
+		}
+		for _, fpath := range files {
+			docs, err := splitDocuments(fpath)
+			if err != nil {
+				return nil, err
+			}
+			for _, doc := range docs {
+				if err := addResource(data, doc); err != nil {
+					return nil, fmt.Errorf("failed to load a resource in %s; %s", fpath, err.Error())
+				}
+			}
+		}
+	}
+	return data, nil
+}
+
+func addResource(data *shield.LocalData, doc []byte) error {
+	var meta metav1.TypeMeta
+	if err := json.Unmarshal(doc, &meta); err != nil {
+		return err
+	}
+	switch meta.Kind {
+	case common.ProfileCustomResourceKind:
+		var rsp rspapi.ResourceSigningProfile
+		if err := json.Unmarshal(doc, &rsp); err != nil {
+			return err
+		}
+		if rsp.GetNamespace() == "" {
+			rsp.SetNamespace(defaultProfileNamespace)
+		}
+		data.RSPList = append(data.RSPList, rsp)
+	case common.ClusterProfileCustomResourceKind:
+		var crsp crspapi.ClusterResourceSigningProfile
+		if err := json.Unmarshal(doc, &crsp); err != nil {
+			return err
+		}
+		data.CRSPList = append(data.CRSPList, crsp)
+	case "Namespace":
+		var ns v1.Namespace
+		if err := json.Unmarshal(doc, &ns); err != nil {
+			return err
+		}
+		data.NSList = append(data.NSList, ns)
+	case common.SignatureCustomResourceKind:
+		var rsig *rsigapi.ResourceSignature
+		if err := json.Unmarshal(doc, &rsig); err != nil {
+			return err
+		}
+		data.ResSigList = append(data.ResSigList, rsig)
+	case "ConfigMap":
+		var cm v1.ConfigMap
+		if err := json.Unmarshal(doc, &cm); err != nil {
+			return err
+		}
+		data.BreakGlassRequests = append(data.BreakGlassRequests, cm)
+	}
+	return nil
+}
+
+// completeNamespaceList adds Namespaces which are referred by requests or profiles but not given explicitly
+func completeNamespaceList(nsList []v1.Namespace, rspList []rspapi.ResourceSigningProfile, requests []*admv1.AdmissionRequest) []v1.Namespace {
+	known := map[string]bool{}
+	for _, ns := range nsList {
+		known[ns.GetName()] = true
+	}
+	names := []string{}
+	addName := func(name string) {
+		if name != "" && !known[name] {
+			known[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, rsp := range rspList {
+		addName(rsp.GetNamespace())
+	}
+	for _, req := range requests {
+		addName(req.Namespace)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ns := v1.Namespace{}
+		ns.SetName(name)
+		nsList = append(nsList, ns)
+	}
+	return nsList
+}
+
+// loadAdmissionReview accepts either an AdmissionReview or a bare AdmissionRequest
+func loadAdmissionReview(fpath string) (*admv1.AdmissionReview, error) {
+	reviewBytes, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s; %s", fpath, err.Error())
+	}
+	// `kind` of an AdmissionRequest is an object, so it cannot be decoded as an AdmissionReview
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(reviewBytes, &fields); err != nil {
+		return nil, fmt.Errorf("failed to load %s; %s", fpath, err.Error())
+	}
+	review := &admv1.AdmissionReview{}
+	if _, ok := fields["request"]; ok {
+		if err := json.Unmarshal(reviewBytes, &review); err != nil {
+			return nil, fmt.Errorf("failed to load %s; %s", fpath, err.Error())
+		}
+	} else {
+		var req *admv1.AdmissionRequest
+		if err := json.Unmarshal(reviewBytes, &req); err != nil {
+			return nil, fmt.Errorf("failed to load %s; %s", fpath, err.Error())
+		}
+		review = &admv1.AdmissionReview{Request: req}
+	}
+	if review.Request == nil || review.Request.Kind.Kind == "" {
+		return nil, fmt.Errorf("%s is not an AdmissionReview nor an AdmissionRequest", fpath)
+	}
+	return review, nil
+}
+
+func loadExpectations(fpath string) (map[string]*Expectation, error) {
+	expBytes, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expectations; %s", err.Error())
+	}
+	var list []*Expectation
+	if err := yaml.Unmarshal(expBytes, &list); err != nil {
+		return nil, fmt.Errorf("failed to load expectations; %s", err.Error())
+	}
+	expectations := map[string]*Expectation{}
+	for _, exp := range list {
+		if exp.Reason != "" && !isValidReason(exp.Reason) {
+			return nil, fmt.Errorf("unknown reason `%s` for %s", exp.Reason, exp.File)
+		}
+		expectations[filepath.Clean(exp.File)] = exp
+	}
+	return expectations, nil
+}
+
+// findExpectation looks up by a path relative to reviewPath, then by the file name
+func findExpectation(expectations map[string]*Expectation, reviewPath, fpath string) *Expectation {
+	if rel, err := filepath.Rel(reviewPath, fpath); err == nil && rel != "." {
+		if exp, ok := expectations[rel]; ok {
+			return exp
+		}
+	}
+	if exp, ok := expectations[filepath.Clean(fpath)]; ok {
+		return exp
+	}
+	if exp, ok := expectations[filepath.Base(fpath)]; ok {
+		return exp
+	}
+	return nil
+}
+
+func isValidReason(reason string) bool {
+	for _, rc := range common.ReasonCodeMap {
+		if rc.Code == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// findKeyPaths returns key paths in the same layout as the ones mounted in the server,
+// i.e. `<keyConfig>/<secret>/pgp/<file>`, `<keyConfig>/<secret>/sigstore/<file>` and `<keyConfig>/<secret>/x509/`
+func findKeyPaths(keyDir string) ([]string, error) {
+	keyPathList := []string{}
+	x509Dirs := map[string]bool{}
+	err := filepath.Walk(keyDir, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		dir := filepath.Dir(fpath)
+		switch filepath.Base(dir) {
+		case string(common.SignatureTypePGP), string(common.SignatureTypeSigStore):
+			keyPathList = append(keyPathList, fpath)
+		case string(common.SignatureTypeX509):
+			if !x509Dirs[dir] {
+				x509Dirs[dir] = true
+				keyPathList = append(keyPathList, dir+string(os.PathSeparator))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find keys in %s; %s", keyDir, err.Error())
+	}
+	return keyPathList, nil
+}
+
+func listFiles(fpath string, exts []string) ([]string, error) {
+	info, err := os.Stat(fpath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{fpath}, nil
+	}
+	files := []string{}
+	err = filepath.Walk(fpath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		for _, ext := range exts {
+			if strings.HasSuffix(p, ext) {
+				files = append(files, p)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// splitDocuments returns each document in a (multi-document) yaml file as json
+func splitDocuments(fpath string) ([][]byte, error) {
+	fileBytes, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	reader := k8syaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(fileBytes)))
+	docs := [][]byte{}
+	for {
+		docBytes, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s; %s", fpath, err.Error())
+		}
+		doc, err := yaml.YAMLToJSON(docBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s; %s", fpath, err.Error())
+		}
+		if len(bytes.TrimSpace(doc)) == 0 || string(doc) == "null" {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
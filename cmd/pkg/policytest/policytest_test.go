@@ -0,0 +1,308 @@
+package policytest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testDataDir = "testdata"
+
+func testOptions(expectPath string) *Options {
+	return &Options{
+		ConfigPath:   filepath.Join(testDataDir, "shieldconfig.yaml"),
+		ProfilePaths: []string{filepath.Join(testDataDir, "profiles")},
+		ExpectPath:   expectPath,
+	}
+}
+
+func TestRun(t *testing.T) {
+	reviewPath := filepath.Join(testDataDir, "requests")
+	testCases := []struct {
+		name            string
+		expectPath      string
+		expectedFailed  int
+		expectedAllowed map[string]bool
+		expectedReason  map[string]string
+	}{
+		{
+			name:           "expectations matched",
+			expectPath:     filepath.Join(testDataDir, "expect.yaml"),
+			expectedFailed: 0,
+		},
+		{
+			name:           "expectations not matched",
+			expectPath:     filepath.Join(testDataDir, "expect-wrong.yaml"),
+			expectedFailed: 2,
+		},
+		{
+			// only the captured review is asserted with its response
+			name:           "no expect file",
+			expectedFailed: 0,
+		},
+	}
+	expectedAllowed := map[string]bool{
+		"cm-captured.json": true,
+		"cm-ignored.json":  true,
+		"cm-unsigned.json": false,
+	}
+	expectedReason := map[string]string{
+		"cm-captured.json": "unprotected",
+		"cm-ignored.json":  "ignore-rule-matched",
+		"cm-unsigned.json": "no-signature",
+	}
+
+	for _, tc := range testCases {
+		results, err := Run(testOptions(tc.expectPath), reviewPath)
+		if err != nil {
+			t.Errorf("[%s] Test failed for Run(); %s", tc.name, err.Error())
+			continue
+		}
+		if len(results) != len(expectedAllowed) {
+			t.Errorf("[%s] expected %d results, actual %d", tc.name, len(expectedAllowed), len(results))
+		}
+		if failed := Failed(results); failed != tc.expectedFailed {
+			t.Errorf("[%s] expected failed: %d, actual: %d", tc.name, tc.expectedFailed, failed)
+		}
+		for _, r := range results {
+			base := filepath.Base(r.File)
+			if r.Allowed != expectedAllowed[base] {
+				t.Errorf("[%s] expected allowed of %s: %v, actual: %v (%s)", tc.name, base, expectedAllowed[base], r.Allowed, r.Message)
+			}
+			if r.Reason != expectedReason[base] {
+				t.Errorf("[%s] expected reason of %s: %s, actual: %s", tc.name, base, expectedReason[base], r.Reason)
+			}
+			if base == "cm-captured.json" && (r.Expected == nil || r.Expected.Allowed == nil || !*r.Expected.Allowed) {
+				t.Errorf("[%s] expectation of %s should be taken from the response", tc.name, base)
+			}
+		}
+	}
+
+	if _, err := Run(testOptions(filepath.Join(testDataDir, "expect-unknown-reason.yaml")), reviewPath); err == nil {
+		t.Errorf("Test failed for Run(); an unknown reason in the expect file should be an error")
+	}
+	if _, err := Run(testOptions(""), filepath.Join(testDataDir, "invalid")); err == nil {
+		t.Errorf("Test failed for Run(); a file which is not an AdmissionReview should be an error")
+	}
+}
+
+func TestRunOffline(t *testing.T) {
+	// any kube client built in Run() is pointed to this server by kubeconfig
+	var requested int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requested, 1)
+		http.Error(w, "unexpected request", http.StatusForbidden)
+	}))
+	defer server.Close()
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	kubeconfigData := fmt.Sprintf("apiVersion: v1\nkind: Config\nclusters:\n- name: test\n  cluster:\n    server: %s\ncontexts:\n- name: test\n  context:\n    cluster: test\n    user: test\ncurrent-context: test\nusers:\n- name: test\n  user:\n    token: test\n", server.URL)
+	if err := os.WriteFile(kubeconfig, []byte(kubeconfigData), 0644); err != nil {
+		t.Fatalf("failed to write a kubeconfig; %s", err.Error())
+	}
+	for key, value := range map[string]string{"KUBECONFIG": kubeconfig, "KUBERNETES_SERVICE_HOST": ""} {
+		orgValue, exists := os.LookupEnv(key)
+		os.Setenv(key, value)
+		defer func(key, orgValue string, exists bool) {
+			if exists {
+				os.Setenv(key, orgValue)
+			} else {
+				os.Unsetenv(key)
+			}
+		}(key, orgValue, exists)
+	}
+
+	opts := testOptions(filepath.Join(testDataDir, "expect.yaml"))
+	cfg, _, err := loadInputs(opts)
+	if err != nil {
+		t.Fatalf("Test failed for loadInputs(); %s", err.Error())
+	}
+	// the ShieldConfig enables helm plugin and does not configure dryRunFallback
+	if cfg.DryRunFallbackEnabled() || len(cfg.GetEnabledPlugins()) > 0 {
+		t.Errorf("Test failed for setOfflineConfig(); dry-run fallback: %v, plugins: %v", cfg.DryRunFallbackEnabled(), cfg.GetEnabledPlugins())
+	}
+	results, err := Run(opts, filepath.Join(testDataDir, "requests"))
+	if err != nil {
+		t.Fatalf("Test failed for Run(); %s", err.Error())
+	}
+	if failed := Failed(results); failed != 0 {
+		t.Errorf("Test failed for Run(); %d requests failed", failed)
+	}
+	if count := atomic.LoadInt32(&requested); count > 0 {
+		t.Errorf("Test failed for Run(); %d requests were sent to the cluster", count)
+	}
+}
+
+func TestLoadShieldConfig(t *testing.T) {
+	for _, fname := range []string{"shieldconfig.yaml", "shieldconfig-cr.yaml"} {
+		cfg, err := loadShieldConfig(filepath.Join(testDataDir, fname))
+		if err != nil {
+			t.Errorf("Test failed for loadShieldConfig(%s); %s", fname, err.Error())
+			continue
+		}
+		if cfg.Namespace != "integrity-shield-operator-system" {
+			t.Errorf("Test failed for loadShieldConfig(%s); unexpected namespace %s", fname, cfg.Namespace)
+		}
+	}
+
+	crWithoutConfig := filepath.Join(t.TempDir(), "cr.yaml")
+	if err := os.WriteFile(crWithoutConfig, []byte("apiVersion: apis.integrityshield.io/v1alpha1\nkind: ShieldConfig\nmetadata:\n  name: ishield-config\nspec: {}\n"), 0644); err != nil {
+		t.Fatalf("failed to write a ShieldConfig; %s", err.Error())
+	}
+	for _, fpath := range []string{"", crWithoutConfig, filepath.Join(testDataDir, "not-found.yaml")} {
+		if _, err := loadShieldConfig(fpath); err == nil {
+			t.Errorf("Test failed for loadShieldConfig(%s); expected an error", fpath)
+		}
+	}
+}
+
+func TestLoadAdmissionReview(t *testing.T) {
+	testCases := []struct {
+		name             string
+		fpath            string
+		expectedError    bool
+		expectedName     string
+		expectedResponse bool
+	}{
+		{
+			name:         "AdmissionReview",
+			fpath:        filepath.Join(testDataDir, "requests", "cm-unsigned.json"),
+			expectedName: "sample-cm",
+		},
+		{
+			name:             "AdmissionReview with response",
+			fpath:            filepath.Join(testDataDir, "requests", "cm-captured.json"),
+			expectedName:     "sample-cm",
+			expectedResponse: true,
+		},
+		{
+			name:         "bare AdmissionRequest",
+			fpath:        filepath.Join(testDataDir, "requests", "cm-ignored.json"),
+			expectedName: "ignored-cm",
+		},
+		{
+			name:          "not an AdmissionReview",
+			fpath:         filepath.Join(testDataDir, "invalid", "not-review.json"),
+			expectedError: true,
+		},
+		{
+			name:          "file not found",
+			fpath:         filepath.Join(testDataDir, "requests", "not-found.json"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		review, err := loadAdmissionReview(tc.fpath)
+		if (err != nil) != tc.expectedError {
+			t.Errorf("[%s] expected error: %v, actual: %v", tc.name, tc.expectedError, err)
+			continue
+		}
+		if tc.expectedError {
+			continue
+		}
+		if review.Request.Name != tc.expectedName {
+			t.Errorf("[%s] expected name: %s, actual: %s", tc.name, tc.expectedName, review.Request.Name)
+		}
+		if (review.Response != nil) != tc.expectedResponse {
+			t.Errorf("[%s] expected response: %v, actual: %v", tc.name, tc.expectedResponse, review.Response != nil)
+		}
+	}
+}
+
+func TestFindKeyPaths(t *testing.T) {
+	keyDir := t.TempDir()
+	files := []string{
+		filepath.Join("keyring-a", "keyring-secret", "pgp", "pubring.gpg"),
+		filepath.Join("keyring-b", "cosign-secret", "sigstore", "cosign.pub"),
+		filepath.Join("keyring-c", "x509-secret", "x509", "ca.crt"),
+		filepath.Join("keyring-c", "x509-secret", "x509", "sub.crt"),
+		filepath.Join("keyring-d", "README.md"),
+	}
+	for _, f := range files {
+		fpath := filepath.Join(keyDir, f)
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			t.Fatalf("failed to create a key directory; %s", err.Error())
+		}
+		if err := os.WriteFile(fpath, []byte("key"), 0644); err != nil {
+			t.Fatalf("failed to write a key; %s", err.Error())
+		}
+	}
+
+	keyPathList, err := findKeyPaths(keyDir)
+	if err != nil {
+		t.Fatalf("Test failed for findKeyPaths(); %s", err.Error())
+	}
+	expected := []string{
+		filepath.Join(keyDir, files[0]),
+		filepath.Join(keyDir, files[1]),
+		// x509 certs are passed as a directory
+		filepath.Join(keyDir, "keyring-c", "x509-secret", "x509") + string(os.PathSeparator),
+	}
+	if !reflect.DeepEqual(keyPathList, expected) {
+		t.Errorf("Test failed for findKeyPaths(); expected %v, actual %v", expected, keyPathList)
+	}
+
+	if _, err := findKeyPaths(filepath.Join(keyDir, "not-found")); err == nil {
+		t.Errorf("Test failed for findKeyPaths(); a missing directory should be an error")
+	}
+}
+
+func TestExpectations(t *testing.T) {
+	expectations, err := loadExpectations(filepath.Join(testDataDir, "expect.yaml"))
+	if err != nil {
+		t.Fatalf("Test failed for loadExpectations(); %s", err.Error())
+	}
+	if len(expectations) != 2 {
+		t.Errorf("Test failed for loadExpectations(); expected 2 expectations, actual %d", len(expectations))
+	}
+	if _, err := loadExpectations(filepath.Join(testDataDir, "expect-unknown-reason.yaml")); err == nil || !strings.Contains(err.Error(), "not-signed") {
+		t.Errorf("Test failed for loadExpectations(); an unknown reason should be an error, but got %v", err)
+	}
+
+	reviewPath := filepath.Join("reviews")
+	byRel := &Expectation{File: "ns1/cm.json"}
+	byFull := &Expectation{File: filepath.Join("reviews", "ns2", "cm.json")}
+	byBase := &Expectation{File: "secret.json"}
+	expectations = map[string]*Expectation{
+		filepath.Clean(byRel.File):  byRel,
+		filepath.Clean(byFull.File): byFull,
+		filepath.Clean(byBase.File): byBase,
+	}
+	lookupCases := map[string]*Expectation{
+		filepath.Join(reviewPath, "ns1", "cm.json"):     byRel,
+		filepath.Join(reviewPath, "ns2", "cm.json"):     byFull,
+		filepath.Join(reviewPath, "ns3", "secret.json"): byBase,
+		filepath.Join(reviewPath, "ns3", "cm.json"):     nil,
+	}
+	for fpath, expected := range lookupCases {
+		if actual := findExpectation(expectations, reviewPath, fpath); actual != expected {
+			t.Errorf("Test failed for findExpectation(%s); expected %v, actual %v", fpath, expected, actual)
+		}
+	}
+
+	allowed, denied := true, false
+	result := &Result{Allowed: false, Reason: "no-signature"}
+	assertCases := []struct {
+		name     string
+		expected *Expectation
+		passed   bool
+	}{
+		{name: "no expectation", expected: nil, passed: true},
+		{name: "decision and reason matched", expected: &Expectation{Allowed: &denied, Reason: "no-signature"}, passed: true},
+		{name: "reason only", expected: &Expectation{Reason: "no-signature"}, passed: true},
+		{name: "decision not matched", expected: &Expectation{Allowed: &allowed}},
+		{name: "reason not matched", expected: &Expectation{Reason: "invalid-signature"}},
+	}
+	for _, tc := range assertCases {
+		passed, failure := assert(result, tc.expected)
+		if passed != tc.passed || (passed == (failure != "")) {
+			t.Errorf("[%s] expected passed: %v, actual: %v (%s)", tc.name, tc.passed, passed, failure)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package policytest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	admv1 "k8s.io/api/admission/v1"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+)
+
+// max size of a single captured request in capture log
+const maxCapturedRequestSize = 16 * 1024 * 1024
+
+// ReplayResult is a decision for a captured request with a candidate configuration
+type ReplayResult struct {
+	File            string `json:"file"`
+	Line            int    `json:"line"`
+	Timestamp       string `json:"timestamp"`
+	Operation       string `json:"operation"`
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	UserName        string `json:"userName"`
+	CapturedAllowed bool   `json:"capturedAllowed"`
+	CapturedReason  string `json:"capturedReason"`
+	Allowed         bool   `json:"allowed"`
+	Reason          string `json:"reason"`
+	Message         string `json:"message"`
+	Flipped         bool   `json:"flipped"`
+	Skipped         bool   `json:"skipped,omitempty"`
+}
+
+type capturedEntry struct {
+	file      string
+	line      int
+	timestamp time.Time
+	captured  *shield.CapturedRequest
+}
+
+// Replay runs Handler.Check() for the captured requests with the candidate configuration in opts,
+// and returns the results in the captured order. Redacted requests (e.g. Secrets and ResourceSignatures) are skipped,
+// because their signatures cannot be verified after sanitization.
+func Replay(opts *Options, capturePaths []string) ([]*ReplayResult, error) {
+	cfg, localData, err := loadInputs(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*capturedEntry{}
+	for _, p := range capturePaths {
+		files, err := listCaptureFiles(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, fpath := range files {
+			fileEntries, err := loadCapturedRequests(fpath)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, fileEntries...)
+		}
+	}
+	// timestamps cannot be compared as strings, because trailing zeros of fractional seconds are omitted and zone offsets may differ
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].timestamp.Before(entries[j].timestamp)
+	})
+
+	requests := []*admv1.AdmissionRequest{}
+	for _, e := range entries {
+		requests = append(requests, e.captured.Review.Request)
+	}
+	localData.NSList = completeNamespaceList(localData.NSList, localData.RSPList, requests)
+
+	metaLogger := logger.NewLogger(cfg.LoggerConfig())
+	handler := shield.NewHandlerWithLocalData(cfg, metaLogger, localData)
+
+	results := []*ReplayResult{}
+	for _, e := range entries {
+		review := e.captured.Review
+		req := review.Request
+		result := &ReplayResult{
+			File:            e.file,
+			Line:            e.line,
+			Timestamp:       e.captured.Timestamp,
+			Operation:       string(req.Operation),
+			Kind:            req.Kind.Kind,
+			Namespace:       req.Namespace,
+			Name:            req.Name,
+			UserName:        req.UserInfo.Username,
+			CapturedAllowed: review.Response.Allowed,
+			CapturedReason:  e.captured.Reason,
+		}
+		if e.captured.Redacted {
+			result.Skipped = true
+			results = append(results, result)
+			continue
+		}
+		_, dr := handler.Check(req)
+		result.Allowed = dr.Type == common.DecisionAllow
+		result.Reason = common.ReasonCodeMap[dr.ReasonCode].Code
+		result.Message = dr.Message
+		result.Flipped = result.Allowed != result.CapturedAllowed
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Flipped returns the results whose decision is changed by the candidate configuration
+func Flipped(results []*ReplayResult) []*ReplayResult {
+	flipped := []*ReplayResult{}
+	for _, r := range results {
+		if r.Flipped {
+			flipped = append(flipped, r)
+		}
+	}
+	return flipped
+}
+
+// PrintReplayResults writes a line for each flipped request (or all requests) and a summary
+func PrintReplayResults(w io.Writer, results []*ReplayResult, all bool) {
+	allowToDeny := 0
+	denyToAllow := 0
+	skipped := 0
+	for _, r := range results {
+		if r.Skipped {
+			skipped++
+		} else if r.Flipped && r.CapturedAllowed {
+			allowToDeny++
+		} else if r.Flipped {
+			denyToAllow++
+		}
+		if !all && !r.Flipped {
+			continue
+		}
+		status := "SAME"
+		if r.Skipped {
+			status = "SKIP"
+		} else if r.Flipped {
+			status = "FLIP"
+		}
+		resource := r.Name
+		if r.Namespace != "" {
+			resource = r.Namespace + "/" + r.Name
+		}
+		fmt.Fprintf(w, "[%s] %s %s %s %s by %s: %s (%s) -> %s (%s) %s\n", status, r.Timestamp, r.Operation, r.Kind, resource, r.UserName,
+			decisionString(r.CapturedAllowed), r.CapturedReason, decisionString(r.Allowed), r.Reason, r.Message)
+	}
+	fmt.Fprintf(w, "%d requests replayed, %d flipped (allow -> deny: %d, deny -> allow: %d), %d skipped (redacted)\n",
+		len(results)-skipped, allowToDeny+denyToAllow, allowToDeny, denyToAllow, skipped)
+}
+
+func decisionString(allowed bool) string {
+	if allowed {
+		return "allow"
+	}
+	return "deny"
+}
+
+// listCaptureFiles returns a capture log file, or files in a directory sorted by name
+func listCaptureFiles(fpath string) ([]string, error) {
+	info, err := os.Stat(fpath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{fpath}, nil
+	}
+	entries, err := os.ReadDir(fpath)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, filepath.Join(fpath, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// loadCapturedRequests reads a capture log file, which has a CapturedRequest in each line
+func loadCapturedRequests(fpath string) ([]*capturedEntry, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []*capturedEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxCapturedRequestSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		lineBytes := scanner.Bytes()
+		if len(lineBytes) == 0 {
+			continue
+		}
+		var captured *shield.CapturedRequest
+		if err := json.Unmarshal(lineBytes, &captured); err != nil {
+			return nil, fmt.Errorf("failed to load a captured request at %s:%d; %s", fpath, line, err.Error())
+		}
+		if captured.Review == nil || captured.Review.Request == nil || captured.Review.Response == nil {
+			return nil, fmt.Errorf("%s:%d is not a captured request", fpath, line)
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, captured.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp at %s:%d; %s", fpath, line, err.Error())
+		}
+		entries = append(entries, &capturedEntry{file: fpath, line: line, timestamp: timestamp, captured: captured})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s; %s", fpath, err.Error())
+	}
+	return entries, nil
+}
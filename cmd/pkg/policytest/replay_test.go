@@ -0,0 +1,108 @@
+package policytest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	capturePaths := []string{filepath.Join(testDataDir, "capture")}
+	// captured order of the entries in capture.log; the file has them in different order,
+	// and zone offsets and fractional seconds make string order different from this
+	expectedOrder := []string{
+		"secure-ns/ignored-cm",
+		"secure-ns/sample-cm",
+		"other-ns/sample-cm",
+		"secure-ns/sample-secret",
+	}
+	testCases := []struct {
+		name            string
+		profilePaths    []string
+		expectedFlipped []string
+		expectedReason  map[string]string
+	}{
+		{
+			name:            "no profile",
+			profilePaths:    []string{},
+			expectedFlipped: []string{"other-ns/sample-cm"},
+			expectedReason: map[string]string{
+				"secure-ns/ignored-cm": "unprotected",
+				"secure-ns/sample-cm":  "unprotected",
+				"other-ns/sample-cm":   "unprotected",
+			},
+		},
+		{
+			// sample-cm in secure-ns was allowed, but it is denied with the candidate profile
+			name:            "candidate profile protects ConfigMap",
+			profilePaths:    []string{filepath.Join(testDataDir, "profiles")},
+			expectedFlipped: []string{"secure-ns/sample-cm", "other-ns/sample-cm"},
+			expectedReason: map[string]string{
+				"secure-ns/ignored-cm": "ignore-rule-matched",
+				"secure-ns/sample-cm":  "no-signature",
+				"other-ns/sample-cm":   "unprotected",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		opts := testOptions("")
+		opts.ProfilePaths = tc.profilePaths
+		results, err := Replay(opts, capturePaths)
+		if err != nil {
+			t.Errorf("[%s] Test failed for Replay(); %s", tc.name, err.Error())
+			continue
+		}
+		order := []string{}
+		for _, r := range results {
+			resource := r.Namespace + "/" + r.Name
+			order = append(order, resource)
+			if r.Skipped {
+				if r.Kind != "Secret" {
+					t.Errorf("[%s] only the redacted Secret should be skipped, but %s is skipped", tc.name, resource)
+				}
+				continue
+			}
+			if r.Reason != tc.expectedReason[resource] {
+				t.Errorf("[%s] expected reason of %s: %s, actual: %s", tc.name, resource, tc.expectedReason[resource], r.Reason)
+			}
+		}
+		if !reflect.DeepEqual(order, expectedOrder) {
+			t.Errorf("[%s] expected order %v, actual %v", tc.name, expectedOrder, order)
+		}
+		flipped := []string{}
+		for _, r := range Flipped(results) {
+			flipped = append(flipped, r.Namespace+"/"+r.Name)
+		}
+		if !reflect.DeepEqual(flipped, tc.expectedFlipped) {
+			t.Errorf("[%s] expected flipped %v, actual %v", tc.name, tc.expectedFlipped, flipped)
+		}
+	}
+}
+
+func TestLoadCapturedRequests(t *testing.T) {
+	entries, err := loadCapturedRequests(filepath.Join(testDataDir, "capture", "capture.log"))
+	if err != nil {
+		t.Fatalf("Test failed for loadCapturedRequests(); %s", err.Error())
+	}
+	if len(entries) != 4 || entries[3].line != 4 || entries[3].timestamp.UTC().Format("15:04:05") != "10:15:00" {
+		t.Errorf("Test failed for loadCapturedRequests(); unexpected entries")
+	}
+
+	dir := t.TempDir()
+	invalidCases := map[string]string{
+		"invalid-timestamp.log": `{"timestamp":"2021/05/10 10:00:00","review":{"request":{"uid":"a","kind":{"kind":"ConfigMap"}},"response":{"allowed":true}}}`,
+		"no-response.log":       `{"timestamp":"2021-05-10T10:00:00Z","review":{"request":{"uid":"a","kind":{"kind":"ConfigMap"}}}}`,
+		"not-json.log":          `not json`,
+	}
+	for fname, content := range invalidCases {
+		fpath := filepath.Join(dir, fname)
+		if err := os.WriteFile(fpath, []byte(content+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write %s; %s", fname, err.Error())
+		}
+		if _, err := loadCapturedRequests(fpath); err == nil {
+			t.Errorf("Test failed for loadCapturedRequests(%s); expected an error", fname)
+		}
+	}
+}
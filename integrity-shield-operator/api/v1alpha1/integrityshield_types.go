@@ -39,12 +39,13 @@ import (
 )
 
 const (
-	DefaultIntegrityShieldCRDName        = "integrityshields.apis.integrityshield.io"
-	DefaultShieldConfigCRDName           = "shieldconfigs.apis.integrityshield.io"
-	DefaultSignerConfigCRDName           = "signerconfigs.apis.integrityshield.io"
-	DefaultResourceSignatureCRDName      = "resourcesignatures.apis.integrityshield.io"
-	DefaultResourceSigningProfileCRDName = "resourcesigningprofiles.apis.integrityshield.io"
-	DefaultHelmReleaseMetadataCRDName    = "helmreleasemetadatas.apis.integrityshield.io"
+	DefaultIntegrityShieldCRDName               = "integrityshields.apis.integrityshield.io"
+	DefaultShieldConfigCRDName                  = "shieldconfigs.apis.integrityshield.io"
+	DefaultSignerConfigCRDName                  = "signerconfigs.apis.integrityshield.io"
+	DefaultResourceSignatureCRDName             = "resourcesignatures.apis.integrityshield.io"
+	DefaultResourceSigningProfileCRDName        = "resourcesigningprofiles.apis.integrityshield.io"
+	DefaultHelmReleaseMetadataCRDName           = "helmreleasemetadatas.apis.integrityshield.io"
+	DefaultClusterResourceSigningProfileCRDName = "clusterresourcesigningprofiles.apis.integrityshield.io"
 	// DefaultProtectedResourceIntegrityCRDName  = "protectedresourceintegrities.apis.integrityshield.io"
 	DefaultSignerConfigCRName                 = "signer-config"
 	DefaultIShieldAdminClusterRoleName        = "ishield-admin-clusterrole"
@@ -282,6 +283,10 @@ func (self *IntegrityShield) GetResourceSigningProfileCRDName() string {
 	return DefaultResourceSigningProfileCRDName
 }
 
+func (self *IntegrityShield) GetClusterResourceSigningProfileCRDName() string {
+	return DefaultClusterResourceSigningProfileCRDName
+}
+
 // func (self *IntegrityShield) GetProtectedResourceIntegrityCRDName() string {
 // 	return DefaultProtectedResourceIntegrityCRDName
 // }
@@ -424,21 +429,31 @@ func (self *IntegrityShield) GetIShieldResourceList(scheme *runtime.Scheme) ([]*
 	_rolebindingType := getTypeFromObj(&rbacv1.RoleBinding{}, scheme)
 	_pspType := getTypeFromObj(&policyv1.PodSecurityPolicy{}, scheme)
 
+	// ClusterResourceSigningProfile is cluster-scope and has no fixed name, so all resources of the kind are listed without name.
+	// It is in both lists because it is changed by IShield admin, and its status is updated by server.
+	clusterProfileRef := &common.ResourceRef{
+		Kind: common.ClusterProfileCustomResourceKind,
+	}
+
 	iShieldOperatorResourceList := []*common.ResourceRef{
 		{
 			Kind: _crdType.Kind,
 			Name: self.GetIntegrityShieldCRDName(),
 		},
-		{
-			Kind:      _deployType.Kind,
-			Name:      opDeployName,
-			Namespace: opPodNamespace,
-		},
 		{
 			Kind:      self.Kind,
 			Name:      self.Name,
 			Namespace: self.Namespace,
 		},
+		clusterProfileRef,
+	}
+	// a ref without name matches all resources of the kind, so the operator deployment is added only if its name is known
+	if opDeployName != "" {
+		iShieldOperatorResourceList = append(iShieldOperatorResourceList, &common.ResourceRef{
+			Kind:      _deployType.Kind,
+			Name:      opDeployName,
+			Namespace: opPodNamespace,
+		})
 	}
 
 	iShieldServerResourceList := []*common.ResourceRef{
@@ -458,6 +473,10 @@ func (self *IntegrityShield) GetIShieldResourceList(scheme *runtime.Scheme) ([]*
 			Kind: _crdType.Kind,
 			Name: self.GetResourceSigningProfileCRDName(),
 		},
+		{
+			Kind: _crdType.Kind,
+			Name: self.GetClusterResourceSigningProfileCRDName(),
+		},
 		{
 			Kind: _crdType.Kind,
 			Name: self.GetHelmReleaseMetadataCRDName(),
@@ -533,6 +552,7 @@ func (self *IntegrityShield) GetIShieldResourceList(scheme *runtime.Scheme) ([]*
 			Name:      self.GetIShieldServerDeploymentName(),
 			Namespace: self.Namespace,
 		},
+		clusterProfileRef,
 	}
 	if len(self.Spec.ResourceSigningProfiles) > 0 {
 		for _, prof := range self.Spec.ResourceSigningProfiles {
@@ -163,6 +163,12 @@ func (r *IntegrityShieldReconciler) createOrUpdateResourceSigningProfileCRD(
 	return r.createOrUpdateCRD(instance, expected)
 }
 
+func (r *IntegrityShieldReconciler) createOrUpdateClusterResourceSigningProfileCRD(
+	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	expected := res.BuildClusterResourceSigningProfileCRD(instance)
+	return r.createOrUpdateCRD(instance, expected)
+}
+
 // func (r *IntegrityShieldReconciler) createOrUpdateProtectedResourceIntegrityCRD(
 // 	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
 // 	expected := res.BuildProtectedResourceIntegrityCRD(instance)
@@ -198,6 +204,12 @@ func (r *IntegrityShieldReconciler) deleteResourceSigningProfileCRD(
 	return r.deleteCRD(instance, expected)
 }
 
+func (r *IntegrityShieldReconciler) deleteClusterResourceSigningProfileCRD(
+	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
+	expected := res.BuildClusterResourceSigningProfileCRD(instance)
+	return r.deleteCRD(instance, expected)
+}
+
 // func (r *IntegrityShieldReconciler) deleteProtectedResourceIntegrityCRD(
 // 	instance *apiv1alpha1.IntegrityShield) (ctrl.Result, error) {
 // 	expected := res.BuildProtectedResourceIntegrityCRD(instance)
@@ -115,6 +115,22 @@ func BuildResourceSigningProfileCRD(cr *apiv1alpha1.IntegrityShield) *extv1.Cust
 	return buildCRD(cr.GetResourceSigningProfileCRDName(), cr.Namespace, crdNames)
 }
 
+// clusterresourcesigningprofile crd
+func BuildClusterResourceSigningProfileCRD(cr *apiv1alpha1.IntegrityShield) *extv1.CustomResourceDefinition {
+
+	crdNames := extv1.CustomResourceDefinitionNames{
+		Kind:       "ClusterResourceSigningProfile",
+		Plural:     "clusterresourcesigningprofiles",
+		ListKind:   "ClusterResourceSigningProfileList",
+		Singular:   "clusterresourcesigningprofile",
+		ShortNames: []string{"crsp", "crsps"},
+	}
+	// cluster scope CRD does not have namespace
+	crd := buildCRD(cr.GetClusterResourceSigningProfileCRDName(), "", crdNames)
+	crd.Spec.Scope = extv1.ClusterScoped
+	return crd
+}
+
 // // protectedresourceintegrity crd
 // func BuildProtectedResourceIntegrityCRD(cr *apiv1alpha1.IntegrityShield) *extv1.CustomResourceDefinition {
 
@@ -157,6 +157,12 @@ func TestResourceSigningProfileCRD(t *testing.T) {
 	yamlPath := "./testdata/resourceSigningProfileCRD.yaml"
 	testObjAndYaml(t, obj, yamlPath)
 }
+func TestClusterResourceSigningProfileCRD(t *testing.T) {
+	instance := loadTestInstance(t)
+	obj := BuildClusterResourceSigningProfileCRD(instance)
+	yamlPath := "./testdata/clusterResourceSigningProfileCRD.yaml"
+	testObjAndYaml(t, obj, yamlPath)
+}
 func TestShieldConfigCR(t *testing.T) {
 	instance := loadTestInstance(t)
 	obj := BuildShieldConfigForIShield(instance, nil, commonProfilePathList)
@@ -62,7 +62,7 @@ func BuildClusterRoleForIShield(cr *apiv1alpha1.IntegrityShield) *rbacv1.Cluster
 					"extensions", "", "apis.integrityshield.io",
 				},
 				Resources: []string{
-					"secrets", "namespaces", "resourcesignatures", "shieldconfigs", "signerconfigs", "signerconfigs", "resourcesigningprofiles", "resourcesignatures", "protectedresourceintegrities",
+					"secrets", "namespaces", "resourcesignatures", "shieldconfigs", "signerconfigs", "signerconfigs", "resourcesigningprofiles", "clusterresourcesigningprofiles", "resourcesignatures", "protectedresourceintegrities",
 				},
 				Verbs: []string{
 					"get", "list", "watch", "patch", "update",
@@ -87,7 +87,7 @@ func BuildClusterRoleForIShield(cr *apiv1alpha1.IntegrityShield) *rbacv1.Cluster
 					"customresourcedefinitions",
 				},
 				Verbs: []string{
-					"get", "list", "create", "update",
+					"get", "list", "watch", "create", "update",
 				},
 			},
 			{
@@ -289,6 +289,7 @@ func BuildClusterRoleForIShieldAdmin(cr *apiv1alpha1.IntegrityShield) *rbacv1.Cl
 				},
 				Resources: []string{
 					"resourcesigningprofiles",
+					"clusterresourcesigningprofiles",
 					"resourcesignatures",
 				},
 				Verbs: []string{
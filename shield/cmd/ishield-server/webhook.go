@@ -25,7 +25,9 @@ import (
 
 	sconfloder "github.com/IBM/integrity-enforcer/shield/pkg/config/loader"
 	"github.com/IBM/integrity-enforcer/shield/pkg/shield"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
 	"github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/metrics"
 	log "github.com/sirupsen/logrus"
 	admv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -60,9 +62,10 @@ func init() {
 func (server *WebhookServer) handleAdmissionRequest(admissionReviewReq *admv1.AdmissionReview) *admv1.AdmissionResponse {
 
 	_ = config.InitShieldConfig()
+	shieldConfig := config.GetShieldConfig()
 
-	metaLogger := logger.NewLogger(config.ShieldConfig.LoggerConfig())
-	requestHandler := shield.NewHandler(config.ShieldConfig, metaLogger)
+	metaLogger := logger.NewLogger(shieldConfig.LoggerConfig())
+	requestHandler := shield.NewHandler(shieldConfig, metaLogger)
 	admissionRequest := admissionReviewReq.Request
 
 	// Run Request Handler
@@ -161,9 +164,26 @@ func (server *WebhookServer) Run() {
 		panic(fmt.Sprintf("unable to load certs: %v", err))
 	}
 
+	// rebuild RuleTable when namespaces are changed outside of the webhook
+	namespaceWatcher, err := shield.NewNamespaceWatcher(config.GetShieldConfig)
+	if err != nil {
+		logger.Error("Failed to start namespace watcher; ", err)
+	} else {
+		namespaceWatcher.Start(make(chan struct{}))
+	}
+
+	// write verification inventory into RSP status asynchronously
+	shield.NewInventoryWorker(config.GetShieldConfig).Start(make(chan struct{}))
+
+	// refresh OpenAPI schema for matching signed manifests when CRDs are changed
+	if err := kubeutil.DefaultOpenAPISchemaCache().Start(make(chan struct{})); err != nil {
+		logger.Error("Failed to start OpenAPI schema watcher; ", err)
+	}
+
 	server.mux.HandleFunc("/mutate", server.serveRequest)
 	server.mux.HandleFunc("/health/liveness", server.checkLiveness)
 	server.mux.HandleFunc("/health/readiness", server.checkReadiness)
+	server.mux.Handle("/metrics", metrics.Handler())
 
 	serverObj := &http.Server{
 		Addr:      ":8443",
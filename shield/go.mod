@@ -14,6 +14,7 @@ require (
 	github.com/onsi/gomega v1.10.2
 	github.com/openshift/api v3.9.0+incompatible
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.10.0
 	github.com/r3labs/diff v0.0.0-20191120142937-b4ed99a31f5a
 	github.com/sigstore/cosign v0.4.0
 	github.com/sigstore/sigstore v0.0.0-20210516171352-bee6a385d4af
@@ -0,0 +1,108 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1alpha1
+
+import (
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterResourceSigningProfileSpec defines the desired state of ClusterResourceSigningProfile
+type ClusterResourceSigningProfileSpec struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// `NamespaceSelector` selects namespaces in which namespaced resources are protected by this profile.
+	// Cluster-scope resources are protected regardless of this selector.
+	NamespaceSelector *common.NamespaceSelector  `json:"namespaceSelector,omitempty"`
+	ProtectRules      []*common.Rule             `json:"protectRules,omitempty"`
+	IgnoreRules       []*common.Rule             `json:"ignoreRules,omitempty"`
+	ForceCheckRules   []*common.Rule             `json:"forceCheckRules,omitempty"`
+	KustomizePatterns []*common.KustomizePattern `json:"kustomizePatterns,omitempty"`
+	ProtectAttrs      []*common.AttrsPattern     `json:"protectAttrs,omitempty"`
+	IgnoreAttrs       []*common.AttrsPattern     `json:"ignoreAttrs,omitempty"`
+	// `FailurePolicy` decides the response when the deadline of a check is exceeded
+	FailurePolicy rspapi.FailurePolicyType `json:"failurePolicy,omitempty"`
+	// `References` are names of profile fragments in iShield NS which are merged into this profile
+	References []string `json:"references,omitempty"`
+	// `MatchMode` decides how a signed manifest is compared with the requested object
+	MatchMode rspapi.MatchModeType `json:"matchMode,omitempty"`
+	// `FieldManager` is the field manager of server-side apply, which is used only for `ServerSideApply` match mode
+	FieldManager string `json:"fieldManager,omitempty"`
+	// `TrustedFieldManagers` are the field managers whose fields are not compared in `ServerSideApply` match mode (e.g. an autoscaler),
+	// when they are changed by the requests which match with the patterns of the manager
+	TrustedFieldManagers []*common.TrustedFieldManager `json:"trustedFieldManagers,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +resource:path=clusterresourcesigningprofile,scope=Cluster
+
+// ClusterResourceSigningProfile is the cluster-scope version of ResourceSigningProfile.
+// This is not visible to users who have only namespaced roles, and can be changed only by IShield admin.
+type ClusterResourceSigningProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterResourceSigningProfileSpec   `json:"spec,omitempty"`
+	Status rspapi.ResourceSigningProfileStatus `json:"status,omitempty"`
+}
+
+// ToResourceSigningProfile converts this profile to ResourceSigningProfile without namespace,
+// so that it can be evaluated in RuleTable together with RSPs.
+func (self ClusterResourceSigningProfile) ToResourceSigningProfile() rspapi.ResourceSigningProfile {
+	rsp := rspapi.ResourceSigningProfile{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersion.String(),
+			Kind:       common.ClusterProfileCustomResourceKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            self.GetName(),
+			UID:             self.GetUID(),
+			ResourceVersion: self.GetResourceVersion(),
+			Labels:          self.GetLabels(),
+			Annotations:     self.GetAnnotations(),
+		},
+		Spec: rspapi.ResourceSigningProfileSpec{
+			Disabled:                self.Spec.Disabled,
+			TargetNamespaceSelector: self.Spec.NamespaceSelector,
+			ProtectRules:            self.Spec.ProtectRules,
+			IgnoreRules:             self.Spec.IgnoreRules,
+			ForceCheckRules:         self.Spec.ForceCheckRules,
+			KustomizePatterns:       self.Spec.KustomizePatterns,
+			ProtectAttrs:            self.Spec.ProtectAttrs,
+			IgnoreAttrs:             self.Spec.IgnoreAttrs,
+			FailurePolicy:           self.Spec.FailurePolicy,
+			References:              self.Spec.References,
+			MatchMode:               self.Spec.MatchMode,
+			FieldManager:            self.Spec.FieldManager,
+			TrustedFieldManagers:    self.Spec.TrustedFieldManagers,
+		},
+		Status: self.Status,
+	}
+	return rsp
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterResourceSigningProfileList contains a list of ClusterResourceSigningProfile
+type ClusterResourceSigningProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterResourceSigningProfile `json:"items"`
+}
@@ -17,9 +17,12 @@
 package v1alpha1
 
 import (
+	"reflect"
+	"sort"
 	"time"
 
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -27,6 +30,43 @@ var layout = "2006-01-02 15:04:05"
 
 const maxHistoryLength = 3
 
+// RSP is stored in etcd, so only paths and change types of the first diff entries are recorded in status
+const maxStatusDiffLength = 10
+
+// RSP is stored in etcd, so the inventory cannot list resources without limit
+const maxInventoryLength = 1000
+
+type VerificationState string
+
+const (
+	// the resource was created/updated with a valid signature
+	VerificationStateVerified VerificationState = "Verified"
+	// the resource was created/updated by a verified service account or owner without signature
+	VerificationStateAllowedByServiceAccount VerificationState = "AllowedByServiceAccount"
+	// the resource has a signature which cannot be verified (e.g. invalid signature, no matched signer)
+	VerificationStateUnverified VerificationState = "Unverified"
+	// the resource has no signature
+	VerificationStateMissingSignature VerificationState = "MissingSignature"
+)
+
+type FailurePolicyType string
+
+const (
+	// the request is denied when a check timed out (default)
+	FailurePolicyFailClosed FailurePolicyType = "FailClosed"
+	// the request is allowed when a check timed out
+	FailurePolicyFailOpen FailurePolicyType = "FailOpen"
+)
+
+type MatchModeType string
+
+const (
+	// a signed manifest is compared with the requested object directly, with defaulting, with client-side apply and with patch (default)
+	MatchModeDefault MatchModeType = "Default"
+	// only the fields applied by `FieldManager` with server-side apply are compared with a signed manifest
+	MatchModeServerSideApply MatchModeType = "ServerSideApply"
+)
+
 // ResourceSigningProfileSpec defines the desired state of AppEnforcePolicy
 type ResourceSigningProfileSpec struct {
 	Disabled bool `json:"disabled,omitempty"`
@@ -39,6 +79,19 @@ type ResourceSigningProfileSpec struct {
 	ProtectAttrs            []*common.AttrsPattern     `json:"protectAttrs,omitempty"`
 	UnprotectAttrs          []*common.AttrsPattern     `json:"unprotectAttrs,omitempty"`
 	IgnoreAttrs             []*common.AttrsPattern     `json:"ignoreAttrs,omitempty"`
+	// `FailurePolicy` decides the response when the deadline of a check is exceeded
+	FailurePolicy FailurePolicyType `json:"failurePolicy,omitempty"`
+	// `Fragment` is used only for profile in iShield NS; a fragment is not evaluated by itself, but merged into profiles which refer to it
+	Fragment bool `json:"fragment,omitempty"`
+	// `References` are names of fragments in iShield NS which are merged into this profile
+	References []string `json:"references,omitempty"`
+	// `MatchMode` decides how a signed manifest is compared with the requested object
+	MatchMode MatchModeType `json:"matchMode,omitempty"`
+	// `FieldManager` is the field manager of server-side apply, which is used only for `ServerSideApply` match mode
+	FieldManager string `json:"fieldManager,omitempty"`
+	// `TrustedFieldManagers` are the field managers whose fields are not compared in `ServerSideApply` match mode (e.g. an autoscaler),
+	// when they are changed by the requests which match with the patterns of the manager
+	TrustedFieldManagers []*common.TrustedFieldManager `json:"trustedFieldManagers,omitempty"`
 }
 
 // ResourceSigningProfileStatus defines the observed state of AppEnforcePolicy
@@ -46,6 +99,15 @@ type ResourceSigningProfileStatus struct {
 	DenyCount int                     `json:"denyCount,omitempty"`
 	Summary   []*ProfileStatusSummary `json:"denySummary,omitempty"`
 	Latest    []*ProfileStatusDetail  `json:"latestDeniedEvents,omitempty"`
+
+	BreakGlassCount  int                    `json:"breakGlassCount,omitempty"`
+	LatestBreakGlass []*ProfileStatusDetail `json:"latestBreakGlassEvents,omitempty"`
+
+	// `ProtectedResources` is the verification inventory of resources which are protected by this profile
+	ProtectedResources []*ProtectedResourceStatus `json:"protectedResources,omitempty"`
+
+	// `TargetNamespaces` is the list of namespaces computed from the namespace / `TargetNamespaceSelector` of this profile
+	TargetNamespaces []string `json:"targetNamespaces,omitempty"`
 }
 
 type ProfileStatusSummary struct {
@@ -58,6 +120,24 @@ type ProfileStatusDetail struct {
 	Result  *common.Result  `json:"result,omitempty"`
 }
 
+// ProtectedResourceStatus is the verification state of a protected resource at its latest admission request
+type ProtectedResourceStatus struct {
+	ApiGroup     string            `json:"apiGroup,omitempty"`
+	ApiVersion   string            `json:"apiVersion,omitempty"`
+	Kind         string            `json:"kind"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Name         string            `json:"name"`
+	State        VerificationState `json:"state"`
+	Signer       string            `json:"signer,omitempty"`
+	Reason       string            `json:"reason,omitempty"`
+	LastChecked  string            `json:"lastChecked,omitempty"`
+	LastVerified string            `json:"lastVerified,omitempty"`
+}
+
+func (self *ProtectedResourceStatus) sameResource(another *ProtectedResourceStatus) bool {
+	return self.ApiGroup == another.ApiGroup && self.Kind == another.Kind && self.Namespace == another.Namespace && self.Name == another.Name
+}
+
 // +genclient
 // +genclient:noStatus
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -80,38 +160,48 @@ func (self ResourceSigningProfile) IsEmpty() bool {
 	return len(self.Spec.ProtectRules) == 0
 }
 
-func (self ResourceSigningProfile) Match(reqFields map[string]string, iShieldNS string) (bool, *common.Rule) {
+func (self ResourceSigningProfile) FailOpen() bool {
+	return self.Spec.FailurePolicy == FailurePolicyFailOpen
+}
+
+// Match returns true and the matched rule if the request is protected by this profile; reqObjects has the objects
+// in the request for field conditions, and the objects are parsed from reqFields if it is nil.
+func (self ResourceSigningProfile) Match(reqFields map[string]string, reqObjects *common.RequestObjects, iShieldNS string) (bool, *common.Rule) {
 
 	rspNS := self.ObjectMeta.Namespace
+	if reqObjects == nil {
+		reqObjects = common.NewRequestObjectsFromFields(reqFields)
+	}
 
 	scope := "Namespaced"
 	if reqScope, ok := reqFields["ResourceScope"]; ok && reqScope == "Cluster" {
 		scope = reqScope
 	}
 
+	// profiles converted from ClusterResourceSigningProfile have no namespace, and are not strict
 	strictMatch := false
-	if scope == "Cluster" && rspNS != iShieldNS {
+	if scope == "Cluster" && rspNS != iShieldNS && rspNS != "" {
 		strictMatch = true
 	}
 
 	for _, rule := range self.Spec.ForceCheckRules {
-		if strictMatch && rule.StrictMatchWithRequest(reqFields) {
+		if strictMatch && rule.StrictMatchWithRequest(reqFields, reqObjects) {
 			return true, rule
-		} else if !strictMatch && rule.MatchWithRequest(reqFields) {
+		} else if !strictMatch && rule.MatchWithRequest(reqFields, reqObjects) {
 			return true, rule
 		}
 	}
 	for _, rule := range self.Spec.IgnoreRules {
-		if strictMatch && rule.StrictMatchWithRequest(reqFields) {
+		if strictMatch && rule.StrictIgnoreMatchWithRequest(reqFields, reqObjects) {
 			return false, rule
-		} else if !strictMatch && rule.MatchWithRequest(reqFields) {
+		} else if !strictMatch && rule.IgnoreMatchWithRequest(reqFields, reqObjects) {
 			return false, rule
 		}
 	}
 	for _, rule := range self.Spec.ProtectRules {
-		if strictMatch && rule.StrictMatchWithRequest(reqFields) {
+		if strictMatch && rule.StrictMatchWithRequest(reqFields, reqObjects) {
 			return true, rule
-		} else if !strictMatch && rule.MatchWithRequest(reqFields) {
+		} else if !strictMatch && rule.MatchWithRequest(reqFields, reqObjects) {
 			return true, rule
 		}
 	}
@@ -126,6 +216,20 @@ func (self ResourceSigningProfile) Merge(another ResourceSigningProfile) Resourc
 	newProfile.Spec.ForceCheckRules = append(newProfile.Spec.ForceCheckRules, another.Spec.ForceCheckRules...)
 	newProfile.Spec.ProtectAttrs = append(newProfile.Spec.ProtectAttrs, another.Spec.ProtectAttrs...)
 	newProfile.Spec.IgnoreAttrs = append(newProfile.Spec.IgnoreAttrs, another.Spec.IgnoreAttrs...)
+	newProfile.Spec.UnprotectAttrs = append(newProfile.Spec.UnprotectAttrs, another.Spec.UnprotectAttrs...)
+	newProfile.Spec.KustomizePatterns = append(newProfile.Spec.KustomizePatterns, another.Spec.KustomizePatterns...)
+	// match mode is inherited only if it is not specified in this profile
+	if newProfile.Spec.MatchMode == "" {
+		newProfile.Spec.MatchMode = another.Spec.MatchMode
+		newProfile.Spec.FieldManager = another.Spec.FieldManager
+	}
+	// same for trusted field managers and failure policy
+	if len(newProfile.Spec.TrustedFieldManagers) == 0 && len(another.Spec.TrustedFieldManagers) > 0 {
+		newProfile.Spec.TrustedFieldManagers = append([]*common.TrustedFieldManager{}, another.Spec.TrustedFieldManagers...)
+	}
+	if newProfile.Spec.FailurePolicy == "" {
+		newProfile.Spec.FailurePolicy = another.Spec.FailurePolicy
+	}
 	return newProfile
 }
 
@@ -165,7 +269,7 @@ func (self ResourceSigningProfile) IgnoreAttrs(reqFields map[string]string) []*c
 	return patterns
 }
 
-func (self *ResourceSigningProfile) UpdateStatus(request *common.Request, errMsg string) *ResourceSigningProfile {
+func (self *ResourceSigningProfile) UpdateStatus(request *common.Request, errMsg string, diff []*mapnode.FieldDiff) *ResourceSigningProfile {
 
 	// Increment DenyCount
 	self.Status.DenyCount = self.Status.DenyCount + 1
@@ -191,9 +295,12 @@ func (self *ResourceSigningProfile) UpdateStatus(request *common.Request, errMsg
 	}
 
 	// Update Latest events
+	statusDiff, omitted := makeStatusDiff(diff)
 	result := &common.Result{
-		Message:   errMsg,
-		Timestamp: time.Now().UTC().Format(layout),
+		Message:          errMsg,
+		Timestamp:        time.Now().UTC().Format(layout),
+		Diff:             statusDiff,
+		OmittedDiffCount: omitted,
 	}
 	newLatestEvents := []*ProfileStatusDetail{}
 	newSingleEvent := &ProfileStatusDetail{Request: request, Result: result}
@@ -206,6 +313,118 @@ func (self *ResourceSigningProfile) UpdateStatus(request *common.Request, errMsg
 	return self
 }
 
+// makeStatusDiff returns the diff entries without values up to maxStatusDiffLength, and the number of omitted entries.
+// Values can be large (e.g. ConfigMap data), so they are shown only in the context log.
+func makeStatusDiff(diff []*mapnode.FieldDiff) ([]*mapnode.FieldDiff, int) {
+	statusDiff := []*mapnode.FieldDiff{}
+	omitted := 0
+	for _, d := range diff {
+		if d == nil {
+			continue
+		}
+		if len(statusDiff) >= maxStatusDiffLength {
+			omitted++
+			continue
+		}
+		statusDiff = append(statusDiff, &mapnode.FieldDiff{Path: d.Path, Change: d.Change})
+	}
+	return statusDiff, omitted
+}
+
+// UpdateBreakGlassStatus records a request which would be denied by this profile but allowed by BreakGlassRequest
+func (self *ResourceSigningProfile) UpdateBreakGlassStatus(request *common.Request, msg string) *ResourceSigningProfile {
+
+	// Increment BreakGlassCount
+	self.Status.BreakGlassCount = self.Status.BreakGlassCount + 1
+
+	// Update Latest break glass events
+	result := &common.Result{
+		Message:   msg,
+		Timestamp: time.Now().UTC().Format(layout),
+	}
+	newLatestEvents := []*ProfileStatusDetail{}
+	newSingleEvent := &ProfileStatusDetail{Request: request, Result: result}
+	newLatestEvents = append(newLatestEvents, newSingleEvent)
+	newLatestEvents = append(newLatestEvents, self.Status.LatestBreakGlass...)
+	if len(newLatestEvents) > maxHistoryLength {
+		newLatestEvents = newLatestEvents[:maxHistoryLength]
+	}
+	self.Status.LatestBreakGlass = newLatestEvents
+	return self
+}
+
+// UpdateTargetNamespaces sets the computed target namespaces, and returns nil if nothing is changed.
+func (self *ResourceSigningProfile) UpdateTargetNamespaces(namespaces []string) *ResourceSigningProfile {
+	sorted := append([]string{}, namespaces...)
+	sort.Strings(sorted)
+	if reflect.DeepEqual(self.Status.TargetNamespaces, sorted) || (len(self.Status.TargetNamespaces) == 0 && len(sorted) == 0) {
+		return nil
+	}
+	self.Status.TargetNamespaces = sorted
+	return self
+}
+
+// UpdateInventory adds or updates the verification state of a protected resource.
+// `LastVerified` is kept from the current entry unless the resource is verified again.
+func (self *ResourceSigningProfile) UpdateInventory(resource *ProtectedResourceStatus) *ResourceSigningProfile {
+	now := time.Now().UTC().Format(layout)
+	newResource := *resource
+	newResource.LastChecked = now
+	if newResource.State == VerificationStateVerified {
+		newResource.LastVerified = now
+	}
+
+	inventory := []*ProtectedResourceStatus{}
+	for _, r := range self.Status.ProtectedResources {
+		if r.sameResource(&newResource) {
+			if newResource.LastVerified == "" {
+				newResource.LastVerified = r.LastVerified
+			}
+			continue
+		}
+		inventory = append(inventory, r)
+	}
+	inventory = append(inventory, &newResource)
+	if len(inventory) > maxInventoryLength {
+		// drop the resources which are not checked for the longest time
+		sort.SliceStable(inventory, func(i, j int) bool {
+			return inventory[i].LastChecked > inventory[j].LastChecked
+		})
+		inventory = inventory[:maxInventoryLength]
+	}
+	self.Status.ProtectedResources = sortInventory(inventory)
+	return self
+}
+
+// RemoveFromInventory removes a deleted resource from the verification inventory
+func (self *ResourceSigningProfile) RemoveFromInventory(resource *ProtectedResourceStatus) *ResourceSigningProfile {
+	inventory := []*ProtectedResourceStatus{}
+	for _, r := range self.Status.ProtectedResources {
+		if !r.sameResource(resource) {
+			inventory = append(inventory, r)
+		}
+	}
+	self.Status.ProtectedResources = inventory
+	return self
+}
+
+func sortInventory(inventory []*ProtectedResourceStatus) []*ProtectedResourceStatus {
+	sort.SliceStable(inventory, func(i, j int) bool {
+		ri, rj := inventory[i], inventory[j]
+		if ri.Namespace != rj.Namespace {
+			return ri.Namespace < rj.Namespace
+		}
+		if ri.ApiGroup != rj.ApiGroup {
+			return ri.ApiGroup < rj.ApiGroup
+		}
+		if ri.Kind != rj.Kind {
+			return ri.Kind < rj.Kind
+		}
+		return ri.Name < rj.Name
+	})
+	return inventory
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ResourceSigningProfileList contains a list of ResourceSigningProfile
@@ -0,0 +1,54 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
+)
+
+func TestUpdateStatusWithDiff(t *testing.T) {
+	diff := []*mapnode.FieldDiff{}
+	for i := 0; i < maxStatusDiffLength+5; i++ {
+		diff = append(diff, &mapnode.FieldDiff{
+			Path:   fmt.Sprintf("data.key%d", i),
+			Change: mapnode.ChangeTypeChanged,
+			Before: strings.Repeat("a", 1024),
+			After:  strings.Repeat("b", 1024),
+		})
+	}
+	rsp := &ResourceSigningProfile{}
+	req := &common.Request{Kind: "ConfigMap", Name: "sample-cm", Namespace: "secure-ns", Operation: "UPDATE"}
+	rsp.UpdateStatus(req, "denied", diff)
+
+	result := rsp.Status.Latest[0].Result
+	if len(result.Diff) != maxStatusDiffLength || result.OmittedDiffCount != 5 {
+		t.Errorf("Test failed for UpdateStatus(); expected %d diff entries and 5 omitted, actual: %d and %d", maxStatusDiffLength, len(result.Diff), result.OmittedDiffCount)
+	}
+	for i, d := range result.Diff {
+		if d.Path != diff[i].Path || d.Change != diff[i].Change || d.Before != nil || d.After != nil {
+			t.Errorf("Test failed for UpdateStatus(); expected only path and change in status, actual: %#v", d)
+		}
+	}
+	if diff[0].Before == nil {
+		t.Errorf("Test failed for UpdateStatus(); the original diff must not be changed")
+	}
+}
@@ -66,6 +66,22 @@ func (in *ProfileStatusSummary) DeepCopy() *ProfileStatusSummary {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProtectedResourceStatus) DeepCopyInto(out *ProtectedResourceStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProtectedResourceStatus.
+func (in *ProtectedResourceStatus) DeepCopy() *ProtectedResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProtectedResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceSigningProfile) DeepCopyInto(out *ResourceSigningProfile) {
 	*out = *in
@@ -204,6 +220,21 @@ func (in *ResourceSigningProfileSpec) DeepCopyInto(out *ResourceSigningProfileSp
 			}
 		}
 	}
+	if in.References != nil {
+		in, out := &in.References, &out.References
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TrustedFieldManagers != nil {
+		in, out := &in.TrustedFieldManagers, &out.TrustedFieldManagers
+		*out = make([]*common.TrustedFieldManager, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = (*in).DeepCopy()
+			}
+		}
+	}
 	return
 }
 
@@ -242,6 +273,33 @@ func (in *ResourceSigningProfileStatus) DeepCopyInto(out *ResourceSigningProfile
 			}
 		}
 	}
+	if in.LatestBreakGlass != nil {
+		in, out := &in.LatestBreakGlass, &out.LatestBreakGlass
+		*out = make([]*ProfileStatusDetail, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ProfileStatusDetail)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.ProtectedResources != nil {
+		in, out := &in.ProtectedResources, &out.ProtectedResources
+		*out = make([]*ProtectedResourceStatus, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ProtectedResourceStatus)
+				**out = **in
+			}
+		}
+	}
+	if in.TargetNamespaces != nil {
+		in, out := &in.TargetNamespaces, &out.TargetNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
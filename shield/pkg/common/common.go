@@ -17,11 +17,13 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"strconv"
 
 	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
 	"github.com/jinzhu/copier"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -43,6 +45,9 @@ const (
 	ProfileCustomResourceAPIVersion = "apis.integrityshield.io/v1alpha1"
 	ProfileCustomResourceKind       = "ResourceSigningProfile"
 
+	ClusterProfileCustomResourceAPIVersion = "apis.integrityshield.io/v1alpha1"
+	ClusterProfileCustomResourceKind       = "ClusterResourceSigningProfile"
+
 	HelmReleaseMetadataCustomResourceAPIVersion = "apis.integrityshield.io/v1alpha1"
 	HelmReleaseMetadataCustomResourceKind       = "HelmReleasemetadata"
 )
@@ -67,6 +72,11 @@ const (
 
 	LabelValueVerified   = "verified"
 	LabelValueUnverified = "unverified"
+
+	BreakGlassRequestLabelKey     = "integrityshield.io/breakGlassRequest"
+	BreakGlassRequestDataKey      = "request"
+	LabelValueBreakGlassRequested = "requested"
+	LabelValueBreakGlassRevoked   = "revoked"
 )
 
 const (
@@ -77,6 +87,7 @@ const (
 	EventTypeValueVerifyResult    = "verify-result"
 	EventResultValueAllow         = "allow"
 	EventResultValueDeny          = "deny"
+	EventResultValueBreakGlass    = "breakglass"
 )
 
 type SignatureType string
@@ -247,6 +258,11 @@ func (self *ResourceLabel) isDefined(key string) bool {
 	return ok
 }
 
+// jsonString returns labels as JSON string, "{}" if no label
+func (self *ResourceLabel) jsonString() string {
+	return mapToJsonString(self.values)
+}
+
 /**********************************************
 
                 ResourceAnnotation
@@ -279,6 +295,19 @@ func (self *ResourceAnnotation) SignatureAnnotations() *SignatureAnnotation {
 	}
 }
 
+// jsonString returns annotations as JSON string, "{}" if no annotation
+func (self *ResourceAnnotation) jsonString() string {
+	return mapToJsonString(self.values)
+}
+
+func mapToJsonString(values map[string]string) string {
+	if values == nil {
+		values = map[string]string{}
+	}
+	valuesBytes, _ := json.Marshal(values)
+	return string(valuesBytes)
+}
+
 func (self *ResourceAnnotation) getString(key string) string {
 	if s, ok := self.values[key]; ok {
 		return s
@@ -317,6 +346,8 @@ type SignatureEvalResult struct {
 	MatchedSignerConfig  string      `json:"matchedSignerConfig"`
 	ResourceSignatureUID string      `json:"resourceSignatureUID"`
 	Error                *CheckError `json:"error"`
+	// `Diff` is the differences between the signed manifest and the requested object, if the signature is denied for them
+	Diff []*mapnode.FieldDiff `json:"diff,omitempty"`
 }
 
 func (self *SignatureEvalResult) GetSignerName() string {
@@ -368,11 +399,12 @@ func (self *SignerInfo) GetNameWithFingerprint() string {
 }
 
 type MutationEvalResult struct {
-	IsMutated bool        `json:"isMutated"`
-	Diff      string      `json:"diff"`
-	Filtered  string      `json:"filtered"`
-	Checked   bool        `json:"checked"`
-	Error     *CheckError `json:"error"`
+	IsMutated  bool        `json:"isMutated"`
+	Diff       string      `json:"diff"`
+	Filtered   string      `json:"filtered"`
+	Violations []string    `json:"violations,omitempty"`
+	Checked    bool        `json:"checked"`
+	Error      *CheckError `json:"error"`
 }
 
 type ReasonCode struct {
@@ -408,6 +440,8 @@ const (
 	REASON_INVALID_SIG_IMAGE
 	REASON_UNEXPECTED
 	REASON_ERROR
+	REASON_TIMEOUT
+	REASON_VALUE_CONSTRAINT_VIOLATED
 )
 
 var ReasonCodeMap = map[int]ReasonCode{
@@ -515,4 +549,12 @@ var ReasonCodeMap = map[int]ReasonCode{
 		Message: "error",
 		Code:    "error",
 	},
+	REASON_TIMEOUT: {
+		Message: "Signature verification is required for this request, but some checks were not completed before the deadline",
+		Code:    "timeout",
+	},
+	REASON_VALUE_CONSTRAINT_VIOLATED: {
+		Message: "The change is allowed only for specific values of the attributes, but the new value is not allowed",
+		Code:    "value-constraint-violated",
+	},
 }
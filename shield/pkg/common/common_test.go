@@ -18,6 +18,7 @@ package common
 
 import (
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -70,3 +71,37 @@ func TestResourceRef(t *testing.T) {
 		return
 	}
 }
+
+func TestBreakGlassRequest(t *testing.T) {
+	now := time.Now().UTC()
+	req := &BreakGlassRequest{
+		Namespaces: []string{"secure-ns"},
+		Kinds:      []string{"ConfigMap", "Deploy*"},
+		Expiry:     now.Add(time.Hour).Format(time.RFC3339),
+	}
+	if req.IsExpired(now) {
+		t.Error("TestBreakGlassRequest() Failed")
+		return
+	}
+	if !req.IsExpired(now.Add(2 * time.Hour)) {
+		t.Error("TestBreakGlassRequest() Failed")
+		return
+	}
+	if ok := req.Match("Namespaced", "secure-ns", "Deployment"); !ok {
+		t.Error("TestBreakGlassRequest() Failed")
+		return
+	}
+	if ok := req.Match("Namespaced", "secure-ns", "Secret"); ok {
+		t.Error("TestBreakGlassRequest() Failed")
+		return
+	}
+	if ok := req.Match("Cluster", "", "ConfigMap"); ok {
+		t.Error("TestBreakGlassRequest() Failed")
+		return
+	}
+	invalid := &BreakGlassRequest{Namespaces: []string{"secure-ns"}, Expiry: "tomorrow"}
+	if !invalid.IsExpired(now) {
+		t.Error("TestBreakGlassRequest() Failed")
+		return
+	}
+}
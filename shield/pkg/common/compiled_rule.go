@@ -0,0 +1,198 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package common
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+/**********************************************
+
+				CompiledRule
+
+***********************************************/
+
+// CompiledRule is a Rule whose patterns and label/annotation selectors are parsed in advance, for a rule evaluated for many requests
+// (e.g. rules in the index of RuleTable). The results are same as the ones of the Rule.
+type CompiledRule struct {
+	Rule    *Rule
+	match   []*compiledRequestPattern
+	exclude []*compiledRequestPattern
+}
+
+type compiledRequestPattern struct {
+	// nil if RequestPatternWithNamespace does not have `namespace`
+	namespace func(string) bool
+	// nil if RequestPatternWithNamespace does not have other than `namespace`
+	request *compiledRequestFields
+}
+
+type compiledRequestFields struct {
+	fields []compiledField
+	// `name` for strict match, nil if not specified
+	name               *RulePattern
+	labelSelector      labels.Selector
+	annotationSelector labels.Selector
+	patternCount       int
+}
+
+type compiledField struct {
+	fieldName string
+	match     func(string) bool
+}
+
+func NewCompiledRule(rule *Rule) *CompiledRule {
+	compiled := &CompiledRule{Rule: rule}
+	for _, m := range rule.Match {
+		if m != nil {
+			compiled.match = append(compiled.match, compileRequestPattern(m))
+		}
+	}
+	for _, ex := range rule.Exclude {
+		if ex != nil {
+			compiled.exclude = append(compiled.exclude, compileRequestPattern(ex))
+		}
+	}
+	return compiled
+}
+
+func compileRequestPattern(pattern *RequestPatternWithNamespace) *compiledRequestPattern {
+	compiled := &compiledRequestPattern{}
+	if pattern.Namespace != nil {
+		compiled.namespace = newPatternMatcher(string(*pattern.Namespace))
+	}
+	p := pattern.RequestPattern
+	if p == nil {
+		return compiled
+	}
+	req := &compiledRequestFields{name: p.Name}
+	// same fields as RequestPattern.match()
+	fieldPatterns := [...]struct {
+		fieldName string
+		pattern   *RulePattern
+	}{
+		{"Scope", p.Scope},
+		{"ApiGroup", p.ApiGroup},
+		{"ApiVersion", p.ApiVersion},
+		{"Kind", p.Kind},
+		{"Name", p.Name},
+		{"Operation", p.Operation},
+		{"UserName", p.UserName},
+		{"UserGroup", p.UserGroup},
+	}
+	for _, fp := range fieldPatterns {
+		if fp.pattern == nil {
+			continue
+		}
+		req.fields = append(req.fields, compiledField{fieldName: fp.fieldName, match: newPatternMatcher(string(*fp.pattern))})
+		req.patternCount++
+	}
+	if p.LabelSelector != nil {
+		req.labelSelector = parseObjectSelector(p.LabelSelector)
+		req.patternCount++
+	}
+	if p.AnnotationSelector != nil {
+		req.annotationSelector = parseObjectSelector(p.AnnotationSelector)
+		req.patternCount++
+	}
+	compiled.request = req
+	return compiled
+}
+
+// match is same as RequestPatternWithNamespace.Match() and IgnoreMatch(), or StrictMatch() and StrictIgnoreMatch() if exactMatchForName is true
+func (self *compiledRequestPattern) match(reqFields map[string]string, exactMatchForName bool, mode selectorMatchMode) bool {
+	if exactMatchForName {
+		// strict match is done by RequestPattern, so namespace is not evaluated
+		return self.request != nil && self.request.match(reqFields, true, mode)
+	}
+	if self.namespace == nil && self.request == nil {
+		return false
+	}
+	if self.namespace != nil && !self.namespace(reqFields["Namespace"]) {
+		return false
+	}
+	return self.request == nil || self.request.match(reqFields, false, mode)
+}
+
+// match is same as RequestPattern.match()
+func (self *compiledRequestFields) match(reqFields map[string]string, exactMatchForName bool, mode selectorMatchMode) bool {
+	if exactMatchForName && reqFields["ResourceScope"] == "Cluster" && self.name == nil {
+		return false
+	}
+	for _, f := range self.fields {
+		reqValue := reqFields[f.fieldName]
+		if f.fieldName == "Name" && exactMatchForName {
+			if !self.name.exactMatch(reqValue) {
+				return false
+			}
+		} else if !f.match(reqValue) {
+			return false
+		}
+	}
+	if self.labelSelector != nil && !matchObjectSelector(self.labelSelector, reqFields, ReqFieldObjLabels, ReqFieldOldObjLabels, mode) {
+		return false
+	}
+	if self.annotationSelector != nil && !matchObjectSelector(self.annotationSelector, reqFields, ReqFieldObjAnnotations, ReqFieldOldObjAnnotations, mode) {
+		return false
+	}
+	return self.patternCount > 0
+}
+
+// MatchWithRequest is same as Rule.MatchWithRequest()
+func (self *CompiledRule) MatchWithRequest(reqFields map[string]string, reqObjects *RequestObjects) bool {
+	return self.matchWithRequest(reqFields, reqObjects, false, false)
+}
+
+// StrictMatchWithRequest is same as Rule.StrictMatchWithRequest()
+func (self *CompiledRule) StrictMatchWithRequest(reqFields map[string]string, reqObjects *RequestObjects) bool {
+	return self.matchWithRequest(reqFields, reqObjects, true, false)
+}
+
+// IgnoreMatchWithRequest is same as Rule.IgnoreMatchWithRequest()
+func (self *CompiledRule) IgnoreMatchWithRequest(reqFields map[string]string, reqObjects *RequestObjects) bool {
+	return self.matchWithRequest(reqFields, reqObjects, false, true)
+}
+
+// StrictIgnoreMatchWithRequest is same as Rule.StrictIgnoreMatchWithRequest()
+func (self *CompiledRule) StrictIgnoreMatchWithRequest(reqFields map[string]string, reqObjects *RequestObjects) bool {
+	return self.matchWithRequest(reqFields, reqObjects, true, true)
+}
+
+// matchWithRequest evaluates `match`, `exclude` and `conditions`. For ignore rules, label/annotation selectors in `match`
+// and conditions must match with all objects in the request, and the ones in `exclude` with any of them (vice versa for other rules).
+func (self *CompiledRule) matchWithRequest(reqFields map[string]string, reqObjects *RequestObjects, exactMatchForName, ignore bool) bool {
+	matchMode, excludeMode := selectorMatchAnyObject, selectorMatchAllObjects
+	if ignore {
+		matchMode, excludeMode = selectorMatchAllObjects, selectorMatchAnyObject
+	}
+	matched := false
+	for _, m := range self.match {
+		if m.match(reqFields, exactMatchForName, matchMode) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	for _, ex := range self.exclude {
+		if ex.match(reqFields, exactMatchForName, excludeMode) {
+			return false
+		}
+	}
+	return matchFieldConditions(self.Rule.Conditions, reqFields, reqObjects, matchMode)
+}
@@ -0,0 +1,92 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package common
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompiledRule(t *testing.T) {
+	rules := []string{
+		`{"match":[{"kind":"ConfigMap"}]}`,
+		`{"match":[{"kind":"ConfigMap,Secret","name":"app-*"}],"exclude":[{"name":"app-ignored"}]}`,
+		`{"match":[{"namespace":"secure-*"}]}`,
+		`{"match":[{"namespace":"secure-ns","kind":"re:^Cluster"}]}`,
+		`{"match":[{"apiGroup":"-","kind":"ClusterRole","name":"sample-*"}]}`,
+		`{"match":[{"kind":"ClusterRole","name":"sample-role"}]}`,
+		`{"match":[{"kind":"ClusterRole"}]}`,
+		`{"match":[{"labelSelector":{"matchLabels":{"app":"a"}}}],"exclude":[{"annotationSelector":{"matchLabels":{"generated":"true"}}}]}`,
+		`{"match":[{"labelSelector":{"matchExpressions":[{"key":"app","operator":"Unknown"}]}}]}`,
+		`{"match":[{"kind":"ConfigMap"}],"conditions":[{"path":"data.key1","operator":"Equals","value":"val1"}]}`,
+		`{"match":[{"operation":"CREATE","usergroup":"system:masters"}]}`,
+		`{"match":[{}]}`,
+	}
+	requests := []map[string]string{
+		{
+			"ResourceScope": "Namespaced", "Namespace": "secure-ns", "ApiGroup": "", "Kind": "ConfigMap", "Name": "app-cm", "Operation": "CREATE",
+			"UserGroup": "system:masters", ReqFieldObjLabels: `{"app":"a"}`, ReqFieldOldObjLabels: "", ReqFieldObjAnnotations: `{"generated":"true"}`,
+			ReqFieldObject: `{"kind":"ConfigMap","data":{"key1":"val1"}}`,
+		},
+		{
+			"ResourceScope": "Namespaced", "Namespace": "other-ns", "ApiGroup": "", "Kind": "Secret", "Name": "app-ignored", "Operation": "UPDATE",
+			ReqFieldObjLabels: `{"app":"b"}`, ReqFieldOldObjLabels: `{"app":"a"}`,
+			ReqFieldObject: `{"kind":"Secret","data":{"key1":"dmFsMQ=="}}`, ReqFieldOldObject: `{"kind":"Secret"}`,
+		},
+		{
+			"ResourceScope": "Cluster", "Namespace": "", "ApiGroup": "rbac.authorization.k8s.io", "Kind": "ClusterRole", "Name": "sample-role", "Operation": "CREATE",
+			ReqFieldObjLabels: `{"app":"a"}`,
+		},
+		{
+			"ResourceScope": "Cluster", "Namespace": "", "ApiGroup": "", "Kind": "ClusterRole", "Name": "sample-*", "Operation": "DELETE",
+		},
+	}
+	type matchFunc func(map[string]string, *RequestObjects) bool
+
+	matchedCount := 0
+	for _, ruleStr := range rules {
+		var rule *Rule
+		if err := json.Unmarshal([]byte(ruleStr), &rule); err != nil {
+			t.Fatalf("failed to load a rule %s; %s", ruleStr, err.Error())
+		}
+		compiled := NewCompiledRule(rule)
+		modes := []struct {
+			name     string
+			expected matchFunc
+			actual   matchFunc
+		}{
+			{"MatchWithRequest", rule.MatchWithRequest, compiled.MatchWithRequest},
+			{"StrictMatchWithRequest", rule.StrictMatchWithRequest, compiled.StrictMatchWithRequest},
+			{"IgnoreMatchWithRequest", rule.IgnoreMatchWithRequest, compiled.IgnoreMatchWithRequest},
+			{"StrictIgnoreMatchWithRequest", rule.StrictIgnoreMatchWithRequest, compiled.StrictIgnoreMatchWithRequest},
+		}
+		for _, reqFields := range requests {
+			for _, mode := range modes {
+				expected := mode.expected(reqFields, nil)
+				if actual := mode.actual(reqFields, nil); actual != expected {
+					t.Errorf("Test failed for CompiledRule.%s(); rule: %s, request: %v, expected: %v, actual: %v", mode.name, ruleStr, reqFields, expected, actual)
+				}
+				if expected {
+					matchedCount++
+				}
+			}
+		}
+	}
+	if matchedCount == 0 {
+		t.Errorf("Test failed for CompiledRule; no rule matches with the test requests")
+	}
+}
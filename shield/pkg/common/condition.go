@@ -0,0 +1,225 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
+)
+
+/**********************************************
+
+				Field Condition
+
+***********************************************/
+
+type FieldConditionOperator string
+
+const (
+	FieldConditionEquals       FieldConditionOperator = "Equals"
+	FieldConditionNotEquals    FieldConditionOperator = "NotEquals"
+	FieldConditionIn           FieldConditionOperator = "In"
+	FieldConditionNotIn        FieldConditionOperator = "NotIn"
+	FieldConditionExists       FieldConditionOperator = "Exists"
+	FieldConditionDoesNotExist FieldConditionOperator = "DoesNotExist"
+)
+
+// FieldCondition is a condition on a field value of the object in the request (e.g. `spec.type` Equals `LoadBalancer`).
+// `Path` is a JSONPath like `$.spec.template.spec.hostNetwork`, and `$.` can be omitted.
+// If the path selects multiple values (e.g. `$.spec.containers[*].image`), Equals / In are satisfied if any of them matches,
+// and NotEquals / NotIn are satisfied if none of them matches. Values are compared as string (e.g. `true`, `80`).
+type FieldCondition struct {
+	Path     string                 `json:"path"`
+	Operator FieldConditionOperator `json:"operator"`
+	Value    string                 `json:"value,omitempty"`
+	Values   []string               `json:"values,omitempty"`
+}
+
+func (self *FieldCondition) jsonPath() string {
+	path := strings.TrimSpace(self.Path)
+	if strings.HasPrefix(path, "$") {
+		return path
+	}
+	return "$." + path
+}
+
+// Validate returns an error if the path or the operator is invalid
+func (self *FieldCondition) Validate() error {
+	if strings.TrimSpace(self.Path) == "" {
+		return fmt.Errorf("path is empty in field condition")
+	}
+	if err := mapnode.ValidateJSONPath(self.jsonPath()); err != nil {
+		return err
+	}
+	switch self.Operator {
+	case FieldConditionEquals, FieldConditionNotEquals, FieldConditionExists, FieldConditionDoesNotExist:
+	case FieldConditionIn, FieldConditionNotIn:
+		if len(self.Values) == 0 {
+			return fmt.Errorf("values must be specified for operator `%s` in field condition", self.Operator)
+		}
+	default:
+		return fmt.Errorf("unknown operator `%s` in field condition", self.Operator)
+	}
+	return nil
+}
+
+// match evaluates the condition on the object; obj is nil if the object does not exist in the request
+func (self *FieldCondition) match(obj *mapnode.Node) bool {
+	values, found := fieldValues(obj, self.jsonPath())
+	switch self.Operator {
+	case FieldConditionExists:
+		return found
+	case FieldConditionDoesNotExist:
+		return !found
+	case FieldConditionEquals:
+		return containsAnyValue(values, []string{self.Value})
+	case FieldConditionNotEquals:
+		return !containsAnyValue(values, []string{self.Value})
+	case FieldConditionIn:
+		return containsAnyValue(values, self.Values)
+	case FieldConditionNotIn:
+		return !containsAnyValue(values, self.Values)
+	}
+	return false
+}
+
+// fieldValues returns values selected by the path as string; false is returned if nothing is selected
+func fieldValues(obj *mapnode.Node, jpathKey string) ([]string, bool) {
+	if obj == nil {
+		return nil, false
+	}
+	found, ok := obj.GetNodeByJSONPath(jpathKey)
+	if !ok || found == nil {
+		return nil, false
+	}
+	var items []interface{}
+	if list, isList := found.Interface().([]interface{}); isList {
+		items = list
+	} else {
+		items = []interface{}{found.Interface()}
+	}
+	values := []string{}
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		values = append(values, fieldValueString(item))
+	}
+	return values, len(values) > 0
+}
+
+func fieldValueString(v interface{}) string {
+	switch tv := v.(type) {
+	case string:
+		return tv
+	case bool:
+		return strconv.FormatBool(tv)
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(tv)
+	}
+	vB, _ := json.Marshal(v)
+	return string(vB)
+}
+
+func containsAnyValue(values, candidates []string) bool {
+	for _, v := range values {
+		for _, c := range candidates {
+			if v == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RequestObjects holds the new and old objects in a request for field conditions. They are parsed at the first use and
+// the parsed nodes are reused, so that they are parsed only once per request even if many rules have conditions.
+type RequestObjects struct {
+	objects []string
+	once    sync.Once
+	nodes   []*mapnode.Node
+}
+
+// NewRequestObjects returns RequestObjects of the JSON strings; empty strings (objects which do not exist) are skipped
+func NewRequestObjects(objects ...string) *RequestObjects {
+	return &RequestObjects{objects: objects}
+}
+
+// NewRequestObjectsFromFields returns RequestObjects of the new and old object in reqFields
+func NewRequestObjectsFromFields(reqFields map[string]string) *RequestObjects {
+	return NewRequestObjects(reqFields[ReqFieldObject], reqFields[ReqFieldOldObject])
+}
+
+// Nodes returns the parsed objects; objects which cannot be parsed are skipped
+func (self *RequestObjects) Nodes() []*mapnode.Node {
+	self.once.Do(func() {
+		self.nodes = []*mapnode.Node{}
+		for _, objStr := range self.objects {
+			if objStr == "" {
+				continue
+			}
+			obj, err := mapnode.NewFromBytes([]byte(objStr))
+			if err != nil {
+				continue
+			}
+			self.nodes = append(self.nodes, obj)
+		}
+	})
+	return self.nodes
+}
+
+// matchFieldConditions evaluates all conditions against the new and old object like label selectors; objects are parsed from reqFields if nil.
+// A rule to protect resources matches if any object satisfies the conditions, and a rule to ignore them matches only if all objects do.
+func matchFieldConditions(conditions []*FieldCondition, reqFields map[string]string, reqObjects *RequestObjects, mode selectorMatchMode) bool {
+	if len(conditions) == 0 {
+		return true
+	}
+	if reqObjects == nil {
+		reqObjects = NewRequestObjectsFromFields(reqFields)
+	}
+	objects := reqObjects.Nodes()
+	if len(objects) == 0 {
+		// no object in the request (e.g. reqFields without object)
+		objects = []*mapnode.Node{nil}
+	}
+	for _, obj := range objects {
+		objMatched := true
+		for _, c := range conditions {
+			if c == nil {
+				continue
+			}
+			if !c.match(obj) {
+				objMatched = false
+				break
+			}
+		}
+		if mode == selectorMatchAnyObject && objMatched {
+			return true
+		}
+		if mode == selectorMatchAllObjects && !objMatched {
+			return false
+		}
+	}
+	return mode == selectorMatchAllObjects
+}
@@ -0,0 +1,249 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**********************************************
+
+				Kustomize Transformers
+
+***********************************************/
+
+// KustomizeImage is same as `images` in kustomization.yaml; the image whose name is `Name` is replaced with
+// `NewName` and `NewTag` (or `Digest`) in containers and initContainers.
+type KustomizeImage struct {
+	Name    string `json:"name"`
+	NewName string `json:"newName,omitempty"`
+	NewTag  string `json:"newTag,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+// KustomizeReplica is same as `replicas` in kustomization.yaml; `spec.replicas` of the workload named `Name` is set to `Count`.
+type KustomizeReplica struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// fields where commonLabels are added in addition to `metadata.labels`, by kind (same as the default field specs of kustomize)
+var kustomizeLabelFields = map[string][][]string{
+	"Service":               {{"spec", "selector"}},
+	"ReplicationController": {{"spec", "selector"}, {"spec", "template", "metadata", "labels"}},
+	"Deployment":            {{"spec", "selector", "matchLabels"}, {"spec", "template", "metadata", "labels"}},
+	"ReplicaSet":            {{"spec", "selector", "matchLabels"}, {"spec", "template", "metadata", "labels"}},
+	"DaemonSet":             {{"spec", "selector", "matchLabels"}, {"spec", "template", "metadata", "labels"}},
+	"StatefulSet":           {{"spec", "selector", "matchLabels"}, {"spec", "template", "metadata", "labels"}},
+	"Job":                   {{"spec", "template", "metadata", "labels"}},
+	"CronJob":               {{"spec", "jobTemplate", "metadata", "labels"}, {"spec", "jobTemplate", "spec", "template", "metadata", "labels"}},
+	"PodDisruptionBudget":   {{"spec", "selector", "matchLabels"}},
+}
+
+// fields where commonAnnotations are added in addition to `metadata.annotations`, by kind
+var kustomizeAnnotationFields = map[string][][]string{
+	"ReplicationController": {{"spec", "template", "metadata", "annotations"}},
+	"Deployment":            {{"spec", "template", "metadata", "annotations"}},
+	"ReplicaSet":            {{"spec", "template", "metadata", "annotations"}},
+	"DaemonSet":             {{"spec", "template", "metadata", "annotations"}},
+	"StatefulSet":           {{"spec", "template", "metadata", "annotations"}},
+	"Job":                   {{"spec", "template", "metadata", "annotations"}},
+	"CronJob":               {{"spec", "jobTemplate", "metadata", "annotations"}, {"spec", "jobTemplate", "spec", "template", "metadata", "annotations"}},
+}
+
+// kinds whose `spec.replicas` is set by `replicas`
+var kustomizeReplicaKinds = map[string]bool{
+	"Deployment":            true,
+	"ReplicaSet":            true,
+	"ReplicationController": true,
+	"StatefulSet":           true,
+}
+
+// HasTransformers returns true if the pattern changes contents of the resource (labels, annotations, images or replicas)
+func (self *KustomizePattern) HasTransformers() bool {
+	return len(self.CommonLabels) > 0 || len(self.CommonAnnotations) > 0 || len(self.Images) > 0 || len(self.Replicas) > 0
+}
+
+// Validate returns an error if an image or a replica in the pattern is invalid
+func (self *KustomizePattern) Validate() error {
+	for i, img := range self.Images {
+		if img == nil || img.Name == "" {
+			return fmt.Errorf("images[%d] has no name", i)
+		}
+		if img.NewTag != "" && img.Digest != "" {
+			return fmt.Errorf("images[%d] has both newTag and digest", i)
+		}
+	}
+	for i, r := range self.Replicas {
+		if r == nil || r.Name == "" {
+			return fmt.Errorf("replicas[%d] has no name", i)
+		}
+		if r.Count < 0 {
+			return fmt.Errorf("replicas[%d] has negative count %d", i, r.Count)
+		}
+	}
+	return nil
+}
+
+// Transform applies commonLabels, commonAnnotations, images and replicas to the (signed base) object in the same way as kustomize,
+// so that it can be compared with the kustomized object in the request. obj is modified and returned.
+func (self *KustomizePattern) Transform(obj map[string]interface{}) map[string]interface{} {
+	if obj == nil {
+		return obj
+	}
+	kind, _ := obj["kind"].(string)
+	if len(self.CommonLabels) > 0 {
+		setStringMap(obj, []string{"metadata", "labels"}, self.CommonLabels)
+		for _, path := range kustomizeLabelFields[kind] {
+			setStringMap(obj, path, self.CommonLabels)
+		}
+		if kind == "StatefulSet" {
+			// labels of PVC templates are also changed
+			if templates, ok := getNestedValue(obj, []string{"spec", "volumeClaimTemplates"}).([]interface{}); ok {
+				for _, tmpl := range templates {
+					if tmplMap, ok := tmpl.(map[string]interface{}); ok {
+						setStringMap(tmplMap, []string{"metadata", "labels"}, self.CommonLabels)
+					}
+				}
+			}
+		}
+	}
+	if len(self.CommonAnnotations) > 0 {
+		setStringMap(obj, []string{"metadata", "annotations"}, self.CommonAnnotations)
+		for _, path := range kustomizeAnnotationFields[kind] {
+			setStringMap(obj, path, self.CommonAnnotations)
+		}
+	}
+	if len(self.Images) > 0 {
+		transformImages(obj, self.Images)
+	}
+	if len(self.Replicas) > 0 && kustomizeReplicaKinds[kind] {
+		name, _ := getNestedValue(obj, []string{"metadata", "name"}).(string)
+		for _, r := range self.Replicas {
+			if r != nil && r.Name == name {
+				setNestedValue(obj, []string{"spec", "replicas"}, r.Count)
+			}
+		}
+	}
+	return obj
+}
+
+// transformImages replaces images in any `containers` and `initContainers` in the object (e.g. Pod, Deployment, CronJob)
+func transformImages(v interface{}, images []*KustomizeImage) {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for k, child := range tv {
+			if k == "containers" || k == "initContainers" {
+				if containers, ok := child.([]interface{}); ok {
+					for _, c := range containers {
+						if cMap, ok := c.(map[string]interface{}); ok {
+							if image, ok := cMap["image"].(string); ok {
+								cMap["image"] = replaceImage(image, images)
+							}
+						}
+					}
+					continue
+				}
+			}
+			transformImages(child, images)
+		}
+	case []interface{}:
+		for _, child := range tv {
+			transformImages(child, images)
+		}
+	}
+}
+
+func replaceImage(image string, images []*KustomizeImage) string {
+	name, tag, digest := splitImage(image)
+	for _, img := range images {
+		if img == nil || img.Name != name {
+			continue
+		}
+		if img.NewName != "" {
+			name = img.NewName
+		}
+		if img.Digest != "" {
+			return fmt.Sprintf("%s@%s", name, img.Digest)
+		}
+		if img.NewTag != "" {
+			return fmt.Sprintf("%s:%s", name, img.NewTag)
+		}
+		if digest != "" {
+			return fmt.Sprintf("%s@%s", name, digest)
+		}
+		if tag != "" {
+			return fmt.Sprintf("%s:%s", name, tag)
+		}
+		return name
+	}
+	return image
+}
+
+// splitImage splits an image like `registry.example.com:5000/app:1.0.0` into the name, the tag and the digest
+func splitImage(image string) (string, string, string) {
+	name := image
+	digest := ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		digest = name[i+1:]
+		name = name[:i]
+	}
+	tag := ""
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i+1:], "/") {
+		tag = name[i+1:]
+		name = name[:i]
+	}
+	return name, tag, digest
+}
+
+func getNestedValue(obj map[string]interface{}, path []string) interface{} {
+	var current interface{} = obj
+	for _, p := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[p]
+	}
+	return current
+}
+
+func setNestedValue(obj map[string]interface{}, path []string, value interface{}) {
+	current := obj
+	for _, p := range path[:len(path)-1] {
+		next, ok := current[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[p] = next
+		}
+		current = next
+	}
+	current[path[len(path)-1]] = value
+}
+
+// setStringMap adds the key-values to the map at the path; the map is created if it does not exist
+func setStringMap(obj map[string]interface{}, path []string, values map[string]string) {
+	m, ok := getNestedValue(obj, path).(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+		setNestedValue(obj, path, m)
+	}
+	for k, v := range values {
+		m[k] = v
+	}
+}
@@ -17,9 +17,12 @@
 package common
 
 import (
+	"fmt"
 	"math/big"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 /**********************************************
@@ -28,26 +31,154 @@ import (
 
 ***********************************************/
 
+// RegexPatternPrefix is the prefix of a pattern which is evaluated as a regular expression (e.g. `re:^app-(a|b)$`)
+const RegexPatternPrefix = "re:"
+
+// compiled patterns are cached, because the same patterns in RSPs and ShieldConfig are evaluated for every request
+var compiledPatterns sync.Map // map[string]*regexp.Regexp, nil for an invalid pattern
+
+// MatchPattern matches the value with the pattern.
+//   - "" and "*" match any value, "-" matches empty value
+//   - "re:<regexp>" is a regular expression
+//   - comma separated list matches if any of the patterns matches
+//   - glob with "*", "?" and "[...]"; "*" matches any characters including "/"
+//   - otherwise, exact match
 func MatchPattern(pattern, value string) bool {
 	pattern = strings.TrimSpace(pattern)
 	if pattern == "" {
 		return true
 	} else if pattern == "*" {
 		return true
+	} else if strings.HasPrefix(pattern, RegexPatternPrefix) {
+		re := compilePattern(pattern)
+		return re != nil && re.MatchString(value)
 	} else if pattern == "-" && value == "" {
 		return true
-	} else if strings.HasSuffix(pattern, "*") {
-		return strings.HasPrefix(value, strings.TrimRight(pattern, "*"))
 	} else if pattern == value {
 		return true
 	} else if strings.Contains(pattern, ",") {
 		patterns := SplitRule(pattern)
 		return MatchWithPatternArray(value, patterns)
+	} else if isGlobPattern(pattern) {
+		re := compilePattern(pattern)
+		return re != nil && re.MatchString(value)
 	} else {
 		return false
 	}
 }
 
+// newPatternMatcher returns a function which is same as MatchPattern(pattern, value), for a pattern which is evaluated many times
+func newPatternMatcher(pattern string) func(string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || pattern == "*" {
+		return func(string) bool { return true }
+	} else if strings.HasPrefix(pattern, RegexPatternPrefix) {
+		re := compilePattern(pattern)
+		return func(value string) bool { return re != nil && re.MatchString(value) }
+	} else if pattern == "-" {
+		return func(value string) bool { return value == "" || value == pattern }
+	} else if strings.Contains(pattern, ",") {
+		matchers := []func(string) bool{}
+		for _, p := range SplitRule(pattern) {
+			matchers = append(matchers, newPatternMatcher(p))
+		}
+		return func(value string) bool {
+			if value == pattern {
+				return true
+			}
+			for _, m := range matchers {
+				if m(value) {
+					return true
+				}
+			}
+			return false
+		}
+	} else if isGlobPattern(pattern) {
+		re := compilePattern(pattern)
+		return func(value string) bool { return value == pattern || (re != nil && re.MatchString(value)) }
+	}
+	return func(value string) bool { return value == pattern }
+}
+
+// ValidatePattern returns an error if the pattern cannot be compiled
+func ValidatePattern(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	if strings.HasPrefix(pattern, RegexPatternPrefix) {
+		if _, err := regexp.Compile(strings.TrimPrefix(pattern, RegexPatternPrefix)); err != nil {
+			return fmt.Errorf("invalid regular expression `%s`; %s", pattern, err.Error())
+		}
+		return nil
+	}
+	for _, p := range SplitRule(pattern) {
+		if isGlobPattern(p) {
+			if _, err := regexp.Compile(globToRegexp(p)); err != nil {
+				return fmt.Errorf("invalid pattern `%s`; %s", p, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+func compilePattern(pattern string) *regexp.Regexp {
+	if cached, ok := compiledPatterns.Load(pattern); ok {
+		return cached.(*regexp.Regexp)
+	}
+	var expr string
+	if strings.HasPrefix(pattern, RegexPatternPrefix) {
+		expr = strings.TrimPrefix(pattern, RegexPatternPrefix)
+	} else {
+		expr = globToRegexp(pattern)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		re = nil
+	}
+	compiledPatterns.Store(pattern, re)
+	return re
+}
+
+// globToRegexp converts a glob pattern to an anchored regular expression
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := -1
+			for j := i + 1; j < len(runes); j++ {
+				if runes[j] == ']' && j > i+1 {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := string(runes[i+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + strings.TrimPrefix(class, "!")
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
 func ExactMatch(pattern, value string) bool {
 	return pattern == value
 }
@@ -35,3 +35,47 @@ func TestPattern(t *testing.T) {
 		t.Errorf("TestPattern() Failed")
 	}
 }
+
+func TestGlobAndRegexPattern(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		value   string
+		matched bool
+	}{
+		{"*-config", "app-config", true},
+		{"*-config", "app-config-2", false},
+		{"team-?-ns", "team-a-ns", true},
+		{"team-?-ns", "team-ab-ns", false},
+		{"team-[ab]-ns", "team-b-ns", true},
+		{"team-[!ab]-ns", "team-b-ns", false},
+		{"app.*", "app.v1", true},
+		{"app.*", "appxv1", false},
+		{"docker.io/org/*", "docker.io/org/app/image:v1", true},
+		{"re:^app-(a|b)$", "app-a", true},
+		{"re:^app-(a|b)$", "app-c", false},
+		{"re:^ns-[0-9]{1,3}$", "ns-12", true},
+		{"re:^app-(a", "app-a", false},
+		{"sample-cm, *-config", "app-config", true},
+		{"-", "", true},
+		{"-", "value", false},
+		{"app,-", "", true},
+		{" app-a ", "app-a", true},
+		{"", "value", true},
+		{"*", "", true},
+	}
+	for _, tc := range testCases {
+		if actual := MatchPattern(tc.pattern, tc.value); actual != tc.matched {
+			t.Errorf("TestGlobAndRegexPattern() Failed; MatchPattern(%s, %s) expected: %v, actual: %v", tc.pattern, tc.value, tc.matched, actual)
+		}
+		if actual := newPatternMatcher(tc.pattern)(tc.value); actual != tc.matched {
+			t.Errorf("TestGlobAndRegexPattern() Failed; newPatternMatcher(%s)(%s) expected: %v, actual: %v", tc.pattern, tc.value, tc.matched, actual)
+		}
+	}
+
+	if ValidatePattern("re:^app-(a") == nil {
+		t.Errorf("TestGlobAndRegexPattern() Failed; ValidatePattern() should return an error for an invalid regular expression")
+	}
+	if err := ValidatePattern("team-?-ns,re-*"); err != nil {
+		t.Errorf("TestGlobAndRegexPattern() Failed; %s", err.Error())
+	}
+}
@@ -19,6 +19,7 @@ package common
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jinzhu/copier"
 )
@@ -46,10 +47,11 @@ const (
 ***********************************************/
 
 type SignerConfig struct {
-	Policies    []SignerConfigCondition `json:"policies,omitempty"`
-	Signers     []SignerCondition       `json:"signers,omitempty"`
-	BreakGlass  []BreakGlassCondition   `json:"breakGlass,omitempty"`
-	Description string                  `json:"description,omitempty"`
+	Policies          []SignerConfigCondition `json:"policies,omitempty"`
+	Signers           []SignerCondition       `json:"signers,omitempty"`
+	BreakGlass        []BreakGlassCondition   `json:"breakGlass,omitempty"`
+	BreakGlassSigners []string                `json:"breakGlassSigners,omitempty"`
+	Description       string                  `json:"description,omitempty"`
 }
 
 func (p *SignerConfig) DeepCopyInto(p2 *SignerConfig) {
@@ -84,6 +86,7 @@ func (self *SignerConfig) Merge(data *SignerConfig) *SignerConfig {
 	merged.Policies = append(self.Policies, data.Policies...)
 	merged.Signers = append(self.Signers, data.Signers...)
 	merged.BreakGlass = append(self.BreakGlass, data.BreakGlass...)
+	merged.BreakGlassSigners = append(self.BreakGlassSigners, data.BreakGlassSigners...)
 	merged.Description = self.Description
 	return merged
 }
@@ -114,13 +117,30 @@ func (self *SignerConfig) GetCandidatePubkeys(keyPathList []string, namespace st
 			}
 		}
 	}
+	return filterKeyPathsByKeyConfig(keyPathList, candidates)
+}
+
+// GetBreakGlassPubkeys returns keys of the signers who are allowed to sign BreakGlassRequest
+func (self *SignerConfig) GetBreakGlassPubkeys(keyPathList []string) map[SignatureType][]string {
+	candidates := []string{}
+	for _, signerName := range self.BreakGlassSigners {
+		for _, signerCondition := range self.Signers {
+			if signerCondition.Name == signerName {
+				candidates = append(candidates, signerCondition.KeyConfig)
+			}
+		}
+	}
+	return filterKeyPathsByKeyConfig(keyPathList, candidates)
+}
+
+func filterKeyPathsByKeyConfig(keyPathList, keyConfigs []string) map[SignatureType][]string {
 	candidateKeys := map[SignatureType][]string{
 		SignatureTypePGP:      {},
 		SignatureTypeX509:     {},
 		SignatureTypeSigStore: {},
 	}
 	for _, keyPath := range keyPathList {
-		for _, keyConfName := range candidates {
+		for _, keyConfName := range keyConfigs {
 			keyConfPattern := fmt.Sprintf("/%s/", keyConfName)
 			pgpPattern := fmt.Sprintf("/%s/", string(SignatureTypePGP))
 			x509Pattern := fmt.Sprintf("/%s/", string(SignatureTypeX509))
@@ -202,6 +222,43 @@ type BreakGlassCondition struct {
 	Namespaces []string  `json:"namespaces,omitempty"`
 }
 
+// BreakGlassRequest is a time-boxed break glass grant.
+// It is stored in a ConfigMap in IShield namespace and must be signed by one of `SignerConfig.BreakGlassSigners`.
+type BreakGlassRequest struct {
+	Scope      ScopeType `json:"scope,omitempty"`
+	Namespaces []string  `json:"namespaces,omitempty"`
+	Kinds      []string  `json:"kinds,omitempty"`
+	Expiry     string    `json:"expiry"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// ExpiryTime parses `expiry` in RFC3339 format
+func (self *BreakGlassRequest) ExpiryTime() (time.Time, error) {
+	return time.Parse(time.RFC3339, self.Expiry)
+}
+
+// IsExpired returns true also when `expiry` is invalid, so a request without valid expiry is never effective
+func (self *BreakGlassRequest) IsExpired(now time.Time) bool {
+	expiry, err := self.ExpiryTime()
+	if err != nil {
+		return true
+	}
+	return now.After(expiry)
+}
+
+func (self *BreakGlassRequest) Match(resourceScope, namespace, kind string) bool {
+	if len(self.Kinds) > 0 && !MatchWithPatternArray(kind, self.Kinds) {
+		return false
+	}
+	if resourceScope == string(ScopeNamespaced) {
+		if self.Scope != ScopeUndefined && self.Scope != ScopeNamespaced {
+			return false
+		}
+		return MatchWithPatternArray(namespace, self.Namespaces)
+	}
+	return self.Scope == ScopeCluster
+}
+
 type SubjectMatchPattern struct {
 	Email              string `json:"email,omitempty"`
 	Uid                string `json:"uid,omitempty"`
@@ -18,11 +18,15 @@ package common
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"regexp"
 	"strings"
 
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
 	"github.com/jinzhu/copier"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -34,6 +38,8 @@ type CommonProfile struct {
 type Rule struct {
 	Match   []*RequestPatternWithNamespace `json:"match,omitempty"`
 	Exclude []*RequestPatternWithNamespace `json:"exclude,omitempty"`
+	// `Conditions` are evaluated on the object in the request; the rule matches only if all of them are satisfied
+	Conditions []*FieldCondition `json:"conditions,omitempty"`
 }
 
 type RequestPattern struct {
@@ -46,13 +52,45 @@ type RequestPattern struct {
 	Operation  *RulePattern `json:"operation,omitempty"`
 	UserName   *RulePattern `json:"username,omitempty"`
 	UserGroup  *RulePattern `json:"usergroup,omitempty"`
+
+	// set-based selectors for labels/annotations, evaluated against the new and old object
+	LabelSelector      *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	AnnotationSelector *metav1.LabelSelector `json:"annotationSelector,omitempty"`
 }
 
+// selectorMatchMode decides how label/annotation selectors are evaluated when the request has both new and old object.
+// A pattern to protect resources matches if any of them matches, so that a resource cannot escape from protection by removing labels.
+// A pattern to ignore (or exclude) resources matches only if all of them match, so that a resource cannot be ignored by adding labels.
+type selectorMatchMode int
+
+const (
+	selectorMatchAnyObject selectorMatchMode = iota
+	selectorMatchAllObjects
+)
+
+// keys in reqFields for labels and annotations of the new and old object (JSON string, empty if the object does not exist)
+const (
+	ReqFieldObjLabels         = "ObjLabels"
+	ReqFieldObjAnnotations    = "ObjAnnotations"
+	ReqFieldOldObjLabels      = "OldObjLabels"
+	ReqFieldOldObjAnnotations = "OldObjAnnotations"
+)
+
+// keys in reqFields for the new and old object (JSON string, empty if the object does not exist)
+const (
+	ReqFieldObject    = "Object"
+	ReqFieldOldObject = "OldObject"
+)
+
 type KustomizePattern struct {
-	Match                []*RequestPattern `json:"match,omitempty"`
-	NamePrefix           *RulePattern      `json:"namePrefix,omitempty"`
-	NameSuffix           *RulePattern      `json:"nameSuffix,omitempty"`
-	AllowNamespaceChange bool              `json:"allowNamespaceChange,omitempty"`
+	Match                []*RequestPattern   `json:"match,omitempty"`
+	NamePrefix           *RulePattern        `json:"namePrefix,omitempty"`
+	NameSuffix           *RulePattern        `json:"nameSuffix,omitempty"`
+	AllowNamespaceChange bool                `json:"allowNamespaceChange,omitempty"`
+	CommonLabels         map[string]string   `json:"commonLabels,omitempty"`
+	CommonAnnotations    map[string]string   `json:"commonAnnotations,omitempty"`
+	Images               []*KustomizeImage   `json:"images,omitempty"`
+	Replicas             []*KustomizeReplica `json:"replicas,omitempty"`
 }
 
 type RequestPatternWithNamespace struct {
@@ -61,6 +99,25 @@ type RequestPatternWithNamespace struct {
 }
 
 func (self *RequestPatternWithNamespace) Match(reqFields map[string]string) bool {
+	return self.match(reqFields, selectorMatchAnyObject)
+}
+
+// IgnoreMatch is same as Match, but label/annotation selectors must match with all objects in the request
+func (self *RequestPatternWithNamespace) IgnoreMatch(reqFields map[string]string) bool {
+	return self.match(reqFields, selectorMatchAllObjects)
+}
+
+// Validate returns an error if any pattern or label/annotation selector is invalid
+func (self *RequestPatternWithNamespace) Validate() error {
+	if self.Namespace != nil {
+		if err := ValidatePattern(string(*self.Namespace)); err != nil {
+			return err
+		}
+	}
+	return self.RequestPattern.Validate()
+}
+
+func (self *RequestPatternWithNamespace) match(reqFields map[string]string, mode selectorMatchMode) bool {
 	if self.Namespace == nil && self.RequestPattern == nil {
 		return false
 	}
@@ -72,7 +129,7 @@ func (self *RequestPatternWithNamespace) Match(reqFields map[string]string) bool
 	}
 	otherMatched := true
 	if self.RequestPattern != nil {
-		otherMatched = self.RequestPattern.Match(reqFields)
+		otherMatched = self.RequestPattern.match(reqFields, false, mode)
 	}
 	return nsMatched && otherMatched
 }
@@ -82,7 +139,9 @@ func (self *Rule) String() string {
 	return string(rB)
 }
 
-func (self *Rule) MatchWithRequest(reqFields map[string]string) bool {
+// MatchWithRequest returns true if the request matches with the rule. reqObjects has the objects in the request for field conditions,
+// and the objects are parsed from reqFields if it is nil.
+func (self *Rule) MatchWithRequest(reqFields map[string]string, reqObjects *RequestObjects) bool {
 	matched := false
 	for _, m := range self.Match {
 		if m.Match(reqFields) {
@@ -93,17 +152,17 @@ func (self *Rule) MatchWithRequest(reqFields map[string]string) bool {
 	excluded := false
 	if matched {
 		for _, ex := range self.Exclude {
-			if ex.Match(reqFields) {
+			if ex.IgnoreMatch(reqFields) {
 				excluded = true
 				break
 			}
 		}
 	}
 
-	return matched && !excluded
+	return matched && !excluded && matchFieldConditions(self.Conditions, reqFields, reqObjects, selectorMatchAnyObject)
 }
 
-func (self *Rule) StrictMatchWithRequest(reqFields map[string]string) bool {
+func (self *Rule) StrictMatchWithRequest(reqFields map[string]string, reqObjects *RequestObjects) bool {
 	matched := false
 	for _, m := range self.Match {
 		if m.StrictMatch(reqFields) {
@@ -112,6 +171,50 @@ func (self *Rule) StrictMatchWithRequest(reqFields map[string]string) bool {
 		}
 	}
 	excluded := false
+	if matched {
+		for _, ex := range self.Exclude {
+			if ex.StrictIgnoreMatch(reqFields) {
+				excluded = true
+				break
+			}
+		}
+	}
+
+	return matched && !excluded && matchFieldConditions(self.Conditions, reqFields, reqObjects, selectorMatchAnyObject)
+}
+
+// IgnoreMatchWithRequest is used for ignore rules; label/annotation selectors in `match` must match with all objects in the request,
+// and the ones in `exclude` match with any of them.
+func (self *Rule) IgnoreMatchWithRequest(reqFields map[string]string, reqObjects *RequestObjects) bool {
+	matched := false
+	for _, m := range self.Match {
+		if m.IgnoreMatch(reqFields) {
+			matched = true
+			break
+		}
+	}
+	excluded := false
+	if matched {
+		for _, ex := range self.Exclude {
+			if ex.Match(reqFields) {
+				excluded = true
+				break
+			}
+		}
+	}
+
+	return matched && !excluded && matchFieldConditions(self.Conditions, reqFields, reqObjects, selectorMatchAllObjects)
+}
+
+func (self *Rule) StrictIgnoreMatchWithRequest(reqFields map[string]string, reqObjects *RequestObjects) bool {
+	matched := false
+	for _, m := range self.Match {
+		if m.StrictIgnoreMatch(reqFields) {
+			matched = true
+			break
+		}
+	}
+	excluded := false
 	if matched {
 		for _, ex := range self.Exclude {
 			if ex.StrictMatch(reqFields) {
@@ -121,59 +224,155 @@ func (self *Rule) StrictMatchWithRequest(reqFields map[string]string) bool {
 		}
 	}
 
-	return matched && !excluded
+	return matched && !excluded && matchFieldConditions(self.Conditions, reqFields, reqObjects, selectorMatchAllObjects)
 }
 
 // match the input request with pattern, allow wildcard for resource name
 func (self *RequestPattern) Match(reqFields map[string]string) bool {
-	return self.match(reqFields, false)
+	return self.match(reqFields, false, selectorMatchAnyObject)
 }
 
 // match the input request with pattern, exact match for resource name
 func (self *RequestPattern) StrictMatch(reqFields map[string]string) bool {
-	return self.match(reqFields, true)
+	return self.match(reqFields, true, selectorMatchAnyObject)
+}
+
+// match the input request with pattern to ignore it; label/annotation selectors must match with all objects in the request
+func (self *RequestPattern) IgnoreMatch(reqFields map[string]string) bool {
+	return self.match(reqFields, false, selectorMatchAllObjects)
 }
 
-func (self *RequestPattern) match(reqFields map[string]string, exactMatchForName bool) bool {
+// same as IgnoreMatch, but exact match for resource name
+func (self *RequestPattern) StrictIgnoreMatch(reqFields map[string]string) bool {
+	return self.match(reqFields, true, selectorMatchAllObjects)
+}
+
+func (self *RequestPattern) match(reqFields map[string]string, exactMatchForName bool, mode selectorMatchMode) bool {
 	scope := "Namespaced"
 	if reqScope, ok := reqFields["ResourceScope"]; ok && reqScope == "Cluster" {
 		scope = reqScope
 	}
 
-	if exactMatchForName && scope == "Cluster" && self.Name == nil {
+	if self == nil {
 		return false
 	}
 
-	p := reflect.ValueOf(self)
-	if p.IsNil() {
+	if exactMatchForName && scope == "Cluster" && self.Name == nil {
 		return false
 	}
-	v := reflect.Indirect(p)
-	t := v.Type()
+	// keys are the field names in RequestContext.Map() / ResourceContext.Map()
+	fieldPatterns := [...]struct {
+		fieldName string
+		pattern   *RulePattern
+	}{
+		{"Scope", self.Scope},
+		{"ApiGroup", self.ApiGroup},
+		{"ApiVersion", self.ApiVersion},
+		{"Kind", self.Kind},
+		{"Name", self.Name},
+		{"Operation", self.Operation},
+		{"UserName", self.UserName},
+		{"UserGroup", self.UserGroup},
+	}
 	matched := true
 	patternCount := 0
-	for i := 0; i < t.NumField(); i++ {
-		fieldName := t.Field(i).Name
-		f := v.Field(i)
-		i := f.Interface()
-		if value, ok := i.(*RulePattern); ok {
-			if value != nil {
-				pattern := value
-				reqValue := reqFields[fieldName]
-				patternCount += 1
-				if fieldName == "Name" && exactMatchForName {
-					matched = matched && pattern.exactMatch(reqValue)
-				} else {
-					matched = matched && pattern.match(reqValue)
-				}
-			}
-		} else {
+	for _, fp := range fieldPatterns {
+		if fp.pattern == nil {
 			continue
 		}
+		reqValue := reqFields[fp.fieldName]
+		patternCount += 1
+		if fp.fieldName == "Name" && exactMatchForName {
+			matched = matched && fp.pattern.exactMatch(reqValue)
+		} else {
+			matched = matched && fp.pattern.match(reqValue)
+		}
+		if !matched {
+			return false
+		}
+	}
+	if self.LabelSelector != nil {
+		patternCount += 1
+		matched = matched && matchObjectSelector(parseObjectSelector(self.LabelSelector), reqFields, ReqFieldObjLabels, ReqFieldOldObjLabels, mode)
+	}
+	if self.AnnotationSelector != nil {
+		patternCount += 1
+		matched = matched && matchObjectSelector(parseObjectSelector(self.AnnotationSelector), reqFields, ReqFieldObjAnnotations, ReqFieldOldObjAnnotations, mode)
 	}
 	return (patternCount > 0) && matched
 }
 
+// Validate returns an error if any pattern or label/annotation selector is invalid
+func (self *RequestPattern) Validate() error {
+	if self == nil {
+		return nil
+	}
+	for _, p := range []*RulePattern{self.Scope, self.ApiGroup, self.ApiVersion, self.Kind, self.Name, self.Operation, self.UserName, self.UserGroup} {
+		if p == nil {
+			continue
+		}
+		if err := ValidatePattern(string(*p)); err != nil {
+			return err
+		}
+	}
+	return self.ValidateSelectors()
+}
+
+// ValidateSelectors returns an error if label/annotation selector is invalid
+func (self *RequestPattern) ValidateSelectors() error {
+	if self == nil {
+		return nil
+	}
+	if self.LabelSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(self.LabelSelector); err != nil {
+			return fmt.Errorf("invalid labelSelector; %s", err.Error())
+		}
+	}
+	if self.AnnotationSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(self.AnnotationSelector); err != nil {
+			return fmt.Errorf("invalid annotationSelector; %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// parseObjectSelector returns the label/annotation selector as labels.Selector; an invalid selector never matches
+func parseObjectSelector(selector *metav1.LabelSelector) labels.Selector {
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return labels.Nothing()
+	}
+	return sel
+}
+
+// matchObjectSelector evaluates the selector against labels (or annotations) of the new and old object in reqFields
+func matchObjectSelector(sel labels.Selector, reqFields map[string]string, newKey, oldKey string, mode selectorMatchMode) bool {
+	objects := []labels.Set{}
+	for _, key := range []string{newKey, oldKey} {
+		valuesStr, ok := reqFields[key]
+		if !ok || valuesStr == "" {
+			continue
+		}
+		var values map[string]string
+		_ = json.Unmarshal([]byte(valuesStr), &values)
+		objects = append(objects, labels.Set(values))
+	}
+	if len(objects) == 0 {
+		// no object in the request (e.g. reqFields without metadata)
+		objects = append(objects, labels.Set{})
+	}
+	for _, obj := range objects {
+		objMatched := sel.Matches(obj)
+		if mode == selectorMatchAnyObject && objMatched {
+			return true
+		}
+		if mode == selectorMatchAllObjects && !objMatched {
+			return false
+		}
+	}
+	return mode == selectorMatchAllObjects
+}
+
 type RulePattern string
 
 func (self *RulePattern) match(value string) bool {
@@ -276,6 +475,8 @@ type ServiceAccountPattern struct {
 type AttrsPattern struct {
 	Match []*RequestPatternWithNamespace `json:"match,omitempty"`
 	Attrs []string                       `json:"attrs,omitempty"`
+	// Values restricts the new values of Attrs in ignoreAttrs; an update to other values is denied in the mutation check
+	Values *ValueConstraint `json:"values,omitempty"`
 }
 
 func (self *AttrsPattern) MatchWith(reqFields map[string]string) bool {
@@ -287,6 +488,36 @@ func (self *AttrsPattern) MatchWith(reqFields map[string]string) bool {
 	return false
 }
 
+// TrustedFieldManager is a field manager of server-side apply whose fields are not compared with a signed manifest.
+// Any client can use the name of any manager in managedFields, so the fields are trusted only in requests which match with `Match`
+// (e.g. `username` of an autoscaler).
+type TrustedFieldManager struct {
+	Name  string                         `json:"name,omitempty"`
+	Match []*RequestPatternWithNamespace `json:"match,omitempty"`
+}
+
+// MatchWithRequest returns true if the request is sent by a user who is trusted for the manager.
+// `usergroup` in Match is checked with each group of the user.
+func (self *TrustedFieldManager) MatchWithRequest(reqc *RequestContext) bool {
+	if reqc == nil {
+		return false
+	}
+	reqFields := reqc.Map()
+	userGroups := reqc.UserGroups
+	if len(userGroups) == 0 {
+		userGroups = []string{""}
+	}
+	for _, reqPattern := range self.Match {
+		for _, userGroup := range userGroups {
+			reqFields["UserGroup"] = userGroup
+			if reqPattern.Match(reqFields) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type Request struct {
 	// Scope      string `json:"scope,omitempty"`
 	Operation  string `json:"operation,omitempty"`
@@ -330,8 +561,11 @@ func NewRequestFromReqContext(reqc *RequestContext) *Request {
 }
 
 type Result struct {
-	Message   string `json:"message,omitempty"`
-	Timestamp string `json:"timestamp,omitempty"`
+	Message   string               `json:"message,omitempty"`
+	Timestamp string               `json:"timestamp,omitempty"`
+	Diff      []*mapnode.FieldDiff `json:"diff,omitempty"`
+	// the number of diff entries which are not included in `Diff`
+	OmittedDiffCount int `json:"omittedDiffCount,omitempty"`
 }
 
 func (p *Rule) DeepCopyInto(p2 *Rule) {
@@ -384,6 +618,16 @@ func (p *AttrsPattern) DeepCopy() *AttrsPattern {
 	return p2
 }
 
+func (p *TrustedFieldManager) DeepCopyInto(p2 *TrustedFieldManager) {
+	copier.Copy(&p2, &p)
+}
+
+func (p *TrustedFieldManager) DeepCopy() *TrustedFieldManager {
+	p2 := &TrustedFieldManager{}
+	p.DeepCopyInto(p2)
+	return p2
+}
+
 func (p *Result) DeepCopyInto(p2 *Result) {
 	copier.Copy(&p2, &p)
 }
@@ -20,6 +20,8 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestProfile(t *testing.T) {
@@ -42,7 +44,7 @@ func TestProfile(t *testing.T) {
 		return
 	}
 	reqFields := reqc.Map()
-	ok1 := rule.MatchWithRequest(reqFields)
+	ok1 := rule.MatchWithRequest(reqFields, nil)
 	ruleStr := rule.String()
 
 	// t.Log(reqFields)
@@ -65,7 +67,7 @@ func TestProfile(t *testing.T) {
 		t.Error(err)
 		return
 	}
-	ok2 := rule.MatchWithRequest(reqFields)
+	ok2 := rule.MatchWithRequest(reqFields, nil)
 	ruleStr = rule.String()
 
 	// t.Log(reqFields)
@@ -77,7 +79,7 @@ func TestProfile(t *testing.T) {
 
 	sampleClusterRoleName := RulePattern("sample-clusterrole")
 	rule.Match[0].Name = &sampleClusterRoleName
-	ok3 := rule.MatchWithRequest(reqFields)
+	ok3 := rule.MatchWithRequest(reqFields, nil)
 	ruleStr = rule.String()
 	if !ok3 {
 		t.Errorf("Rule does not match; Rule: %s, RequestRef: %s", ruleStr, reqc.ResourceRef())
@@ -129,3 +131,234 @@ func TestKustomizePattern(t *testing.T) {
 		return
 	}
 }
+
+func TestKustomizeTransform(t *testing.T) {
+	images := []*KustomizeImage{
+		{Name: "registry.example.com:5000/app", NewTag: "2.0.0"},
+		{Name: "nginx", NewName: "mirror.example.com/nginx"},
+		{Name: "busybox", Digest: "sha256:abcd"},
+	}
+	testCases := map[string]string{
+		"registry.example.com:5000/app:1.0.0":  "registry.example.com:5000/app:2.0.0",
+		"registry.example.com:5000/app":        "registry.example.com:5000/app:2.0.0",
+		"nginx:1.21":                           "mirror.example.com/nginx:1.21",
+		"busybox:1.33":                         "busybox@sha256:abcd",
+		"registry.example.com:5000/app2:1.0.0": "registry.example.com:5000/app2:1.0.0",
+	}
+	for image, expected := range testCases {
+		if actual := replaceImage(image, images); actual != expected {
+			t.Errorf("replaceImage(%s) returns wrong result; expected: %s, actual: %s", image, expected, actual)
+		}
+	}
+
+	var obj map[string]interface{}
+	_ = json.Unmarshal([]byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"sample-pod"},"spec":{"containers":[{"name":"web","image":"nginx:1.21"}]}}`), &obj)
+	kust := &KustomizePattern{CommonLabels: map[string]string{"env": "prod"}, Images: images, Replicas: []*KustomizeReplica{{Name: "sample-pod", Count: 3}}}
+	obj = kust.Transform(obj)
+	objBytes, _ := json.Marshal(obj)
+	// replicas is not set for Pod, and labels are set only in metadata
+	expected := `{"apiVersion":"v1","kind":"Pod","metadata":{"labels":{"env":"prod"},"name":"sample-pod"},"spec":{"containers":[{"image":"mirror.example.com/nginx:1.21","name":"web"}]}}`
+	if string(objBytes) != expected {
+		t.Errorf("Transform() returns wrong result; expected: %s, actual: %s", expected, string(objBytes))
+	}
+}
+
+func TestProfileWithSelectors(t *testing.T) {
+	reqFields := map[string]string{
+		"ResourceScope":           "Namespaced",
+		"Namespace":               "payments-ns",
+		"Kind":                    "ConfigMap",
+		"Name":                    "sample-cm",
+		ReqFieldObjLabels:         `{"app.kubernetes.io/part-of":"payments"}`,
+		ReqFieldObjAnnotations:    `{"generated":"true"}`,
+		ReqFieldOldObjLabels:      `{}`,
+		ReqFieldOldObjAnnotations: `{}`,
+	}
+
+	var protectRule *Rule
+	_ = json.Unmarshal([]byte(`{"match":[{"kind":"ConfigMap","labelSelector":{"matchExpressions":[{"key":"app.kubernetes.io/part-of","operator":"In","values":["payments"]}]}}]}`), &protectRule)
+	var ignoreRule *Rule
+	_ = json.Unmarshal([]byte(`{"match":[{"annotationSelector":{"matchLabels":{"generated":"true"}}}]}`), &ignoreRule)
+
+	// labels of the new object match
+	if !protectRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule does not match; Rule: %s", protectRule.String())
+	}
+	// the annotation is added by this request, so it must not be ignored
+	if ignoreRule.IgnoreMatchWithRequest(reqFields, nil) {
+		t.Errorf("Ignore rule should not match when only the new object has the annotation; Rule: %s", ignoreRule.String())
+	}
+
+	// labels are removed by this request, but it is still protected
+	reqFields[ReqFieldObjLabels] = `{}`
+	reqFields[ReqFieldOldObjLabels] = `{"app.kubernetes.io/part-of":"payments"}`
+	if !protectRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule should match with labels of the old object; Rule: %s", protectRule.String())
+	}
+
+	// both objects are annotated
+	reqFields[ReqFieldOldObjAnnotations] = `{"generated":"true"}`
+	if !ignoreRule.IgnoreMatchWithRequest(reqFields, nil) {
+		t.Errorf("Ignore rule does not match; Rule: %s", ignoreRule.String())
+	}
+
+	// CREATE request has no old object
+	reqFields[ReqFieldObjLabels] = `{"app.kubernetes.io/part-of":"billing"}`
+	reqFields[ReqFieldOldObjLabels] = ""
+	if protectRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule should not match; Rule: %s", protectRule.String())
+	}
+
+	invalid := &RequestPattern{LabelSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Unknown"}}}}
+	if invalid.ValidateSelectors() == nil {
+		t.Errorf("ValidateSelectors() should return an error for an invalid operator")
+	}
+}
+
+func TestProfileWithFieldConditions(t *testing.T) {
+	reqFields := map[string]string{
+		"ResourceScope":   "Namespaced",
+		"Namespace":       "payments-ns",
+		"Kind":            "Service",
+		"Name":            "sample-svc",
+		ReqFieldObject:    `{"kind":"Service","spec":{"type":"LoadBalancer","ports":[{"port":80},{"port":443}]}}`,
+		ReqFieldOldObject: "",
+	}
+
+	var protectRule *Rule
+	_ = json.Unmarshal([]byte(`{"match":[{"kind":"Service"}],"conditions":[{"path":"spec.type","operator":"Equals","value":"LoadBalancer"}]}`), &protectRule)
+	var portRule *Rule
+	_ = json.Unmarshal([]byte(`{"match":[{"kind":"Service"}],"conditions":[{"path":"$.spec.ports[*].port","operator":"In","values":["443","8443"]}]}`), &portRule)
+	var hostNetworkRule *Rule
+	_ = json.Unmarshal([]byte(`{"match":[{"kind":"Deployment"}],"conditions":[{"path":"spec.template.spec.hostNetwork","operator":"Equals","value":"true"}]}`), &hostNetworkRule)
+	var ignoreRule *Rule
+	_ = json.Unmarshal([]byte(`{"match":[{"kind":"Service"}],"conditions":[{"path":"spec.externalName","operator":"DoesNotExist"},{"path":"spec.type","operator":"NotIn","values":["LoadBalancer","NodePort"]}]}`), &ignoreRule)
+
+	if !protectRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule does not match; Rule: %s", protectRule.String())
+	}
+	if !portRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule should match with any value in the list; Rule: %s", portRule.String())
+	}
+	if ignoreRule.IgnoreMatchWithRequest(reqFields, nil) {
+		t.Errorf("Ignore rule should not match with LoadBalancer; Rule: %s", ignoreRule.String())
+	}
+
+	// the type is changed to ClusterIP by this request, but it is still protected
+	reqFields[ReqFieldObject] = `{"kind":"Service","spec":{"type":"ClusterIP"}}`
+	reqFields[ReqFieldOldObject] = `{"kind":"Service","spec":{"type":"LoadBalancer"}}`
+	if !protectRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule should match with the old object; Rule: %s", protectRule.String())
+	}
+	// the old object is not ignorable
+	if ignoreRule.IgnoreMatchWithRequest(reqFields, nil) {
+		t.Errorf("Ignore rule should not match when the old object is LoadBalancer; Rule: %s", ignoreRule.String())
+	}
+	reqFields[ReqFieldOldObject] = `{"kind":"Service","spec":{"type":"ClusterIP"}}`
+	if protectRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule should not match; Rule: %s", protectRule.String())
+	}
+	if !ignoreRule.IgnoreMatchWithRequest(reqFields, nil) {
+		t.Errorf("Ignore rule does not match; Rule: %s", ignoreRule.String())
+	}
+
+	// the field does not exist
+	reqFields["Kind"] = "Deployment"
+	reqFields[ReqFieldObject] = `{"kind":"Deployment","spec":{"template":{"spec":{"containers":[]}}}}`
+	reqFields[ReqFieldOldObject] = ""
+	if hostNetworkRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule should not match without hostNetwork; Rule: %s", hostNetworkRule.String())
+	}
+	reqFields[ReqFieldObject] = `{"kind":"Deployment","spec":{"template":{"spec":{"hostNetwork":true}}}}`
+	if !hostNetworkRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule does not match; Rule: %s", hostNetworkRule.String())
+	}
+
+	invalidConditions := []*FieldCondition{
+		{Path: "", Operator: FieldConditionExists},
+		{Path: "spec.type", Operator: "Matches", Value: "Load*"},
+		{Path: "spec.type", Operator: FieldConditionIn},
+		{Path: "spec.ports[x", Operator: FieldConditionExists},
+	}
+	for _, c := range invalidConditions {
+		if c.Validate() == nil {
+			t.Errorf("Validate() should return an error for %v", c)
+		}
+	}
+	if err := protectRule.Conditions[0].Validate(); err != nil {
+		t.Errorf("Validate() returns an error for a valid condition; %s", err.Error())
+	}
+}
+
+func TestRequestObjects(t *testing.T) {
+	reqFields := map[string]string{
+		"Kind":            "Service",
+		ReqFieldObject:    `{"kind":"Service","spec":{"type":"ClusterIP"}}`,
+		ReqFieldOldObject: "",
+	}
+	var protectRule *Rule
+	_ = json.Unmarshal([]byte(`{"match":[{"kind":"Service"}],"conditions":[{"path":"spec.type","operator":"Equals","value":"LoadBalancer"}]}`), &protectRule)
+
+	// the parsed objects are used instead of the ones in reqFields, and they are parsed only once
+	reqObjects := NewRequestObjects(`{"kind":"Service","spec":{"type":"LoadBalancer"}}`, "")
+	nodes := reqObjects.Nodes()
+	if len(nodes) != 1 {
+		t.Fatalf("expected 1 parsed object, actual: %d", len(nodes))
+	}
+	if !protectRule.MatchWithRequest(reqFields, reqObjects) {
+		t.Errorf("Rule should match with the parsed object; Rule: %s", protectRule.String())
+	}
+	if again := reqObjects.Nodes(); len(again) != 1 || again[0] != nodes[0] {
+		t.Errorf("the objects should be parsed only once")
+	}
+	if protectRule.MatchWithRequest(reqFields, nil) {
+		t.Errorf("Rule should not match with the object in reqFields; Rule: %s", protectRule.String())
+	}
+
+	reqc := &RequestContext{Object: reqFields[ReqFieldObject]}
+	reqc.objects = NewRequestObjects(reqc.Object, reqc.OldObject)
+	if reqc.Objects() != reqc.Objects() {
+		t.Errorf("RequestContext should return the same objects for the request")
+	}
+}
+
+func TestValueConstraint(t *testing.T) {
+	testCases := []struct {
+		constraint ValueConstraint
+		value      interface{}
+		ok         bool
+	}{
+		{constraint: ValueConstraint{Min: "2", Max: "20"}, value: float64(2), ok: true},
+		{constraint: ValueConstraint{Min: "2", Max: "20"}, value: float64(20), ok: true},
+		{constraint: ValueConstraint{Min: "2", Max: "20"}, value: float64(1), ok: false},
+		{constraint: ValueConstraint{Min: "2", Max: "20"}, value: float64(21), ok: false},
+		{constraint: ValueConstraint{Min: "2", Max: "20"}, value: "abc", ok: false},
+		{constraint: ValueConstraint{Min: "2", Max: "20"}, value: nil, ok: false},
+		{constraint: ValueConstraint{Max: "1Gi"}, value: "512Mi", ok: true},
+		{constraint: ValueConstraint{Max: "1Gi"}, value: "2Gi", ok: false},
+		{constraint: ValueConstraint{Min: "100m"}, value: 0.5, ok: true},
+		{constraint: ValueConstraint{Enum: []string{"true", "false"}}, value: true, ok: true},
+		{constraint: ValueConstraint{Enum: []string{"Recreate"}}, value: "RollingUpdate", ok: false},
+		{constraint: ValueConstraint{Pattern: "registry.example.com/.*"}, value: "registry.example.com/app:1.0.0", ok: true},
+		// the pattern must match the whole value
+		{constraint: ValueConstraint{Pattern: "registry.example.com/.*"}, value: "evil.io/registry.example.com/app:1.0.0", ok: false},
+		{constraint: ValueConstraint{Pattern: ".*"}, value: map[string]interface{}{"a": "b"}, ok: false},
+	}
+	for i, tc := range testCases {
+		err := tc.constraint.Check(tc.value)
+		if tc.ok && err != nil {
+			t.Errorf("case %d: value %v should be allowed, but got error: %s", i, tc.value, err.Error())
+		} else if !tc.ok && err == nil {
+			t.Errorf("case %d: value %v should not be allowed", i, tc.value)
+		}
+	}
+
+	if err := (&ValueConstraint{Min: "2", Max: "20", Pattern: "[0-9]+"}).Validate(); err != nil {
+		t.Errorf("valid constraint should not have error: %s", err.Error())
+	}
+	for _, c := range []ValueConstraint{{Min: "x"}, {Max: "1GB"}, {Min: "3", Max: "2"}, {Pattern: "("}} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("invalid constraint %v should have error", c)
+		}
+	}
+}
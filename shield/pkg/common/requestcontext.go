@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	gjson "github.com/tidwall/gjson"
@@ -45,6 +46,18 @@ type RequestContext struct {
 	UserName       string   `json:"userName"`
 	UserGroups     []string `json:"userGroups"`
 	Type           string   `json:"Type"`
+
+	// labels and annotations of the new and old object as JSON string, empty if the object does not exist. These are used for selectors in RequestPattern.
+	ObjLabels         string `json:"-"`
+	ObjAnnotations    string `json:"-"`
+	OldObjLabels      string `json:"-"`
+	OldObjAnnotations string `json:"-"`
+
+	// the new and old object as JSON string, empty if the object does not exist. These are used for field conditions in Rule.
+	Object    string `json:"-"`
+	OldObject string `json:"-"`
+
+	objects *RequestObjects
 }
 
 type RequestObject struct {
@@ -74,12 +87,24 @@ func (reqc *RequestContext) ResourceRef() *ResourceRef {
 	}
 }
 
+// Objects returns the new and old object for field conditions, which are parsed only once for the request
+func (reqc *RequestContext) Objects() *RequestObjects {
+	if reqc.objects == nil {
+		return NewRequestObjects(reqc.Object, reqc.OldObject)
+	}
+	return reqc.objects
+}
+
 func (reqc *RequestContext) Map() map[string]string {
 	m := map[string]string{}
 	v := reflect.Indirect(reflect.ValueOf(reqc))
 	t := v.Type()
 	for i := 0; i < t.NumField(); i++ {
 		f := v.Field(i)
+		if !f.CanInterface() {
+			// unexported fields (e.g. parsed objects) are not request fields
+			continue
+		}
 		itf := f.Interface()
 		if value, ok := itf.(string); ok {
 			filedName := t.Field(i).Name
@@ -143,6 +168,11 @@ func AdmissionRequestToResourceContext(request *admv1.AdmissionRequest) *Resourc
 	return NewResourceContext(obj)
 }
 
+func objectExists(raw []byte) bool {
+	trimmed := strings.TrimSpace(string(raw))
+	return trimmed != "" && trimmed != "null"
+}
+
 type ParsedRequest struct {
 	UID     string
 	JsonStr string
@@ -272,6 +302,17 @@ func NewRequestContext(req *admv1.AdmissionRequest) (*RequestContext, *RequestOb
 		UserGroups:     pr.getArrayValue("userInfo.groups"),
 		Type:           pr.getValue("object.type"),
 	}
+	if objectExists(req.Object.Raw) {
+		rc.ObjLabels = claimedMetadata.Labels.jsonString()
+		rc.ObjAnnotations = claimedMetadata.Annotations.jsonString()
+		rc.Object = string(req.Object.Raw)
+	}
+	if objectExists(req.OldObject.Raw) {
+		rc.OldObjLabels = orgMetadata.Labels.jsonString()
+		rc.OldObjAnnotations = orgMetadata.Annotations.jsonString()
+		rc.OldObject = string(req.OldObject.Raw)
+	}
+	rc.objects = NewRequestObjects(rc.Object, rc.OldObject)
 	ro := &RequestObject{
 		RawObject:       req.Object.Raw,
 		RawOldObject:    req.OldObject.Raw,
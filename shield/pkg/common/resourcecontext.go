@@ -39,7 +39,13 @@ type ResourceContext struct {
 	Kind            string          `json:"kind"`
 	ClaimedMetadata *ObjectMetadata `json:"claimedMetadata"`
 	ObjLabels       string          `json:"objLabels"`
+	ObjAnnotations  string          `json:"-"`
 	ObjMetaName     string          `json:"objMetaName"`
+
+	// the resource as JSON string for field conditions in Rule
+	Object string `json:"-"`
+
+	objects *RequestObjects
 }
 
 func (resc *ResourceContext) ResourceRef() *ResourceRef {
@@ -55,12 +61,24 @@ func (resc *ResourceContext) ResourceRef() *ResourceRef {
 	}
 }
 
+// Objects returns the resource for field conditions, which is parsed only once for the resource
+func (resc *ResourceContext) Objects() *RequestObjects {
+	if resc.objects == nil {
+		return NewRequestObjects(resc.Object)
+	}
+	return resc.objects
+}
+
 func (resc *ResourceContext) Map() map[string]string {
 	m := map[string]string{}
 	v := reflect.Indirect(reflect.ValueOf(resc))
 	t := v.Type()
 	for i := 0; i < t.NumField(); i++ {
 		f := v.Field(i)
+		if !f.CanInterface() {
+			// unexported fields (e.g. parsed objects) are not request fields
+			continue
+		}
 		itf := f.Interface()
 		if value, ok := itf.(string); ok {
 			filedName := t.Field(i).Name
@@ -226,9 +244,12 @@ func NewResourceContext(res *unstructured.Unstructured) *ResourceContext {
 		Kind:            kind,
 		Namespace:       namespace,
 		ObjLabels:       labelsStr,
+		ObjAnnotations:  claimedMetadata.Annotations.jsonString(),
 		ObjMetaName:     name,
 		ClaimedMetadata: claimedMetadata,
+		Object:          string(resBytes),
 	}
+	rc.objects = NewRequestObjects(rc.Object)
 	return rc
 
 }
@@ -0,0 +1,107 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+/**********************************************
+
+				Value Constraint
+
+***********************************************/
+
+// ValueConstraint restricts values of attrs which are allowed to be changed (e.g. `spec.replicas` only within 2..20).
+// `Min` and `Max` are numbers or quantities (e.g. `2`, `500m`, `1Gi`), `Enum` is a list of allowed values compared as string,
+// and `Pattern` is a regular expression which must match the whole value. All specified constraints must be satisfied.
+type ValueConstraint struct {
+	Min     string   `json:"min,omitempty"`
+	Max     string   `json:"max,omitempty"`
+	Enum    []string `json:"enum,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+}
+
+// Validate returns an error if min / max is not a quantity, the pattern cannot be compiled or min is greater than max
+func (self *ValueConstraint) Validate() error {
+	var min, max resource.Quantity
+	var err error
+	if self.Min != "" {
+		if min, err = resource.ParseQuantity(self.Min); err != nil {
+			return fmt.Errorf("min `%s` is not a number; %s", self.Min, err.Error())
+		}
+	}
+	if self.Max != "" {
+		if max, err = resource.ParseQuantity(self.Max); err != nil {
+			return fmt.Errorf("max `%s` is not a number; %s", self.Max, err.Error())
+		}
+	}
+	if self.Min != "" && self.Max != "" && min.Cmp(max) > 0 {
+		return fmt.Errorf("min `%s` is greater than max `%s`", self.Min, self.Max)
+	}
+	if self.Pattern != "" {
+		if _, err := regexp.Compile(self.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern `%s`; %s", self.Pattern, err.Error())
+		}
+	}
+	return nil
+}
+
+// Check returns an error if the value does not satisfy the constraint; value is nil if the attr is removed
+func (self *ValueConstraint) Check(value interface{}) error {
+	if value == nil {
+		return fmt.Errorf("removing the value is not allowed")
+	}
+	if _, isMap := value.(map[string]interface{}); isMap {
+		return fmt.Errorf("the value must be a scalar")
+	}
+	if _, isList := value.([]interface{}); isList {
+		return fmt.Errorf("the value must be a scalar")
+	}
+	valueStr := fieldValueString(value)
+
+	if self.Min != "" || self.Max != "" {
+		q, err := resource.ParseQuantity(valueStr)
+		if err != nil {
+			return fmt.Errorf("`%s` is not a number", valueStr)
+		}
+		if self.Min != "" {
+			if min, err := resource.ParseQuantity(self.Min); err != nil || q.Cmp(min) < 0 {
+				return fmt.Errorf("`%s` is less than min `%s`", valueStr, self.Min)
+			}
+		}
+		if self.Max != "" {
+			if max, err := resource.ParseQuantity(self.Max); err != nil || q.Cmp(max) > 0 {
+				return fmt.Errorf("`%s` is greater than max `%s`", valueStr, self.Max)
+			}
+		}
+	}
+	if len(self.Enum) > 0 && !containsAnyValue([]string{valueStr}, self.Enum) {
+		return fmt.Errorf("`%s` is not in [%s]", valueStr, strings.Join(self.Enum, ", "))
+	}
+	if self.Pattern != "" {
+		re, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", self.Pattern))
+		if err != nil || !re.MatchString(valueStr) {
+			return fmt.Errorf("`%s` does not match pattern `%s`", valueStr, self.Pattern)
+		}
+	}
+	return nil
+}
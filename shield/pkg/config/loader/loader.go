@@ -20,6 +20,8 @@ import (
 	"context"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	ecfgclient "github.com/IBM/integrity-enforcer/shield/pkg/client/shieldconfig/clientset/versioned/typed/shieldconfig/v1alpha1"
@@ -35,66 +37,87 @@ const defaultShieldConfigName = "ishield-config"
 type Config struct {
 	ShieldConfig *sconfig.ShieldConfig
 	lastUpdated  time.Time
+	mu           sync.RWMutex
+	loading      int32 // 1 while ShieldConfig is being reloaded
+
+	load func() *sconfig.ShieldConfig
 }
 
 func NewConfig() *Config {
-	config := &Config{}
+	config := &Config{load: loadShieldConfigFromEnv}
 	return config
 }
 
-func (conf *Config) InitShieldConfig() bool {
+// GetShieldConfig returns the current ShieldConfig; this should be used instead of the field when InitShieldConfig() may be called in parallel
+func (conf *Config) GetShieldConfig() *sconfig.ShieldConfig {
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	return conf.ShieldConfig
+}
 
-	renew := false
+// InitShieldConfig reloads ShieldConfig if it is older than the reload interval, and returns true if it is reloaded.
+// ShieldConfig is loaded outside the lock and swapped in, so requests are not blocked by the API call;
+// while one request reloads it, other requests keep using the current one.
+func (conf *Config) InitShieldConfig() bool {
 	t := time.Now()
-	if conf.ShieldConfig != nil {
-
-		interval := 20
-		if s := os.Getenv("SHIELD_CM_RELOAD_SEC"); s != "" {
-			if v, err := strconv.Atoi(s); err != nil {
-				interval = v
-			}
+	conf.mu.RLock()
+	current := conf.ShieldConfig
+	lastUpdated := conf.lastUpdated
+	conf.mu.RUnlock()
+
+	if current != nil {
+		if t.Sub(lastUpdated) <= reloadInterval() {
+			return false
 		}
-
-		duration := t.Sub(conf.lastUpdated)
-		if int(duration.Seconds()) > interval {
-			renew = true
+		if !atomic.CompareAndSwapInt32(&conf.loading, 0, 1) {
+			return false
 		}
-	} else {
-		renew = true
+		defer atomic.StoreInt32(&conf.loading, 0)
 	}
 
-	if renew {
-		shieldNs := os.Getenv("SHIELD_NS")
-		if shieldNs == "" {
-			shieldNs = defaultShieldNS
-		}
-		shieldConfigName := os.Getenv("SHIELD_CONFIG_NAME")
-		if shieldConfigName == "" {
-			shieldConfigName = defaultShieldConfigName
-		}
-		shieldConfig := LoadShieldConfig(shieldNs, shieldConfigName)
-		if shieldConfig == nil {
-			if conf.ShieldConfig == nil {
-				log.Fatal("Failed to initialize ShieldConfig. Exiting...")
-			} else {
-				shieldConfig = conf.ShieldConfig
-				log.Warn("The loaded ShieldConfig is nil, re-use the existing one.")
-			}
+	shieldConfig := conf.load()
+	if shieldConfig == nil {
+		if current == nil {
+			log.Fatal("Failed to initialize ShieldConfig. Exiting...")
+		} else {
+			shieldConfig = current
+			log.Warn("The loaded ShieldConfig is nil, re-use the existing one.")
 		}
+	}
 
-		chartRepo := os.Getenv("CHART_BASE_URL")
-		if chartRepo == "" {
-			chartRepo = ""
-		}
+	if shieldConfig != nil {
+		conf.mu.Lock()
+		conf.ShieldConfig = shieldConfig
+		conf.lastUpdated = t
+		conf.mu.Unlock()
+	}
+	return true
+}
 
-		if shieldConfig != nil {
-			shieldConfig.ChartRepo = chartRepo
-			conf.ShieldConfig = shieldConfig
-			conf.lastUpdated = t
+func reloadInterval() time.Duration {
+	interval := 20
+	if s := os.Getenv("SHIELD_CM_RELOAD_SEC"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			interval = v
 		}
 	}
+	return time.Duration(interval) * time.Second
+}
 
-	return renew
+func loadShieldConfigFromEnv() *sconfig.ShieldConfig {
+	shieldNs := os.Getenv("SHIELD_NS")
+	if shieldNs == "" {
+		shieldNs = defaultShieldNS
+	}
+	shieldConfigName := os.Getenv("SHIELD_CONFIG_NAME")
+	if shieldConfigName == "" {
+		shieldConfigName = defaultShieldConfigName
+	}
+	shieldConfig := LoadShieldConfig(shieldNs, shieldConfigName)
+	if shieldConfig != nil {
+		shieldConfig.ChartRepo = os.Getenv("CHART_BASE_URL")
+	}
+	return shieldConfig
 }
 
 func LoadShieldConfig(namespace, cmname string) *sconfig.ShieldConfig {
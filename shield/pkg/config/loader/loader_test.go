@@ -0,0 +1,73 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package loader
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sconfig "github.com/IBM/integrity-enforcer/shield/pkg/config"
+)
+
+func TestInitShieldConfig(t *testing.T) {
+	var loadCount int32
+	release := make(chan struct{})
+	conf := &Config{load: func() *sconfig.ShieldConfig {
+		if atomic.AddInt32(&loadCount, 1) > 1 {
+			<-release
+		}
+		return &sconfig.ShieldConfig{Namespace: "integrity-shield-operator-system"}
+	}}
+
+	if !conf.InitShieldConfig() || conf.GetShieldConfig() == nil {
+		t.Fatalf("Test failed for InitShieldConfig(); ShieldConfig is not loaded at first")
+	}
+	// fresh ShieldConfig is not reloaded
+	if conf.InitShieldConfig() || atomic.LoadInt32(&loadCount) != 1 {
+		t.Errorf("Test failed for InitShieldConfig(); fresh ShieldConfig is reloaded")
+	}
+
+	// while one request reloads the expired ShieldConfig, other requests are not blocked and use the current one
+	current := conf.GetShieldConfig()
+	conf.mu.Lock()
+	conf.lastUpdated = time.Now().Add(-time.Hour)
+	conf.mu.Unlock()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		conf.InitShieldConfig()
+	}()
+	for atomic.LoadInt32(&loadCount) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		if conf.InitShieldConfig() {
+			t.Errorf("Test failed for InitShieldConfig(); ShieldConfig is reloaded in parallel")
+		}
+		if conf.GetShieldConfig() != current {
+			t.Errorf("Test failed for InitShieldConfig(); the current ShieldConfig should be used while reloading")
+		}
+	}
+	close(release)
+	wg.Wait()
+	if atomic.LoadInt32(&loadCount) != 2 || conf.GetShieldConfig() == current {
+		t.Errorf("Test failed for InitShieldConfig(); expected 2 loads and new ShieldConfig, actual: %d loads", atomic.LoadInt32(&loadCount))
+	}
+}
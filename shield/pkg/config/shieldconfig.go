@@ -17,6 +17,9 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	"github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
 	"github.com/jinzhu/copier"
@@ -34,6 +37,39 @@ type PatchConfig struct {
 	Enabled bool `json:"enabled,omitempty"`
 }
 
+// DryRunFallbackConfig is a setting for server-side dry-run in matching a signed manifest with a request.
+// A signed manifest is defaulted in process first, and dry-run is used only if it does not match after that.
+type DryRunFallbackConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// DefaultMaskDefinitions is a table of fields which are set by controllers or API server after a resource is created,
+// by `<apiGroup>/<kind>` (`<kind>` for the core group). See MaskDefinitionKey().
+// These fields do not match with a signed manifest and are changed without any user operation, so they are masked unless
+// MaskDefinitions in the config replaces them.
+var DefaultMaskDefinitions = map[string][]string{
+	"Service": {
+		"spec.clusterIP",
+		"spec.clusterIPs",
+	},
+	"ServiceAccount": {
+		"secrets",
+		"imagePullSecrets",
+	},
+	"admissionregistration.k8s.io/MutatingWebhookConfiguration": {
+		"webhooks[].clientConfig.caBundle",
+	},
+	"admissionregistration.k8s.io/ValidatingWebhookConfiguration": {
+		"webhooks[].clientConfig.caBundle",
+	},
+	"apiregistration.k8s.io/APIService": {
+		"spec.caBundle",
+	},
+	"apiextensions.k8s.io/CustomResourceDefinition": {
+		"spec.conversion.webhook.clientConfig.caBundle",
+	},
+}
+
 type IShieldResourceCondition struct {
 	OperatorResources      []*common.ResourceRef `json:"operatorResources,omitempty"`
 	ServerResources        []*common.ResourceRef `json:"serverResources,omitempty"`
@@ -44,6 +80,12 @@ type ShieldConfig struct {
 	Patch      *PatchConfig        `json:"patch,omitempty"`
 	Log        *LoggingScopeConfig `json:"log,omitempty"`
 	SideEffect *SideEffectConfig   `json:"sideEffect,omitempty"`
+	Timeout    *TimeoutConfig      `json:"timeout,omitempty"`
+
+	DryRunFallback *DryRunFallbackConfig `json:"dryRunFallback,omitempty"`
+	// MaskDefinitions is a table of fields which are ignored when comparing objects, by `<apiGroup>/<kind>` (`<kind>` for the core group, "*" for all kinds).
+	// An entry replaces the one in DefaultMaskDefinitions for the same kind, so an empty list disables the default masks.
+	MaskDefinitions map[string][]string `json:"maskDefinitions,omitempty"`
 
 	InScopeNamespaceSelector *common.NamespaceSelector `json:"inScopeNamespaceSelector,omitempty"`
 	Allow                    []common.RequestPattern   `json:"allow,omitempty"`
@@ -81,6 +123,10 @@ type LoggingScopeConfig struct {
 	ConsoleLogFile       string          `json:"consoleLogFile,omitempty"`
 	ContextLogFile       string          `json:"contextLogFile,omitempty"`
 	ContextLogRotateSize int64           `json:"contextLogRotateSize,omitempty"`
+	CaptureLog           *LogScopeConfig `json:"captureLog,omitempty"`
+	CaptureLogFile       string          `json:"captureLogFile,omitempty"`
+	CaptureLogRotateSize int64           `json:"captureLogRotateSize,omitempty"`
+	CaptureLogMaxBackups int             `json:"captureLogMaxBackups,omitempty"`
 }
 
 type PluginConfig struct {
@@ -101,16 +147,26 @@ type ImageVerificationConfig struct {
 }
 
 func (self *IShieldResourceCondition) IsOperatorResource(ref *common.ResourceRef) bool {
-	for _, refi := range self.OperatorResources {
-		if refi.EqualsWithoutVersionCheck(ref) {
-			return true
-		}
-	}
-	return false
+	return matchIShieldResourceRefs(self.OperatorResources, ref)
 }
 
 func (self *IShieldResourceCondition) IsServerResource(ref *common.ResourceRef) bool {
-	for _, refi := range self.ServerResources {
+	return matchIShieldResourceRefs(self.ServerResources, ref)
+}
+
+// matchIShieldResourceRefs returns true if the ref is in the list.
+// A ref without name in the list matches all resources of the kind in the namespace (e.g. ClusterResourceSigningProfile).
+func matchIShieldResourceRefs(refs []*common.ResourceRef, ref *common.ResourceRef) bool {
+	if ref == nil {
+		return false
+	}
+	for _, refi := range refs {
+		if refi == nil {
+			continue
+		}
+		if refi.Name == "" && refi.Kind == ref.Kind && refi.Namespace == ref.Namespace {
+			return true
+		}
 		if refi.EqualsWithoutVersionCheck(ref) {
 			return true
 		}
@@ -175,13 +231,16 @@ func (ec *ShieldConfig) PatchEnabled(reqc *common.RequestContext) bool {
 	return ec.Patch.Enabled
 }
 
+// LogConfig returns a copy of the logging config with default values filled in.
+// The ShieldConfig itself is not modified because it is shared by parallel admission requests.
 func (ec *ShieldConfig) LogConfig() *LoggingScopeConfig {
 	conf := ec.Log
 
 	var lc *LoggingScopeConfig
 
 	if conf != nil {
-		lc = conf
+		tmp := *conf
+		lc = &tmp
 	} else {
 		lc = &LoggingScopeConfig{
 			LogLevel:       "info",
@@ -202,10 +261,18 @@ func (ec *ShieldConfig) LogConfig() *LoggingScopeConfig {
 		}
 	}
 
+	if lc.CaptureLog == nil {
+		lc.CaptureLog = &LogScopeConfig{
+			Enabled: false,
+		}
+	}
+
 	defaultFormat := "json"
 	defaultLogOutput := "" // console
 	defaultFilePath := "/ishield-app/public/events.txt"
 	defaultRotateSize := int64(10485760) // 10MB
+	defaultCaptureFilePath := "/ishield-app/public/captured-requests.txt"
+	defaultCaptureMaxBackups := 5
 	if lc.ConsoleLogFormat == "" {
 		lc.ConsoleLogFormat = defaultFormat
 	}
@@ -218,6 +285,15 @@ func (ec *ShieldConfig) LogConfig() *LoggingScopeConfig {
 	if lc.ContextLogRotateSize == 0 {
 		lc.ContextLogRotateSize = defaultRotateSize
 	}
+	if lc.CaptureLogFile == "" {
+		lc.CaptureLogFile = defaultCaptureFilePath
+	}
+	if lc.CaptureLogRotateSize == 0 {
+		lc.CaptureLogRotateSize = defaultRotateSize
+	}
+	if lc.CaptureLogMaxBackups == 0 {
+		lc.CaptureLogMaxBackups = defaultCaptureMaxBackups
+	}
 
 	return lc
 
@@ -243,14 +319,27 @@ func (ec *ShieldConfig) ContextLoggerConfig() logger.ContextLoggerConfig {
 	return logger.ContextLoggerConfig{Enabled: lc.ContextLog.Enabled, File: lc.ContextLogFile, LimitSize: lc.ContextLogRotateSize}
 }
 
+func (ec *ShieldConfig) CaptureLoggerConfig() logger.CaptureLoggerConfig {
+	lc := ec.LogConfig()
+	return logger.CaptureLoggerConfig{Enabled: lc.CaptureLog.Enabled, File: lc.CaptureLogFile, LimitSize: lc.CaptureLogRotateSize, MaxBackups: lc.CaptureLogMaxBackups}
+}
+
 func (ec *ShieldConfig) ConsoleLogEnabled(resc *common.ResourceContext) (bool, string) {
-	enabled, level := ec.Log.ConsoleLog.IsInScope(resc)
-	level = logger.GetGreaterLevel(ec.Log.LogLevel, level)
+	lc := ec.LogConfig()
+	enabled, level := lc.ConsoleLog.IsInScope(resc)
+	level = logger.GetGreaterLevel(lc.LogLevel, level)
 	return enabled, level
 }
 
 func (ec *ShieldConfig) ContextLogEnabled(resc *common.ResourceContext) bool {
-	enabled, _ := ec.Log.ContextLog.IsInScope(resc)
+	lc := ec.LogConfig()
+	enabled, _ := lc.ContextLog.IsInScope(resc)
+	return enabled
+}
+
+func (ec *ShieldConfig) CaptureLogEnabled(resc *common.ResourceContext) bool {
+	lc := ec.LogConfig()
+	enabled, _ := lc.CaptureLog.IsInScope(resc)
 	return enabled
 }
 
@@ -264,6 +353,34 @@ func (ec *ShieldConfig) GetEnabledPlugins() map[string]bool {
 	return plugins
 }
 
+// DryRunFallbackEnabled returns true if dryRunFallback is not configured, in order to keep the behavior of older configs
+func (ec *ShieldConfig) DryRunFallbackEnabled() bool {
+	if ec.DryRunFallback == nil {
+		return true
+	}
+	return ec.DryRunFallback.Enabled
+}
+
+// GetMaskDefinitions returns DefaultMaskDefinitions overridden by MaskDefinitions in the config
+func (ec *ShieldConfig) GetMaskDefinitions() map[string][]string {
+	maskDefs := map[string][]string{}
+	for key, masks := range DefaultMaskDefinitions {
+		maskDefs[key] = append([]string{}, masks...)
+	}
+	for key, masks := range ec.MaskDefinitions {
+		maskDefs[key] = append([]string{}, masks...)
+	}
+	return maskDefs
+}
+
+// MaskDefinitionKey returns the key of the mask definitions for the kind; `<apiGroup>/<kind>`, or `<kind>` for the core group
+func MaskDefinitionKey(apiGroup, kind string) string {
+	if apiGroup == "" {
+		return kind
+	}
+	return fmt.Sprintf("%s/%s", apiGroup, kind)
+}
+
 func (ec *ShieldConfig) SigStoreEnabled() bool {
 	return ec.SigStoreConfig.Enabled
 }
@@ -286,10 +403,12 @@ type SideEffectConfig struct {
 
 	// RSP
 	UpdateRSPStatusForDeniedRequest bool `json:"updateRSPStatusForDeniedRequest"`
+	UpdateRSPInventory              bool `json:"updateRSPInventory,omitempty"`
+	UpdateRSPTargetNamespaces       bool `json:"updateRSPTargetNamespaces,omitempty"`
 }
 
 func (sc *SideEffectConfig) Enabled() bool {
-	return sc.CreateEventEnabled() || sc.UpdateRSPStatusEnabled()
+	return sc.CreateEventEnabled() || sc.UpdateRSPStatusEnabled() || sc.UpdateRSPInventoryEnabled() || sc.UpdateRSPTargetNamespacesEnabled()
 }
 
 func (sc *SideEffectConfig) CreateEventEnabled() bool {
@@ -307,3 +426,85 @@ func (sc *SideEffectConfig) CreateIShieldResourceEventEnabled() bool {
 func (sc *SideEffectConfig) UpdateRSPStatusEnabled() bool {
 	return sc.UpdateRSPStatusForDeniedRequest
 }
+
+func (sc *SideEffectConfig) UpdateRSPInventoryEnabled() bool {
+	return sc.UpdateRSPInventory
+}
+
+func (sc *SideEffectConfig) UpdateRSPTargetNamespacesEnabled() bool {
+	return sc.UpdateRSPTargetNamespaces
+}
+
+/**********************************************
+
+				TimeoutConfig
+
+***********************************************/
+
+const (
+	// should be shorter than the webhook timeout (10s by default)
+	DefaultRequestTimeout           = 8 * time.Second
+	DefaultHelmReleaseSecretTimeout = 3 * time.Second
+	DefaultImageVerificationTimeout = 5 * time.Second
+	DefaultDryRunTimeout            = 3 * time.Second
+)
+
+const (
+	CheckHelmReleaseSecret = "helmReleaseSecret"
+	CheckImageVerification = "imageVerification"
+	CheckDryRun            = "dryRun"
+)
+
+// TimeoutConfig is a set of time budgets in duration format like "3s" or "500ms".
+// A budget of a single check is also limited by the deadline of the whole request.
+type TimeoutConfig struct {
+	Request           string `json:"request,omitempty"`
+	HelmReleaseSecret string `json:"helmReleaseSecret,omitempty"`
+	ImageVerification string `json:"imageVerification,omitempty"`
+	DryRun            string `json:"dryRun,omitempty"`
+}
+
+func (tc *TimeoutConfig) RequestTimeout() time.Duration {
+	if tc == nil {
+		return DefaultRequestTimeout
+	}
+	return parseTimeout(tc.Request, DefaultRequestTimeout)
+}
+
+func (tc *TimeoutConfig) CheckTimeout(checkName string) time.Duration {
+	var budget string
+	var defaultTimeout time.Duration
+	switch checkName {
+	case CheckHelmReleaseSecret:
+		defaultTimeout = DefaultHelmReleaseSecretTimeout
+		if tc != nil {
+			budget = tc.HelmReleaseSecret
+		}
+	case CheckImageVerification:
+		defaultTimeout = DefaultImageVerificationTimeout
+		if tc != nil {
+			budget = tc.ImageVerification
+		}
+	case CheckDryRun:
+		defaultTimeout = DefaultDryRunTimeout
+		if tc != nil {
+			budget = tc.DryRun
+		}
+	default:
+		// unknown check is limited only by the request deadline
+		return 0
+	}
+	return parseTimeout(budget, defaultTimeout)
+}
+
+func parseTimeout(budget string, defaultTimeout time.Duration) time.Duration {
+	if budget == "" {
+		return defaultTimeout
+	}
+	d, err := time.ParseDuration(budget)
+	if err != nil || d <= 0 {
+		logger.Warn("invalid timeout \"", budget, "\" is ignored; use default: ", defaultTimeout.String())
+		return defaultTimeout
+	}
+	return d
+}
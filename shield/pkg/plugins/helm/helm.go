@@ -307,6 +307,11 @@ func MatchReleaseSecret(rls, hrm string) bool {
 }
 
 func FindReleaseSecret(namespace, kind, name string, rawObj []byte) ([]byte, error) {
+	return FindReleaseSecretWithContext(context.Background(), namespace, kind, name, rawObj)
+}
+
+// FindReleaseSecretWithContext is the same as FindReleaseSecret(), but the API request is canceled when the context is done
+func FindReleaseSecretWithContext(ctx context.Context, namespace, kind, name string, rawObj []byte) ([]byte, error) {
 	config, err := kubeutil.GetKubeConfig()
 	if err != nil {
 		return nil, err
@@ -319,7 +324,7 @@ func FindReleaseSecret(namespace, kind, name string, rawObj []byte) ([]byte, err
 		}
 	} else {
 		v1client := v1cli.NewForConfigOrDie(config)
-		rsecList, err := v1client.Secrets(namespace).List(context.Background(), metav1.ListOptions{})
+		rsecList, err := v1client.Secrets(namespace).List(ctx, metav1.ListOptions{})
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,129 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	pgp "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/pgp"
+	x509 "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/x509"
+	ishieldyaml "github.com/IBM/integrity-enforcer/shield/pkg/util/yaml"
+	v1 "k8s.io/api/core/v1"
+)
+
+/**********************************************
+
+				BreakGlassGrant
+
+***********************************************/
+
+// BreakGlassGrant is a BreakGlassRequest whose signature is verified and which is not expired yet
+type BreakGlassGrant struct {
+	Name      string                    `json:"name"`
+	Namespace string                    `json:"namespace"`
+	Signer    string                    `json:"signer"`
+	Request   *common.BreakGlassRequest `json:"request"`
+}
+
+func (self *BreakGlassGrant) String() string {
+	return fmt.Sprintf("%s/%s (signer: %s, expiry: %s)", self.Namespace, self.Name, self.Signer, self.Request.Expiry)
+}
+
+func parseBreakGlassRequest(cm *v1.ConfigMap) (*common.BreakGlassRequest, error) {
+	reqYaml, ok := cm.Data[common.BreakGlassRequestDataKey]
+	if !ok || reqYaml == "" {
+		return nil, fmt.Errorf("`data.%s` is empty", common.BreakGlassRequestDataKey)
+	}
+	var bgReq *common.BreakGlassRequest
+	err := yaml.Unmarshal([]byte(reqYaml), &bgReq)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = bgReq.ExpiryTime(); err != nil {
+		return nil, fmt.Errorf("`expiry` must be RFC3339 format; %s", err.Error())
+	}
+	return bgReq, nil
+}
+
+// verifyBreakGlassRequest checks the signature in the annotations against the request in `data` using break glass signers' keys
+func verifyBreakGlassRequest(cm *v1.ConfigMap, signerConfig *common.SignerConfig, keyPathList []string) (*common.SignerInfo, error) {
+	message := []byte(cm.Data[common.BreakGlassRequestDataKey])
+	annotations := cm.GetAnnotations()
+	signature := []byte(ishieldyaml.Base64decode(annotations[common.SignatureAnnotationKey]))
+	if len(signature) == 0 {
+		return nil, fmt.Errorf("no signature is found in `metadata.annotations`")
+	}
+	certificate := []byte(ishieldyaml.Decompress(ishieldyaml.Base64decode(annotations[common.CertificateAnnotationKey])))
+
+	candidatePubkeys := signerConfig.GetBreakGlassPubkeys(keyPathList)
+	for _, keyPath := range candidatePubkeys[common.SignatureTypePGP] {
+		if ok, signer, _, _ := pgp.Verify(message, signature, nil, keyPath, nil); ok && signer != nil {
+			return signer, nil
+		}
+	}
+	if len(certificate) > 0 {
+		for _, certPath := range candidatePubkeys[common.SignatureTypeX509] {
+			if ok, signer, _, _ := x509.Verify(message, signature, certificate, certPath, nil); ok && signer != nil {
+				return signer, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("failed to verify the signature with the keys of break glass signers")
+}
+
+// getBreakGlassGrants returns only signed and unexpired requests; invalid ones are just ignored
+func getBreakGlassGrants(cms []v1.ConfigMap, signerConfig *common.SignerConfig, keyPathList []string, now time.Time) []*BreakGlassGrant {
+	grants := []*BreakGlassGrant{}
+	if signerConfig == nil || len(signerConfig.BreakGlassSigners) == 0 {
+		return grants
+	}
+	for i := range cms {
+		cm := &cms[i]
+		bgReq, err := parseBreakGlassRequest(cm)
+		if err != nil || bgReq.IsExpired(now) {
+			continue
+		}
+		signer, err := verifyBreakGlassRequest(cm, signerConfig, keyPathList)
+		if err != nil {
+			continue
+		}
+		grants = append(grants, &BreakGlassGrant{
+			Name:      cm.GetName(),
+			Namespace: cm.GetNamespace(),
+			Signer:    signer.GetName(),
+			Request:   bgReq,
+		})
+	}
+	return grants
+}
+
+// findBreakGlassGrant returns the grant which matches with the request; grants are cached, so expiry is checked again here
+func findBreakGlassGrant(reqc *common.RequestContext, grants []*BreakGlassGrant, now time.Time) *BreakGlassGrant {
+	for _, g := range grants {
+		if g.Request.IsExpired(now) {
+			continue
+		}
+		if g.Request.Match(reqc.ResourceScope, reqc.Namespace, reqc.Kind) {
+			return g
+		}
+	}
+	return nil
+}
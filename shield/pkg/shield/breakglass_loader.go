@@ -0,0 +1,129 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	cache "github.com/IBM/integrity-enforcer/shield/pkg/util/cache"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// BreakGlassRequest
+
+type BreakGlassRequestLoader struct {
+	interval        time.Duration
+	shieldNamespace string
+
+	local bool // if true, Data is given in advance and never loaded from cluster
+
+	Client v1client.CoreV1Interface
+	Data   []v1.ConfigMap
+}
+
+func NewBreakGlassRequestLoader(shieldNamespace string) *BreakGlassRequestLoader {
+	interval := time.Second * 10
+	config, _ := kubeutil.GetKubeConfig()
+	client, _ := v1client.NewForConfig(config)
+
+	return &BreakGlassRequestLoader{
+		interval:        interval,
+		shieldNamespace: shieldNamespace,
+		Client:          client,
+	}
+}
+
+func (self *BreakGlassRequestLoader) GetData(doK8sApiCall bool) []v1.ConfigMap {
+	if self.Data == nil && !self.local {
+		self.Load(doK8sApiCall)
+	}
+	return self.Data
+}
+
+func (self *BreakGlassRequestLoader) Load(doK8sApiCall bool) {
+	var err error
+	var list1 *v1.ConfigMapList
+	var keyName string
+
+	keyName = fmt.Sprintf("BreakGlassRequestLoader/%s/list", self.shieldNamespace)
+	if cached := cache.GetString(keyName); cached == "" && doK8sApiCall {
+		labelSelector := fmt.Sprintf("%s=%s", common.BreakGlassRequestLabelKey, common.LabelValueBreakGlassRequested)
+		list1, err = self.Client.ConfigMaps(self.shieldNamespace).List(context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			logger.Error("failed to get BreakGlassRequest:", err)
+			return
+		}
+		logger.Debug("BreakGlassRequest reloaded.")
+		// revoke expired requests here so that this is done at most once per cache interval
+		list1.Items = self.revokeExpired(list1.Items, time.Now().UTC())
+		// cache empty list too, because no BreakGlassRequest exists in most cases
+		tmp, _ := json.Marshal(list1)
+		cache.SetString(keyName, string(tmp), &(self.interval))
+	} else if cached != "" {
+		err = json.Unmarshal([]byte(cached), &list1)
+		if err != nil {
+			logger.Error("failed to Unmarshal cached BreakGlassRequest:", err)
+			return
+		}
+	}
+
+	data := []v1.ConfigMap{}
+	if list1 != nil && len(list1.Items) > 0 {
+		data = list1.Items
+	}
+	self.Data = data
+	return
+}
+
+// revokeExpired updates the label of expired requests so that they are never loaded again, and returns the remaining ones.
+// Requests which cannot be parsed are not revoked (they may be fixed by the requester), but they are never granted.
+func (self *BreakGlassRequestLoader) revokeExpired(items []v1.ConfigMap, now time.Time) []v1.ConfigMap {
+	remaining := []v1.ConfigMap{}
+	for _, item := range items {
+		bgReq, err := parseBreakGlassRequest(&item)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("BreakGlassRequest `%s` is invalid and ignored; %s", item.GetName(), err.Error()))
+			remaining = append(remaining, item)
+			continue
+		}
+		if !bgReq.IsExpired(now) {
+			remaining = append(remaining, item)
+			continue
+		}
+		revoked := item.DeepCopy()
+		revoked.Labels[common.BreakGlassRequestLabelKey] = common.LabelValueBreakGlassRevoked
+		_, err = self.Client.ConfigMaps(self.shieldNamespace).Update(context.Background(), revoked, metav1.UpdateOptions{})
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to revoke BreakGlassRequest `%s`: %s", item.GetName(), err.Error()))
+			continue
+		}
+		logger.Info(fmt.Sprintf("BreakGlassRequest `%s` has been revoked.", item.GetName()))
+	}
+	return remaining
+}
+
+func (self *BreakGlassRequestLoader) ClearCache() {
+	cache.Unset(fmt.Sprintf("BreakGlassRequestLoader/%s/list", self.shieldNamespace))
+}
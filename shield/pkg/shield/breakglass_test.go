@@ -0,0 +1,260 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"golang.org/x/crypto/openpgp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testBreakGlassNamespace = "integrity-shield-operator-system"
+
+var testBreakGlassNow = time.Date(2021, 5, 10, 0, 0, 0, 0, time.UTC)
+
+// testBreakGlassSigner generates a PGP key and writes the public key into `<dir>/<keyConfig>/pgp/pubring.gpg`
+func testBreakGlassSigner(t *testing.T, dir, keyConfig, email string) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity("Break Glass Signer", "", email, nil)
+	if err != nil {
+		t.Fatalf("failed to generate a key; %s", err.Error())
+	}
+	keyPath := filepath.Join(dir, keyConfig, "pgp", "pubring.gpg")
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0755); err != nil {
+		t.Fatalf("failed to create a key directory; %s", err.Error())
+	}
+	var buf bytes.Buffer
+	if err := entity.Serialize(&buf); err != nil {
+		t.Fatalf("failed to serialize a key; %s", err.Error())
+	}
+	if err := os.WriteFile(keyPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write a key; %s", err.Error())
+	}
+	return entity, keyPath
+}
+
+// testBreakGlassRequest returns a BreakGlassRequest ConfigMap; the request is signed by signer if it is not nil
+func testBreakGlassRequest(t *testing.T, name, request string, signer *openpgp.Entity) v1.ConfigMap {
+	cm := v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testBreakGlassNamespace,
+			Labels:    map[string]string{common.BreakGlassRequestLabelKey: common.LabelValueBreakGlassRequested},
+		},
+		Data: map[string]string{common.BreakGlassRequestDataKey: request},
+	}
+	if signer != nil {
+		var sig bytes.Buffer
+		if err := openpgp.ArmoredDetachSign(&sig, signer, strings.NewReader(request), nil); err != nil {
+			t.Fatalf("failed to sign a request; %s", err.Error())
+		}
+		cm.SetAnnotations(map[string]string{common.SignatureAnnotationKey: base64.StdEncoding.EncodeToString(sig.Bytes())})
+	}
+	return cm
+}
+
+func TestBreakGlassRequest(t *testing.T) {
+	dir := t.TempDir()
+	signer, signerKeyPath := testBreakGlassSigner(t, dir, "breakglass-keyring", "admin@enterprise.com")
+	other, otherKeyPath := testBreakGlassSigner(t, dir, "other-keyring", "someone@enterprise.com")
+	keyPathList := []string{signerKeyPath, otherKeyPath}
+	signerConfig := &common.SignerConfig{
+		Signers: []common.SignerCondition{
+			{Name: "admin", KeyConfig: "breakglass-keyring"},
+			{Name: "someone", KeyConfig: "other-keyring"},
+		},
+		BreakGlassSigners: []string{"admin"},
+	}
+
+	validRequest := "scope: Namespaced\nnamespaces:\n- secure-ns\nexpiry: \"2021-05-10T12:00:00Z\"\nreason: incident-1234\n"
+	expiredRequest := "scope: Namespaced\nnamespaces:\n- secure-ns\nexpiry: \"2021-05-09T12:00:00Z\"\n"
+	tampered := testBreakGlassRequest(t, "tampered", validRequest, signer)
+	tampered.Data[common.BreakGlassRequestDataKey] = strings.Replace(validRequest, "secure-ns", "*", 1)
+
+	testCases := []struct {
+		name          string
+		cm            v1.ConfigMap
+		signerConfig  *common.SignerConfig
+		expectedValid bool
+		expectedGrant bool
+	}{
+		{
+			name:          "signed by break glass signer",
+			cm:            testBreakGlassRequest(t, "signed", validRequest, signer),
+			signerConfig:  signerConfig,
+			expectedValid: true,
+			expectedGrant: true,
+		},
+		{
+			name:         "unsigned",
+			cm:           testBreakGlassRequest(t, "unsigned", validRequest, nil),
+			signerConfig: signerConfig,
+		},
+		{
+			name:         "signed by signer who is not a break glass signer",
+			cm:           testBreakGlassRequest(t, "other-signer", validRequest, other),
+			signerConfig: signerConfig,
+		},
+		{
+			name:         "request changed after signing",
+			cm:           tampered,
+			signerConfig: signerConfig,
+		},
+		{
+			// the signature is valid, but the request is not effective
+			name:          "expired",
+			cm:            testBreakGlassRequest(t, "expired", expiredRequest, signer),
+			signerConfig:  signerConfig,
+			expectedValid: true,
+		},
+		{
+			name:          "no break glass signer in SignerConfig",
+			cm:            testBreakGlassRequest(t, "no-signer", validRequest, signer),
+			signerConfig:  &common.SignerConfig{Signers: signerConfig.Signers},
+			expectedValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		signerInfo, err := verifyBreakGlassRequest(&tc.cm, tc.signerConfig, keyPathList)
+		if valid := (err == nil && signerInfo != nil); valid != tc.expectedValid {
+			t.Errorf("[%s] expected valid signature: %v, actual: %v; %v", tc.name, tc.expectedValid, valid, err)
+		} else if valid && signerInfo.GetName() != "admin@enterprise.com" {
+			t.Errorf("[%s] unexpected signer %s", tc.name, signerInfo.GetName())
+		}
+
+		grants := getBreakGlassGrants([]v1.ConfigMap{tc.cm}, tc.signerConfig, keyPathList, testBreakGlassNow)
+		if granted := len(grants) > 0; granted != tc.expectedGrant {
+			t.Errorf("[%s] expected grant: %v, actual: %v", tc.name, tc.expectedGrant, granted)
+		} else if granted && (grants[0].Name != tc.cm.GetName() || grants[0].Signer != "admin@enterprise.com" || grants[0].Request.Reason != "incident-1234") {
+			t.Errorf("[%s] unexpected grant %s", tc.name, grants[0].String())
+		}
+	}
+}
+
+func TestFindBreakGlassGrant(t *testing.T) {
+	grant := func(scope common.ScopeType, namespaces, kinds []string, expiry time.Time) *BreakGlassGrant {
+		return &BreakGlassGrant{Name: "grant", Namespace: testBreakGlassNamespace, Signer: "admin@enterprise.com", Request: &common.BreakGlassRequest{
+			Scope:      scope,
+			Namespaces: namespaces,
+			Kinds:      kinds,
+			Expiry:     expiry.Format(time.RFC3339),
+		}}
+	}
+	now := time.Now().UTC()
+	namespacedReq := &common.RequestContext{ResourceScope: string(common.ScopeNamespaced), Namespace: "secure-ns", Kind: "ConfigMap"}
+	clusterReq := &common.RequestContext{ResourceScope: string(common.ScopeCluster), Kind: "ClusterRole"}
+
+	testCases := []struct {
+		name     string
+		reqc     *common.RequestContext
+		grant    *BreakGlassGrant
+		expected bool
+	}{
+		{
+			name:     "namespace matched",
+			reqc:     namespacedReq,
+			grant:    grant(common.ScopeNamespaced, []string{"secure-*"}, nil, now.Add(time.Hour)),
+			expected: true,
+		},
+		{
+			name:  "namespace not matched",
+			reqc:  namespacedReq,
+			grant: grant(common.ScopeNamespaced, []string{"other-ns"}, nil, now.Add(time.Hour)),
+		},
+		{
+			name:  "kind not matched",
+			reqc:  namespacedReq,
+			grant: grant(common.ScopeNamespaced, []string{"secure-ns"}, []string{"Secret"}, now.Add(time.Hour)),
+		},
+		{
+			name:  "namespaced grant for cluster scope request",
+			reqc:  clusterReq,
+			grant: grant(common.ScopeNamespaced, []string{"*"}, nil, now.Add(time.Hour)),
+		},
+		{
+			name:     "cluster scope",
+			reqc:     clusterReq,
+			grant:    grant(common.ScopeCluster, nil, nil, now.Add(time.Hour)),
+			expected: true,
+		},
+		{
+			// a cached grant may be expired after it is loaded
+			name:  "expired after loaded",
+			reqc:  namespacedReq,
+			grant: grant(common.ScopeNamespaced, []string{"secure-ns"}, nil, now.Add(-time.Minute)),
+		},
+	}
+
+	for _, tc := range testCases {
+		found := findBreakGlassGrant(tc.reqc, []*BreakGlassGrant{tc.grant}, now)
+		if (found != nil) != tc.expected {
+			t.Errorf("[%s] expected grant found: %v, actual: %v", tc.name, tc.expected, found != nil)
+		}
+	}
+}
+
+func TestRevokeExpired(t *testing.T) {
+	validRequest := "scope: Namespaced\nnamespaces:\n- secure-ns\nexpiry: \"2021-05-10T12:00:00Z\"\n"
+	items := []v1.ConfigMap{
+		testBreakGlassRequest(t, "valid", validRequest, nil),
+		testBreakGlassRequest(t, "expired", "scope: Namespaced\nnamespaces:\n- secure-ns\nexpiry: \"2021-05-09T12:00:00Z\"\n", nil),
+		testBreakGlassRequest(t, "invalid-expiry", "scope: Namespaced\nexpiry: tomorrow\n", nil),
+	}
+	client := fake.NewSimpleClientset()
+	for i := range items {
+		if _, err := client.CoreV1().ConfigMaps(testBreakGlassNamespace).Create(context.Background(), &items[i], metav1.CreateOptions{}); err != nil {
+			t.Fatalf("failed to create a ConfigMap; %s", err.Error())
+		}
+	}
+	loader := &BreakGlassRequestLoader{shieldNamespace: testBreakGlassNamespace, Client: client.CoreV1()}
+
+	remaining := loader.revokeExpired(items, testBreakGlassNow)
+	remainingNames := []string{}
+	for _, item := range remaining {
+		remainingNames = append(remainingNames, item.GetName())
+	}
+	// an invalid request is left as it is so that it can be fixed, but it is not granted
+	if strings.Join(remainingNames, ",") != "valid,invalid-expiry" {
+		t.Errorf("Test failed for revokeExpired(); unexpected remaining requests %v", remainingNames)
+	}
+	expectedLabels := map[string]string{
+		"valid":          common.LabelValueBreakGlassRequested,
+		"expired":        common.LabelValueBreakGlassRevoked,
+		"invalid-expiry": common.LabelValueBreakGlassRequested,
+	}
+	for name, expected := range expectedLabels {
+		cm, err := client.CoreV1().ConfigMaps(testBreakGlassNamespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Errorf("failed to get ConfigMap %s; %s", name, err.Error())
+			continue
+		}
+		if label := cm.GetLabels()[common.BreakGlassRequestLabelKey]; label != expected {
+			t.Errorf("Test failed for revokeExpired(); expected label of %s: %s, actual: %s", name, expected, label)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"time"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	admv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const redactedValue = "REDACTED"
+
+// annotations which may contain the data of a Secret
+var secretDataAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	common.MessageAnnotationKey,
+}
+
+/**********************************************
+
+				CapturedRequest
+
+***********************************************/
+
+// CapturedRequest is a sanitized AdmissionReview with its decision, which is written by capture mode.
+// This can be replayed against other RSPs and SignerConfig by `ishieldctl replay`.
+type CapturedRequest struct {
+	Timestamp string                 `json:"timestamp"`
+	Redacted  bool                   `json:"redacted,omitempty"`
+	Reason    string                 `json:"reason,omitempty"`
+	Review    *admv1.AdmissionReview `json:"review"`
+}
+
+func newCapturedRequest(req *admv1.AdmissionRequest, resp *admv1.AdmissionResponse, dr *DecisionResult, reqc *common.RequestContext) *CapturedRequest {
+	capturedReq := req.DeepCopy()
+	redacted := false
+	var redact func([]byte) ([]byte, bool)
+	if reqc.IsSecret() {
+		redact = redactSecret
+	} else if reqc.Kind == common.SignatureCustomResourceKind {
+		// a ResourceSignature can have the signed manifest of a Secret
+		redact = redactResourceSignature
+	}
+	if redact != nil {
+		capturedReq.Object.Raw, redacted = redact(capturedReq.Object.Raw)
+		var oldRedacted bool
+		capturedReq.OldObject.Raw, oldRedacted = redact(capturedReq.OldObject.Raw)
+		redacted = redacted || oldRedacted
+		capturedReq.Object.Object = nil
+		capturedReq.OldObject.Object = nil
+	}
+	capturedResp := &admv1.AdmissionResponse{
+		UID:     resp.UID,
+		Allowed: resp.Allowed,
+		Result:  resp.Result,
+	}
+	return &CapturedRequest{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Redacted:  redacted,
+		Reason:    common.ReasonCodeMap[dr.ReasonCode].Code,
+		Review: &admv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: admv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+			Request:  capturedReq,
+			Response: capturedResp,
+		},
+	}
+}
+
+// redactSecret replaces values of `data` and `stringData` in a Secret with "REDACTED".
+// Keys are kept so that the captured request can be still matched with rules.
+func redactSecret(raw []byte) ([]byte, bool) {
+	if len(raw) == 0 {
+		return raw, false
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		// never write a Secret which cannot be sanitized
+		return nil, true
+	}
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k := range values {
+			values[k] = redactedValue
+		}
+	}
+	redactAnnotations(obj)
+	redactedRaw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, true
+	}
+	return redactedRaw, true
+}
+
+// redactResourceSignature replaces `message` and `signature` of each item in `spec.data` of a ResourceSignature with "REDACTED".
+func redactResourceSignature(raw []byte) ([]byte, bool) {
+	if len(raw) == 0 {
+		return raw, false
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, true
+	}
+	if spec, ok := obj["spec"].(map[string]interface{}); ok {
+		if items, ok := spec["data"].([]interface{}); ok {
+			for _, item := range items {
+				itemMap, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				for _, field := range []string{"message", "signature"} {
+					if _, found := itemMap[field]; found {
+						itemMap[field] = redactedValue
+					}
+				}
+			}
+		}
+	}
+	// last-applied-configuration has the same data
+	redactAnnotations(obj)
+	redactedRaw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, true
+	}
+	return redactedRaw, true
+}
+
+func redactAnnotations(obj map[string]interface{}) {
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			for _, key := range secretDataAnnotations {
+				if _, found := annotations[key]; found {
+					annotations[key] = redactedValue
+				}
+			}
+		}
+	}
+}
+
+func (self *Handler) capture(st *requestState, req *admv1.AdmissionRequest, resp *admv1.AdmissionResponse, dr *DecisionResult) {
+	if !self.config.CaptureLogEnabled(st.resc) {
+		return
+	}
+	captured := newCapturedRequest(req, resp, dr, st.reqc)
+	capturedBytes, err := json.Marshal(captured)
+	if err != nil {
+		st.requestLog.Error(err)
+		return
+	}
+	captureLogger := logger.InitCaptureLogger(self.config.CaptureLoggerConfig())
+	captureLogger.SendLog(capturedBytes)
+}
@@ -0,0 +1,133 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rsigapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	admv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCapture(t *testing.T) {
+	captureFile := filepath.Join(t.TempDir(), "captured-requests.txt")
+
+	expectedAllowed := []bool{}
+	for i := 0; i <= MaxCaseNum; i++ {
+		if skipCaseNum[i] {
+			continue
+		}
+		_, _, _, cfg, data, _, _, _, _ := getTestData(i)
+		cfg.Log.ConsoleLog.Enabled = false
+		cfg.Log.ContextLog.Enabled = false
+		_ = json.Unmarshal([]byte(`{"enabled":true,"inScope":[{"kind":"*"}]}`), &cfg.Log.CaptureLog)
+		cfg.Log.CaptureLogFile = captureFile
+
+		resSigList := []*rsigapi.ResourceSignature{}
+		if data.ResSigList != nil {
+			resSigList = data.ResSigList.Items
+		}
+		localData := &LocalData{RSPList: data.RSPList, NSList: data.NSList, SignerConfig: data.SignerConfig, ResSigList: resSigList}
+
+		var adreq *admv1.AdmissionRequest
+		adreqBytes, _ := ioutil.ReadFile(testFileName(testAdReqFile, i))
+		_ = json.Unmarshal(adreqBytes, &adreq)
+
+		handler := NewHandlerWithLocalData(cfg, logger.NewLogger(cfg.LoggerConfig()), localData)
+		resp, _ := handler.Check(adreq)
+		expectedAllowed = append(expectedAllowed, resp.Allowed)
+	}
+
+	f, err := os.Open(captureFile)
+	if err != nil {
+		t.Fatalf("Test failed for capture; capture log is not written; %s", err.Error())
+	}
+	defer f.Close()
+	captured := []*CapturedRequest{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var c *CapturedRequest
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			t.Fatalf("Test failed for capture; invalid captured request; %s", err.Error())
+		}
+		captured = append(captured, c)
+	}
+	if len(captured) != len(expectedAllowed) {
+		t.Fatalf("Test failed for capture; expected %d captured requests, actual %d", len(expectedAllowed), len(captured))
+	}
+	for i, c := range captured {
+		if c.Review.Request == nil || c.Review.Response == nil || c.Review.Response.Allowed != expectedAllowed[i] {
+			t.Errorf("Test failed for capture; captured request %d does not have the actual decision", i)
+		}
+	}
+}
+
+func TestRedactSecret(t *testing.T) {
+	secret := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","annotations":{"integrityshield.io/message":"c2VjcmV0","integrityshield.io/signature":"sig"}},"data":{"password":"cGFzc3dvcmQ="},"stringData":{"token":"abc"}}`
+	redacted, ok := redactSecret([]byte(secret))
+	if !ok {
+		t.Errorf("Test failed for redactSecret(); Secret is not redacted")
+	}
+	redactedStr := string(redacted)
+	for _, v := range []string{"cGFzc3dvcmQ=", "abc", "c2VjcmV0"} {
+		if strings.Contains(redactedStr, v) {
+			t.Errorf("Test failed for redactSecret(); `%s` remains in %s", v, redactedStr)
+		}
+	}
+	for _, v := range []string{`"password":"REDACTED"`, `"token":"REDACTED"`, `"integrityshield.io/signature":"sig"`} {
+		if !strings.Contains(redactedStr, v) {
+			t.Errorf("Test failed for redactSecret(); `%s` is not found in %s", v, redactedStr)
+		}
+	}
+}
+
+func TestRedactResourceSignature(t *testing.T) {
+	resSig := `{"apiVersion":"apis.integrityshield.io/v1alpha1","kind":"ResourceSignature","metadata":{"name":"rsig-test-secret",` +
+		`"annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"spec\":{\"data\":[{\"message\":\"a2luZDogU2VjcmV0\"}]}}"},` +
+		`"labels":{"integrityshield.io/sigobject-kind":"Secret"}},` +
+		`"spec":{"data":[{"message":"a2luZDogU2VjcmV0","signature":"c2lnbmF0dXJl","type":"resource"}]}}`
+
+	adreq := &admv1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "ResourceSignature"}}
+	adreq.Object.Raw = []byte(resSig)
+	reqc := &common.RequestContext{Kind: "ResourceSignature"}
+	resp := &admv1.AdmissionResponse{Allowed: true}
+	captured := newCapturedRequest(adreq, resp, &DecisionResult{Type: common.DecisionAllow}, reqc)
+	if !captured.Redacted {
+		t.Errorf("Test failed for newCapturedRequest(); ResourceSignature is not redacted")
+	}
+	redactedStr := string(captured.Review.Request.Object.Raw)
+	for _, v := range []string{"a2luZDogU2VjcmV0", "c2lnbmF0dXJl"} {
+		if strings.Contains(redactedStr, v) {
+			t.Errorf("Test failed for newCapturedRequest(); `%s` remains in %s", v, redactedStr)
+		}
+	}
+	for _, v := range []string{`"message":"REDACTED"`, `"signature":"REDACTED"`, `"type":"resource"`, `"integrityshield.io/sigobject-kind":"Secret"`} {
+		if !strings.Contains(redactedStr, v) {
+			t.Errorf("Test failed for newCapturedRequest(); `%s` is not found in %s", v, redactedStr)
+		}
+	}
+}
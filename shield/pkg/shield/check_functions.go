@@ -17,6 +17,7 @@
 package shield
 
 import (
+	"fmt"
 	"strings"
 
 	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
@@ -114,6 +115,21 @@ func formatCheck(reqc *common.RequestContext, reqobj *common.RequestObject, conf
 			Message:    msg,
 		}
 	}
+	// semantic validation of RSP / CRSP
+	if lint := lintProfileRequest(reqc, reqobj, config, data); lint != nil {
+		ctx.Warnings = append(ctx.Warnings, lint.Warnings...)
+		if len(lint.Errors) > 0 {
+			msg := fmt.Sprintf("Semantic validation failed; %s", strings.Join(lint.Errors, "; "))
+			ctx.Allow = false
+			ctx.ReasonCode = common.REASON_VALIDATION_FAIL
+			ctx.Message = msg
+			return &DecisionResult{
+				Type:       common.DecisionDeny,
+				ReasonCode: common.REASON_VALIDATION_FAIL,
+				Message:    msg,
+			}
+		}
+	}
 	return undeterminedDescision()
 }
 
@@ -190,7 +206,7 @@ func protectedCheck(reqc *common.RequestContext, config *config.ShieldConfig, da
 			Message:    common.ReasonCodeMap[common.REASON_NOT_PROTECTED].Message,
 		}, nil
 	}
-	protected, ignoreMatched, matchedProfiles := ruleTable.CheckIfProtected(reqFields)
+	protected, ignoreMatched, matchedProfiles := ruleTable.CheckIfProtected(reqFields, reqc.Objects())
 	if !protected {
 		ctx.Allow = true
 		ctx.Verified = true
@@ -234,7 +250,7 @@ func protectedCheckByResource(resc *common.ResourceContext, config *config.Shiel
 			Message:    common.ReasonCodeMap[common.REASON_NOT_PROTECTED].Message,
 		}, nil
 	}
-	protected, ignoreMatched, matchedProfiles := ruleTable.CheckIfProtected(reqFields)
+	protected, ignoreMatched, matchedProfiles := ruleTable.CheckIfProtected(reqFields, resc.Objects())
 	if !protected {
 		ctx.Allow = true
 		ctx.Verified = true
@@ -271,6 +287,9 @@ func mutationCheck(matchedProfiles []rspapi.ResourceSigningProfile, reqc *common
 			// no mutations are found
 			dr = tmpdr
 			mutationCheckPassedCount += 1
+		} else if tmpdr.isDenied() {
+			// values of changed attrs are not allowed by the profile
+			return tmpdr
 		} else {
 			// mutation is found.
 			break
@@ -288,17 +307,22 @@ func mutationCheck(matchedProfiles []rspapi.ResourceSigningProfile, reqc *common
 
 func mutationCheckWithSingleProfile(singleProfile rspapi.ResourceSigningProfile, reqc *common.RequestContext, reqobj *common.RequestObject, config *config.ShieldConfig, data *RunData, ctx *CheckContext) *DecisionResult {
 	var allowed bool
+	var denied bool
 	var evalMessage string
 	var evalReason int
 	var mutResult *common.MutationEvalResult
 	var err error
 
 	if reqc.IsUpdateRequest() {
-		mutResult, err = NewMutationChecker().Eval(reqc, reqobj, singleProfile)
+		mutResult, err = NewMutationChecker(config.GetMaskDefinitions()).Eval(reqc, reqobj, singleProfile)
 		if err != nil {
 			allowed = false
 			evalMessage = err.Error()
 			evalReason = common.REASON_ERROR
+		} else if len(mutResult.Violations) > 0 {
+			denied = true
+			evalMessage = fmt.Sprintf("%s (%s)", common.ReasonCodeMap[common.REASON_VALUE_CONSTRAINT_VIOLATED].Message, strings.Join(mutResult.Violations, "; "))
+			evalReason = common.REASON_VALUE_CONSTRAINT_VIOLATED
 		}
 		if mutResult.Checked && !mutResult.IsMutated {
 			allowed = true
@@ -322,13 +346,20 @@ func mutationCheckWithSingleProfile(singleProfile rspapi.ResourceSigningProfile,
 			ReasonCode: evalReason,
 			Message:    evalMessage,
 		}
+	} else if denied {
+		return &DecisionResult{
+			Type:       common.DecisionDeny,
+			ReasonCode: evalReason,
+			Message:    evalMessage,
+			denyRSP:    &singleProfile,
+		}
 	} else {
 		// return undetermined DecisionResult to trigger resource checker
 		return undeterminedDescision()
 	}
 }
 
-func signatureCheckWithSingleProfile(singleProfile rspapi.ResourceSigningProfile, resc *common.ResourceContext, config *config.ShieldConfig, data *RunData, ctx *CheckContext) *DecisionResult {
+func signatureCheckWithSingleProfile(singleProfile rspapi.ResourceSigningProfile, resc *common.ResourceContext, reqc *common.RequestContext, config *config.ShieldConfig, data *RunData, ctx *CheckContext) *DecisionResult {
 	var allowed bool
 	var evalMessage string
 	var evalReason int
@@ -341,13 +372,15 @@ func signatureCheckWithSingleProfile(singleProfile rspapi.ResourceSigningProfile
 
 	signerConfig := sigConf.Spec.Config
 	plugins := config.GetEnabledPlugins()
+	reqContext := data.Context()
+	mark := timeoutMark(reqContext)
 	evaluator, err := NewSignatureEvaluator(config, signerConfig, plugins)
 	if err != nil {
 		allowed = false
 		evalMessage = err.Error()
 		evalReason = common.REASON_ERROR
 	} else {
-		sigResult, err = evaluator.Eval(resc, rsigList, singleProfile)
+		sigResult, err = evaluator.Eval(reqContext, resc, reqc, rsigList, singleProfile)
 		if err != nil {
 			allowed = false
 			evalMessage = err.Error()
@@ -377,11 +410,25 @@ func signatureCheckWithSingleProfile(singleProfile rspapi.ResourceSigningProfile
 		}
 	}
 
+	// if some checks timed out, the result is decided by FailurePolicy of this profile
+	if !allowed {
+		if tdr := timeoutDecision(timedOutChecksSince(reqContext, mark), []rspapi.ResourceSigningProfile{singleProfile}); tdr != nil {
+			ctx.Allow = tdr.isAllowed()
+			ctx.ReasonCode = tdr.ReasonCode
+			ctx.Message = tdr.Message
+			if sigResult != nil {
+				ctx.SignatureEvalResult = sigResult
+			}
+			return tdr
+		}
+	}
+
 	ctx.Allow = allowed
 	ctx.ReasonCode = evalReason
 	ctx.Message = evalMessage
 	if sigResult != nil {
 		ctx.SignatureEvalResult = sigResult
+		ctx.Diff = sigResult.Diff
 	}
 
 	if allowed {
@@ -24,6 +24,7 @@ import (
 	"strings"
 	"testing"
 
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	"github.com/IBM/integrity-enforcer/shield/pkg/config"
 )
 
@@ -108,6 +109,63 @@ func testIShieldResourceCheck(t *testing.T, caseNum int) {
 	}
 }
 
+func TestIShieldResourceCheckForClusterProfile(t *testing.T) {
+	// ClusterResourceSigningProfile is registered without name in both lists by operator
+	clusterProfileRef := &common.ResourceRef{Kind: common.ClusterProfileCustomResourceKind}
+	conf := &config.ShieldConfig{
+		IShieldAdminUserGroup: "ishield-admin",
+		IShieldServerUserName: "system:serviceaccount:ishield-ns:ishield-sa",
+		IShieldResourceCondition: &config.IShieldResourceCondition{
+			OperatorResources: []*common.ResourceRef{clusterProfileRef},
+			ServerResources:   []*common.ResourceRef{clusterProfileRef},
+		},
+	}
+	testCases := []struct {
+		name       string
+		kind       string
+		userName   string
+		userGroups []string
+		expected   *DecisionResult
+	}{
+		{
+			name:       "admin",
+			kind:       common.ClusterProfileCustomResourceKind,
+			userName:   "admin-user",
+			userGroups: []string{"ishield-admin"},
+			expected:   &DecisionResult{Type: common.DecisionAllow, Verified: true, ReasonCode: common.REASON_ISHIELD_ADMIN, Message: common.ReasonCodeMap[common.REASON_ISHIELD_ADMIN].Message},
+		},
+		{
+			name:     "server",
+			kind:     common.ClusterProfileCustomResourceKind,
+			userName: "system:serviceaccount:ishield-ns:ishield-sa",
+			expected: &DecisionResult{Type: common.DecisionAllow, Verified: true, ReasonCode: common.REASON_ISHIELD_ADMIN, Message: common.ReasonCodeMap[common.REASON_ISHIELD_ADMIN].Message},
+		},
+		{
+			name:       "other user",
+			kind:       common.ClusterProfileCustomResourceKind,
+			userName:   "sample-user",
+			userGroups: []string{"system:authenticated"},
+			expected:   &DecisionResult{Type: common.DecisionDeny, ReasonCode: common.REASON_BLOCK_ISHIELD_RESOURCE_OPERATION, Message: common.ReasonCodeMap[common.REASON_BLOCK_ISHIELD_RESOURCE_OPERATION].Message},
+		},
+		{
+			name:       "not ishield resource",
+			kind:       "ClusterRole",
+			userName:   "sample-user",
+			userGroups: []string{"system:authenticated"},
+			expected:   undeterminedDescision(),
+		},
+	}
+	for _, tc := range testCases {
+		reqc := &common.RequestContext{Kind: tc.kind, Name: "sample-crsp", UserName: tc.userName, UserGroups: tc.userGroups}
+		actualDr := iShieldResourceCheck(reqc, conf, nil, &CheckContext{})
+		if !reflect.DeepEqual(actualDr, tc.expected) {
+			actDrBytes, _ := json.Marshal(actualDr)
+			expDrBytes, _ := json.Marshal(tc.expected)
+			t.Errorf("[%s] Test failed for iShieldResourceCheck()\nexpected:\n  %s\nactual:\n  %s", tc.name, string(expDrBytes), string(actDrBytes))
+		}
+	}
+}
+
 func testDeleteCheck(t *testing.T, caseNum int) {
 	reqc, _, _, config, data, ctx, expectedDr, _, _ := getTestData(caseNum)
 	actualDr := deleteCheck(reqc, config, data, ctx)
@@ -161,7 +219,7 @@ func testSingleSignatureCheck(t *testing.T, caseNum int) {
 	if strings.Contains(expectedDr.Message, "no mutation") {
 		return
 	}
-	actualDr := signatureCheckWithSingleProfile(prof, resc, config, data, ctx)
+	actualDr := signatureCheckWithSingleProfile(prof, resc, nil, config, data, ctx)
 	actualDr.denyRSP = nil // `denyRSP` is an unexported field. this must be ignored when checking equivalent
 
 	if !reflect.DeepEqual(actualDr, expectedDr) {
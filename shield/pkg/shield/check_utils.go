@@ -24,8 +24,10 @@ import (
 
 	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
 	sigconfapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
+	crspclient "github.com/IBM/integrity-enforcer/shield/pkg/client/clusterresourcesigningprofile/clientset/versioned/typed/clusterresourcesigningprofile/v1alpha1"
 	rspclient "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesigningprofile/clientset/versioned/typed/resourcesigningprofile/v1alpha1"
 	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
 
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
@@ -33,6 +35,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 func createAdmissionResponse(allowed bool, msg string, reqc *common.RequestContext, reqobj *common.RequestObject, ctx *CheckContext, conf *config.ShieldConfig) *admv1.AdmissionResponse {
@@ -53,6 +56,9 @@ func createAdmissionResponse(allowed bool, msg string, reqc *common.RequestConte
 		resp.Patch = patchBytes
 		resp.PatchType = &patchType
 	}
+	if ctx != nil && len(ctx.Warnings) > 0 {
+		resp.Warnings = ctx.Warnings
+	}
 	return resp
 }
 
@@ -72,6 +78,10 @@ func createOrUpdateEvent(reqc *common.RequestContext, ctx *CheckContext, sconfig
 		resultStr = "allow"
 		eventResult = common.EventResultValueAllow
 	}
+	if ctx.BreakGlassRequest != "" {
+		resultStr = "breakglass"
+		eventResult = common.EventResultValueBreakGlass
+	}
 
 	sourceName := "IntegrityShield"
 	evtName := fmt.Sprintf("ishield-%s-%s-%s-%s", resultStr, strings.ToLower(reqc.Operation), strings.ToLower(reqc.Kind), reqc.Name)
@@ -118,7 +128,9 @@ func createOrUpdateEvent(reqc *common.RequestContext, ctx *CheckContext, sconfig
 	}
 
 	rspInfo := ""
-	if denyRSP != nil {
+	if denyRSP != nil && denyRSP.Kind == common.ClusterProfileCustomResourceKind {
+		rspInfo = fmt.Sprintf(" (%s `name: %s`)", common.ClusterProfileCustomResourceKind, denyRSP.GetName())
+	} else if denyRSP != nil {
 		rspInfo = fmt.Sprintf(" (RSP `namespace: %s, name: %s`)", denyRSP.GetNamespace(), denyRSP.GetName())
 	}
 	responseMessage := fmt.Sprintf("Result: %s, Reason: \"%s\"%s, Request: %s", resultStr, ctx.Message, rspInfo, reqc.Info(nil))
@@ -144,7 +156,21 @@ func createOrUpdateEvent(reqc *common.RequestContext, ctx *CheckContext, sconfig
 	return nil
 }
 
-func updateRSPStatus(rsp *rspapi.ResourceSigningProfile, reqc *common.RequestContext, errMsg string) error {
+func updateRSPStatus(rsp *rspapi.ResourceSigningProfile, reqc *common.RequestContext, errMsg string, diff []*mapnode.FieldDiff) error {
+	return updateRSPStatusWith(rsp, func(rspOrg *rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile {
+		req := common.NewRequestFromReqContext(reqc)
+		return rspOrg.UpdateStatus(req, errMsg, diff)
+	})
+}
+
+func updateRSPBreakGlassStatus(rsp *rspapi.ResourceSigningProfile, reqc *common.RequestContext, msg string) error {
+	return updateRSPStatusWith(rsp, func(rspOrg *rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile {
+		req := common.NewRequestFromReqContext(reqc)
+		return rspOrg.UpdateBreakGlassStatus(req, msg)
+	})
+}
+
+func updateRSPStatusWith(rsp *rspapi.ResourceSigningProfile, update func(*rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile) error {
 	if rsp == nil {
 		return nil
 	}
@@ -153,6 +179,9 @@ func updateRSPStatus(rsp *rspapi.ResourceSigningProfile, reqc *common.RequestCon
 	if err != nil {
 		return err
 	}
+	if rsp.Kind == common.ClusterProfileCustomResourceKind {
+		return updateCRSPStatusWith(config, rsp.GetName(), update)
+	}
 	client, err := rspclient.NewForConfig(config)
 	if err != nil {
 		return err
@@ -165,8 +194,11 @@ func updateRSPStatus(rsp *rspapi.ResourceSigningProfile, reqc *common.RequestCon
 		return err
 	}
 
-	req := common.NewRequestFromReqContext(reqc)
-	rspNew := rspOrg.UpdateStatus(req, errMsg)
+	rspNew := update(rspOrg)
+	// nothing to update
+	if rspNew == nil {
+		return nil
+	}
 
 	_, err = client.ResourceSigningProfiles(rspNamespace).Update(context.Background(), rspNew, metav1.UpdateOptions{})
 	if err != nil {
@@ -175,6 +207,32 @@ func updateRSPStatus(rsp *rspapi.ResourceSigningProfile, reqc *common.RequestCon
 	return nil
 }
 
+// updateCRSPStatusWith updates status of ClusterResourceSigningProfile, which is handled as RSP in RuleTable
+func updateCRSPStatusWith(config *rest.Config, name string, update func(*rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile) error {
+	client, err := crspclient.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	crspOrg, err := client.ClusterResourceSigningProfiles().Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	rspOrg := crspOrg.ToResourceSigningProfile()
+	rspNew := update(&rspOrg)
+	// nothing to update
+	if rspNew == nil {
+		return nil
+	}
+
+	crspOrg.Status = rspNew.Status
+	_, err = client.ClusterResourceSigningProfiles().Update(context.Background(), crspOrg, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func checkIfProfileTargetNamespace(reqNamespace, shieldNamespace string, data *RunData) bool {
 	ruleTable := data.GetRuleTable(shieldNamespace)
 	if ruleTable == nil {
@@ -197,7 +255,7 @@ func checkIfDryRunAdmission(reqc *common.RequestContext) bool {
 
 func checkIfUnprocessedInIShield(reqFeilds map[string]string, config *config.ShieldConfig) bool {
 	for _, d := range config.Ignore {
-		if d.Match(reqFeilds) {
+		if d.IgnoreMatch(reqFeilds) {
 			return true
 		}
 	}
@@ -313,6 +371,11 @@ func checkIfBreakGlassEnabled(reqc *common.RequestContext, signerConfig *sigconf
 	return breakGlassEnabled
 }
 
+func checkIfBreakGlassGranted(reqc *common.RequestContext, sconf *config.ShieldConfig, data *RunData) *BreakGlassGrant {
+	grants := data.GetBreakGlassGrants(sconf.KeyPathList)
+	return findBreakGlassGrant(reqc, grants, time.Now().UTC())
+}
+
 func checkIfDetectOnly(sconf *config.ShieldConfig) bool {
 	return (sconf.Mode == config.DetectMode)
 }
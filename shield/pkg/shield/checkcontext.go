@@ -23,6 +23,7 @@ import (
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
 	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
 )
 
 /**********************************************
@@ -34,6 +35,7 @@ import (
 type CheckContext struct {
 	DetectOnlyModeEnabled bool   `json:"detectOnly"`
 	BreakGlassModeEnabled bool   `json:"breakGlass"`
+	BreakGlassRequest     string `json:"breakGlassRequest"`
 	IgnoredSA             bool   `json:"ignoredSA"`
 	Protected             bool   `json:"protected"`
 	IShieldResource       bool   `json:"iShieldResource"`
@@ -47,7 +49,13 @@ type CheckContext struct {
 	SignatureEvalResult *common.SignatureEvalResult `json:"signature"`
 	MutationEvalResult  *common.MutationEvalResult  `json:"mutation"`
 
+	// `Diff` is the differences between the signed manifest and the requested object when the request is denied for them
+	Diff []*mapnode.FieldDiff `json:"diff,omitempty"`
+
 	ReasonCode int `json:"reasonCode"`
+
+	// `Warnings` are returned to the client as admission warnings
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 func InitCheckContext(config *config.ShieldConfig) *CheckContext {
@@ -99,6 +107,7 @@ func (self *CheckContext) convertToLogRecord(reqc *common.RequestContext, lggr *
 		"abortReason":     self.AbortReason,
 		"msg":             self.Message,
 		"breakglass":      self.BreakGlassModeEnabled,
+		"breakglassReq":   self.BreakGlassRequest,
 		"detectOnly":      self.DetectOnlyModeEnabled,
 
 		//reason code
@@ -131,6 +140,10 @@ func (self *CheckContext) convertToLogRecord(reqc *common.RequestContext, lggr *
 			logRecord["sig.errOccured"] = false
 		}
 	}
+	if len(self.Diff) > 0 {
+		logRecord["sig.diff"] = self.Diff
+		logRecord["sig.unifiedDiff"] = mapnode.UnifiedDiff(self.Diff)
+	}
 
 	//context from mutation eval
 	if self.MutationEvalResult != nil {
@@ -0,0 +1,101 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	crspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/clusterresourcesigningprofile/v1alpha1"
+	crspclient "github.com/IBM/integrity-enforcer/shield/pkg/client/clusterresourcesigningprofile/clientset/versioned/typed/clusterresourcesigningprofile/v1alpha1"
+	cache "github.com/IBM/integrity-enforcer/shield/pkg/util/cache"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterResourceSigningProfile
+
+type CRSPLoader struct {
+	defaultProfileInterval time.Duration
+
+	local bool // if true, Data is given in advance and never loaded from cluster
+
+	Client *crspclient.ApisV1alpha1Client
+	Data   []crspapi.ClusterResourceSigningProfile
+}
+
+func NewCRSPLoader() *CRSPLoader {
+	defaultProfileInterval := time.Second * 60
+	config, _ := kubeutil.GetKubeConfig()
+	client, _ := crspclient.NewForConfig(config)
+
+	return &CRSPLoader{
+		defaultProfileInterval: defaultProfileInterval,
+		Client:                 client,
+	}
+}
+
+func (self *CRSPLoader) GetData(doK8sApiCall bool) ([]crspapi.ClusterResourceSigningProfile, bool) {
+	reloaded := false
+	if len(self.Data) == 0 && !self.local {
+		reloaded = self.Load(doK8sApiCall)
+	}
+	return self.Data, reloaded
+}
+
+func (self *CRSPLoader) Load(doK8sApiCall bool) bool {
+	var err error
+	var list1 *crspapi.ClusterResourceSigningProfileList
+	var keyName string
+	reloaded := false
+
+	keyName = "CRSPLoader/list"
+	if cached := cache.GetString(keyName); cached == "" && doK8sApiCall {
+		list1, err = self.Client.ClusterResourceSigningProfiles().List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			logger.Error("failed to get ClusterResourceSigningProfile:", err)
+			return false
+		}
+		reloaded = true
+		logger.Debug("ClusterResourceSigningProfile reloaded.")
+		if len(list1.Items) > 0 {
+			tmp, _ := json.Marshal(list1)
+			cache.SetString(keyName, string(tmp), &(self.defaultProfileInterval))
+		}
+	} else if cached != "" {
+		err = json.Unmarshal([]byte(cached), &list1)
+		if err != nil {
+			logger.Error("failed to Unmarshal cached ClusterResourceSigningProfile:", err)
+			return false
+		}
+	}
+	data := []crspapi.ClusterResourceSigningProfile{}
+	if list1 != nil {
+		for _, d := range list1.Items {
+			data = append(data, d)
+		}
+	}
+	self.Data = data
+	return reloaded
+}
+
+func (self *CRSPLoader) ClearCache() {
+	cache.Unset("CRSPLoader/list")
+}
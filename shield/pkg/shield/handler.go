@@ -17,6 +17,7 @@
 package shield
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -37,14 +38,25 @@ import (
 
 ***********************************************/
 
+// Handler can be shared by parallel admission requests.
+// All per-request state is kept in requestState which is passed explicitly,
+// and shared data (RuleTable, SignerConfig) is read from immutable snapshots in SharedData.
 type Handler struct {
-	config        *config.ShieldConfig
+	config       *config.ShieldConfig
+	serverLogger *logger.Logger
+	shared       *SharedData
+	localData    *LocalData // if set, resources are not loaded from cluster and no side effect is made
+}
+
+type requestState struct {
+	reqContext    context.Context
+	cancel        context.CancelFunc
 	ctx           *CheckContext
 	reqc          *common.RequestContext
 	reqobj        *common.RequestObject
 	resc          *common.ResourceContext
 	data          *RunData
-	serverLogger  *logger.Logger
+	reqLogger     *logger.Logger
 	requestLog    *log.Entry
 	contextLogger *logger.ContextLogger
 	logInScope    bool
@@ -53,87 +65,120 @@ type Handler struct {
 }
 
 func NewHandler(config *config.ShieldConfig, metaLogger *logger.Logger) *Handler {
-	return &Handler{config: config, data: &RunData{}, serverLogger: metaLogger}
+	return NewHandlerWithSharedData(config, metaLogger, defaultSharedData)
+}
+
+func NewHandlerWithSharedData(config *config.ShieldConfig, metaLogger *logger.Logger, shared *SharedData) *Handler {
+	return &Handler{config: config, serverLogger: metaLogger, shared: shared}
+}
+
+// NewHandlerWithLocalData returns Handler which runs checks offline with LocalData instead of resources in cluster
+func NewHandlerWithLocalData(config *config.ShieldConfig, metaLogger *logger.Logger, localData *LocalData) *Handler {
+	handler := NewHandlerWithSharedData(config, metaLogger, NewSharedData())
+	handler.localData = localData
+	return handler
 }
 
 func (self *Handler) Run(req *admv1.AdmissionRequest) *admv1.AdmissionResponse {
+	resp, _ := self.Check(req)
+	return resp
+}
+
+// Check runs the whole admission pipeline, and returns DecisionResult along with AdmissionResponse
+func (self *Handler) Check(req *admv1.AdmissionRequest) (*admv1.AdmissionResponse, *DecisionResult) {
 
 	// init ctx, reqc and data & init logger
-	self.initialize(req)
+	st := self.initialize(req)
+	defer st.cancel()
 
 	// make DecisionResult based on reqc, config and data
-	dr := self.Check()
+	dr := self.check(st)
+	checked := *dr
 
 	// overwrite DecisionResult if needed (DetectMode & BreakGlass)
-	dr = self.overwriteDecision(dr)
+	dr = self.overwriteDecision(st, dr)
 
 	// make AdmissionResponse based on DecisionResult
 	resp := &admv1.AdmissionResponse{}
 
 	if dr.isUndetermined() {
-		resp = createAdmissionResponse(false, "IntegrityShield failed to decide the response for this request", self.reqc, self.reqobj, self.ctx, self.config)
+		resp = createAdmissionResponse(false, "IntegrityShield failed to decide the response for this request", st.reqc, st.reqobj, st.ctx, self.config)
 	} else if dr.isErrorOccurred() {
-		resp = createAdmissionResponse(false, dr.Message, self.reqc, self.reqobj, self.ctx, self.config)
+		resp = createAdmissionResponse(false, dr.Message, st.reqc, st.reqobj, st.ctx, self.config)
 	} else {
-		resp = createAdmissionResponse(dr.isAllowed(), dr.Message, self.reqc, self.reqobj, self.ctx, self.config)
+		resp = createAdmissionResponse(dr.isAllowed(), dr.Message, st.reqc, st.reqobj, st.ctx, self.config)
 	}
 
 	// log results
-	self.logResponse(req, dr)
-	self.logContext()
+	self.logResponse(st, req, dr)
+	self.logContext(st)
+	self.capture(st, req, resp, dr)
 
 	// create Event & update RSP status
-	_ = self.Report(dr.denyRSP)
+	_ = self.report(st, dr.denyRSP)
+
+	// update verification inventory in RSP status
+	self.updateInventory(st, &checked, dr)
 
 	// clear some cache if needed
-	self.finalize(dr)
+	self.finalize(st, dr)
 
-	return resp
+	return resp, dr
 }
 
-func (self *Handler) Check() *DecisionResult {
+func (self *Handler) check(st *requestState) *DecisionResult {
 	var dr *DecisionResult
 	dr = undeterminedDescision()
 
-	dr = ishieldScopeCheck(self.reqc, self.config, self.data, self.ctx)
+	dr = ishieldScopeCheck(st.reqc, self.config, st.data, st.ctx)
 	if !dr.isUndetermined() {
 		return dr
 	}
-	self.logInScope = true
+	st.logInScope = true
 
-	dr = formatCheck(self.reqc, self.reqobj, self.config, self.data, self.ctx)
+	dr = formatCheck(st.reqc, st.reqobj, self.config, st.data, st.ctx)
 	if !dr.isUndetermined() {
 
 		return dr
 	}
 
-	dr = iShieldResourceCheck(self.reqc, self.config, self.data, self.ctx)
+	dr = iShieldResourceCheck(st.reqc, self.config, st.data, st.ctx)
 	if !dr.isUndetermined() {
 		return dr
 	}
 
-	dr = deleteCheck(self.reqc, self.config, self.data, self.ctx)
+	dr = deleteCheck(st.reqc, self.config, st.data, st.ctx)
 	if !dr.isUndetermined() {
 		return dr
 	}
 
 	var matchedProfiles []rspapi.ResourceSigningProfile
-	dr, matchedProfiles = protectedCheck(self.reqc, self.config, self.data, self.ctx)
+	dr, matchedProfiles = protectedCheck(st.reqc, self.config, st.data, st.ctx)
 	if !dr.isUndetermined() {
 		return dr
 	}
 
-	dr = mutationCheck(matchedProfiles, self.reqc, self.reqobj, self.config, self.data, self.ctx)
+	dr = mutationCheck(matchedProfiles, st.reqc, st.reqobj, self.config, st.data, st.ctx)
 	if !dr.isUndetermined() {
 		return dr
 	}
 
 	var obj *unstructured.Unstructured
-	_ = json.Unmarshal(self.reqobj.RawObject, &obj)
+	_ = json.Unmarshal(st.reqobj.RawObject, &obj)
 	// For the case that RawObject does not have metadata.namespace
-	obj.SetNamespace(self.reqc.Namespace)
+	obj.SetNamespace(st.reqc.Namespace)
+
+	dr = st.resHandler.RunWithRequest(obj, st.reqc)
 
-	dr = self.resHandler.Run(obj)
+	// if the request deadline is exceeded before deciding the result, it is decided by FailurePolicy of matched profiles
+	if dr.isUndetermined() || dr.isErrorOccurred() {
+		if tdr := timeoutDecision(timedOutChecksSince(st.reqContext, 0), matchedProfiles); tdr != nil {
+			st.ctx.Allow = tdr.isAllowed()
+			st.ctx.ReasonCode = tdr.ReasonCode
+			st.ctx.Message = tdr.Message
+			dr = tdr
+		}
+	}
 
 	if dr.isUndetermined() {
 		dr = &DecisionResult{
@@ -145,14 +190,25 @@ func (self *Handler) Check() *DecisionResult {
 	return dr
 }
 
-func (self *Handler) Report(denyRSP *rspapi.ResourceSigningProfile) error {
+func (self *Handler) report(st *requestState, denyRSP *rspapi.ResourceSigningProfile) error {
+	// nothing is reported to cluster when running offline
+	if self.localData != nil {
+		return nil
+	}
+
 	// report only for denying request or for IShield resource request by IShield Admin
 	shouldReport := false
-	if !self.ctx.Allow && self.config.SideEffect.CreateDenyEventEnabled() {
+	if !st.ctx.Allow && self.config.SideEffect.CreateDenyEventEnabled() {
+		shouldReport = true
+	}
+	iShieldAdmin := checkIfIShieldAdminRequest(st.reqc, self.config)
+	if st.ctx.IShieldResource && !iShieldAdmin && self.config.SideEffect.CreateIShieldResourceEventEnabled() {
 		shouldReport = true
 	}
-	iShieldAdmin := checkIfIShieldAdminRequest(self.reqc, self.config)
-	if self.ctx.IShieldResource && !iShieldAdmin && self.config.SideEffect.CreateIShieldResourceEventEnabled() {
+
+	// every use of BreakGlassRequest is recorded
+	usedBreakGlassRequest := (st.ctx.BreakGlassRequest != "")
+	if usedBreakGlassRequest {
 		shouldReport = true
 	}
 
@@ -163,18 +219,22 @@ func (self *Handler) Report(denyRSP *rspapi.ResourceSigningProfile) error {
 	var err error
 	// create/update Event
 	if self.config.SideEffect.CreateEventEnabled() {
-		err = createOrUpdateEvent(self.reqc, self.ctx, self.config, denyRSP)
+		err = createOrUpdateEvent(st.reqc, st.ctx, self.config, denyRSP)
 		if err != nil {
-			self.requestLog.Error("Failed to create event; ", err)
+			st.requestLog.Error("Failed to create event; ", err)
 			return err
 		}
 	}
 
 	// update RSP status
 	if self.config.SideEffect.UpdateRSPStatusEnabled() {
-		err = updateRSPStatus(denyRSP, self.reqc, self.ctx.Message)
+		if usedBreakGlassRequest {
+			err = updateRSPBreakGlassStatus(denyRSP, st.reqc, st.ctx.Message)
+		} else {
+			err = updateRSPStatus(denyRSP, st.reqc, st.ctx.Message, st.ctx.Diff)
+		}
 		if err != nil {
-			self.requestLog.Error("Failed to update status; ", err)
+			st.requestLog.Error("Failed to update status; ", err)
 		}
 	}
 
@@ -182,9 +242,20 @@ func (self *Handler) Report(denyRSP *rspapi.ResourceSigningProfile) error {
 }
 
 // load resoruces / set default values
-func (self *Handler) initialize(req *admv1.AdmissionRequest) *DecisionResult {
+func (self *Handler) initialize(req *admv1.AdmissionRequest) *requestState {
+	st := &requestState{}
+
+	// context for this request; every check must be done before its deadline
+	st.reqContext, st.cancel = newRequestContext(self.config.Timeout)
+
+	// logger for this request only; its log level can be changed without affecting other requests
+	st.reqLogger = logger.NewLogger(self.config.LoggerConfig())
+	if self.serverLogger != nil {
+		st.reqLogger.SetLevel(self.serverLogger.GetLevel())
+	}
+
 	gv := metav1.GroupVersion{Group: req.Kind.Group, Version: req.Kind.Version}
-	self.requestLog = self.serverLogger.WithFields(
+	st.requestLog = st.reqLogger.WithFields(
 		log.Fields{
 			"namespace":  req.Namespace,
 			"name":       req.Name,
@@ -196,125 +267,144 @@ func (self *Handler) initialize(req *admv1.AdmissionRequest) *DecisionResult {
 	)
 
 	// init CheckContext
-	self.ctx = InitCheckContext(self.config)
+	st.ctx = InitCheckContext(self.config)
+
+	// init RunData for this request
+	st.data = NewRunDataWithSharedData(self.shared)
+	st.data.reqContext = st.reqContext
 
 	// init resource handler with shared CheckContext
-	self.resHandler = NewResourceCheckHandlerWithContext(self.config, self.serverLogger, self.ctx, self.data)
+	st.resHandler = NewResourceCheckHandlerWithContext(self.config, st.reqLogger, st.ctx, st.data)
 
 	reqNamespace := getRequestNamespace(req)
 
 	// init RequestContext & RequestObject
-	self.reqc, self.reqobj = common.NewRequestContext(req)
+	st.reqc, st.reqobj = common.NewRequestContext(req)
 
 	// init ResourceContext
-	self.resc = common.AdmissionRequestToResourceContext(req)
+	st.resc = common.AdmissionRequestToResourceContext(req)
 
 	// Note: logEntry() calls ShieldConfig.ConsoleLogEnabled() internally, and this requires ResourceContext.
-	self.logEntry()
+	self.logEntry(st)
 
-	runDataLoader := NewLoader(self.config, reqNamespace)
-	self.data.loader = runDataLoader
-	self.data.Init(self.config)
+	var runDataLoader *Loader
+	if self.localData != nil {
+		runDataLoader = NewLocalLoader(self.config, reqNamespace, self.localData)
+	} else {
+		runDataLoader = NewLoader(self.config, reqNamespace)
+	}
+	st.data.loader = runDataLoader
+	st.data.Init(self.config)
 
-	return &DecisionResult{Type: common.DecisionUndetermined}
+	return st
 }
 
-func (self *Handler) overwriteDecision(dr *DecisionResult) *DecisionResult {
-	sigConf := self.data.GetSignerConfig()
-	isBreakGlass := checkIfBreakGlassEnabled(self.reqc, sigConf)
+func (self *Handler) overwriteDecision(st *requestState, dr *DecisionResult) *DecisionResult {
+	sigConf := st.data.GetSignerConfig()
+	isBreakGlass := checkIfBreakGlassEnabled(st.reqc, sigConf)
 	isDetectMode := checkIfDetectOnly(self.config)
 
+	// signed BreakGlassRequest is checked only for a request which is going to be denied
+	var bgGrant *BreakGlassGrant
+	if !isBreakGlass && !isDetectMode && !dr.isAllowed() {
+		bgGrant = checkIfBreakGlassGranted(st.reqc, self.config, st.data)
+		isBreakGlass = (bgGrant != nil)
+	}
+
 	if !isBreakGlass && !isDetectMode {
 		return dr
 	}
 
 	if !dr.isAllowed() && isDetectMode {
-		self.ctx.Allow = true
-		self.ctx.DetectOnlyModeEnabled = true
-		self.ctx.ReasonCode = common.REASON_DETECTION
-		self.ctx.Message = common.ReasonCodeMap[common.REASON_DETECTION].Message
+		st.ctx.Allow = true
+		st.ctx.DetectOnlyModeEnabled = true
+		st.ctx.ReasonCode = common.REASON_DETECTION
+		st.ctx.Message = common.ReasonCodeMap[common.REASON_DETECTION].Message
 		dr.Type = common.DecisionAllow
 		dr.Verified = false
 		dr.Message = common.ReasonCodeMap[common.REASON_DETECTION].Message
 		dr.ReasonCode = common.REASON_DETECTION
 	} else if !dr.isAllowed() && isBreakGlass {
-		self.ctx.Allow = true
-		self.ctx.BreakGlassModeEnabled = true
-		self.ctx.ReasonCode = common.REASON_BREAK_GLASS
-		self.ctx.Message = common.ReasonCodeMap[common.REASON_BREAK_GLASS].Message
+		msg := common.ReasonCodeMap[common.REASON_BREAK_GLASS].Message
+		if bgGrant != nil {
+			st.ctx.BreakGlassRequest = bgGrant.String()
+			msg = fmt.Sprintf("%s (BreakGlassRequest: %s, original reason: %s)", msg, bgGrant.String(), dr.Message)
+		}
+		st.ctx.Allow = true
+		st.ctx.BreakGlassModeEnabled = true
+		st.ctx.ReasonCode = common.REASON_BREAK_GLASS
+		st.ctx.Message = msg
 		dr.Type = common.DecisionAllow
 		dr.Verified = false
-		dr.Message = common.ReasonCodeMap[common.REASON_BREAK_GLASS].Message
+		dr.Message = msg
 		dr.ReasonCode = common.REASON_BREAK_GLASS
 	}
 	return dr
 }
 
-func (self *Handler) finalize(dr *DecisionResult) {
+func (self *Handler) finalize(st *requestState, dr *DecisionResult) {
 	if dr.isAllowed() {
 		resetRuleTableCache := false
-		iShieldServer := checkIfIShieldServerRequest(self.reqc, self.config)
-		iShieldOperator := checkIfIShieldOperatorRequest(self.reqc, self.config)
-		if self.reqc.Kind == "Namespace" {
-			if self.reqc.IsUpdateRequest() {
-				mtResult, _ := MutationCheck(self.reqc, self.reqobj)
+		iShieldServer := checkIfIShieldServerRequest(st.reqc, self.config)
+		iShieldOperator := checkIfIShieldOperatorRequest(st.reqc, self.config)
+		if st.reqc.Kind == "Namespace" {
+			if st.reqc.IsUpdateRequest() {
+				mtResult, _ := MutationCheck(st.reqc, st.reqobj, self.config.GetMaskDefinitions())
 				if mtResult != nil && mtResult.IsMutated {
 					resetRuleTableCache = true
 				}
 			} else {
 				resetRuleTableCache = true
 			}
-		} else if self.reqc.Kind == common.ProfileCustomResourceKind && !iShieldServer && !iShieldOperator {
+		} else if (st.reqc.Kind == common.ProfileCustomResourceKind || st.reqc.Kind == common.ClusterProfileCustomResourceKind) && !iShieldServer && !iShieldOperator {
 			resetRuleTableCache = true
 		}
 		if resetRuleTableCache {
 			// if namespace/RSP request is allowed, then reset cache for RuleTable (RSP list & NS list).
-			self.data.resetRuleTableCache()
+			st.data.resetRuleTableCache()
 		}
 	}
-	self.logExit()
+	self.logExit(st)
 	return
 }
 
-func (self *Handler) logEntry() {
-	if ok, levelStr := self.config.ConsoleLogEnabled(self.resc); ok {
-		logger.SetSingletonLoggerLevel(levelStr) // change singleton logger level; this might be overwritten by parallel handler instance
+func (self *Handler) logEntry(st *requestState) {
+	if ok, levelStr := self.config.ConsoleLogEnabled(st.resc); ok {
 		lvl, _ := log.ParseLevel(levelStr)
-		self.serverLogger.SetLevel(lvl) // set custom log level for this request
-		self.requestLog.Trace("New Admission Request Received")
+		st.reqLogger.SetLevel(lvl) // set custom log level for this request
+		st.requestLog.Trace("New Admission Request Received")
 	}
 }
 
-func (self *Handler) logContext() {
-	if self.config.ContextLogEnabled(self.resc) && self.logInScope {
-		self.contextLogger = logger.InitContextLogger(self.config.ContextLoggerConfig())
-		logRecord := self.ctx.convertToLogRecord(self.reqc, self.serverLogger)
-		if self.config.Log.IncludeRequest && !self.reqc.IsSecret() {
-			logRecord["request.dump"] = self.reqc.RequestJsonStr
+func (self *Handler) logContext(st *requestState) {
+	if self.config.ContextLogEnabled(st.resc) && st.logInScope {
+		st.contextLogger = logger.InitContextLogger(self.config.ContextLoggerConfig())
+		logRecord := st.ctx.convertToLogRecord(st.reqc, st.reqLogger)
+		if self.config.Log.IncludeRequest && !st.reqc.IsSecret() {
+			logRecord["request.dump"] = st.reqc.RequestJsonStr
 		}
 		logBytes, err := json.Marshal(logRecord)
 		if err != nil {
-			self.requestLog.Error(err)
+			st.requestLog.Error(err)
 			logBytes = []byte("")
 		}
-		if self.reqc.ResourceScope == "Namespaced" || (self.reqc.ResourceScope == "Cluster" && self.ctx.Protected) {
-			self.contextLogger.SendLog(logBytes)
+		if st.reqc.ResourceScope == "Namespaced" || (st.reqc.ResourceScope == "Cluster" && st.ctx.Protected) {
+			st.contextLogger.SendLog(logBytes)
 		}
 	}
 }
 
-func (self *Handler) logExit() {
-	if ok, _ := self.config.ConsoleLogEnabled(self.resc); ok {
-		logger.SetSingletonLoggerLevel(self.config.Log.LogLevel)
-		self.requestLog.WithFields(log.Fields{
-			"allowed":    self.ctx.Allow,
-			"aborted":    self.ctx.Aborted,
-			"requestUID": self.reqc.RequestUid,
+func (self *Handler) logExit(st *requestState) {
+	if ok, _ := self.config.ConsoleLogEnabled(st.resc); ok {
+		st.requestLog.WithFields(log.Fields{
+			"allowed":    st.ctx.Allow,
+			"aborted":    st.ctx.Aborted,
+			"requestUID": st.reqc.RequestUid,
 		}).Trace("New Admission Request Sent")
 	}
 }
 
-func (self *Handler) logResponse(req *admv1.AdmissionRequest, dr *DecisionResult) {
+func (self *Handler) logResponse(st *requestState, req *admv1.AdmissionRequest, dr *DecisionResult) {
 	if self.config.Log.LogAllResponse {
 		respData := map[string]interface{}{}
 		respData["allowed"] = dr.isAllowed()
@@ -326,10 +416,10 @@ func (self *Handler) logResponse(req *admv1.AdmissionRequest, dr *DecisionResult
 		// respData["patch"] = resp.Patch
 		respDataBytes, err := json.Marshal(respData)
 		if err != nil {
-			self.requestLog.Error(err.Error())
+			st.requestLog.Error(err.Error())
 			return
 		}
-		self.requestLog.Trace(fmt.Sprintf("[AdmissionResponse] %s", string(respDataBytes)))
+		st.requestLog.Trace(fmt.Sprintf("[AdmissionResponse] %s", string(respDataBytes)))
 	}
 	return
 }
@@ -0,0 +1,106 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	admv1 "k8s.io/api/admission/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	concurrentWorkers    = 8
+	concurrentIterations = 20
+)
+
+// TestHandlerConcurrency fires admission requests at a single Handler in parallel.
+// Run this with `-race` to detect data races in the request pipeline.
+func TestHandlerConcurrency(t *testing.T) {
+	// all API calls fail immediately; RuleTable and SignerConfig are served from the snapshots below
+	kubeutil.SetKubeConfig(&rest.Config{Host: "http://127.0.0.1:1", Timeout: time.Second})
+
+	caseNums := []int{}
+	adreqBytesMap := map[int][]byte{}
+	for i := 0; i <= MaxCaseNum; i++ {
+		if skipCaseNum[i] {
+			continue
+		}
+		adreqBytes, err := ioutil.ReadFile(testFileName(testAdReqFile, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		caseNums = append(caseNums, i)
+		adreqBytesMap[i] = adreqBytes
+	}
+
+	_, _, _, cfg, data, _, _, _, _ := getTestData(0)
+	cfg.Log.ConsoleLog.Enabled = false
+	cfg.Log.ContextLog.Enabled = false
+	cfg.SideEffect.CreateDenyEvent = false
+	cfg.SideEffect.CreateIShieldResourceEvent = false
+	cfg.SideEffect.UpdateRSPStatusForDeniedRequest = false
+
+	shared := NewSharedData()
+	shared.StoreRuleTable(NewRuleTableSnapshot(data.RSPList, data.NSList, cfg.CommonProfile, cfg.Namespace, time.Hour))
+	shared.StoreSignerConfig(NewSignerConfigSnapshot(data.SignerConfig, nil, time.Hour))
+
+	metaLogger := logger.NewLogger(cfg.LoggerConfig())
+	handler := NewHandlerWithSharedData(cfg, metaLogger, shared)
+
+	// results of sequential runs are the expected ones
+	expected := map[int]*admv1.AdmissionResponse{}
+	for _, num := range caseNums {
+		expected[num] = handler.Run(loadTestAdmissionRequest(adreqBytesMap[num]))
+	}
+
+	wg := &sync.WaitGroup{}
+	errCh := make(chan string, concurrentWorkers*concurrentIterations)
+	for w := 0; w < concurrentWorkers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < concurrentIterations; i++ {
+				num := caseNums[(worker+i)%len(caseNums)]
+				resp := handler.Run(loadTestAdmissionRequest(adreqBytesMap[num]))
+				exp := expected[num]
+				if resp.Allowed != exp.Allowed || resp.Result.Message != exp.Result.Message {
+					errCh <- "[Case " + strconv.Itoa(num) + "] expected: " + exp.Result.Message + ", actual: " + resp.Result.Message
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errCh)
+	for msg := range errCh {
+		t.Errorf("Test failed for concurrent Handler.Run(); %s", msg)
+	}
+}
+
+// every run gets its own AdmissionRequest, as the webhook server does
+func loadTestAdmissionRequest(adreqBytes []byte) *admv1.AdmissionRequest {
+	var adreq *admv1.AdmissionRequest
+	_ = json.Unmarshal(adreqBytes, &adreq)
+	return adreq
+}
@@ -66,7 +66,7 @@ type ImageCheckProfile struct {
 	CosignExperimental bool   `json:"cosignExperimental"`
 }
 
-func requestCheckForImageCheck(resc *common.ResourceContext) (bool, *SigCheckImages, string) {
+func requestCheckForImageCheck(ctx context.Context, resc *common.ResourceContext) (bool, *SigCheckImages, string) {
 	// return needsigcheck, image, msg
 	// scope check
 	inscope := filterByKind(resc.Kind)
@@ -83,7 +83,7 @@ func requestCheckForImageCheck(resc *common.ResourceContext) (bool, *SigCheckIma
 		return false, nil, "no image referenced: fail to get podspec"
 	}
 	images := getImages(podspec.Containers)
-	imagesToVerify, msg := getImageProfile(resc.Namespace, images)
+	imagesToVerify, msg := getImageProfile(ctx, resc.Namespace, images)
 	if len(imagesToVerify) == 0 {
 		return false, nil, msg
 	}
@@ -143,10 +143,10 @@ func contains(s []string, e string) bool {
 	return false
 }
 
-func getImageProfile(namespace string, images []string) ([]ImageToVerify, string) {
+func getImageProfile(ctx context.Context, namespace string, images []string) ([]ImageToVerify, string) {
 	var imagesToVerify []ImageToVerify
 	// load image profile
-	ip, err := getConfigmapProfile(namespace, "image-profile-cm")
+	ip, err := getConfigmapProfile(ctx, namespace, "image-profile-cm")
 	if err != nil {
 		return imagesToVerify, "fail to load image profile"
 	}
@@ -169,20 +169,7 @@ func getImageProfile(namespace string, images []string) ([]ImageToVerify, string
 
 func isMatchImage(pattern, value string) bool {
 	// profileImage, givenImage
-	pattern = strings.TrimSpace(pattern)
-	if pattern == "" {
-		return true
-	} else if pattern == "*" {
-		return true
-	} else if pattern == "-" && value == "" {
-		return true
-	} else if strings.HasSuffix(pattern, "*") {
-		return strings.HasPrefix(value, strings.TrimRight(pattern, "*"))
-	} else if pattern == value {
-		return true
-	} else {
-		return false
-	}
+	return common.MatchPattern(pattern, value)
 }
 
 func filterByKind(resource string) bool {
@@ -200,11 +187,11 @@ func getImages(containers []corev1.Container) []string {
 	return images
 }
 
-func getConfigmapProfile(namespace, profileName string) (*ImageCheckProfile, error) {
+func getConfigmapProfile(ctx context.Context, namespace, profileName string) (*ImageCheckProfile, error) {
 	// Retrieve secret
 	config, _ := kubeutil.GetKubeConfig()
 	c, _ := corev1client.NewForConfig(config)
-	cm, err := c.ConfigMaps(namespace).Get(context.Background(), profileName, metav1.GetOptions{})
+	cm, err := c.ConfigMaps(namespace).Get(ctx, profileName, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -227,7 +214,7 @@ func getConfigmapProfile(namespace, profileName string) (*ImageCheckProfile, err
 		imageProfile.Image = image
 		return &imageProfile, nil
 	}
-	return nil, fmt.Errorf("no image is defined in image profile configmap", namespace)
+	return nil, fmt.Errorf("no image is defined in image profile configmap in namespace %s", namespace)
 }
 
 func getPodSpec(rawObj []byte, group, version, kind string) (*corev1.PodSpec, error) {
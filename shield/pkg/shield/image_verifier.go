@@ -42,7 +42,7 @@ type PublicKey interface {
 	signature.PublicKeyProvider
 }
 
-func (sci *SigCheckImages) imageSignatureCheck() {
+func (sci *SigCheckImages) imageSignatureCheck(ctx context.Context) {
 	for i, img := range sci.ImagesToVerify {
 		var res ImageVerifyResult
 		co := &cosign.CheckOpts{
@@ -55,7 +55,7 @@ func (sci *SigCheckImages) imageSignatureCheck() {
 			if img.Profile.KeyNamespace == "" {
 				break
 			} else {
-				co.PubKey, _ = loadPubKey(img.Profile.Key, img.Profile.KeyNamespace)
+				co.PubKey, _ = loadPubKey(ctx, img.Profile.Key, img.Profile.KeyNamespace)
 			}
 		}
 
@@ -66,8 +66,14 @@ func (sci *SigCheckImages) imageSignatureCheck() {
 			sci.ImagesToVerify[i] = img
 			continue
 		}
-		verified, err := cosign.Verify(context.Background(), ref, co)
-		if err != nil {
+		verified, err := cosign.Verify(ctx, ref, co)
+		if err != nil && ctx.Err() != nil {
+			// the time budget for image verification is exceeded; cannot decide whether the image is signed or not
+			res.Error = fmt.Errorf("image verification is canceled; %s", ctx.Err().Error())
+			img.Result = res
+			sci.ImagesToVerify[i] = img
+			continue
+		} else if err != nil {
 			//  cosign verify err
 			res.Allowed = false
 			res.Reason = "no valid signature for this image; " + err.Error()
@@ -106,10 +112,10 @@ func (sci *SigCheckImages) imageSignatureCheck() {
 	}
 }
 
-func loadPubKey(keyname, namespace string) (PublicKey, error) {
+func loadPubKey(ctx context.Context, keyname, namespace string) (PublicKey, error) {
 	config, _ := kubeutil.GetKubeConfig()
 	c, _ := corev1client.NewForConfig(config)
-	secret, err := c.Secrets(namespace).Get(context.Background(), keyname, metav1.GetOptions{})
+	secret, err := c.Secrets(namespace).Get(ctx, keyname, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,458 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/retry"
+)
+
+const defaultInventoryFlushInterval = time.Second * 5
+
+// the number of resources in a page when listing existing resources for inventory
+const inventoryListLimit = 500
+
+/**********************************************
+
+				Verification Inventory
+
+***********************************************/
+
+// inventoryState returns the verification state of a resource from the reason code of the checks.
+// false is returned if the checks did not verify the resource (e.g. no mutation, error, timeout).
+func inventoryState(reasonCode int) (rspapi.VerificationState, bool) {
+	switch reasonCode {
+	case common.REASON_VALID_SIG:
+		return rspapi.VerificationStateVerified, true
+	case common.REASON_VERIFIED_OWNER, common.REASON_VERIFIED_SA, common.REASON_UPDATE_BY_SA:
+		return rspapi.VerificationStateAllowedByServiceAccount, true
+	case common.REASON_NO_SIG:
+		return rspapi.VerificationStateMissingSignature, true
+	case common.REASON_INVALID_SIG, common.REASON_NOT_VERIFIED, common.REASON_NO_VALID_KEYRING, common.REASON_NO_MATCH_SIGNER_CONFIG, common.REASON_INVALID_SIG_IMAGE, common.REASON_NO_MATCH_IMAGE_PROFILE:
+		return rspapi.VerificationStateUnverified, true
+	}
+	return "", false
+}
+
+func newProtectedResourceStatus(reqc *common.RequestContext) *rspapi.ProtectedResourceStatus {
+	return &rspapi.ProtectedResourceStatus{
+		ApiGroup:   reqc.ApiGroup,
+		ApiVersion: reqc.ApiVersion,
+		Kind:       reqc.Kind,
+		Namespace:  reqc.Namespace,
+		Name:       reqc.Name,
+	}
+}
+
+func inventoryContains(rsp rspapi.ResourceSigningProfile, resource *rspapi.ProtectedResourceStatus) bool {
+	for _, r := range rsp.Status.ProtectedResources {
+		if r.ApiGroup == resource.ApiGroup && r.Kind == resource.Kind && r.Namespace == resource.Namespace && r.Name == resource.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// updateInventory queues the verification state of a protected resource for the status of matched profiles,
+// and InventoryWorker writes it asynchronously so that the request does not wait for API calls.
+// The inventory is updated only when the request is allowed, so that it reflects resources in cluster.
+// `checked` is the decision before DetectMode / BreakGlass overwrites it.
+func (self *Handler) updateInventory(st *requestState, checked, dr *DecisionResult) {
+	// nothing is reported to cluster when running offline
+	if self.localData != nil {
+		return
+	}
+	if !self.config.SideEffect.UpdateRSPInventoryEnabled() {
+		return
+	}
+	if !dr.isAllowed() || st.reqc.DryRun {
+		return
+	}
+	ruleTable := st.data.GetRuleTable(self.config.Namespace)
+	if ruleTable == nil {
+		return
+	}
+	resource := newProtectedResourceStatus(st.reqc)
+
+	if st.reqc.IsDeleteRequest() {
+		// DELETE is not checked with rules, so remove the resource from every profile which lists it
+		for _, item := range ruleTable.Items {
+			if inventoryContains(item.Profile, resource) {
+				self.shared.inventory.Add(item.Profile, resource, true)
+			}
+		}
+		return
+	}
+
+	state, ok := inventoryState(checked.ReasonCode)
+	if !ok {
+		return
+	}
+	resource.State = state
+	resource.Reason = common.ReasonCodeMap[checked.ReasonCode].Code
+	if state == rspapi.VerificationStateVerified && st.ctx.SignatureEvalResult != nil {
+		resource.Signer = st.ctx.SignatureEvalResult.GetSignerName()
+	}
+
+	protected, _, matchedProfiles := ruleTable.CheckIfProtected(st.reqc.Map(), st.reqc.Objects())
+	if !protected {
+		return
+	}
+	for i := range matchedProfiles {
+		self.shared.inventory.Add(matchedProfiles[i], resource, false)
+	}
+}
+
+/**********************************************
+
+				InventoryQueue
+
+***********************************************/
+
+// inventoryUpdate is a queued change of a resource in the inventory; the resource is removed if remove is true
+type inventoryUpdate struct {
+	resource *rspapi.ProtectedResourceStatus
+	remove   bool
+}
+
+// InventoryQueue holds inventory updates by parallel admission requests until InventoryWorker writes them into status.
+// Updates for the same resource in a profile are coalesced into the latest one. Nothing is queued until the worker is started.
+type InventoryQueue struct {
+	mu       sync.Mutex
+	enabled  bool
+	profiles map[string]rspapi.ResourceSigningProfile
+	updates  map[string]map[string]*inventoryUpdate
+}
+
+func newInventoryQueue() *InventoryQueue {
+	return &InventoryQueue{
+		profiles: map[string]rspapi.ResourceSigningProfile{},
+		updates:  map[string]map[string]*inventoryUpdate{},
+	}
+}
+
+func (self *InventoryQueue) enable() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.enabled = true
+}
+
+// Add queues an update (or removal) of the resource in the inventory of the profile
+func (self *InventoryQueue) Add(profile rspapi.ResourceSigningProfile, resource *rspapi.ProtectedResourceStatus, remove bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if !self.enabled {
+		return
+	}
+	profileKey := inventoryProfileKey(profile)
+	if _, ok := self.updates[profileKey]; !ok {
+		self.updates[profileKey] = map[string]*inventoryUpdate{}
+	}
+	self.profiles[profileKey] = profile
+	self.updates[profileKey][inventoryResourceKey(resource)] = &inventoryUpdate{resource: resource, remove: remove}
+}
+
+// drain returns all queued updates by profile, and clears the queue
+func (self *InventoryQueue) drain() (map[string]rspapi.ResourceSigningProfile, map[string]map[string]*inventoryUpdate) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	profiles, updates := self.profiles, self.updates
+	self.profiles = map[string]rspapi.ResourceSigningProfile{}
+	self.updates = map[string]map[string]*inventoryUpdate{}
+	return profiles, updates
+}
+
+func inventoryProfileKey(profile rspapi.ResourceSigningProfile) string {
+	return fmt.Sprintf("%s/%s/%s", profile.Kind, profile.GetNamespace(), profile.GetName())
+}
+
+func inventoryResourceKey(resource *rspapi.ProtectedResourceStatus) string {
+	return fmt.Sprintf("%s/%s/%s/%s", resource.ApiGroup, resource.Kind, resource.Namespace, resource.Name)
+}
+
+// applyInventoryUpdates applies the updates to the profile, and returns nil if nothing is changed
+func applyInventoryUpdates(rsp *rspapi.ResourceSigningProfile, updates map[string]*inventoryUpdate) *rspapi.ResourceSigningProfile {
+	changed := false
+	for _, u := range updates {
+		if u.remove {
+			if inventoryContains(*rsp, u.resource) {
+				rsp = rsp.RemoveFromInventory(u.resource)
+				changed = true
+			}
+			continue
+		}
+		rsp = rsp.UpdateInventory(u.resource)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return rsp
+}
+
+/**********************************************
+
+				InventoryWorker
+
+***********************************************/
+
+// InventoryWorker writes the queued inventory updates into status of profiles in batches, one update per profile.
+// When started, it also seeds the inventory by listing existing resources protected by profiles,
+// because resources which are not changed after IShield is installed never pass through the webhook.
+// Only the kinds and namespaces which protect rules can match are listed, page by page.
+type InventoryWorker struct {
+	shared    *SharedData
+	getConfig func() *config.ShieldConfig
+	interval  time.Duration
+
+	updateStatus    func(rsp *rspapi.ResourceSigningProfile, update func(*rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile) error
+	getAPIResources func() ([]metav1.APIResource, error)
+	listResources   func(apiResource metav1.APIResource, namespace string, handle func([]unstructured.Unstructured) error) error
+	checkResource   func(conf *config.ShieldConfig, obj *unstructured.Unstructured) *DecisionResult
+}
+
+func NewInventoryWorker(getConfig func() *config.ShieldConfig) *InventoryWorker {
+	return newInventoryWorker(defaultSharedData, getConfig)
+}
+
+func newInventoryWorker(shared *SharedData, getConfig func() *config.ShieldConfig) *InventoryWorker {
+	return &InventoryWorker{
+		shared:          shared,
+		getConfig:       getConfig,
+		interval:        defaultInventoryFlushInterval,
+		updateStatus:    updateRSPStatusWith,
+		getAPIResources: kubeutil.GetAPIResources,
+		listResources:   listResourcesForInventory,
+		checkResource:   checkExistingResource,
+	}
+}
+
+// Start seeds the inventory and runs the worker which writes queued updates until stopCh is closed
+func (self *InventoryWorker) Start(stopCh <-chan struct{}) {
+	self.shared.inventory.enable()
+	go self.run(stopCh)
+	logger.Info("Inventory worker has been started.")
+}
+
+func (self *InventoryWorker) run(stopCh <-chan struct{}) {
+	if self.enabled() {
+		self.seed()
+	}
+	ticker := time.NewTicker(self.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			self.flush()
+			return
+		case <-ticker.C:
+			self.flush()
+		}
+	}
+}
+
+func (self *InventoryWorker) enabled() bool {
+	conf := self.getConfig()
+	return conf != nil && conf.SideEffect != nil && conf.SideEffect.UpdateRSPInventoryEnabled()
+}
+
+// flush writes all queued updates; the status of a profile is read again and updated when it conflicts with another update
+func (self *InventoryWorker) flush() {
+	profiles, updates := self.shared.inventory.drain()
+	for profileKey, profileUpdates := range updates {
+		profile := profiles[profileKey]
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			return self.updateStatus(&profile, func(rspOrg *rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile {
+				return applyInventoryUpdates(rspOrg, profileUpdates)
+			})
+		})
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to update inventory in status of %s; %s", profileKey, err.Error()))
+		}
+	}
+}
+
+// seed queues existing resources which are protected by profiles but not in their inventory yet, with the result of ResourceCheckHandler,
+// and removes resources which no longer exist from the inventory
+func (self *InventoryWorker) seed() {
+	conf := self.getConfig()
+	snapshot := self.shared.loadRuleTable()
+	if !snapshot.isValid(conf.CommonProfile, conf.Namespace) {
+		snapshot = self.shared.GetRuleTable(NewLoader(conf, ""), conf.CommonProfile, conf.Namespace)
+	}
+	if snapshot == nil || snapshot.RuleTable == nil || snapshot.RuleTable.IsEmpty() {
+		return
+	}
+	ruleTable := snapshot.RuleTable
+	apiResources, err := self.getAPIResources()
+	if err != nil {
+		logger.Error("Failed to get API resources for inventory; ", err)
+		return
+	}
+
+	existing := map[string]bool{}
+	// kinds and namespaces which are listed successfully
+	listed := map[string]bool{}
+	for _, target := range inventoryTargets(ruleTable, apiResources) {
+		err := self.listResources(target.apiResource, target.namespace, func(objs []unstructured.Unstructured) error {
+			for i := range objs {
+				self.seedResource(conf, ruleTable, &objs[i], existing)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Debug(fmt.Sprintf("Failed to list %s in namespace `%s` for inventory; %s", target.apiResource.Name, target.namespace, err.Error()))
+			continue
+		}
+		listed[inventoryListKey(target.apiResource.Group, target.apiResource.Kind, target.namespace)] = true
+	}
+
+	for _, item := range ruleTable.Items {
+		for _, r := range item.Profile.Status.ProtectedResources {
+			// resources which could not be listed (e.g. no RBAC) are kept
+			if !listed[inventoryListKey(r.ApiGroup, r.Kind, r.Namespace)] || existing[inventoryResourceKey(r)] {
+				continue
+			}
+			self.shared.inventory.Add(item.Profile, r, true)
+		}
+	}
+	logger.Debug(fmt.Sprintf("Inventory has been seeded with %d existing resources", len(existing)))
+}
+
+// seedResource queues the resource for the profiles which protect it but do not have it in their inventory yet
+func (self *InventoryWorker) seedResource(conf *config.ShieldConfig, ruleTable *RuleTable, obj *unstructured.Unstructured, existing map[string]bool) {
+	resc := common.NewResourceContext(obj)
+	protected, _, matchedProfiles := ruleTable.CheckIfProtected(resc.Map(), resc.Objects())
+	if !protected {
+		return
+	}
+	resource := &rspapi.ProtectedResourceStatus{
+		ApiGroup:   resc.ApiGroup,
+		ApiVersion: resc.ApiVersion,
+		Kind:       resc.Kind,
+		Namespace:  resc.Namespace,
+		Name:       resc.Name,
+	}
+	existing[inventoryResourceKey(resource)] = true
+
+	var checked *DecisionResult
+	for _, profile := range matchedProfiles {
+		if inventoryContains(profile, resource) {
+			continue
+		}
+		// the resource is checked only once even if it is protected by multiple profiles
+		if checked == nil {
+			checked = self.checkResource(conf, obj)
+		}
+		state, ok := inventoryState(checked.ReasonCode)
+		if !ok {
+			return
+		}
+		seeded := *resource
+		seeded.State = state
+		seeded.Reason = common.ReasonCodeMap[checked.ReasonCode].Code
+		self.shared.inventory.Add(profile, &seeded, false)
+	}
+}
+
+func inventoryListKey(apiGroup, kind, namespace string) string {
+	return fmt.Sprintf("%s/%s/%s", apiGroup, kind, namespace)
+}
+
+// inventoryTarget is a kind in a namespace which is listed for inventory; namespace is empty for cluster-scope kinds
+type inventoryTarget struct {
+	apiResource metav1.APIResource
+	namespace   string
+}
+
+// inventoryTargets returns the listable kinds which can be matched with protect (or forceCheck) rules of the profiles,
+// together with the target namespaces of the profiles. Kinds which no rule can match are not listed.
+func inventoryTargets(ruleTable *RuleTable, apiResources []metav1.APIResource) []inventoryTarget {
+	targets := []inventoryTarget{}
+	for _, apiResource := range apiResources {
+		if !kubeutil.IsListable(apiResource) {
+			continue
+		}
+		namespaces := map[string]bool{}
+		for _, item := range ruleTable.Items {
+			rules := append([]*common.Rule{}, item.Profile.Spec.ForceCheckRules...)
+			rules = append(rules, item.Profile.Spec.ProtectRules...)
+			for _, rule := range rules {
+				if rule == nil {
+					continue
+				}
+				for _, m := range rule.Match {
+					if m == nil || !matchInventoryPattern(m, apiResource) {
+						continue
+					}
+					if !apiResource.Namespaced {
+						namespaces[""] = true
+						continue
+					}
+					for _, ns := range item.TargetNamespaces {
+						if m.Namespace == nil || common.MatchPattern(string(*m.Namespace), ns) {
+							namespaces[ns] = true
+						}
+					}
+				}
+			}
+		}
+		nsList := []string{}
+		for ns := range namespaces {
+			nsList = append(nsList, ns)
+		}
+		sort.Strings(nsList)
+		for _, ns := range nsList {
+			targets = append(targets, inventoryTarget{apiResource: apiResource, namespace: ns})
+		}
+	}
+	return targets
+}
+
+// matchInventoryPattern returns whether apiGroup and kind in the pattern can match with the APIResource
+func matchInventoryPattern(m *common.RequestPatternWithNamespace, apiResource metav1.APIResource) bool {
+	if m.RequestPattern == nil {
+		return true
+	}
+	if m.ApiGroup != nil && !common.MatchPattern(string(*m.ApiGroup), apiResource.Group) {
+		return false
+	}
+	if m.Kind != nil && !common.MatchPattern(string(*m.Kind), apiResource.Kind) {
+		return false
+	}
+	return true
+}
+
+func listResourcesForInventory(apiResource metav1.APIResource, namespace string, handle func([]unstructured.Unstructured) error) error {
+	return kubeutil.ListResourcesInPages(apiResource, namespace, inventoryListLimit, handle)
+}
+
+func checkExistingResource(conf *config.ShieldConfig, obj *unstructured.Unstructured) *DecisionResult {
+	metaLogger := logger.NewLogger(conf.LoggerConfig())
+	return NewResourceCheckHandler(conf, metaLogger).Run(obj)
+}
@@ -0,0 +1,212 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestInventory(t *testing.T) {
+	expectedStates := map[int]rspapi.VerificationState{
+		common.REASON_VALID_SIG:   rspapi.VerificationStateVerified,
+		common.REASON_VERIFIED_SA: rspapi.VerificationStateAllowedByServiceAccount,
+		common.REASON_NO_SIG:      rspapi.VerificationStateMissingSignature,
+		common.REASON_INVALID_SIG: rspapi.VerificationStateUnverified,
+	}
+	for reasonCode, expected := range expectedStates {
+		if state, ok := inventoryState(reasonCode); !ok || state != expected {
+			t.Errorf("Test failed for inventoryState(); expected: %s, actual: %s", expected, state)
+		}
+	}
+	if _, ok := inventoryState(common.REASON_NO_MUTATION); ok {
+		t.Errorf("Test failed for inventoryState(); no mutation request should not update inventory")
+	}
+
+	reqc := &common.RequestContext{ApiVersion: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "test-cm"}
+	rsp := &rspapi.ResourceSigningProfile{}
+
+	verified := newProtectedResourceStatus(reqc)
+	verified.State = rspapi.VerificationStateVerified
+	verified.Signer = "signer@enterprise.com"
+	rsp = rsp.UpdateInventory(verified)
+
+	other := newProtectedResourceStatus(&common.RequestContext{ApiVersion: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "another-cm"})
+	other.State = rspapi.VerificationStateMissingSignature
+	rsp = rsp.UpdateInventory(other)
+
+	unverified := newProtectedResourceStatus(reqc)
+	unverified.State = rspapi.VerificationStateUnverified
+	rsp = rsp.UpdateInventory(unverified)
+
+	if len(rsp.Status.ProtectedResources) != 2 {
+		t.Fatalf("Test failed for UpdateInventory(); expected 2 resources, actual %d", len(rsp.Status.ProtectedResources))
+	}
+	if r := rsp.Status.ProtectedResources[0]; r.Name != "another-cm" {
+		t.Errorf("Test failed for UpdateInventory(); inventory is not sorted; %v", rsp.Status.ProtectedResources)
+	}
+	if r := rsp.Status.ProtectedResources[1]; r.State != rspapi.VerificationStateUnverified || r.LastVerified == "" || r.Signer != "" {
+		t.Errorf("Test failed for UpdateInventory(); unexpected status %v", r)
+	}
+
+	rsp = rsp.RemoveFromInventory(newProtectedResourceStatus(reqc))
+	if len(rsp.Status.ProtectedResources) != 1 || inventoryContains(*rsp, verified) {
+		t.Errorf("Test failed for RemoveFromInventory(); %v", rsp.Status.ProtectedResources)
+	}
+}
+
+func TestInventoryWorker(t *testing.T) {
+	var rsp rspapi.ResourceSigningProfile
+	_ = json.Unmarshal([]byte(`{"kind":"ResourceSigningProfile","metadata":{"name":"test-rsp","namespace":"test-ns"},"spec":{"protectRules":[{"match":[{"kind":"ConfigMap"}]}]},`+
+		`"status":{"protectedResources":[{"apiVersion":"v1","kind":"ConfigMap","namespace":"test-ns","name":"known-cm","state":"Verified"},{"apiVersion":"v1","kind":"ConfigMap","namespace":"test-ns","name":"deleted-cm","state":"Verified"}]}}`), &rsp)
+	namespaces := []v1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}}
+	shared := NewSharedData()
+	shared.StoreRuleTable(NewRuleTableSnapshot([]rspapi.ResourceSigningProfile{rsp}, namespaces, nil, testRuleTableShieldNamespace, time.Hour))
+	conf := &config.ShieldConfig{Namespace: testRuleTableShieldNamespace, SideEffect: &config.SideEffectConfig{UpdateRSPInventory: true}}
+
+	newObj := func(kind, name string) unstructured.Unstructured {
+		obj := unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetNamespace("test-ns")
+		obj.SetName(name)
+		return obj
+	}
+	checked := 0
+	updated := 0
+	stored := rsp.DeepCopy()
+	worker := newInventoryWorker(shared, func() *config.ShieldConfig { return conf })
+	worker.getAPIResources = func() ([]metav1.APIResource, error) {
+		return []metav1.APIResource{
+			{Name: "configmaps", Version: "v1", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list"}},
+			{Name: "secrets", Version: "v1", Kind: "Secret", Namespaced: true, Verbs: []string{"list"}},
+		}, nil
+	}
+	listed := []string{}
+	worker.listResources = func(apiResource metav1.APIResource, namespace string, handle func([]unstructured.Unstructured) error) error {
+		listed = append(listed, apiResource.Kind+"/"+namespace)
+		// resources are handled page by page
+		if err := handle([]unstructured.Unstructured{newObj(apiResource.Kind, "known-cm")}); err != nil {
+			return err
+		}
+		return handle([]unstructured.Unstructured{newObj(apiResource.Kind, "new-cm")})
+	}
+	worker.checkResource = func(conf *config.ShieldConfig, obj *unstructured.Unstructured) *DecisionResult {
+		checked++
+		return &DecisionResult{Type: common.DecisionDeny, ReasonCode: common.REASON_NO_SIG}
+	}
+	// the first update conflicts with another update, and it is retried with the latest profile
+	worker.updateStatus = func(rsp *rspapi.ResourceSigningProfile, update func(*rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile) error {
+		updated++
+		if updated == 1 {
+			return apierrors.NewConflict(schema.GroupResource{Resource: "resourcesigningprofiles"}, rsp.GetName(), nil)
+		}
+		if rspNew := update(stored.DeepCopy()); rspNew != nil {
+			stored = rspNew
+		}
+		return nil
+	}
+
+	// nothing is queued until the worker is started
+	shared.inventory.Add(rsp, &rspapi.ProtectedResourceStatus{Kind: "ConfigMap", Namespace: "test-ns", Name: "ignored-cm"}, false)
+	if profiles, _ := shared.inventory.drain(); len(profiles) != 0 {
+		t.Errorf("Test failed for InventoryQueue; updates are queued before the worker is started")
+	}
+
+	shared.inventory.enable()
+	worker.seed()
+	// an update by an admission request is written in the same batch
+	shared.inventory.Add(rsp, &rspapi.ProtectedResourceStatus{ApiVersion: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "updated-cm", State: rspapi.VerificationStateVerified}, false)
+	worker.flush()
+
+	if len(listed) != 1 || listed[0] != "ConfigMap/test-ns" {
+		t.Errorf("Test failed for seed(); only ConfigMap in test-ns should be listed, but listed %v", listed)
+	}
+	if checked != 1 {
+		t.Errorf("Test failed for seed(); only the resource which is not in inventory should be checked, but %d resources are checked", checked)
+	}
+	if updated != 2 {
+		t.Errorf("Test failed for flush(); the profile should be updated in a batch with a retry, but updated %d times", updated)
+	}
+	states := map[string]rspapi.VerificationState{}
+	for _, r := range stored.Status.ProtectedResources {
+		states[r.Name] = r.State
+	}
+	expected := map[string]rspapi.VerificationState{
+		"known-cm":   rspapi.VerificationStateVerified,
+		"new-cm":     rspapi.VerificationStateMissingSignature,
+		"updated-cm": rspapi.VerificationStateVerified,
+	}
+	if len(states) != len(expected) {
+		t.Errorf("Test failed for InventoryWorker; expected %v, actual %v", expected, states)
+	}
+	for name, state := range expected {
+		if states[name] != state {
+			t.Errorf("Test failed for InventoryWorker; expected state of %s: %s, actual: %s", name, state, states[name])
+		}
+	}
+}
+
+func TestInventoryTargets(t *testing.T) {
+	profiles := []string{
+		`{"kind":"ResourceSigningProfile","metadata":{"name":"rsp1","namespace":"ns1"},"spec":{"protectRules":[{"match":[{"kind":"ConfigMap"},{"apiGroup":"rbac.authorization.k8s.io","kind":"ClusterRole","name":"sample-role"}]}],` +
+			`"ignoreRules":[{"match":[{"kind":"Secret"}]}]}}`,
+		`{"kind":"ResourceSigningProfile","metadata":{"name":"rsp2","namespace":"ns2"},"spec":{"protectRules":[{"match":[{"namespace":"other-ns","kind":"Deployment"}]}],` +
+			`"forceCheckRules":[{"match":[{"apiGroup":"apps","kind":"re:^(Deployment|DaemonSet)$"}]}]}}`,
+	}
+	rsps := []rspapi.ResourceSigningProfile{}
+	for _, p := range profiles {
+		var rsp rspapi.ResourceSigningProfile
+		if err := json.Unmarshal([]byte(p), &rsp); err != nil {
+			t.Fatalf("failed to load a profile; %s", err.Error())
+		}
+		rsps = append(rsps, rsp)
+	}
+	namespaces := []v1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}}, {ObjectMeta: metav1.ObjectMeta{Name: "ns2"}}}
+	ruleTable := NewRuleTable(rsps, namespaces, nil, testRuleTableShieldNamespace)
+
+	listVerbs := []string{"get", "list"}
+	apiResources := []metav1.APIResource{
+		{Name: "configmaps", Version: "v1", Kind: "ConfigMap", Namespaced: true, Verbs: listVerbs},
+		{Name: "secrets", Version: "v1", Kind: "Secret", Namespaced: true, Verbs: listVerbs},
+		{Name: "deployments", Group: "apps", Version: "v1", Kind: "Deployment", Namespaced: true, Verbs: listVerbs},
+		{Name: "deployments/status", Group: "apps", Version: "v1", Kind: "Deployment", Namespaced: true, Verbs: listVerbs},
+		{Name: "daemonsets", Group: "apps", Version: "v1", Kind: "DaemonSet", Namespaced: true},
+		{Name: "clusterroles", Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole", Verbs: listVerbs},
+		{Name: "namespaces", Version: "v1", Kind: "Namespace", Verbs: listVerbs},
+	}
+	actual := []string{}
+	for _, target := range inventoryTargets(ruleTable, apiResources) {
+		actual = append(actual, target.apiResource.Name+"/"+target.namespace)
+	}
+	// Secret is only ignored, DaemonSet is not listable, and Namespace is not matched with any rule
+	expected := []string{"configmaps/ns1", "deployments/ns2", "clusterroles/"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Test failed for inventoryTargets(); expected %v, actual %v", expected, actual)
+	}
+}
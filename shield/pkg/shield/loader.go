@@ -17,6 +17,7 @@
 package shield
 
 import (
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
 	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
 )
 
@@ -29,8 +30,10 @@ import (
 type Loader struct {
 	SignerConfig      *SignerConfigLoader
 	RSP               *RSPLoader
+	CRSP              *CRSPLoader
 	Namespace         *NamespaceLoader
 	ResourceSignature *ResSigLoader
+	BreakGlassRequest *BreakGlassRequestLoader
 }
 
 func NewLoader(cfg *config.ShieldConfig, reqNamespace string) *Loader {
@@ -41,8 +44,38 @@ func NewLoader(cfg *config.ShieldConfig, reqNamespace string) *Loader {
 	loader := &Loader{
 		SignerConfig:      NewSignerConfigLoader(shieldNamespace),
 		RSP:               NewRSPLoader(shieldNamespace, profileNamespace, requestNamespace, cfg.CommonProfile),
+		CRSP:              NewCRSPLoader(),
 		Namespace:         NewNamespaceLoader(),
 		ResourceSignature: NewResSigLoader(signatureNamespace, requestNamespace),
+		BreakGlassRequest: NewBreakGlassRequestLoader(shieldNamespace),
 	}
 	return loader
 }
+
+// GetProfiles returns RSPs and ClusterResourceSigningProfiles which are converted to RSP.
+// Disabled ClusterResourceSigningProfiles are not included.
+func (self *Loader) GetProfiles(doK8sApiCall bool) ([]rspapi.ResourceSigningProfile, bool) {
+	profiles, rspReloaded := self.RSP.GetData(doK8sApiCall)
+	if self.CRSP == nil {
+		return profiles, rspReloaded
+	}
+	crspList, crspReloaded := self.CRSP.GetData(doK8sApiCall)
+	if len(crspList) == 0 {
+		return profiles, rspReloaded || crspReloaded
+	}
+	merged := append([]rspapi.ResourceSigningProfile{}, profiles...)
+	for _, crsp := range crspList {
+		if crsp.Spec.Disabled {
+			continue
+		}
+		merged = append(merged, crsp.ToResourceSigningProfile())
+	}
+	return merged, rspReloaded || crspReloaded
+}
+
+func (self *Loader) ClearProfileCache() {
+	self.RSP.ClearCache()
+	if self.CRSP != nil {
+		self.CRSP.ClearCache()
+	}
+}
@@ -0,0 +1,92 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"strings"
+
+	crspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/clusterresourcesigningprofile/v1alpha1"
+	rsigapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	sigconfapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
+	v1 "k8s.io/api/core/v1"
+)
+
+/**********************************************
+
+				LocalData
+
+***********************************************/
+
+// LocalData is a set of resources which are used instead of the ones in cluster.
+// This is used for running admission checks offline (e.g. `ishieldctl test`).
+type LocalData struct {
+	RSPList            []rspapi.ResourceSigningProfile         `json:"rspList,omitempty"`
+	CRSPList           []crspapi.ClusterResourceSigningProfile `json:"crspList,omitempty"`
+	NSList             []v1.Namespace                          `json:"nsList,omitempty"`
+	SignerConfig       *sigconfapi.SignerConfig                `json:"signerConfig,omitempty"`
+	ResSigList         []*rsigapi.ResourceSignature            `json:"resSigList,omitempty"`
+	BreakGlassRequests []v1.ConfigMap                          `json:"breakGlassRequests,omitempty"`
+}
+
+// NewLocalLoader returns Loader which serves LocalData and never calls K8s API; no K8s client is created
+func NewLocalLoader(cfg *config.ShieldConfig, reqNamespace string, data *LocalData) *Loader {
+	signerConfig := data.SignerConfig
+	if signerConfig == nil {
+		signerConfig = &sigconfapi.SignerConfig{}
+	}
+	rspList := data.RSPList
+	if rspList == nil {
+		rspList = []rspapi.ResourceSigningProfile{}
+	}
+	crspList := data.CRSPList
+	if crspList == nil {
+		crspList = []crspapi.ClusterResourceSigningProfile{}
+	}
+	nsList := data.NSList
+	if nsList == nil {
+		nsList = []v1.Namespace{}
+	}
+	bgRequests := data.BreakGlassRequests
+	if bgRequests == nil {
+		bgRequests = []v1.ConfigMap{}
+	}
+
+	return &Loader{
+		SignerConfig:      &SignerConfigLoader{shieldNamespace: cfg.Namespace, local: true, Data: signerConfig},
+		RSP:               &RSPLoader{shieldNamespace: cfg.Namespace, profileNamespace: cfg.ProfileNamespace, requestNamespace: reqNamespace, commonProfile: cfg.CommonProfile, local: true, Data: rspList},
+		CRSP:              &CRSPLoader{local: true, Data: crspList},
+		Namespace:         &NamespaceLoader{local: true, Data: nsList},
+		ResourceSignature: &ResSigLoader{signatureNamespace: cfg.SignatureNamespace, requestNamespace: reqNamespace, local: true, Data: &rsigapi.ResourceSignatureList{Items: sortByTimestamp(data.ResSigList)}},
+		BreakGlassRequest: &BreakGlassRequestLoader{shieldNamespace: cfg.Namespace, local: true, Data: bgRequests},
+	}
+}
+
+// filterResSigList returns ResourceSignatures for the resource like the label selector in ResSigLoader
+func filterResSigList(items []*rsigapi.ResourceSignature, resc *common.ResourceContext) []*rsigapi.ResourceSignature {
+	reqApiVersion := strings.ReplaceAll(resc.GroupVersion(), "/", "_")
+	filtered := []*rsigapi.ResourceSignature{}
+	for _, item := range items {
+		labels := item.GetLabels()
+		if labels[common.ResSigLabelApiVer] == reqApiVersion && labels[common.ResSigLabelKind] == resc.Kind {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
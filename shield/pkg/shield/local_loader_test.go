@@ -0,0 +1,66 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	rsigapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	admv1 "k8s.io/api/admission/v1"
+)
+
+func TestHandlerWithLocalData(t *testing.T) {
+	for i := 0; i <= MaxCaseNum; i++ {
+		if skipCaseNum[i] {
+			continue
+		}
+		_, _, _, cfg, data, _, _, _, expectedDr := getTestData(i)
+		cfg.Log.ConsoleLog.Enabled = false
+		cfg.Log.ContextLog.Enabled = false
+
+		resSigList := []*rsigapi.ResourceSignature{}
+		if data.ResSigList != nil {
+			resSigList = data.ResSigList.Items
+		}
+		localData := &LocalData{
+			RSPList:      data.RSPList,
+			NSList:       data.NSList,
+			SignerConfig: data.SignerConfig,
+			ResSigList:   resSigList,
+		}
+
+		var adreq *admv1.AdmissionRequest
+		adreqBytes, _ := ioutil.ReadFile(testFileName(testAdReqFile, i))
+		_ = json.Unmarshal(adreqBytes, &adreq)
+
+		metaLogger := logger.NewLogger(cfg.LoggerConfig())
+		handler := NewHandlerWithLocalData(cfg, metaLogger, localData)
+		resp, actualDr := handler.Check(adreq)
+
+		if actualDr.Type != expectedDr.Type || actualDr.ReasonCode != expectedDr.ReasonCode {
+			t.Errorf("[Case %s] Test failed for Handler.Check() with LocalData\nexpected:\n  %s\nactual:\n  %s", strconv.Itoa(i), expectedDr.String(), actualDr.String())
+		} else if resp.Allowed != expectedDr.isAllowed() {
+			t.Errorf("[Case %s] Test failed for Handler.Check() with LocalData; AdmissionResponse.Allowed should be %v", strconv.Itoa(i), expectedDr.isAllowed())
+		} else {
+			t.Logf("[Case %s] Test for Handler.Check() with LocalData passed.", strconv.Itoa(i))
+		}
+	}
+}
@@ -34,8 +34,10 @@ import (
 
 type NamespaceLoader struct {
 	interval time.Duration
-	Client   *v1client.CoreV1Client
-	Data     []v1.Namespace
+	local    bool // if true, Data is given in advance and never loaded from cluster
+
+	Client *v1client.CoreV1Client
+	Data   []v1.Namespace
 }
 
 func NewNamespaceLoader() *NamespaceLoader {
@@ -51,7 +53,7 @@ func NewNamespaceLoader() *NamespaceLoader {
 
 func (self *NamespaceLoader) GetData(doK8sApiCall bool) ([]v1.Namespace, bool) {
 	reloaded := false
-	if len(self.Data) == 0 {
+	if len(self.Data) == 0 && !self.local {
 		reloaded = self.Load(doK8sApiCall)
 	}
 	return self.Data, reloaded
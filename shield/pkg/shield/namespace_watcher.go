@@ -0,0 +1,232 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	crspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/clusterresourcesigningprofile/v1alpha1"
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	crspclient "github.com/IBM/integrity-enforcer/shield/pkg/client/clusterresourcesigningprofile/clientset/versioned/typed/clusterresourcesigningprofile/v1alpha1"
+	rspclient "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesigningprofile/clientset/versioned/typed/resourcesigningprofile/v1alpha1"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	k8scache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+const defaultNamespaceWatcherResync = time.Minute * 10
+
+/**********************************************
+
+				NamespaceWatcher
+
+***********************************************/
+
+// NamespaceWatcher rebuilds RuleTable when a namespace is created, deleted or relabeled, or when the spec of a profile (RSP / CRSP) is changed.
+// Without this, RuleTable is rebuilt only when a Namespace or a profile request passes through the webhook,
+// so `TargetNamespaceSelector` is not updated for namespaces changed by requests which are not intercepted,
+// and target namespaces in status are not updated when a profile is changed.
+type NamespaceWatcher struct {
+	client     kubernetes.Interface
+	rspClient  rspclient.ApisV1alpha1Interface
+	crspClient crspclient.ApisV1alpha1Interface
+	shared     *SharedData
+	getConfig  func() *config.ShieldConfig
+	resync     time.Duration
+
+	rebuild          chan struct{}
+	rebuildRuleTable func()
+	updateStatus     func(rsp *rspapi.ResourceSigningProfile, update func(*rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile) error
+}
+
+func NewNamespaceWatcher(getConfig func() *config.ShieldConfig) (*NamespaceWatcher, error) {
+	kubeConfig, err := kubeutil.GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	rspClient, err := rspclient.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	crspClient, err := crspclient.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newNamespaceWatcher(client, rspClient, crspClient, defaultSharedData, getConfig), nil
+}
+
+func newNamespaceWatcher(client kubernetes.Interface, rspClient rspclient.ApisV1alpha1Interface, crspClient crspclient.ApisV1alpha1Interface, shared *SharedData, getConfig func() *config.ShieldConfig) *NamespaceWatcher {
+	w := &NamespaceWatcher{
+		client:       client,
+		rspClient:    rspClient,
+		crspClient:   crspClient,
+		shared:       shared,
+		getConfig:    getConfig,
+		resync:       defaultNamespaceWatcherResync,
+		rebuild:      make(chan struct{}, 1),
+		updateStatus: updateRSPStatusWith,
+	}
+	w.rebuildRuleTable = w.rebuildAndUpdateStatus
+	return w
+}
+
+// Start runs the namespace and profile informers and the worker which rebuilds RuleTable until stopCh is closed
+func (self *NamespaceWatcher) Start(stopCh <-chan struct{}) {
+	factory := informers.NewSharedInformerFactory(self.client, self.resync)
+	informer := factory.Core().V1().Namespaces().Informer()
+	informer.AddEventHandler(k8scache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { self.onChange() },
+		UpdateFunc: self.onUpdate,
+		DeleteFunc: func(obj interface{}) { self.onChange() },
+	})
+	profileHandler := k8scache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { self.onProfileChange() },
+		UpdateFunc: self.onProfileUpdate,
+		DeleteFunc: func(obj interface{}) { self.onProfileChange() },
+	}
+	rspInformer := k8scache.NewSharedIndexInformer(&k8scache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return self.rspClient.ResourceSigningProfiles("").List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return self.rspClient.ResourceSigningProfiles("").Watch(context.Background(), options)
+		},
+	}, &rspapi.ResourceSigningProfile{}, self.resync, k8scache.Indexers{})
+	rspInformer.AddEventHandler(profileHandler)
+	crspInformer := k8scache.NewSharedIndexInformer(&k8scache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return self.crspClient.ClusterResourceSigningProfiles().List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return self.crspClient.ClusterResourceSigningProfiles().Watch(context.Background(), options)
+		},
+	}, &crspapi.ClusterResourceSigningProfile{}, self.resync, k8scache.Indexers{})
+	crspInformer.AddEventHandler(profileHandler)
+
+	go self.run(stopCh)
+	factory.Start(stopCh)
+	go rspInformer.Run(stopCh)
+	go crspInformer.Run(stopCh)
+	logger.Info("Namespace watcher has been started.")
+}
+
+func (self *NamespaceWatcher) onUpdate(oldObj, newObj interface{}) {
+	oldNs, ok1 := oldObj.(*v1.Namespace)
+	newNs, ok2 := newObj.(*v1.Namespace)
+	if !ok1 || !ok2 {
+		return
+	}
+	// only labels are used by NamespaceSelector; skip status changes and periodic resync
+	if reflect.DeepEqual(oldNs.GetLabels(), newNs.GetLabels()) {
+		return
+	}
+	self.onChange()
+}
+
+func (self *NamespaceWatcher) onProfileUpdate(oldObj, newObj interface{}) {
+	// skip status changes (e.g. target namespaces written by this watcher) and periodic resync
+	switch oldProfile := oldObj.(type) {
+	case *rspapi.ResourceSigningProfile:
+		if newProfile, ok := newObj.(*rspapi.ResourceSigningProfile); ok && reflect.DeepEqual(oldProfile.Spec, newProfile.Spec) {
+			return
+		}
+	case *crspapi.ClusterResourceSigningProfile:
+		if newProfile, ok := newObj.(*crspapi.ClusterResourceSigningProfile); ok && reflect.DeepEqual(oldProfile.Spec, newProfile.Spec) {
+			return
+		}
+	}
+	self.onProfileChange()
+}
+
+// onProfileChange clears the cached profile list in addition to onChange(), so that the rebuilt RuleTable has the new profile
+func (self *NamespaceWatcher) onProfileChange() {
+	(&RSPLoader{}).ClearCache()
+	(&CRSPLoader{}).ClearCache()
+	self.onChange()
+}
+
+// onChange invalidates the current RuleTable immediately, and requests the worker to rebuild it.
+// Multiple changes (e.g. initial list of namespaces) are coalesced into one rebuild.
+func (self *NamespaceWatcher) onChange() {
+	(&NamespaceLoader{}).ClearCache()
+	self.shared.InvalidateRuleTable()
+	select {
+	case self.rebuild <- struct{}{}:
+	default:
+	}
+}
+
+func (self *NamespaceWatcher) run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-self.rebuild:
+			self.rebuildRuleTable()
+		}
+	}
+}
+
+// rebuildAndUpdateStatus rebuilds RuleTable and writes target namespaces into status of profiles if enabled
+func (self *NamespaceWatcher) rebuildAndUpdateStatus() {
+	conf := self.getConfig()
+	if conf == nil {
+		return
+	}
+	snapshot := self.shared.GetRuleTable(NewLoader(conf, ""), conf.CommonProfile, conf.Namespace)
+	if snapshot == nil || snapshot.RuleTable == nil {
+		return
+	}
+	logger.Debug("RuleTable has been rebuilt by namespace watcher")
+	if conf.SideEffect != nil && conf.SideEffect.UpdateRSPTargetNamespacesEnabled() {
+		self.updateTargetNamespaces(snapshot.RuleTable)
+	}
+}
+
+// updateTargetNamespaces writes the computed target namespaces into status of each profile if changed;
+// the status of a profile is read again and updated when it conflicts with another update (e.g. inventory)
+func (self *NamespaceWatcher) updateTargetNamespaces(ruleTable *RuleTable) {
+	for _, item := range ruleTable.Items {
+		rsp := item.Profile
+		targetNamespaces := item.TargetNamespaces
+		if (&rsp).DeepCopy().UpdateTargetNamespaces(targetNamespaces) == nil {
+			continue
+		}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			return self.updateStatus(&rsp, func(rspOrg *rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile {
+				return rspOrg.UpdateTargetNamespaces(targetNamespaces)
+			})
+		})
+		if err != nil {
+			logger.Error("Failed to update target namespaces in status; ", err)
+		}
+	}
+}
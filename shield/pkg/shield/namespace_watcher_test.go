@@ -0,0 +1,190 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	crspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/clusterresourcesigningprofile/v1alpha1"
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	crspfake "github.com/IBM/integrity-enforcer/shield/pkg/client/clusterresourcesigningprofile/clientset/versioned/fake"
+	rspfake "github.com/IBM/integrity-enforcer/shield/pkg/client/resourcesigningprofile/clientset/versioned/fake"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNamespaceWatcher(t *testing.T) {
+	shared := NewSharedData()
+	storeValidSnapshot := func() {
+		shared.StoreRuleTable(NewRuleTableSnapshot([]rspapi.ResourceSigningProfile{}, []v1.Namespace{}, nil, testRuleTableShieldNamespace, time.Hour))
+	}
+	isValid := func() bool {
+		return shared.loadRuleTable().isValid(nil, testRuleTableShieldNamespace)
+	}
+
+	client := fake.NewSimpleClientset()
+	watcher := newNamespaceWatcher(client, rspfake.NewSimpleClientset().ApisV1alpha1(), crspfake.NewSimpleClientset().ApisV1alpha1(), shared, func() *config.ShieldConfig { return nil })
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	watcher.Start(stopCh)
+
+	// a namespace created without the webhook invalidates RuleTable
+	storeValidSnapshot()
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+	if _, err := client.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Test failed for creating namespace; %s", err.Error())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for isValid() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if isValid() {
+		t.Errorf("Test failed for NamespaceWatcher; RuleTable is not invalidated by namespace creation")
+	}
+
+	// namespace update without label change does not affect RuleTable
+	storeValidSnapshot()
+	oldNs := ns.DeepCopy()
+	newNs := ns.DeepCopy()
+	newNs.Status.Phase = v1.NamespaceTerminating
+	watcher.onUpdate(oldNs, newNs)
+	if !isValid() {
+		t.Errorf("Test failed for NamespaceWatcher; RuleTable is invalidated without label change")
+	}
+
+	// relabeling invalidates RuleTable
+	newNs.SetLabels(map[string]string{"env": "prod"})
+	watcher.onUpdate(oldNs, newNs)
+	if isValid() {
+		t.Errorf("Test failed for NamespaceWatcher; RuleTable is not invalidated by relabeling")
+	}
+}
+
+func TestNamespaceWatcherWithProfiles(t *testing.T) {
+	shared := NewSharedData()
+	storeValidSnapshot := func() {
+		shared.StoreRuleTable(NewRuleTableSnapshot([]rspapi.ResourceSigningProfile{}, []v1.Namespace{}, nil, testRuleTableShieldNamespace, time.Hour))
+	}
+	isValid := func() bool {
+		return shared.loadRuleTable().isValid(nil, testRuleTableShieldNamespace)
+	}
+
+	rspClient := rspfake.NewSimpleClientset().ApisV1alpha1()
+	crspClient := crspfake.NewSimpleClientset().ApisV1alpha1()
+	watcher := newNamespaceWatcher(fake.NewSimpleClientset(), rspClient, crspClient, shared, func() *config.ShieldConfig { return nil })
+	var rebuildCount int32
+	watcher.rebuildRuleTable = func() { atomic.AddInt32(&rebuildCount, 1) }
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	watcher.Start(stopCh)
+
+	waitForRebuild := func(count int32) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for atomic.LoadInt32(&rebuildCount) < count && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return atomic.LoadInt32(&rebuildCount) >= count && !isValid()
+	}
+
+	// creating a profile without the webhook rebuilds RuleTable (and target namespaces in status)
+	storeValidSnapshot()
+	rsp := &rspapi.ResourceSigningProfile{ObjectMeta: metav1.ObjectMeta{Name: "sample-rsp", Namespace: "secure-ns"}}
+	if _, err := rspClient.ResourceSigningProfiles("secure-ns").Create(context.Background(), rsp, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Test failed for creating RSP; %s", err.Error())
+	}
+	if !waitForRebuild(1) {
+		t.Errorf("Test failed for NamespaceWatcher; RuleTable is not rebuilt by RSP creation")
+	}
+
+	storeValidSnapshot()
+	crsp := &crspapi.ClusterResourceSigningProfile{ObjectMeta: metav1.ObjectMeta{Name: "sample-crsp"}}
+	if _, err := crspClient.ClusterResourceSigningProfiles().Create(context.Background(), crsp, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Test failed for creating CRSP; %s", err.Error())
+	}
+	if !waitForRebuild(2) {
+		t.Errorf("Test failed for NamespaceWatcher; RuleTable is not rebuilt by CRSP creation")
+	}
+
+	// status update (e.g. target namespaces written by the watcher) does not affect RuleTable
+	storeValidSnapshot()
+	oldRsp := rsp.DeepCopy()
+	newRsp := rsp.DeepCopy()
+	newRsp.UpdateTargetNamespaces([]string{"secure-ns"})
+	watcher.onProfileUpdate(oldRsp, newRsp)
+	if !isValid() {
+		t.Errorf("Test failed for NamespaceWatcher; RuleTable is invalidated by status update of RSP")
+	}
+
+	// spec update invalidates RuleTable
+	newRsp.Spec.Disabled = true
+	watcher.onProfileUpdate(oldRsp, newRsp)
+	if isValid() {
+		t.Errorf("Test failed for NamespaceWatcher; RuleTable is not invalidated by spec update of RSP")
+	}
+}
+
+func TestUpdateTargetNamespaces(t *testing.T) {
+	rsp := &rspapi.ResourceSigningProfile{}
+	if rsp.UpdateTargetNamespaces([]string{}) != nil {
+		t.Errorf("Test failed for UpdateTargetNamespaces(); expected no change for empty list")
+	}
+	if rsp.UpdateTargetNamespaces([]string{"ns-b", "ns-a"}) == nil {
+		t.Errorf("Test failed for UpdateTargetNamespaces(); expected change")
+	}
+	if rsp.Status.TargetNamespaces[0] != "ns-a" {
+		t.Errorf("Test failed for UpdateTargetNamespaces(); expected sorted list, actual: %v", rsp.Status.TargetNamespaces)
+	}
+	if rsp.UpdateTargetNamespaces([]string{"ns-a", "ns-b"}) != nil {
+		t.Errorf("Test failed for UpdateTargetNamespaces(); expected no change for the same list")
+	}
+
+	// target namespaces are written with a retry when the update conflicts with another update of the status
+	var profile rspapi.ResourceSigningProfile
+	_ = json.Unmarshal([]byte(`{"kind":"ResourceSigningProfile","metadata":{"name":"test-rsp","namespace":"`+testRuleTableShieldNamespace+`"},`+
+		`"spec":{"targetNamespaceSelector":{"include":["ns-*"]},"protectRules":[{"match":[{"kind":"ConfigMap"}]}]}}`), &profile)
+	namespaces := []v1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "ns-a"}}, {ObjectMeta: metav1.ObjectMeta{Name: "ns-b"}}}
+	ruleTable := NewRuleTable([]rspapi.ResourceSigningProfile{profile}, namespaces, nil, testRuleTableShieldNamespace)
+	stored := profile.DeepCopy()
+	updated := 0
+	w := newNamespaceWatcher(fake.NewSimpleClientset(), rspfake.NewSimpleClientset().ApisV1alpha1(), crspfake.NewSimpleClientset().ApisV1alpha1(), NewSharedData(), func() *config.ShieldConfig { return nil })
+	w.updateStatus = func(rsp *rspapi.ResourceSigningProfile, update func(*rspapi.ResourceSigningProfile) *rspapi.ResourceSigningProfile) error {
+		updated++
+		if updated == 1 {
+			return apierrors.NewConflict(schema.GroupResource{Resource: "resourcesigningprofiles"}, rsp.GetName(), nil)
+		}
+		if rspNew := update(stored.DeepCopy()); rspNew != nil {
+			stored = rspNew
+		}
+		return nil
+	}
+	w.updateTargetNamespaces(ruleTable)
+	if updated != 2 {
+		t.Errorf("Test failed for updateTargetNamespaces(); expected an update with a retry, but updated %d times", updated)
+	}
+	if !reflect.DeepEqual(stored.Status.TargetNamespaces, []string{"ns-a", "ns-b"}) {
+		t.Errorf("Test failed for updateTargetNamespaces(); expected [ns-a ns-b], actual: %v", stored.Status.TargetNamespaces)
+	}
+}
@@ -0,0 +1,111 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"fmt"
+	"strings"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	"github.com/IBM/integrity-enforcer/shield/pkg/common"
+)
+
+/**********************************************
+
+				ProfileFragments
+
+***********************************************/
+
+// ProfileFragments is a set of reusable fragments, which are RSPs with `fragment: true` in iShield NS.
+// Only fragments in iShield NS are used, so that fragments cannot be injected by users who have only namespaced roles.
+type ProfileFragments struct {
+	fragments map[string]rspapi.ResourceSigningProfile
+}
+
+// ReferenceCycleError is returned when fragments refer to each other
+type ReferenceCycleError struct {
+	Path []string
+}
+
+func (self *ReferenceCycleError) Error() string {
+	return fmt.Sprintf("reference cycle is detected: %s", strings.Join(self.Path, " -> "))
+}
+
+func IsProfileFragment(p rspapi.ResourceSigningProfile, shieldNamespace string) bool {
+	return p.Spec.Fragment && p.Kind != common.ClusterProfileCustomResourceKind && p.GetNamespace() == shieldNamespace
+}
+
+func NewProfileFragments(profiles []rspapi.ResourceSigningProfile, shieldNamespace string) *ProfileFragments {
+	fragments := map[string]rspapi.ResourceSigningProfile{}
+	for _, p := range profiles {
+		if IsProfileFragment(p, shieldNamespace) {
+			fragments[p.GetName()] = p
+		}
+	}
+	return &ProfileFragments{fragments: fragments}
+}
+
+// Resolve returns the profile merged with all fragments which it refers to directly or indirectly.
+// Each fragment is merged only once even if it is referred multiple times.
+// A missing fragment and a reference cycle are returned as errors, and they are skipped in the merged profile.
+func (self *ProfileFragments) Resolve(p rspapi.ResourceSigningProfile) (rspapi.ResourceSigningProfile, []error) {
+	if len(p.Spec.References) == 0 {
+		return p, nil
+	}
+	ordered := []string{}
+	errs := []error{}
+	visited := map[string]bool{}
+	visiting := map[string]bool{}
+	if p.Spec.Fragment {
+		// a fragment which refers to itself
+		visiting[p.GetName()] = true
+		visited[p.GetName()] = true
+	}
+	path := []string{profileRef(p)}
+	self.collect(p.Spec.References, path, visiting, visited, &ordered, &errs)
+
+	resolved := p
+	// do not modify the slices in the original profile
+	resolved.Spec = *p.Spec.DeepCopy()
+	for _, name := range ordered {
+		resolved = resolved.Merge(self.fragments[name])
+	}
+	return resolved, errs
+}
+
+// collect lists fragments in depth-first order; `visiting` is the set of fragments in the current path to detect a cycle
+func (self *ProfileFragments) collect(references []string, path []string, visiting, visited map[string]bool, ordered *[]string, errs *[]error) {
+	for _, name := range references {
+		if visiting[name] {
+			*errs = append(*errs, &ReferenceCycleError{Path: append(append([]string{}, path...), name)})
+			continue
+		}
+		if visited[name] {
+			continue
+		}
+		fragment, ok := self.fragments[name]
+		if !ok {
+			*errs = append(*errs, fmt.Errorf("profile fragment `%s` referred by %s is not found", name, path[len(path)-1]))
+			continue
+		}
+		visited[name] = true
+		visiting[name] = true
+		*ordered = append(*ordered, name)
+		self.collect(fragment.Spec.References, append(append([]string{}, path...), name), visiting, visited, ordered, errs)
+		delete(visiting, name)
+	}
+}
@@ -0,0 +1,154 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"reflect"
+	"testing"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+)
+
+func TestProfileFragments(t *testing.T) {
+	ns := testRuleTableShieldNamespace
+	openshift := testLintProfile(t, "openshift-defaults", ns, `{"fragment":true,"references":["base"],"ignoreRules":[{"match":[{"username":"system:serviceaccount:openshift-*"}]}],"ignoreAttrs":[{"match":[{"kind":"*"}],"attrs":["metadata.annotations.\"openshift.io/generated-by\""]}]}`)
+	argo := testLintProfile(t, "argo-managed", ns, `{"fragment":true,"references":["base"],"kustomizePatterns":[{"match":[{"kind":"*"}],"namePrefix":"argo-"}]}`)
+	base := testLintProfile(t, "base", ns, `{"fragment":true,"ignoreAttrs":[{"match":[{"kind":"*"}],"attrs":["metadata.labels.\"app.kubernetes.io/instance\""]}]}`)
+	app := testLintProfile(t, "app-rsp", "app-ns", `{"references":["openshift-defaults","argo-managed"],"protectRules":[{"match":[{"kind":"ConfigMap"}]}],"ignoreAttrs":[{"match":[{"kind":"ConfigMap"}],"attrs":["data.comment"]}]}`)
+	profiles := []rspapi.ResourceSigningProfile{openshift, argo, base, app}
+
+	fragments := NewProfileFragments(profiles, ns)
+	resolved, errs := fragments.Resolve(app)
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	// `base` is referred twice, but merged only once
+	if len(resolved.Spec.IgnoreAttrs) != 3 || len(resolved.Spec.IgnoreRules) != 1 || len(resolved.Spec.KustomizePatterns) != 1 || len(resolved.Spec.ProtectRules) != 1 {
+		t.Errorf("fragments are not merged correctly: %v", resolved.Spec)
+	}
+	if len(app.Spec.IgnoreAttrs) != 1 {
+		t.Errorf("the original profile must not be changed: %v", app.Spec)
+	}
+
+	// fragments are not in RuleTable, and the profile is merged with them
+	ruleTable := NewRuleTable(profiles, nil, nil, ns)
+	if len(ruleTable.Items) != 1 || ruleTable.Items[0].Profile.GetName() != "app-rsp" {
+		t.Errorf("only app-rsp should be in RuleTable: %v", ruleTable.Items)
+	}
+	reqFields := map[string]string{"Namespace": "app-ns", "Kind": "ConfigMap", "Name": "test-cm", "UserName": "system:serviceaccount:openshift-config:default"}
+	if protected, ignored, _ := ruleTable.CheckIfProtected(reqFields, nil); protected || !ignored {
+		t.Errorf("the request should be ignored by the rule in openshift-defaults")
+	}
+
+	// fragments outside iShield NS are not used
+	fakeFragment := testLintProfile(t, "base", "app-ns", `{"fragment":true,"ignoreRules":[{"match":[{"kind":"*"}]}]}`)
+	if _, errs := NewProfileFragments([]rspapi.ResourceSigningProfile{fakeFragment}, ns).Resolve(app); len(errs) != 2 {
+		t.Errorf("missing fragments should be reported: %v", errs)
+	}
+
+	// reference cycle
+	cycle1 := testLintProfile(t, "cycle-1", ns, `{"fragment":true,"references":["cycle-2"]}`)
+	cycle2 := testLintProfile(t, "cycle-2", ns, `{"fragment":true,"references":["cycle-1"]}`)
+	cyclic := testLintProfile(t, "cyclic-rsp", "app-ns", `{"references":["cycle-1"],"protectRules":[{"match":[{"kind":"Secret"}]}]}`)
+	fragments = NewProfileFragments([]rspapi.ResourceSigningProfile{cycle1, cycle2, cyclic}, ns)
+	resolved, errs = fragments.Resolve(cyclic)
+	if len(errs) != 1 {
+		t.Errorf("a reference cycle should be detected: %v", errs)
+	} else if _, ok := errs[0].(*ReferenceCycleError); !ok {
+		t.Errorf("ReferenceCycleError is expected, but got %s", errs[0].Error())
+	}
+	if len(resolved.Spec.ProtectRules) != 1 {
+		t.Errorf("the profile should be resolved without the cycle: %v", resolved.Spec)
+	}
+	if _, errs = fragments.Resolve(cycle1); len(errs) != 1 {
+		t.Errorf("a reference cycle should be detected for a fragment: %v", errs)
+	}
+
+	// single-value settings are inherited from fragments only if they are not specified in the profile
+	ssa := testLintProfile(t, "ssa-defaults", ns, `{"fragment":true,"matchMode":"ServerSideApply","fieldManager":"kubectl","trustedFieldManagers":[{"name":"hpa-controller","match":[{"username":"system:serviceaccount:kube-system:horizontal-pod-autoscaler"}]}],"failurePolicy":"FailOpen"}`)
+	testCases := []struct {
+		name                  string
+		spec                  string
+		expectedMatchMode     rspapi.MatchModeType
+		expectedTrusted       []string
+		expectedFailurePolicy rspapi.FailurePolicyType
+	}{
+		{
+			name:                  "inherited",
+			spec:                  `{"references":["ssa-defaults"]}`,
+			expectedMatchMode:     rspapi.MatchModeServerSideApply,
+			expectedTrusted:       []string{"hpa-controller"},
+			expectedFailurePolicy: rspapi.FailurePolicyFailOpen,
+		},
+		{
+			name:                  "trustedFieldManagers in profile",
+			spec:                  `{"references":["ssa-defaults"],"trustedFieldManagers":[{"name":"vpa-recommender","match":[{"username":"system:serviceaccount:kube-system:vpa-recommender"}]}]}`,
+			expectedMatchMode:     rspapi.MatchModeServerSideApply,
+			expectedTrusted:       []string{"vpa-recommender"},
+			expectedFailurePolicy: rspapi.FailurePolicyFailOpen,
+		},
+		{
+			name:                  "failurePolicy in profile",
+			spec:                  `{"references":["ssa-defaults"],"failurePolicy":"FailClosed"}`,
+			expectedMatchMode:     rspapi.MatchModeServerSideApply,
+			expectedTrusted:       []string{"hpa-controller"},
+			expectedFailurePolicy: rspapi.FailurePolicyFailClosed,
+		},
+	}
+	for _, tc := range testCases {
+		p := testLintProfile(t, "ssa-rsp", "app-ns", tc.spec)
+		resolved, errs := NewProfileFragments([]rspapi.ResourceSigningProfile{ssa, p}, ns).Resolve(p)
+		if len(errs) != 0 {
+			t.Errorf("[%s] unexpected errors: %v", tc.name, errs)
+			continue
+		}
+		if resolved.Spec.MatchMode != tc.expectedMatchMode {
+			t.Errorf("[%s] expected matchMode %s, actual %s", tc.name, tc.expectedMatchMode, resolved.Spec.MatchMode)
+		}
+		trustedNames := []string{}
+		for _, m := range resolved.Spec.TrustedFieldManagers {
+			trustedNames = append(trustedNames, m.Name)
+		}
+		if !reflect.DeepEqual(trustedNames, tc.expectedTrusted) {
+			t.Errorf("[%s] expected trustedFieldManagers %v, actual %v", tc.name, tc.expectedTrusted, trustedNames)
+		}
+		if resolved.Spec.FailurePolicy != tc.expectedFailurePolicy {
+			t.Errorf("[%s] expected failurePolicy %s, actual %s", tc.name, tc.expectedFailurePolicy, resolved.Spec.FailurePolicy)
+		}
+	}
+}
+
+func TestLintReferences(t *testing.T) {
+	ns := testRuleTableShieldNamespace
+	fragment := testLintProfile(t, "shared", ns, `{"fragment":true}`)
+	profiles := []rspapi.ResourceSigningProfile{fragment}
+
+	rsp := testLintProfile(t, "app-rsp", "app-ns", `{"references":["shared","not-found"],"protectRules":[{"match":[{"kind":"ConfigMap"}]}]}`)
+	result := &ProfileLintResult{}
+	lintReferences(rsp, profiles, ns, result)
+	if len(result.Errors) != 0 || len(result.Warnings) != 1 {
+		t.Errorf("a warning for the missing fragment is expected, but got %v, %v", result.Errors, result.Warnings)
+	}
+
+	// the fragment is updated to refer to itself
+	updated := testLintProfile(t, "shared", ns, `{"fragment":true,"references":["shared"]}`)
+	result = &ProfileLintResult{}
+	lintReferences(updated, profiles, ns, result)
+	if len(result.Errors) != 1 {
+		t.Errorf("an error for the reference cycle is expected, but got %v", result.Errors)
+	}
+}
@@ -36,7 +36,9 @@ type ResourceCheckHandler struct {
 	config        *config.ShieldConfig
 	ctx           *CheckContext
 	resc          *common.ResourceContext
+	reqc          *common.RequestContext
 	data          *RunData
+	profiles      []rspapi.ResourceSigningProfile
 	serverLogger  *logger.Logger
 	resourceLog   *log.Entry
 	contextLogger *logger.ContextLogger
@@ -56,7 +58,22 @@ func NewResourceCheckHandlerWithContext(config *config.ShieldConfig, metaLogger
 	return resHandler
 }
 
+// NewResourceCheckHandlerWithProfiles returns ResourceCheckHandler which checks a resource with the given profiles
+// instead of the ones in cluster (e.g. a new RSP before it is applied). Other data like SignerConfig is loaded from cluster.
+func NewResourceCheckHandlerWithProfiles(config *config.ShieldConfig, metaLogger *logger.Logger, profiles []rspapi.ResourceSigningProfile) *ResourceCheckHandler {
+	resHandler := NewResourceCheckHandler(config, metaLogger)
+	resHandler.profiles = profiles
+	return resHandler
+}
+
 func (self *ResourceCheckHandler) Run(res *unstructured.Unstructured) *DecisionResult {
+	return self.RunWithRequest(res, nil)
+}
+
+// RunWithRequest checks the resource in the admission request reqc, which is used for the checks depending on the requester
+// (e.g. trustedFieldManagers).
+func (self *ResourceCheckHandler) RunWithRequest(res *unstructured.Unstructured, reqc *common.RequestContext) *DecisionResult {
+	self.reqc = reqc
 
 	// init ctx, resc and data & init logger
 	self.initialize(res)
@@ -87,7 +104,7 @@ func (self *ResourceCheckHandler) Check() *DecisionResult {
 	}
 
 	for _, prof := range matchedProfiles {
-		dr = signatureCheckWithSingleProfile(prof, self.resc, self.config, self.data, self.ctx)
+		dr = signatureCheckWithSingleProfile(prof, self.resc, self.reqc, self.config, self.data, self.ctx)
 		if dr.isAllowed() {
 			// this RSP allowed the resource. will check next RSP.
 		} else {
@@ -105,10 +122,19 @@ func (self *ResourceCheckHandler) Check() *DecisionResult {
 		// TODO: support pgp/x509 image signature
 		if self.config.SigStoreEnabled() {
 			self.resourceLog.Trace("ImageVerificationEnabled")
+			mark := timeoutMark(self.data.Context())
 			imageDecisionResult := self.ImageCheck()
 			self.resourceLog.Trace("image check result: ", imageDecisionResult)
 			imageDenied := imageDecisionResult.isDenied() || imageDecisionResult.isErrorOccurred()
 
+			// if image verification timed out, the result is decided by FailurePolicy of matched profiles
+			if imageDenied {
+				if tdr := timeoutDecision(timedOutChecksSince(self.data.Context(), mark), matchedProfiles); tdr != nil {
+					imageDecisionResult = tdr
+					imageDenied = tdr.isDenied()
+				}
+			}
+
 			// overwride existing DecisionResult only when resource siganature is allowed & image is denied
 			if resourceSigOk && imageDenied {
 				dr = imageDecisionResult
@@ -129,11 +155,21 @@ func (self *ResourceCheckHandler) Check() *DecisionResult {
 // image
 func (self *ResourceCheckHandler) ImageCheck() *DecisionResult {
 	idr := undeterminedDescision()
-	needSigCheck, imageToVerify, _ := requestCheckForImageCheck(self.resc)
+	imageCtx, cancel := withCheckTimeout(self.data.Context(), config.CheckImageVerification)
+	defer cancel()
+	needSigCheck, imageToVerify, _ := requestCheckForImageCheck(imageCtx, self.resc)
 	if !needSigCheck {
+		if checkTimedOut(imageCtx, config.CheckImageVerification) {
+			idr = &DecisionResult{
+				Type:       common.DecisionError,
+				ReasonCode: common.REASON_ERROR,
+				Message:    "failed to load image profile before the deadline",
+			}
+		}
 		return idr
 	}
-	imageToVerify.imageSignatureCheck()
+	imageToVerify.imageSignatureCheck(imageCtx)
+	checkTimedOut(imageCtx, config.CheckImageVerification)
 	imageToVerify.imageVerifiedResultCheckByProfile()
 	idr = makeImageCheckResult(imageToVerify)
 	return idr
@@ -164,6 +200,10 @@ func (self *ResourceCheckHandler) initialize(res *unstructured.Unstructured) *De
 
 	if self.data.loader == nil {
 		runDataLoader := NewLoader(self.config, reqNamespace)
+		if self.profiles != nil {
+			runDataLoader.RSP = &RSPLoader{shieldNamespace: self.config.Namespace, profileNamespace: self.config.ProfileNamespace, requestNamespace: reqNamespace, commonProfile: self.config.CommonProfile, local: true, Data: self.profiles}
+			runDataLoader.CRSP = nil
+		}
 		self.data.loader = runDataLoader
 	}
 	self.data.Init(self.config)
@@ -178,7 +218,6 @@ func (self *ResourceCheckHandler) finalize() {
 
 func (self *ResourceCheckHandler) logEntry() {
 	if ok, levelStr := self.config.ConsoleLogEnabled(self.resc); ok {
-		logger.SetSingletonLoggerLevel(levelStr) // change singleton logger level; this might be overwritten by parallel handler instance
 		lvl, _ := log.ParseLevel(levelStr)
 		self.serverLogger.SetLevel(lvl) // set custom log level for this resource
 		self.resourceLog.Trace("New Resource Check Request Received")
@@ -202,7 +241,6 @@ func (self *ResourceCheckHandler) logEntry() {
 
 func (self *ResourceCheckHandler) logExit() {
 	if ok, _ := self.config.ConsoleLogEnabled(self.resc); ok {
-		logger.SetSingletonLoggerLevel(self.config.Log.LogLevel)
 		self.resourceLog.WithFields(log.Fields{
 			"allowed": self.ctx.Allow,
 			"aborted": self.ctx.Aborted,
@@ -44,6 +44,8 @@ type ResSigLoader struct {
 	reqApiVersion      string
 	reqKind            string
 
+	local bool // if true, Data is given in advance and never loaded from cluster
+
 	Client *rsigclient.ApisV1alpha1Client
 	Data   *rsigapi.ResourceSignatureList
 }
@@ -62,6 +64,14 @@ func NewResSigLoader(signatureNamespace, requestNamespace string) *ResSigLoader
 }
 
 func (self *ResSigLoader) GetData(resc *common.ResourceContext, doK8sApiCall bool) *rsigapi.ResourceSignatureList {
+	if self.local {
+		// Data has all ResourceSignatures, so pick the ones for this resource like the label selector in Load()
+		items := []*rsigapi.ResourceSignature{}
+		if self.Data != nil {
+			items = filterResSigList(self.Data.Items, resc)
+		}
+		return &rsigapi.ResourceSignatureList{Items: items}
+	}
 	if self.Data == nil {
 		self.Load(resc, doK8sApiCall)
 	}
@@ -0,0 +1,572 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	crspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/clusterresourcesigningprofile/v1alpha1"
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
+	v1 "k8s.io/api/core/v1"
+)
+
+/**********************************************
+
+				ProfileLint
+
+***********************************************/
+
+// ProfileLintResult is the result of semantic validation of RSP / CRSP.
+// Errors deny the request, and Warnings are returned as admission warnings.
+type ProfileLintResult struct {
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func (self *ProfileLintResult) addError(format string, a ...interface{}) {
+	self.Errors = append(self.Errors, fmt.Sprintf(format, a...))
+}
+
+func (self *ProfileLintResult) addWarning(format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	for _, w := range self.Warnings {
+		if w == msg {
+			return
+		}
+	}
+	self.Warnings = append(self.Warnings, msg)
+}
+
+// operations in AdmissionRequest
+var validOperations = map[string]bool{"CREATE": true, "UPDATE": true, "DELETE": true, "CONNECT": true}
+
+// well-known cluster-scope kinds; without discovery, other kinds are handled as namespaced ones in lint
+var knownClusterScopeKinds = map[string]bool{
+	"APIService":                     true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"PodSecurityPolicy":              true,
+	"PriorityClass":                  true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+}
+
+type profileRuleSet struct {
+	name  string
+	rules []*common.Rule
+}
+
+func profileRuleSets(rsp rspapi.ResourceSigningProfile) []profileRuleSet {
+	return []profileRuleSet{
+		{name: "protectRules", rules: rsp.Spec.ProtectRules},
+		{name: "ignoreRules", rules: rsp.Spec.IgnoreRules},
+		{name: "forceCheckRules", rules: rsp.Spec.ForceCheckRules},
+	}
+}
+
+// lintProfileRequest runs LintResourceSigningProfile() for RSP / CRSP in the request, and returns nil for other requests
+func lintProfileRequest(reqc *common.RequestContext, reqobj *common.RequestObject, config *config.ShieldConfig, data *RunData) *ProfileLintResult {
+	if reqc.IsDeleteRequest() || checkIfIShieldServerRequest(reqc, config) {
+		// status of existing profiles is updated by server
+		return nil
+	}
+	var rsp rspapi.ResourceSigningProfile
+	switch reqc.Kind {
+	case common.ProfileCustomResourceKind:
+		if err := json.Unmarshal(reqobj.RawObject, &rsp); err != nil {
+			return nil
+		}
+		if rsp.GetNamespace() == "" {
+			rsp.SetNamespace(reqc.Namespace)
+		}
+	case common.ClusterProfileCustomResourceKind:
+		var crsp crspapi.ClusterResourceSigningProfile
+		if err := json.Unmarshal(reqobj.RawObject, &crsp); err != nil {
+			return nil
+		}
+		rsp = crsp.ToResourceSigningProfile()
+	default:
+		return nil
+	}
+
+	var others []RuleItem
+	var nsList []v1.Namespace
+	var profiles []rspapi.ResourceSigningProfile
+	if data != nil {
+		if ruleTable := data.GetRuleTable(config.Namespace); ruleTable != nil {
+			others = ruleTable.Items
+		}
+		nsList = data.NSList
+		profiles = data.RSPList
+	}
+	if IsProfileFragment(rsp, config.Namespace) {
+		// a fragment is not evaluated by itself, so only references are checked
+		result := &ProfileLintResult{}
+		lintAttrPaths(rsp, result)
+		lintKustomizePatterns(rsp, result)
+		lintReferences(rsp, profiles, config.Namespace, result)
+		return result
+	}
+	targetNamespaces := NewRuleTable([]rspapi.ResourceSigningProfile{rsp}, nsList, nil, config.Namespace).Items[0].TargetNamespaces
+	result := LintResourceSigningProfile(rsp, targetNamespaces, others, config.CommonProfile, config.Namespace)
+	lintReferences(rsp, profiles, config.Namespace, result)
+	return result
+}
+
+// lintReferences checks fragments referred by the profile with the current profiles in cluster;
+// a reference cycle is an error, and a missing fragment is a warning because it may be created later
+func lintReferences(rsp rspapi.ResourceSigningProfile, profiles []rspapi.ResourceSigningProfile, shieldNamespace string, result *ProfileLintResult) {
+	if len(rsp.Spec.References) == 0 {
+		return
+	}
+	current := []rspapi.ResourceSigningProfile{rsp}
+	for _, p := range profiles {
+		if p.Kind == rsp.Kind && p.GetNamespace() == rsp.GetNamespace() && p.GetName() == rsp.GetName() {
+			continue
+		}
+		current = append(current, p)
+	}
+	_, errs := NewProfileFragments(current, shieldNamespace).Resolve(rsp)
+	for _, err := range errs {
+		if _, ok := err.(*ReferenceCycleError); ok {
+			result.addError("%s", err.Error())
+		} else {
+			result.addWarning("%s", err.Error())
+		}
+	}
+}
+
+// LintResourceSigningProfile checks the profile semantically.
+//   - errors: rules which can never match, attrs which are invalid as mapnode paths, and invalid kustomize transformers
+//   - warnings: protect rules shadowed by ignore rules, cluster-scope rules without concrete name in per-namespace RSP,
+//     and attrs conflicting with another profile which protects the same resources in the same namespaces
+func LintResourceSigningProfile(rsp rspapi.ResourceSigningProfile, targetNamespaces []string, others []RuleItem, commonProfile *common.CommonProfile, shieldNamespace string) *ProfileLintResult {
+	result := &ProfileLintResult{}
+	lintNeverMatchRules(rsp, result)
+	lintShadowedRules(rsp, commonProfile, result)
+	lintAttrPaths(rsp, result)
+	lintKustomizePatterns(rsp, result)
+	lintClusterScopeRules(rsp, shieldNamespace, result)
+	lintConflictingAttrs(rsp, targetNamespaces, others, commonProfile, result)
+	return result
+}
+
+func lintNeverMatchRules(rsp rspapi.ResourceSigningProfile, result *ProfileLintResult) {
+	for _, set := range profileRuleSets(rsp) {
+		for i, rule := range set.rules {
+			if rule == nil {
+				continue
+			}
+			if len(rule.Match) == 0 {
+				result.addError("%s[%d] has no `match` pattern, so it never matches", set.name, i)
+				continue
+			}
+			invalid := false
+			matchable := false
+			for _, m := range rule.Match {
+				if reason := neverMatchReason(m); reason != "" {
+					result.addError("%s[%d] has a pattern which never matches; %s", set.name, i, reason)
+					invalid = true
+					continue
+				}
+				if !excludedByAny(m, rule.Exclude) {
+					matchable = true
+				}
+			}
+			if !invalid && !matchable {
+				result.addError("%s[%d] never matches, because all patterns in `match` are excluded by `exclude`", set.name, i)
+			}
+		}
+	}
+}
+
+func lintShadowedRules(rsp rspapi.ResourceSigningProfile, commonProfile *common.CommonProfile, result *ProfileLintResult) {
+	type namedRule struct {
+		name string
+		rule *common.Rule
+	}
+	ignoreRules := []namedRule{}
+	for i, rule := range rsp.Spec.IgnoreRules {
+		ignoreRules = append(ignoreRules, namedRule{name: fmt.Sprintf("ignoreRules[%d]", i), rule: rule})
+	}
+	if commonProfile != nil {
+		for _, rule := range commonProfile.IgnoreRules {
+			ignoreRules = append(ignoreRules, namedRule{name: "ignoreRules in common profile", rule: rule})
+		}
+	}
+
+	for i, rule := range rsp.Spec.ProtectRules {
+		if rule == nil || len(rule.Match) == 0 {
+			continue
+		}
+		shadowedBy := ""
+		for _, m := range rule.Match {
+			if neverMatchReason(m) != "" {
+				continue
+			}
+			// forceCheckRules are evaluated before ignoreRules
+			if overlapsAnyRule(m, rsp.Spec.ForceCheckRules) {
+				shadowedBy = ""
+				break
+			}
+			by := ""
+			for _, ig := range ignoreRules {
+				if ig.rule != nil && len(ig.rule.Exclude) == 0 && len(ig.rule.Conditions) == 0 && coveredByAny(m, ig.rule.Match) {
+					by = ig.name
+					break
+				}
+			}
+			if by == "" {
+				shadowedBy = ""
+				break
+			}
+			shadowedBy = by
+		}
+		if shadowedBy != "" {
+			result.addWarning("protectRules[%d] is shadowed by %s, so it never protects any resource", i, shadowedBy)
+		}
+	}
+}
+
+func lintAttrPaths(rsp rspapi.ResourceSigningProfile, result *ProfileLintResult) {
+	attrsSets := []struct {
+		name     string
+		patterns []*common.AttrsPattern
+	}{
+		{name: "protectAttrs", patterns: rsp.Spec.ProtectAttrs},
+		{name: "ignoreAttrs", patterns: rsp.Spec.IgnoreAttrs},
+		{name: "unprotectAttrs", patterns: rsp.Spec.UnprotectAttrs},
+	}
+	for _, set := range attrsSets {
+		for i, pattern := range set.patterns {
+			if pattern == nil {
+				continue
+			}
+			for _, attr := range pattern.Attrs {
+				if err := mapnode.ValidateConcatKey(attr); err != nil {
+					result.addError("%s[%d] has an invalid attr `%s`; %s", set.name, i, attr, err.Error())
+				}
+			}
+			if pattern.Values == nil {
+				continue
+			}
+			if set.name == "protectAttrs" {
+				result.addWarning("%s[%d] has `values`, but it is used only in ignoreAttrs", set.name, i)
+			} else if err := pattern.Values.Validate(); err != nil {
+				result.addError("%s[%d] has invalid `values`; %s", set.name, i, err.Error())
+			}
+		}
+	}
+}
+
+func lintKustomizePatterns(rsp rspapi.ResourceSigningProfile, result *ProfileLintResult) {
+	for i, pattern := range rsp.Spec.KustomizePatterns {
+		if pattern == nil {
+			continue
+		}
+		if err := pattern.Validate(); err != nil {
+			result.addError("kustomizePatterns[%d] is invalid; %s", i, err.Error())
+		}
+	}
+}
+
+// rules for cluster-scope resources are evaluated with strict name match in per-namespace RSP
+func lintClusterScopeRules(rsp rspapi.ResourceSigningProfile, shieldNamespace string, result *ProfileLintResult) {
+	if rsp.Kind == common.ClusterProfileCustomResourceKind || rsp.GetNamespace() == shieldNamespace {
+		return
+	}
+	for _, set := range profileRuleSets(rsp) {
+		for i, rule := range set.rules {
+			if rule == nil {
+				continue
+			}
+			for _, m := range rule.Match {
+				if m == nil || m.RequestPattern == nil || !isClusterScopePattern(m.RequestPattern) || hasConcreteName(m.RequestPattern) {
+					continue
+				}
+				result.addWarning("%s[%d] matches cluster-scope resources without concrete `name`, so it does not match with any cluster-scope request in per-namespace %s", set.name, i, common.ProfileCustomResourceKind)
+			}
+		}
+	}
+}
+
+func lintConflictingAttrs(rsp rspapi.ResourceSigningProfile, targetNamespaces []string, others []RuleItem, commonProfile *common.CommonProfile, result *ProfileLintResult) {
+	commonAttrs := map[*common.AttrsPattern]bool{}
+	if commonProfile != nil {
+		for _, a := range commonProfile.IgnoreAttrs {
+			commonAttrs[a] = true
+		}
+	}
+	ignoreAttrsOf := func(p rspapi.ResourceSigningProfile) []*common.AttrsPattern {
+		attrs := []*common.AttrsPattern{}
+		for _, a := range append(append([]*common.AttrsPattern{}, p.Spec.IgnoreAttrs...), p.Spec.UnprotectAttrs...) {
+			// ignoreAttrs in common profile are merged into every profile in RuleTable
+			if !commonAttrs[a] {
+				attrs = append(attrs, a)
+			}
+		}
+		return attrs
+	}
+
+	for _, item := range others {
+		other := item.Profile
+		if other.Kind == rsp.Kind && other.GetNamespace() == rsp.GetNamespace() && other.GetName() == rsp.GetName() {
+			continue
+		}
+		if !namespacesIntersect(targetNamespaces, item.TargetNamespaces) || !rulesOverlap(rsp.Spec.ProtectRules, other.Spec.ProtectRules) {
+			continue
+		}
+		for _, attr := range conflictingAttrs(rsp.Spec.ProtectAttrs, ignoreAttrsOf(other)) {
+			result.addWarning("protectAttrs `%s` conflicts with ignoreAttrs in %s, which protects the same resources", attr, profileRef(other))
+		}
+		for _, attr := range conflictingAttrs(ignoreAttrsOf(rsp), other.Spec.ProtectAttrs) {
+			result.addWarning("ignoreAttrs `%s` conflicts with protectAttrs in %s, which protects the same resources", attr, profileRef(other))
+		}
+	}
+}
+
+func profileRef(p rspapi.ResourceSigningProfile) string {
+	if p.Kind == common.ClusterProfileCustomResourceKind {
+		return fmt.Sprintf("%s `%s`", common.ClusterProfileCustomResourceKind, p.GetName())
+	}
+	return fmt.Sprintf("%s `%s/%s`", common.ProfileCustomResourceKind, p.GetNamespace(), p.GetName())
+}
+
+// neverMatchReason returns the reason if the pattern never matches with any request
+func neverMatchReason(m *common.RequestPatternWithNamespace) string {
+	if m == nil || (m.RequestPattern == nil && m.Namespace == nil) {
+		return "no condition is defined"
+	}
+	if m.RequestPattern == nil {
+		return ""
+	}
+	fields := patternFields(m)
+	conditions := 0
+	for _, f := range fields[1:] {
+		if f != nil {
+			conditions++
+		}
+	}
+	if m.LabelSelector != nil || m.AnnotationSelector != nil {
+		conditions++
+	}
+	if conditions == 0 {
+		return "no condition is defined"
+	}
+	if values := indexValues(m.Operation); values[0] != anyIndexValue {
+		valid := false
+		for _, v := range values {
+			if validOperations[v] {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Sprintf("operation `%s` is not any of CREATE, UPDATE, DELETE and CONNECT", string(*m.Operation))
+		}
+	}
+	return ""
+}
+
+// patternFields returns namespace, scope, apiGroup, apiVersion, kind, name, operation, username and usergroup patterns
+func patternFields(m *common.RequestPatternWithNamespace) [9]*common.RulePattern {
+	fields := [9]*common.RulePattern{m.Namespace}
+	if p := m.RequestPattern; p != nil {
+		fields[1], fields[2], fields[3], fields[4] = p.Scope, p.ApiGroup, p.ApiVersion, p.Kind
+		fields[5], fields[6], fields[7], fields[8] = p.Name, p.Operation, p.UserName, p.UserGroup
+	}
+	return fields
+}
+
+// covers returns true if every request matched by `specific` is also matched by `general`.
+// This is conservative; `general` with label/annotation selectors is never regarded as covering.
+func covers(general, specific *common.RequestPatternWithNamespace) bool {
+	if general == nil || specific == nil || neverMatchReason(general) != "" {
+		return false
+	}
+	if general.RequestPattern != nil && (general.LabelSelector != nil || general.AnnotationSelector != nil) {
+		return false
+	}
+	gFields := patternFields(general)
+	sFields := patternFields(specific)
+	for i := range gFields {
+		if !coversField(gFields[i], sFields[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func coversField(general, specific *common.RulePattern) bool {
+	if general == nil {
+		return true
+	}
+	g := strings.TrimSpace(string(*general))
+	if g == "" || g == "*" {
+		return true
+	}
+	if specific == nil {
+		return false
+	}
+	values := indexValues(specific)
+	if values[0] == anyIndexValue {
+		return strings.TrimSpace(string(*specific)) == g
+	}
+	for _, v := range values {
+		if !common.MatchPattern(g, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func coveredByAny(m *common.RequestPatternWithNamespace, patterns []*common.RequestPatternWithNamespace) bool {
+	for _, p := range patterns {
+		if covers(p, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func excludedByAny(m *common.RequestPatternWithNamespace, excludes []*common.RequestPatternWithNamespace) bool {
+	return coveredByAny(m, excludes)
+}
+
+// overlaps returns false only if no request can match with both patterns
+func overlaps(a, b *common.RequestPatternWithNamespace) bool {
+	if a == nil || b == nil || neverMatchReason(a) != "" || neverMatchReason(b) != "" {
+		return false
+	}
+	aFields := patternFields(a)
+	bFields := patternFields(b)
+	for i := range aFields {
+		if aFields[i] == nil || bFields[i] == nil {
+			continue
+		}
+		aValues := indexValues(aFields[i])
+		bValues := indexValues(bFields[i])
+		if aValues[0] == anyIndexValue || bValues[0] == anyIndexValue {
+			continue
+		}
+		if !namespacesIntersect(aValues, bValues) {
+			return false
+		}
+	}
+	return true
+}
+
+func overlapsAnyRule(m *common.RequestPatternWithNamespace, rules []*common.Rule) bool {
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		for _, p := range rule.Match {
+			if overlaps(m, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func rulesOverlap(rules1, rules2 []*common.Rule) bool {
+	for _, rule := range rules1 {
+		if rule == nil {
+			continue
+		}
+		for _, m := range rule.Match {
+			if overlapsAnyRule(m, rules2) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conflictingAttrs returns attrs which are listed in both of patterns applied to the same resources
+func conflictingAttrs(patterns1, patterns2 []*common.AttrsPattern) []string {
+	conflicts := []string{}
+	for _, p1 := range patterns1 {
+		for _, p2 := range patterns2 {
+			if p1 == nil || p2 == nil || !attrsPatternsOverlap(p1, p2) {
+				continue
+			}
+			for _, attr := range p1.Attrs {
+				if common.ExactMatchWithPatternArray(attr, p2.Attrs) && !common.ExactMatchWithPatternArray(attr, conflicts) {
+					conflicts = append(conflicts, attr)
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+func attrsPatternsOverlap(p1, p2 *common.AttrsPattern) bool {
+	for _, m1 := range p1.Match {
+		for _, m2 := range p2.Match {
+			if overlaps(m1, m2) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func namespacesIntersect(list1, list2 []string) bool {
+	for _, v := range list1 {
+		if common.ExactMatchWithPatternArray(v, list2) {
+			return true
+		}
+	}
+	return false
+}
+
+func isClusterScopePattern(p *common.RequestPattern) bool {
+	if p.Scope != nil && strings.TrimSpace(string(*p.Scope)) == "Cluster" {
+		return true
+	}
+	kinds := indexValues(p.Kind)
+	if kinds[0] == anyIndexValue {
+		return false
+	}
+	for _, k := range kinds {
+		if !knownClusterScopeKinds[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// name is compared with exact match for cluster-scope requests in per-namespace RSP
+func hasConcreteName(p *common.RequestPattern) bool {
+	if p.Name == nil {
+		return false
+	}
+	name := strings.TrimSpace(string(*p.Name))
+	values := indexValues(p.Name)
+	return len(values) == 1 && values[0] == name
+}
@@ -0,0 +1,177 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	"github.com/IBM/integrity-enforcer/shield/pkg/common"
+)
+
+func testLintProfile(t *testing.T, name, namespace, specJson string) rspapi.ResourceSigningProfile {
+	var rsp rspapi.ResourceSigningProfile
+	rsp.Kind = common.ProfileCustomResourceKind
+	rsp.SetName(name)
+	rsp.SetNamespace(namespace)
+	if err := json.Unmarshal([]byte(specJson), &rsp.Spec); err != nil {
+		t.Fatalf("failed to unmarshal spec: %s", err.Error())
+	}
+	return rsp
+}
+
+func containsMessage(messages []string, substr string) bool {
+	for _, m := range messages {
+		if strings.Contains(m, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLintResourceSigningProfile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     string
+		errors   []string
+		warnings []string
+	}{
+		{
+			name: "valid",
+			spec: `{"protectRules":[{"match":[{"kind":"ConfigMap"}]}],"ignoreRules":[{"match":[{"kind":"Secret"}]}],"protectAttrs":[{"match":[{"kind":"ConfigMap"}],"attrs":["data.key1","metadata.labels.\"app.kubernetes.io/name\""]}]}`,
+		},
+		{
+			name:   "no match",
+			spec:   `{"protectRules":[{"exclude":[{"kind":"ConfigMap"}]}]}`,
+			errors: []string{"protectRules[0] has no `match` pattern"},
+		},
+		{
+			name:   "empty pattern",
+			spec:   `{"protectRules":[{"match":[{}]}]}`,
+			errors: []string{"protectRules[0] has a pattern which never matches; no condition"},
+		},
+		{
+			name:   "invalid operation",
+			spec:   `{"ignoreRules":[{"match":[{"kind":"ConfigMap","operation":"APPLY"}]}]}`,
+			errors: []string{"ignoreRules[0] has a pattern which never matches; operation `APPLY`"},
+		},
+		{
+			name:   "all excluded",
+			spec:   `{"protectRules":[{"match":[{"kind":"ConfigMap","name":"app-1"}],"exclude":[{"kind":"ConfigMap,Secret","name":"app-*"}]}]}`,
+			errors: []string{"protectRules[0] never matches, because all patterns in `match` are excluded"},
+		},
+		{
+			name: "partially excluded",
+			spec: `{"protectRules":[{"match":[{"kind":"ConfigMap"}],"exclude":[{"kind":"ConfigMap","name":"app-*"}]}]}`,
+		},
+		{
+			name:     "shadowed",
+			spec:     `{"protectRules":[{"match":[{"kind":"ConfigMap","name":"test-1"}]}],"ignoreRules":[{"match":[{"kind":"ConfigMap"}]}]}`,
+			warnings: []string{"protectRules[0] is shadowed by ignoreRules[0]"},
+		},
+		{
+			name: "shadowed but force checked",
+			spec: `{"protectRules":[{"match":[{"kind":"ConfigMap","name":"test-1"}]}],"ignoreRules":[{"match":[{"kind":"ConfigMap"}]}],"forceCheckRules":[{"match":[{"name":"test-*"}]}]}`,
+		},
+		{
+			name: "ignore rule with selector",
+			spec: `{"protectRules":[{"match":[{"kind":"ConfigMap"}]}],"ignoreRules":[{"match":[{"kind":"ConfigMap","labelSelector":{"matchLabels":{"app":"a"}}}]}]}`,
+		},
+		{
+			name:   "invalid attr",
+			spec:   `{"protectRules":[{"match":[{"kind":"ConfigMap"}]}],"ignoreAttrs":[{"match":[{"kind":"ConfigMap"}],"attrs":["data..key1","spec.containers[x].image"]}]}`,
+			errors: []string{"ignoreAttrs[0] has an invalid attr `data..key1`", "ignoreAttrs[0] has an invalid attr `spec.containers[x].image`"},
+		},
+		{
+			name:   "invalid values",
+			spec:   `{"protectRules":[{"match":[{"kind":"Deployment"}]}],"ignoreAttrs":[{"match":[{"kind":"Deployment"}],"attrs":["spec.replicas"],"values":{"min":"20","max":"2"}},{"match":[{"kind":"Deployment"}],"attrs":["spec.paused"],"values":{"pattern":"(true"}}]}`,
+			errors: []string{"ignoreAttrs[0] has invalid `values`; min `20` is greater than max `2`", "ignoreAttrs[1] has invalid `values`; invalid pattern `(true`"},
+		},
+		{
+			name:   "invalid kustomize pattern",
+			spec:   `{"protectRules":[{"match":[{"kind":"Deployment"}]}],"kustomizePatterns":[{"match":[{"kind":"Deployment"}],"images":[{"newTag":"1.0.1"}]},{"match":[{"kind":"Deployment"}],"replicas":[{"name":"sample-app","count":-1}]}]}`,
+			errors: []string{"kustomizePatterns[0] is invalid; images[0] has no name", "kustomizePatterns[1] is invalid; replicas[0] has negative count -1"},
+		},
+		{
+			name:     "values in protectAttrs",
+			spec:     `{"protectRules":[{"match":[{"kind":"Deployment"}]}],"protectAttrs":[{"match":[{"kind":"Deployment"}],"attrs":["spec.replicas"],"values":{"max":"20"}}]}`,
+			warnings: []string{"protectAttrs[0] has `values`, but it is used only in ignoreAttrs"},
+		},
+		{
+			name:     "cluster-scope without name",
+			spec:     `{"protectRules":[{"match":[{"kind":"ClusterRole"}]},{"match":[{"kind":"ClusterRoleBinding","name":"test-crb"}]},{"match":[{"scope":"Cluster","name":"test-*"}]}]}`,
+			warnings: []string{"protectRules[0] matches cluster-scope resources", "protectRules[2] matches cluster-scope resources"},
+		},
+	}
+
+	for _, tc := range testCases {
+		rsp := testLintProfile(t, "test-rsp", "test-ns", tc.spec)
+		result := LintResourceSigningProfile(rsp, []string{"test-ns"}, nil, nil, testRuleTableShieldNamespace)
+		if len(result.Errors) != len(tc.errors) {
+			t.Errorf("case `%s`: expected %d errors, but got %v", tc.name, len(tc.errors), result.Errors)
+		}
+		for _, e := range tc.errors {
+			if !containsMessage(result.Errors, e) {
+				t.Errorf("case `%s`: expected error `%s` is not found in %v", tc.name, e, result.Errors)
+			}
+		}
+		if len(result.Warnings) != len(tc.warnings) {
+			t.Errorf("case `%s`: expected %d warnings, but got %v", tc.name, len(tc.warnings), result.Warnings)
+		}
+		for _, w := range tc.warnings {
+			if !containsMessage(result.Warnings, w) {
+				t.Errorf("case `%s`: expected warning `%s` is not found in %v", tc.name, w, result.Warnings)
+			}
+		}
+	}
+
+	// cluster-scope rules without name are valid in RSP in shield namespace
+	rsp := testLintProfile(t, "test-rsp", testRuleTableShieldNamespace, `{"protectRules":[{"match":[{"kind":"ClusterRole"}]}]}`)
+	if result := LintResourceSigningProfile(rsp, nil, nil, nil, testRuleTableShieldNamespace); len(result.Warnings) != 0 {
+		t.Errorf("no warning is expected for RSP in shield namespace, but got %v", result.Warnings)
+	}
+}
+
+func TestLintConflictingAttrs(t *testing.T) {
+	other := testLintProfile(t, "other-rsp", "test-ns", `{"protectRules":[{"match":[{"kind":"ConfigMap"}]}],"ignoreAttrs":[{"match":[{"kind":"ConfigMap"}],"attrs":["data.key1"]}]}`)
+	unrelated := testLintProfile(t, "unrelated-rsp", "test-ns", `{"protectRules":[{"match":[{"kind":"Secret"}]}],"ignoreAttrs":[{"match":[{"kind":"Secret"}],"attrs":["data.key1"]}]}`)
+	others := []RuleItem{
+		{Profile: other, TargetNamespaces: []string{"test-ns"}},
+		{Profile: unrelated, TargetNamespaces: []string{"test-ns"}},
+	}
+
+	rsp := testLintProfile(t, "test-rsp", "test-ns", `{"protectRules":[{"match":[{"kind":"ConfigMap"}]}],"protectAttrs":[{"match":[{"kind":"ConfigMap"}],"attrs":["data.key1","data.key2"]}]}`)
+	result := LintResourceSigningProfile(rsp, []string{"test-ns"}, others, nil, testRuleTableShieldNamespace)
+	if len(result.Warnings) != 1 || !containsMessage(result.Warnings, "protectAttrs `data.key1` conflicts with ignoreAttrs in ResourceSigningProfile `test-ns/other-rsp`") {
+		t.Errorf("a conflict with other-rsp is expected, but got %v", result.Warnings)
+	}
+
+	// no conflict if target namespaces are different
+	result = LintResourceSigningProfile(rsp, []string{"another-ns"}, others, nil, testRuleTableShieldNamespace)
+	if len(result.Warnings) != 0 {
+		t.Errorf("no conflict is expected for different namespaces, but got %v", result.Warnings)
+	}
+
+	// the profile itself in RuleTable is not compared
+	self := testLintProfile(t, "other-rsp", "test-ns", `{"protectRules":[{"match":[{"kind":"ConfigMap"}]}],"protectAttrs":[{"match":[{"kind":"ConfigMap"}],"attrs":["data.key1"]}]}`)
+	result = LintResourceSigningProfile(self, []string{"test-ns"}, others, nil, testRuleTableShieldNamespace)
+	if len(result.Warnings) != 0 {
+		t.Errorf("no conflict is expected with the profile itself, but got %v", result.Warnings)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
 	cache "github.com/IBM/integrity-enforcer/shield/pkg/util/cache"
 	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
 
 	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -40,6 +41,8 @@ type RSPLoader struct {
 	commonProfile          *common.CommonProfile
 	defaultProfileInterval time.Duration
 
+	local bool // if true, Data is given in advance and never loaded from cluster
+
 	Client *rspclient.ApisV1alpha1Client
 	Data   []rspapi.ResourceSigningProfile
 }
@@ -61,7 +64,7 @@ func NewRSPLoader(shieldNamespace, profileNamespace, requestNamespace string, co
 
 func (self *RSPLoader) GetData(doK8sApiCall bool) ([]rspapi.ResourceSigningProfile, bool) {
 	reloaded := false
-	if len(self.Data) == 0 {
+	if len(self.Data) == 0 && !self.local {
 		reloaded = self.Load(doK8sApiCall)
 	}
 	return self.Data, reloaded
@@ -103,7 +106,7 @@ func (self *RSPLoader) Load(doK8sApiCall bool) bool {
 	return reloaded
 }
 
-func (self *RSPLoader) UpdateStatus(rsp *rspapi.ResourceSigningProfile, reqc *common.RequestContext, resc *common.ResourceContext, errMsg string) error {
+func (self *RSPLoader) UpdateStatus(rsp *rspapi.ResourceSigningProfile, reqc *common.RequestContext, resc *common.ResourceContext, errMsg string, diff []*mapnode.FieldDiff) error {
 	rspNamespace := rsp.GetNamespace()
 	rspName := rsp.GetName()
 	rspOrg, err := self.Client.ResourceSigningProfiles(rspNamespace).Get(context.Background(), rspName, metav1.GetOptions{})
@@ -112,7 +115,7 @@ func (self *RSPLoader) UpdateStatus(rsp *rspapi.ResourceSigningProfile, reqc *co
 	}
 
 	req := common.NewRequestFromReqContext(reqc)
-	rspNew := rspOrg.UpdateStatus(req, errMsg)
+	rspNew := rspOrg.UpdateStatus(req, errMsg, diff)
 
 	_, err = self.Client.ResourceSigningProfiles(rspNamespace).Update(context.Background(), rspNew, metav1.UpdateOptions{})
 	if err != nil {
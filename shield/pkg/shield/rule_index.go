@@ -0,0 +1,242 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"sort"
+	"strings"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	"github.com/IBM/integrity-enforcer/shield/pkg/common"
+)
+
+/**********************************************
+
+				RuleIndex
+
+***********************************************/
+
+// ruleIndex is compiled from RuleTable items when the table is built. All rules of all profiles are put in a single index
+// keyed by (namespace, apiGroup, kind), so that only the rules which can match with the request are evaluated.
+// Namespaced requests are looked up by the request namespace (i.e. target namespaces of the profiles), and
+// cluster-scope requests by the empty namespace, where rules of all profiles are put.
+// Label/annotation selectors in the rules are parsed when the index is built.
+type ruleIndex struct {
+	items   []*indexedRuleItem
+	entries []*ruleIndexEntry
+	// indices of entries in ascending order, i.e. in the order of items, rule types (forceCheck, ignore, protect) and rules
+	byKey map[ruleIndexKey][]int
+}
+
+type indexedRuleItem struct {
+	profileNamespace string
+}
+
+type ruleType int
+
+const (
+	ruleTypeForceCheck ruleType = iota
+	ruleTypeIgnore
+	ruleTypeProtect
+)
+
+type ruleIndexEntry struct {
+	item     int
+	ruleType ruleType
+	rule     *common.CompiledRule
+}
+
+type ruleIndexKey struct {
+	namespace string
+	apiGroup  string
+	kind      string
+}
+
+// namespace in ruleIndexKey for cluster-scope requests
+const clusterScopeIndexNamespace = ""
+
+// wildcard key in ruleIndexKey, which is used when the pattern is not a list of exact values
+const anyIndexValue = "\x00*"
+
+func newRuleIndex(items []RuleItem) *ruleIndex {
+	idx := &ruleIndex{
+		items:   []*indexedRuleItem{},
+		entries: []*ruleIndexEntry{},
+		byKey:   map[ruleIndexKey][]int{},
+	}
+	for i, item := range items {
+		idx.items = append(idx.items, &indexedRuleItem{profileNamespace: item.Profile.GetNamespace()})
+		// cluster-scope requests are checked with all profiles
+		namespaces := []string{clusterScopeIndexNamespace}
+		for _, ns := range item.TargetNamespaces {
+			if ns != clusterScopeIndexNamespace && !common.ExactMatchWithPatternArray(ns, namespaces) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		rulesByType := [...][]*common.Rule{
+			ruleTypeForceCheck: item.Profile.Spec.ForceCheckRules,
+			ruleTypeIgnore:     item.Profile.Spec.IgnoreRules,
+			ruleTypeProtect:    item.Profile.Spec.ProtectRules,
+		}
+		for rType, rules := range rulesByType {
+			for _, rule := range rules {
+				if rule == nil {
+					continue
+				}
+				entryIndex := len(idx.entries)
+				idx.entries = append(idx.entries, &ruleIndexEntry{item: i, ruleType: ruleType(rType), rule: common.NewCompiledRule(rule)})
+				for _, key := range ruleIndexKeys(rule) {
+					for _, ns := range namespaces {
+						key.namespace = ns
+						idx.byKey[key] = append(idx.byKey[key], entryIndex)
+					}
+				}
+			}
+		}
+	}
+	return idx
+}
+
+// ruleIndexKeys returns apiGroup/kind keys (without namespace) of the rule
+func ruleIndexKeys(rule *common.Rule) []ruleIndexKey {
+	keys := map[ruleIndexKey]bool{}
+	for _, m := range rule.Match {
+		if m == nil {
+			continue
+		}
+		apiGroups := []string{anyIndexValue}
+		kinds := []string{anyIndexValue}
+		if m.RequestPattern != nil {
+			apiGroups = indexValues(m.ApiGroup)
+			kinds = indexValues(m.Kind)
+		}
+		for _, g := range apiGroups {
+			for _, k := range kinds {
+				keys[ruleIndexKey{apiGroup: g, kind: k}] = true
+			}
+		}
+	}
+	keyList := []ruleIndexKey{}
+	for key := range keys {
+		keyList = append(keyList, key)
+	}
+	return keyList
+}
+
+// indexValues returns exact values in the pattern, or the wildcard key if the pattern can match with other values
+func indexValues(pattern *common.RulePattern) []string {
+	if pattern == nil {
+		return []string{anyIndexValue}
+	}
+	p := strings.TrimSpace(string(*pattern))
+	if p == "" || strings.HasPrefix(p, common.RegexPatternPrefix) {
+		return []string{anyIndexValue}
+	}
+	values := []string{}
+	for _, v := range common.SplitRule(p) {
+		if v == "" || v == "*" || strings.HasPrefix(v, common.RegexPatternPrefix) || strings.ContainsAny(v, "*?[") {
+			return []string{anyIndexValue}
+		}
+		if v == "-" {
+			// "-" matches empty value
+			values = append(values, "")
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// candidates returns indices of the entries which may match with the request, in ascending order
+func (self *ruleIndex) candidates(namespace, apiGroup, kind string) []int {
+	keys := [...]ruleIndexKey{
+		{namespace: namespace, apiGroup: apiGroup, kind: kind},
+		{namespace: namespace, apiGroup: apiGroup, kind: anyIndexValue},
+		{namespace: namespace, apiGroup: anyIndexValue, kind: kind},
+		{namespace: namespace, apiGroup: anyIndexValue, kind: anyIndexValue},
+	}
+	found := 0
+	var single []int
+	for _, key := range keys {
+		if indices, ok := self.byKey[key]; ok && len(indices) > 0 {
+			found++
+			single = indices
+		}
+	}
+	if found <= 1 {
+		return single
+	}
+	merged := []int{}
+	for _, key := range keys {
+		merged = append(merged, self.byKey[key]...)
+	}
+	sort.Ints(merged)
+	uniq := merged[:0]
+	for i, v := range merged {
+		if i == 0 || v != merged[i-1] {
+			uniq = append(uniq, v)
+		}
+	}
+	return uniq
+}
+
+func (self *ruleIndexEntry) match(reqFields map[string]string, reqObjects *common.RequestObjects, strictMatch bool) bool {
+	ignore := self.ruleType == ruleTypeIgnore
+	switch {
+	case ignore && strictMatch:
+		return self.rule.StrictIgnoreMatchWithRequest(reqFields, reqObjects)
+	case ignore:
+		return self.rule.IgnoreMatchWithRequest(reqFields, reqObjects)
+	case strictMatch:
+		return self.rule.StrictMatchWithRequest(reqFields, reqObjects)
+	default:
+		return self.rule.MatchWithRequest(reqFields, reqObjects)
+	}
+}
+
+// checkIfProtected is same as checkIfProtectedWithoutIndex(). In each profile, the first matched rule decides the result,
+// because candidates are in the order of ForceCheck > Ignore > Protect (same as ResourceSigningProfile.Match()).
+func (self *ruleIndex) checkIfProtected(items []RuleItem, reqFields map[string]string, reqObjects *common.RequestObjects, iShieldNS string) (bool, bool, []rspapi.ResourceSigningProfile) {
+	matchedProfiles := []rspapi.ResourceSigningProfile{}
+	protected := false
+	ignoreMatched := false
+	clusterScope := reqFields["ResourceScope"] != "Namespaced"
+	namespace := clusterScopeIndexNamespace
+	if !clusterScope {
+		namespace = reqFields["Namespace"]
+	}
+	// entries of the same item are contiguous in candidates
+	decidedItem := -1
+	for _, i := range self.candidates(namespace, reqFields["ApiGroup"], reqFields["Kind"]) {
+		entry := self.entries[i]
+		if entry.item == decidedItem {
+			continue
+		}
+		profileNamespace := self.items[entry.item].profileNamespace
+		strictMatch := reqFields["ResourceScope"] == "Cluster" && profileNamespace != iShieldNS && profileNamespace != ""
+		if !entry.match(reqFields, reqObjects, strictMatch) {
+			continue
+		}
+		decidedItem = entry.item
+		if entry.ruleType == ruleTypeIgnore {
+			ignoreMatched = true
+		} else {
+			protected = true
+			matchedProfiles = append(matchedProfiles, items[entry.item].Profile)
+		}
+	}
+	return protected, ignoreMatched, matchedProfiles
+}
@@ -17,8 +17,11 @@
 package shield
 
 import (
+	"fmt"
+
 	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
 	"github.com/IBM/integrity-enforcer/shield/pkg/common"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -31,6 +34,8 @@ type RuleTable struct {
 	Items           []RuleItem `json:"items,omitempty"`
 	Namespaces      []string   `json:"namespaces,omitempty"`
 	ShieldNamespace string     `json:"shieldNamespace,omitempty"`
+
+	index *ruleIndex `json:"-"`
 }
 
 func NewRuleTable(profiles []rspapi.ResourceSigningProfile, namespaces []v1.Namespace, commonProfile *common.CommonProfile, shieldNamespace string) *RuleTable {
@@ -43,10 +48,24 @@ func NewRuleTable(profiles []rspapi.ResourceSigningProfile, namespaces []v1.Name
 		commonProfileRSP.Spec.IgnoreRules = commonProfile.IgnoreRules
 		commonProfileRSP.Spec.IgnoreAttrs = commonProfile.IgnoreAttrs
 	}
+	fragments := NewProfileFragments(profiles, shieldNamespace)
 	for _, p := range profiles {
+		if IsProfileFragment(p, shieldNamespace) {
+			// fragments are evaluated only as a part of profiles which refer to them
+			continue
+		}
+		p, errs := fragments.Resolve(p)
+		for _, err := range errs {
+			logger.Warn(fmt.Sprintf("failed to resolve references in %s; %s", profileRef(p), err.Error()))
+		}
 		pNamespace := p.GetNamespace()
 		targetNamespaces := []string{}
-		if pNamespace == shieldNamespace {
+		if p.Kind == common.ClusterProfileCustomResourceKind {
+			// cluster-scope profile protects namespaced resources only in the selected namespaces
+			if nsSelector := p.Spec.TargetNamespaceSelector; nsSelector != nil {
+				targetNamespaces = matchNamespaceListWithSelector(namespaces, nsSelector)
+			}
+		} else if pNamespace == shieldNamespace {
 			nsSelector := p.Spec.TargetNamespaceSelector
 			if nsSelector != nil {
 				targetNamespaces = matchNamespaceListWithSelector(namespaces, nsSelector)
@@ -64,6 +83,7 @@ func NewRuleTable(profiles []rspapi.ResourceSigningProfile, namespaces []v1.Name
 		Items:           items,
 		Namespaces:      allTargetNamespaces,
 		ShieldNamespace: shieldNamespace,
+		index:           newRuleIndex(items),
 	}
 }
 
@@ -74,6 +94,10 @@ func (self *RuleTable) IsEmpty() bool {
 func (self *RuleTable) IsTargetEmpty() bool {
 	count := 0
 	for _, rl := range self.Items {
+		if rl.Profile.Kind == common.ClusterProfileCustomResourceKind {
+			// cluster-scope profile is effective for cluster-scope resources without any target namespace
+			return false
+		}
 		count += len(rl.TargetNamespaces)
 	}
 	return count == 0
@@ -86,17 +110,31 @@ func (self *RuleTable) CheckIfTargetNamespace(nsName string) bool {
 	return common.ExactMatchWithPatternArray(nsName, self.Namespaces)
 }
 
-func (self *RuleTable) CheckIfProtected(reqFields map[string]string) (bool, bool, []rspapi.ResourceSigningProfile) {
+// CheckIfProtected returns whether the request is protected, whether any ignore rule is matched, and the matched profiles.
+// reqObjects has the objects in the request for field conditions (e.g. RequestContext.Objects()), and the objects are parsed
+// from reqFields if it is nil.
+func (self *RuleTable) CheckIfProtected(reqFields map[string]string, reqObjects *common.RequestObjects) (bool, bool, []rspapi.ResourceSigningProfile) {
+	if reqObjects == nil {
+		reqObjects = common.NewRequestObjectsFromFields(reqFields)
+	}
+	// RuleTable which is not built by NewRuleTable() (e.g. unmarshaled one) does not have the index
+	if self.index != nil && len(self.index.items) == len(self.Items) {
+		return self.index.checkIfProtected(self.Items, reqFields, reqObjects, self.ShieldNamespace)
+	}
+	return checkIfProtectedWithoutIndex(self.Items, reqFields, reqObjects, self.ShieldNamespace)
+}
+
+func checkIfProtectedWithoutIndex(items []RuleItem, reqFields map[string]string, reqObjects *common.RequestObjects, shieldNamespace string) (bool, bool, []rspapi.ResourceSigningProfile) {
 	matchedProfiles := []rspapi.ResourceSigningProfile{}
 	reqNs := reqFields["Namespace"]
 	reqScope := reqFields["ResourceScope"]
 	protected := false
 	ignoreMatched := false
-	for _, item := range self.Items {
+	for _, item := range items {
 		if reqScope == "Namespaced" && !common.ExactMatchWithPatternArray(reqNs, item.TargetNamespaces) {
 			continue
 		}
-		if tmpProtected, matchedRule := item.Profile.Match(reqFields, self.ShieldNamespace); tmpProtected {
+		if tmpProtected, matchedRule := item.Profile.Match(reqFields, reqObjects, shieldNamespace); tmpProtected {
 			protected = true
 			matchedProfiles = append(matchedProfiles, item.Profile)
 		} else if !tmpProtected && matchedRule != nil {
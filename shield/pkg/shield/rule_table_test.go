@@ -0,0 +1,241 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	crspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/clusterresourcesigningprofile/v1alpha1"
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	"github.com/IBM/integrity-enforcer/shield/pkg/common"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testRuleTableShieldNamespace = "integrity-shield-operator-system"
+
+var (
+	testRuleApiGroups  = []string{"", "apps", "rbac.authorization.k8s.io", "-", "*", "apps,batch", "re:^(apps|batch)$", "*.k8s.io"}
+	testRuleKinds      = []string{"ConfigMap", "Deployment", "Secret", "ClusterRole", "Config*", "ConfigMap,Secret", "re:^Cluster", "*", ""}
+	testRuleNames      = []string{"", "app-*", "test-0", "re:^test-[0-4]$"}
+	testRuleOperations = []string{"", "CREATE", "UPDATE", "CREATE,UPDATE"}
+	testRuleSelectors  = []string{"", `{"matchLabels":{"app":"a"}}`, `{"matchExpressions":[{"key":"app","operator":"NotIn","values":["b"]}]}`}
+
+	testReqApiGroups = []string{"", "apps", "batch", "rbac.authorization.k8s.io", "policy"}
+	testReqKinds     = []string{"ConfigMap", "Deployment", "Secret", "ClusterRole", "ConfigMapList", "Job"}
+	testReqNames     = []string{"test-0", "test-3", "test-7", "app-1", "other"}
+	testReqLabels    = []string{"", `{"app":"a"}`, `{"app":"b"}`}
+)
+
+func randomRulePattern(r *rand.Rand) *common.RequestPatternWithNamespace {
+	fields := map[string]interface{}{}
+	setField := func(key string, candidates []string) {
+		if v := candidates[r.Intn(len(candidates))]; v != "" {
+			fields[key] = v
+		}
+	}
+	setField("apiGroup", testRuleApiGroups)
+	setField("kind", testRuleKinds)
+	setField("name", testRuleNames)
+	setField("operation", testRuleOperations)
+	if sel := testRuleSelectors[r.Intn(len(testRuleSelectors))]; sel != "" {
+		fields["labelSelector"] = json.RawMessage(sel)
+	}
+	if r.Intn(5) == 0 {
+		fields["namespace"] = fmt.Sprintf("ns-%d", r.Intn(10))
+	}
+	if len(fields) == 0 {
+		fields["kind"] = "ConfigMap"
+	}
+	fieldsBytes, _ := json.Marshal(fields)
+	var pattern *common.RequestPatternWithNamespace
+	_ = json.Unmarshal(fieldsBytes, &pattern)
+	return pattern
+}
+
+func randomRules(r *rand.Rand, max int) []*common.Rule {
+	rules := []*common.Rule{}
+	for i := 0; i < r.Intn(max+1); i++ {
+		rule := &common.Rule{}
+		for j := 0; j <= r.Intn(2); j++ {
+			rule.Match = append(rule.Match, randomRulePattern(r))
+		}
+		if r.Intn(4) == 0 {
+			rule.Exclude = append(rule.Exclude, randomRulePattern(r))
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func testRuleTableData(r *rand.Rand, numProfiles, numNamespaces int) ([]rspapi.ResourceSigningProfile, []v1.Namespace) {
+	namespaces := []v1.Namespace{}
+	for i := 0; i < numNamespaces; i++ {
+		ns := v1.Namespace{}
+		ns.SetName(fmt.Sprintf("ns-%d", i))
+		ns.SetLabels(map[string]string{"group": fmt.Sprintf("g%d", i%3)})
+		namespaces = append(namespaces, ns)
+	}
+	profiles := []rspapi.ResourceSigningProfile{}
+	for i := 0; i < numProfiles; i++ {
+		rsp := rspapi.ResourceSigningProfile{}
+		rsp.SetName(fmt.Sprintf("rsp-%d", i))
+		if r.Intn(4) == 0 {
+			rsp.SetNamespace(testRuleTableShieldNamespace)
+			rsp.Spec.TargetNamespaceSelector = &common.NamespaceSelector{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"group": fmt.Sprintf("g%d", r.Intn(3))}},
+			}
+		} else {
+			rsp.SetNamespace(fmt.Sprintf("ns-%d", r.Intn(numNamespaces)))
+		}
+		rsp.Spec.ProtectRules = randomRules(r, 4)
+		rsp.Spec.IgnoreRules = randomRules(r, 2)
+		rsp.Spec.ForceCheckRules = randomRules(r, 1)
+		profiles = append(profiles, rsp)
+	}
+	return profiles, namespaces
+}
+
+func testRuleTableRequests(r *rand.Rand, num, numNamespaces int) []map[string]string {
+	requests := []map[string]string{}
+	for i := 0; i < num; i++ {
+		kind := testReqKinds[r.Intn(len(testReqKinds))]
+		scope := "Namespaced"
+		namespace := fmt.Sprintf("ns-%d", r.Intn(numNamespaces))
+		if kind == "ClusterRole" {
+			scope = "Cluster"
+			namespace = ""
+		}
+		requests = append(requests, map[string]string{
+			"ApiGroup":      testReqApiGroups[r.Intn(len(testReqApiGroups))],
+			"ApiVersion":    "v1",
+			"Kind":          kind,
+			"Name":          testReqNames[r.Intn(len(testReqNames))],
+			"Namespace":     namespace,
+			"Operation":     testRuleOperations[1+r.Intn(2)],
+			"ResourceScope": scope,
+			"UserName":      "test-user",
+			"ObjLabels":     testReqLabels[r.Intn(len(testReqLabels))],
+			"OldObjLabels":  testReqLabels[r.Intn(len(testReqLabels))],
+		})
+	}
+	return requests
+}
+
+func TestRuleTableIndex(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	profiles, namespaces := testRuleTableData(r, 200, 20)
+	table := NewRuleTable(profiles, namespaces, nil, testRuleTableShieldNamespace)
+	if table.index == nil {
+		t.Fatalf("Test failed for NewRuleTable(); rule index is not built")
+	}
+	protectedCount := 0
+	for _, reqFields := range testRuleTableRequests(r, 2000, 20) {
+		protected, ignoreMatched, matchedProfiles := table.CheckIfProtected(reqFields, nil)
+		expProtected, expIgnoreMatched, expMatchedProfiles := checkIfProtectedWithoutIndex(table.Items, reqFields, nil, table.ShieldNamespace)
+		if protected != expProtected || ignoreMatched != expIgnoreMatched || !reflect.DeepEqual(matchedProfiles, expMatchedProfiles) {
+			t.Errorf("Test failed for CheckIfProtected() with index; request: %v, expected: (%v, %v, %d profiles), actual: (%v, %v, %d profiles)",
+				reqFields, expProtected, expIgnoreMatched, len(expMatchedProfiles), protected, ignoreMatched, len(matchedProfiles))
+		}
+		if protected {
+			protectedCount++
+		}
+	}
+	if protectedCount == 0 {
+		t.Errorf("Test failed for CheckIfProtected() with index; no request is protected by the test profiles")
+	}
+
+	// a table which is not built by NewRuleTable() falls back to the linear search
+	var unmarshaled *RuleTable
+	tableBytes, _ := json.Marshal(table)
+	_ = json.Unmarshal(tableBytes, &unmarshaled)
+	reqFields := map[string]string{"ApiGroup": "", "Kind": "ConfigMap", "Name": "test-0", "Namespace": "ns-0", "Operation": "CREATE", "ResourceScope": "Namespaced"}
+	protected, _, _ := unmarshaled.CheckIfProtected(reqFields, nil)
+	expProtected, _, _ := table.CheckIfProtected(reqFields, nil)
+	if protected != expProtected {
+		t.Errorf("Test failed for CheckIfProtected() without index; expected: %v, actual: %v", expProtected, protected)
+	}
+}
+
+func benchmarkCheckIfProtected(b *testing.B, useIndex bool) {
+	r := rand.New(rand.NewSource(1))
+	profiles, namespaces := testRuleTableData(r, 300, 100)
+	table := NewRuleTable(profiles, namespaces, nil, testRuleTableShieldNamespace)
+	requests := testRuleTableRequests(r, 1000, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reqFields := requests[i%len(requests)]
+		if useIndex {
+			_, _, _ = table.CheckIfProtected(reqFields, nil)
+		} else {
+			_, _, _ = checkIfProtectedWithoutIndex(table.Items, reqFields, nil, table.ShieldNamespace)
+		}
+	}
+}
+
+func BenchmarkCheckIfProtected(b *testing.B) {
+	benchmarkCheckIfProtected(b, true)
+}
+
+func BenchmarkCheckIfProtectedWithoutIndex(b *testing.B) {
+	benchmarkCheckIfProtected(b, false)
+}
+
+func TestClusterProfileRuleTable(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	_, namespaces := testRuleTableData(r, 0, 3)
+	var crsp *crspapi.ClusterResourceSigningProfile
+	crspBytes := []byte(`{"metadata":{"name":"cluster-profile"},"spec":{"namespaceSelector":{"labelSelector":{"matchLabels":{"group":"g0"}}},"protectRules":[{"match":[{"kind":"ClusterRole"},{"kind":"ConfigMap"}]}]}}`)
+	if err := json.Unmarshal(crspBytes, &crsp); err != nil {
+		t.Fatalf("Test failed for unmarshaling ClusterResourceSigningProfile; %s", err.Error())
+	}
+	profiles := []rspapi.ResourceSigningProfile{crsp.ToResourceSigningProfile()}
+	table := NewRuleTable(profiles, namespaces, nil, testRuleTableShieldNamespace)
+	if table.IsTargetEmpty() {
+		t.Errorf("Test failed for IsTargetEmpty() with %s; expected: false, actual: true", common.ClusterProfileCustomResourceKind)
+	}
+
+	testCases := []struct {
+		kind      string
+		namespace string
+		expected  bool
+	}{
+		{kind: "ClusterRole", namespace: "", expected: true},
+		{kind: "ConfigMap", namespace: "ns-0", expected: true},
+		{kind: "ConfigMap", namespace: "ns-1", expected: false},
+		{kind: "Secret", namespace: "ns-0", expected: false},
+	}
+	for _, tc := range testCases {
+		scope := "Namespaced"
+		if tc.namespace == "" {
+			scope = "Cluster"
+		}
+		reqFields := map[string]string{"ApiGroup": "", "Kind": tc.kind, "Name": "test-0", "Namespace": tc.namespace, "Operation": "CREATE", "ResourceScope": scope}
+		protected, _, matchedProfiles := table.CheckIfProtected(reqFields, nil)
+		expProtected, _, _ := checkIfProtectedWithoutIndex(table.Items, reqFields, nil, table.ShieldNamespace)
+		if protected != tc.expected || expProtected != tc.expected {
+			t.Errorf("Test failed for CheckIfProtected() with %s; request: %v, expected: %v, actual: %v (without index: %v)", common.ClusterProfileCustomResourceKind, reqFields, tc.expected, protected, expProtected)
+		}
+		if protected && (len(matchedProfiles) != 1 || matchedProfiles[0].Kind != common.ClusterProfileCustomResourceKind) {
+			t.Errorf("Test failed for CheckIfProtected() with %s; matched profiles: %v", common.ClusterProfileCustomResourceKind, matchedProfiles)
+		}
+	}
+}
@@ -17,7 +17,9 @@
 package shield
 
 import (
+	"context"
 	"encoding/json"
+	"time"
 
 	rsigapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
 	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
@@ -41,12 +43,31 @@ type RunData struct {
 	SignerConfig *sigconfapi.SignerConfig        `json:"signerConfig,omitempty"`
 	ResSigList   *rsigapi.ResourceSignatureList  `json:"resSigList,omitempty"`
 
+	BreakGlassGrants []*BreakGlassGrant `json:"breakGlassGrants,omitempty"`
+
 	loader          *Loader               `json:"-"`
+	reqContext      context.Context       `json:"-"`
+	shared          *SharedData           `json:"-"`
 	commonProfile   *common.CommonProfile `json:"-"`
+	keyPathList     []string              `json:"-"`
 	ruleTable       *RuleTable            `json:"-"`
 	forceInitialize bool                  `json:"-"`
 }
 
+// NewRunDataWithSharedData returns RunData for a single request.
+// RuleTable and SignerConfig are read from the snapshots in SharedData instead of being built for every request.
+func NewRunDataWithSharedData(shared *SharedData) *RunData {
+	return &RunData{shared: shared}
+}
+
+// Context returns the context of the admission request, which has the request deadline
+func (self *RunData) Context() context.Context {
+	if self.reqContext == nil {
+		return context.Background()
+	}
+	return self.reqContext
+}
+
 func (self *RunData) EnableForceInitialize() {
 	self.forceInitialize = true
 	return
@@ -58,7 +79,11 @@ func (self *RunData) DisableForceInitialize() {
 }
 
 func (self *RunData) GetSignerConfig() *sigconfapi.SignerConfig {
-	if self.SignerConfig == nil && self.loader != nil {
+	if self.SignerConfig == nil && self.shared != nil {
+		if snapshot := self.shared.GetSignerConfig(self.loader, self.keyPathList); snapshot != nil {
+			self.SignerConfig = snapshot.SignerConfig
+		}
+	} else if self.SignerConfig == nil && self.loader != nil {
 		self.SignerConfig = self.loader.SignerConfig.GetData(true)
 	}
 	return self.SignerConfig
@@ -71,6 +96,22 @@ func (self *RunData) GetResSigList(resc *common.ResourceContext) *rsigapi.Resour
 	return self.ResSigList
 }
 
+func (self *RunData) GetBreakGlassGrants(keyPathList []string) []*BreakGlassGrant {
+	if self.BreakGlassGrants == nil && self.shared != nil {
+		if snapshot := self.shared.GetSignerConfig(self.loader, keyPathList); snapshot != nil {
+			self.BreakGlassGrants = snapshot.BreakGlassGrants
+		}
+	} else if self.BreakGlassGrants == nil && self.loader != nil {
+		cms := self.loader.BreakGlassRequest.GetData(true)
+		var signerConfig *common.SignerConfig
+		if sigConf := self.GetSignerConfig(); sigConf != nil {
+			signerConfig = sigConf.Spec.Config
+		}
+		self.BreakGlassGrants = getBreakGlassGrants(cms, signerConfig, keyPathList, time.Now().UTC())
+	}
+	return self.BreakGlassGrants
+}
+
 func (self *RunData) setRuleTable(shieldNamespace string) bool {
 	updated := false
 	ruleTable := NewRuleTable(self.RSPList, self.NSList, self.commonProfile, shieldNamespace)
@@ -82,12 +123,17 @@ func (self *RunData) setRuleTable(shieldNamespace string) bool {
 }
 
 func (self *RunData) GetRuleTable(shieldNamespace string) *RuleTable {
+	if self.shared != nil {
+		self.setRuleTableFromSnapshot(shieldNamespace)
+		return self.ruleTable
+	}
+
 	rspReloaded := false
 	nsReloaded := false
 	var tmpRSPList []rspapi.ResourceSigningProfile
 	var tmpNSList []v1.Namespace
 	if self.loader != nil {
-		tmpRSPList, rspReloaded = self.loader.RSP.GetData(true)
+		tmpRSPList, rspReloaded = self.loader.GetProfiles(true)
 		tmpNSList, nsReloaded = self.loader.Namespace.GetData(true)
 		if rspReloaded || len(tmpRSPList) > 0 {
 			self.RSPList = tmpRSPList
@@ -113,13 +159,37 @@ func (self *RunData) GetRuleTable(shieldNamespace string) *RuleTable {
 	return self.ruleTable
 }
 
+// setRuleTableFromSnapshot uses RuleTable in the current snapshot; an empty RuleTable is handled as nil like setRuleTable()
+func (self *RunData) setRuleTableFromSnapshot(shieldNamespace string) {
+	snapshot := self.shared.GetRuleTable(self.loader, self.commonProfile, shieldNamespace)
+	if snapshot == nil {
+		self.ruleTable = nil
+		return
+	}
+	self.RSPList = snapshot.RSPList
+	self.NSList = snapshot.NSList
+	ruleTable := snapshot.RuleTable
+	if ruleTable != nil && !ruleTable.IsEmpty() && !ruleTable.IsTargetEmpty() {
+		self.ruleTable = ruleTable
+	} else {
+		self.ruleTable = nil
+	}
+}
+
 func (self *RunData) Init(conf *config.ShieldConfig) {
+	if self.shared != nil {
+		self.commonProfile = conf.CommonProfile
+		self.keyPathList = conf.KeyPathList
+		self.setRuleTableFromSnapshot(conf.Namespace)
+		return
+	}
+
 	force := false
 	if self.forceInitialize {
 		force = true
 	}
 
-	self.RSPList, _ = self.loader.RSP.GetData(force)
+	self.RSPList, _ = self.loader.GetProfiles(force)
 	self.NSList, _ = self.loader.Namespace.GetData(force)
 	self.commonProfile = conf.CommonProfile
 	rtInited := self.setRuleTable(conf.Namespace)
@@ -130,8 +200,11 @@ func (self *RunData) Init(conf *config.ShieldConfig) {
 }
 
 func (self *RunData) resetRuleTableCache() {
-	self.loader.RSP.ClearCache()
+	self.loader.ClearProfileCache()
 	self.loader.Namespace.ClearCache()
+	if self.shared != nil {
+		self.shared.InvalidateRuleTable()
+	}
 	logger.Debug("RuleTable cache has been cleared")
 	return
 }
@@ -0,0 +1,202 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	sigconfapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/signerconfig/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultRuleTableSnapshotInterval    = time.Second * 10
+	defaultSignerConfigSnapshotInterval = time.Second * 10
+)
+
+/**********************************************
+
+				SharedData
+
+***********************************************/
+
+// SharedData holds data shared by parallel admission requests.
+// Each data is an immutable snapshot; readers never see a partially updated one,
+// and a new snapshot is built by a single goroutine and then swapped atomically.
+type SharedData struct {
+	ruleTable    atomic.Value // *RuleTableSnapshot
+	signerConfig atomic.Value // *SignerConfigSnapshot
+
+	ruleTableMu    sync.Mutex // serializes rebuilding RuleTableSnapshot
+	signerConfigMu sync.Mutex // serializes rebuilding SignerConfigSnapshot
+
+	ruleTableInterval    time.Duration
+	signerConfigInterval time.Duration
+
+	inventory *InventoryQueue // updates of the verification inventory, which are written by InventoryWorker
+}
+
+var defaultSharedData = NewSharedData()
+
+func NewSharedData() *SharedData {
+	return &SharedData{
+		ruleTableInterval:    defaultRuleTableSnapshotInterval,
+		signerConfigInterval: defaultSignerConfigSnapshotInterval,
+		inventory:            newInventoryQueue(),
+	}
+}
+
+// RuleTableSnapshot must not be modified after it is stored in SharedData
+type RuleTableSnapshot struct {
+	RSPList   []rspapi.ResourceSigningProfile
+	NSList    []v1.Namespace
+	RuleTable *RuleTable
+
+	commonProfile   *common.CommonProfile
+	shieldNamespace string
+	expiry          time.Time
+}
+
+func NewRuleTableSnapshot(rspList []rspapi.ResourceSigningProfile, nsList []v1.Namespace, commonProfile *common.CommonProfile, shieldNamespace string, ttl time.Duration) *RuleTableSnapshot {
+	return &RuleTableSnapshot{
+		RSPList:         rspList,
+		NSList:          nsList,
+		RuleTable:       NewRuleTable(rspList, nsList, commonProfile, shieldNamespace),
+		commonProfile:   commonProfile,
+		shieldNamespace: shieldNamespace,
+		expiry:          time.Now().Add(ttl),
+	}
+}
+
+func (self *RuleTableSnapshot) isValid(commonProfile *common.CommonProfile, shieldNamespace string) bool {
+	if self == nil {
+		return false
+	}
+	return self.commonProfile == commonProfile && self.shieldNamespace == shieldNamespace && time.Now().Before(self.expiry)
+}
+
+// SignerConfigSnapshot must not be modified after it is stored in SharedData
+type SignerConfigSnapshot struct {
+	SignerConfig     *sigconfapi.SignerConfig
+	BreakGlassGrants []*BreakGlassGrant
+
+	expiry time.Time
+}
+
+func NewSignerConfigSnapshot(signerConfig *sigconfapi.SignerConfig, grants []*BreakGlassGrant, ttl time.Duration) *SignerConfigSnapshot {
+	return &SignerConfigSnapshot{
+		SignerConfig:     signerConfig,
+		BreakGlassGrants: grants,
+		expiry:           time.Now().Add(ttl),
+	}
+}
+
+func (self *SignerConfigSnapshot) isValid() bool {
+	if self == nil {
+		return false
+	}
+	return time.Now().Before(self.expiry)
+}
+
+func (self *SharedData) loadRuleTable() *RuleTableSnapshot {
+	snapshot, _ := self.ruleTable.Load().(*RuleTableSnapshot)
+	return snapshot
+}
+
+func (self *SharedData) loadSignerConfig() *SignerConfigSnapshot {
+	snapshot, _ := self.signerConfig.Load().(*SignerConfigSnapshot)
+	return snapshot
+}
+
+func (self *SharedData) StoreRuleTable(snapshot *RuleTableSnapshot) {
+	self.ruleTable.Store(snapshot)
+}
+
+func (self *SharedData) StoreSignerConfig(snapshot *SignerConfigSnapshot) {
+	self.signerConfig.Store(snapshot)
+}
+
+// InvalidateRuleTable makes the next request rebuild RuleTable
+func (self *SharedData) InvalidateRuleTable() {
+	self.ruleTable.Store(&RuleTableSnapshot{})
+}
+
+// GetRuleTable returns the current snapshot, or rebuilds it with the loader if it is expired.
+func (self *SharedData) GetRuleTable(loader *Loader, commonProfile *common.CommonProfile, shieldNamespace string) *RuleTableSnapshot {
+	if snapshot := self.loadRuleTable(); snapshot.isValid(commonProfile, shieldNamespace) || loader == nil {
+		return snapshot
+	}
+
+	self.ruleTableMu.Lock()
+	defer self.ruleTableMu.Unlock()
+
+	// another request might have rebuilt it while waiting for the lock
+	current := self.loadRuleTable()
+	if current.isValid(commonProfile, shieldNamespace) {
+		return current
+	}
+
+	rspList, rspReloaded := loader.GetProfiles(true)
+	nsList, nsReloaded := loader.Namespace.GetData(true)
+	// keep the previous list if failed to load new one
+	if current != nil && !rspReloaded && len(rspList) == 0 {
+		rspList = current.RSPList
+	}
+	if current != nil && !nsReloaded && len(nsList) == 0 {
+		nsList = current.NSList
+	}
+	snapshot := NewRuleTableSnapshot(rspList, nsList, commonProfile, shieldNamespace, self.ruleTableInterval)
+	if snapshot.RuleTable.IsEmpty() || snapshot.RuleTable.IsTargetEmpty() {
+		// an empty RuleTable is rebuilt on next request, because RSPs / namespaces may be being created
+		snapshot.expiry = time.Now()
+	}
+	self.StoreRuleTable(snapshot)
+	logger.Trace("RuleTable snapshot is updated.")
+	return snapshot
+}
+
+// GetSignerConfig returns the current snapshot, or rebuilds it with the loader if it is expired.
+func (self *SharedData) GetSignerConfig(loader *Loader, keyPathList []string) *SignerConfigSnapshot {
+	if snapshot := self.loadSignerConfig(); snapshot.isValid() || loader == nil {
+		return snapshot
+	}
+
+	self.signerConfigMu.Lock()
+	defer self.signerConfigMu.Unlock()
+
+	current := self.loadSignerConfig()
+	if current.isValid() {
+		return current
+	}
+
+	sigConf := loader.SignerConfig.GetData(true)
+	var signerConfig *common.SignerConfig
+	if sigConf != nil {
+		signerConfig = sigConf.Spec.Config
+	}
+	cms := loader.BreakGlassRequest.GetData(true)
+	grants := getBreakGlassGrants(cms, signerConfig, keyPathList, time.Now().UTC())
+	snapshot := NewSignerConfigSnapshot(sigConf, grants, self.signerConfigInterval)
+	self.StoreSignerConfig(snapshot)
+	logger.Trace("SignerConfig snapshot is updated.")
+	return snapshot
+}
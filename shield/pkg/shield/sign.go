@@ -17,6 +17,7 @@
 package shield
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -27,6 +28,7 @@ import (
 	helm "github.com/IBM/integrity-enforcer/shield/pkg/plugins/helm"
 	"github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
 	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
 	pgp "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/pgp"
 	"github.com/IBM/integrity-enforcer/shield/pkg/util/sign/sigstore"
 	x509 "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/x509"
@@ -62,7 +64,7 @@ type GeneralSignature struct {
 ***********************************************/
 
 type SignatureEvaluator interface {
-	Eval(resc *common.ResourceContext, resSigList *vrsig.ResourceSignatureList, signingProfile rspapi.ResourceSigningProfile) (*common.SignatureEvalResult, error)
+	Eval(ctx context.Context, resc *common.ResourceContext, reqc *common.RequestContext, resSigList *vrsig.ResourceSignatureList, signingProfile rspapi.ResourceSigningProfile) (*common.SignatureEvalResult, error)
 }
 
 type ConcreteSignatureEvaluator struct {
@@ -79,7 +81,7 @@ func NewSignatureEvaluator(config *config.ShieldConfig, signerConfig *common.Sig
 	}, nil
 }
 
-func (self *ConcreteSignatureEvaluator) GetResourceSignature(ref *common.ResourceRef, resc *common.ResourceContext, resSigList *vrsig.ResourceSignatureList) *GeneralSignature {
+func (self *ConcreteSignatureEvaluator) GetResourceSignature(ctx context.Context, ref *common.ResourceRef, resc *common.ResourceContext, resSigList *vrsig.ResourceSignatureList) *GeneralSignature {
 
 	sigAnnotations := resc.ClaimedMetadata.Annotations.SignatureAnnotations()
 
@@ -160,7 +162,14 @@ func (self *ConcreteSignatureEvaluator) GetResourceSignature(ref *common.Resourc
 
 	//4. helm resource (release secret, helm cahrt resources)
 	if ok := self.plugins["helm"]; ok {
-		rsecBytes, err := helm.FindReleaseSecret(resc.Namespace, resc.Kind, resc.Name, resc.RawObject)
+		helmCtx, cancel := withCheckTimeout(ctx, config.CheckHelmReleaseSecret)
+		rsecBytes, err := helm.FindReleaseSecretWithContext(helmCtx, resc.Namespace, resc.Kind, resc.Name, resc.RawObject)
+		timedOut := checkTimedOut(helmCtx, config.CheckHelmReleaseSecret)
+		cancel()
+		if timedOut {
+			logger.Warn("Timeout in finding helm release secret")
+			return nil
+		}
 		if err != nil {
 			logger.Error(fmt.Sprintf("Error occured in finding helm release secret; %s", err.Error()))
 			return nil
@@ -190,7 +199,7 @@ func (self *ConcreteSignatureEvaluator) GetResourceSignature(ref *common.Resourc
 	// return nil
 }
 
-func (self *ConcreteSignatureEvaluator) Eval(resc *common.ResourceContext, resSigList *vrsig.ResourceSignatureList, signingProfile rspapi.ResourceSigningProfile) (*common.SignatureEvalResult, error) {
+func (self *ConcreteSignatureEvaluator) Eval(ctx context.Context, resc *common.ResourceContext, reqc *common.RequestContext, resSigList *vrsig.ResourceSignatureList, signingProfile rspapi.ResourceSigningProfile) (*common.SignatureEvalResult, error) {
 
 	// eval sign policy
 	ref := resc.ResourceRef()
@@ -202,7 +211,7 @@ func (self *ConcreteSignatureEvaluator) Eval(resc *common.ResourceContext, resSi
 	}
 
 	// find signature
-	rsig := self.GetResourceSignature(ref, resc, resSigList)
+	rsig := self.GetResourceSignature(ctx, ref, resc, resSigList)
 	if rsig == nil {
 		return &common.SignatureEvalResult{
 			Allow:   false,
@@ -252,7 +261,7 @@ func (self *ConcreteSignatureEvaluator) Eval(resc *common.ResourceContext, resSi
 	if resc.ResourceScope == string(common.ScopeNamespaced) {
 		dryRunNamespace = self.config.Namespace
 	}
-	verifier := NewVerifier(rsig.SignType, dryRunNamespace, pgpPubkeys, x509Certs, sigstoreCerts, self.config.KeyPathList, sigstoreEnabled)
+	verifier := NewVerifier(rsig.SignType, dryRunNamespace, self.config.DryRunFallbackEnabled(), self.config.GetMaskDefinitions(), pgpPubkeys, x509Certs, sigstoreCerts, self.config.KeyPathList, sigstoreEnabled)
 
 	// if this verification is not executed in a K8s pod (e.g. using ishieldctl command), then try loading secrets for pubkeys
 	if !kubeutil.IsInCluster() {
@@ -263,7 +272,7 @@ func (self *ConcreteSignatureEvaluator) Eval(resc *common.ResourceContext, resSi
 	}
 
 	// verify signature
-	sigVerifyResult, verifiedKeyPathList, err := verifier.Verify(rsig, resc, signingProfile)
+	sigVerifyResult, verifiedKeyPathList, err := verifier.Verify(ctx, rsig, resc, reqc, signingProfile)
 	if err != nil {
 		reasonFail := fmt.Sprintf("Error during signature verification; %s; %s", sigVerifyResult.Error.Reason, err.Error())
 		return &common.SignatureEvalResult{
@@ -291,8 +300,10 @@ func (self *ConcreteSignatureEvaluator) Eval(resc *common.ResourceContext, resSi
 
 	if sigVerifyResult == nil || sigVerifyResult.Signer == nil {
 		reasonFail := common.ReasonCodeMap[common.REASON_INVALID_SIG].Message
+		var diffs []*mapnode.FieldDiff
 		if sigVerifyResult != nil && sigVerifyResult.Error != nil {
 			reasonFail = fmt.Sprintf("%s; %s", reasonFail, sigVerifyResult.Error.Reason)
+			diffs = sigVerifyResult.Diff
 		}
 		return &common.SignatureEvalResult{
 			Allow:   false,
@@ -301,6 +312,7 @@ func (self *ConcreteSignatureEvaluator) Eval(resc *common.ResourceContext, resSi
 				Reason: reasonFail,
 			},
 			ResourceSignatureUID: rsigUID,
+			Diff:                 diffs,
 		}, nil
 	}
 
@@ -17,6 +17,7 @@
 package shield
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -27,6 +28,7 @@ import (
 	hrm "github.com/IBM/integrity-enforcer/shield/pkg/apis/helmreleasemetadata/v1alpha1"
 	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
 	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
 	helm "github.com/IBM/integrity-enforcer/shield/pkg/plugins/helm"
 	kubeutil "github.com/IBM/integrity-enforcer/shield/pkg/util/kubeutil"
 	logger "github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
@@ -36,6 +38,7 @@ import (
 	sigstore "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/sigstore"
 	x509 "github.com/IBM/integrity-enforcer/shield/pkg/util/sign/x509"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/kubectl/pkg/util/openapi"
 )
 
 /**********************************************
@@ -45,7 +48,7 @@ import (
 ***********************************************/
 
 type VerifierInterface interface {
-	Verify(sig *GeneralSignature, resc *common.ResourceContext, signingProfile rspapi.ResourceSigningProfile) (*SigVerifyResult, []string, error)
+	Verify(ctx context.Context, sig *GeneralSignature, resc *common.ResourceContext, reqc *common.RequestContext, signingProfile rspapi.ResourceSigningProfile) (*SigVerifyResult, []string, error)
 	LoadSecrets(ishieldNS string) error
 }
 
@@ -61,12 +64,14 @@ type ResourceVerifier struct {
 	SigStoreCertPathList  []string
 	AllMountedKeyPathList []string
 	dryRunNamespace       string // namespace for dryrun; should be empty for cluster scope request
+	dryRunFallback        bool   // if false, matching is done only with local defaulting and no resource is created with dryrun
 	sigstoreEnabled       bool
+	maskDefs              map[string][]string // fields ignored in matching by kind, in addition to CommonMessageMask
 }
 
-func NewVerifier(signType SignedResourceType, dryRunNamespace string, pgpKeyPathList, x509CertPathList, sigStoreCertPathList, allKeyPathList []string, sigstoreEnabled bool) VerifierInterface {
+func NewVerifier(signType SignedResourceType, dryRunNamespace string, dryRunFallback bool, maskDefs map[string][]string, pgpKeyPathList, x509CertPathList, sigStoreCertPathList, allKeyPathList []string, sigstoreEnabled bool) VerifierInterface {
 	if signType == SignedResourceTypeResource || signType == SignedResourceTypeApplyingResource || signType == SignedResourceTypePatch {
-		return &ResourceVerifier{dryRunNamespace: dryRunNamespace, PGPKeyPathList: pgpKeyPathList, X509CertPathList: x509CertPathList, SigStoreCertPathList: sigStoreCertPathList, AllMountedKeyPathList: allKeyPathList, sigstoreEnabled: sigstoreEnabled}
+		return &ResourceVerifier{dryRunNamespace: dryRunNamespace, dryRunFallback: dryRunFallback, maskDefs: maskDefs, PGPKeyPathList: pgpKeyPathList, X509CertPathList: x509CertPathList, SigStoreCertPathList: sigStoreCertPathList, AllMountedKeyPathList: allKeyPathList, sigstoreEnabled: sigstoreEnabled}
 	} else if signType == SignedResourceTypeHelm {
 		return &HelmVerifier{Namespace: dryRunNamespace, KeyPathList: pgpKeyPathList}
 	}
@@ -207,7 +212,9 @@ func exists(filename string) bool {
 	return err == nil
 }
 
-func (self *ResourceVerifier) Verify(sig *GeneralSignature, resc *common.ResourceContext, signingProfile rspapi.ResourceSigningProfile) (*SigVerifyResult, []string, error) {
+// Verify verifies the signature of the resource. reqc is the admission request which creates or updates the resource,
+// and it is nil if the resource is checked without a request (e.g. an existing resource).
+func (self *ResourceVerifier) Verify(ctx context.Context, sig *GeneralSignature, resc *common.ResourceContext, reqc *common.RequestContext, signingProfile rspapi.ResourceSigningProfile) (*SigVerifyResult, []string, error) {
 	var vcerr *common.CheckError
 	var vsinfo *common.SignerInfo
 	var retErr error
@@ -215,13 +222,6 @@ func (self *ResourceVerifier) Verify(sig *GeneralSignature, resc *common.Resourc
 	excludeDiffValue := resc.ExcludeDiffValue()
 
 	kustomizeList := signingProfile.Kustomize(resc.Map())
-	allowNSChange := false
-	for _, k := range kustomizeList {
-		if k.AllowNamespaceChange {
-			allowNSChange = true
-			break
-		}
-	}
 	allowDiffPatterns := makeAllowDiffPatterns(resc, kustomizeList)
 
 	protectAttrsList := signingProfile.ProtectAttrs(resc.Map())
@@ -242,20 +242,21 @@ func (self *ResourceVerifier) Verify(sig *GeneralSignature, resc *common.Resourc
 			message = yamlBytes
 		}
 
-		// if allowNamespaceChange is true in KustomizePattern, overwrite namespace in message with requested one
-		if allowNSChange {
-			messageNode, err := mapnode.NewFromYamlBytes([]byte(message))
-			if err == nil {
-				overwriteJson := fmt.Sprintf(`{"metadata":{"namespace":"%s"}}`, resc.Namespace)
-				overwriteNode, _ := mapnode.NewFromBytes([]byte(overwriteJson))
-				newMessageNode, err := messageNode.Merge(overwriteNode)
-				if err == nil {
-					message = newMessageNode.ToYaml()
-				}
+		var matched bool
+		var diffStr string
+		var diffs []*mapnode.FieldDiff
+		// the signed base manifest is compared as it is first, and then after it is transformed in the same way as kustomize overlays
+		// in KustomizePattern, because the overlay may not be applied to the requested object (e.g. deployed without kustomize)
+		for _, kustomized := range kustomizeMessages(message, resc.Namespace, kustomizeList) {
+			if signingProfile.Spec.MatchMode == rspapi.MatchModeServerSideApply {
+				matched, diffStr, diffs = self.MatchMessageWithServerSideApply([]byte(kustomized), resc.RawObject, reqc, resc.ApiGroup, resc.Kind, signingProfile.Spec.FieldManager, signingProfile.Spec.TrustedFieldManagers, protectAttrsList, ignoreAttrsList, allowDiffPatterns, excludeDiffValue)
+			} else {
+				matched, diffStr, diffs = self.MatchMessage(ctx, []byte(kustomized), resc.RawObject, protectAttrsList, ignoreAttrsList, allowDiffPatterns, resc.ResourceScope, resc.ApiGroup, resc.Kind, sig.SignType, excludeDiffValue)
+			}
+			if matched {
+				break
 			}
 		}
-
-		matched, diffStr := self.MatchMessage([]byte(message), resc.RawObject, protectAttrsList, ignoreAttrsList, allowDiffPatterns, resc.ResourceScope, resc.Kind, sig.SignType, excludeDiffValue)
 		if !matched {
 			msg := fmt.Sprintf("The message for this signature in %s is not identical with the requested object. diff: %s", sigFrom, diffStr)
 			return &SigVerifyResult{
@@ -265,6 +266,7 @@ func (self *ResourceVerifier) Verify(sig *GeneralSignature, resc *common.Resourc
 					Error:  nil,
 				},
 				Signer: nil,
+				Diff:   diffs,
 			}, []string{}, nil
 		}
 	}
@@ -370,17 +372,18 @@ func (self *ResourceVerifier) Verify(sig *GeneralSignature, resc *common.Resourc
 	return svresult, verifiedKeyPathList, retErr
 }
 
-func (self *ResourceVerifier) MatchMessage(message, reqObj []byte, protectAttrs, ignoreAttrs []*common.AttrsPattern, allowDiffPatterns []*mapnode.DiffPattern, resScope, resKind string, signType SignedResourceType, excludeDiffValue bool) (bool, string) {
+func (self *ResourceVerifier) MatchMessage(ctx context.Context, message, reqObj []byte, protectAttrs, ignoreAttrs []*common.AttrsPattern, allowDiffPatterns []*mapnode.DiffPattern, resScope, resApiGroup, resKind string, signType SignedResourceType, excludeDiffValue bool) (bool, string, []*mapnode.FieldDiff) {
 	var mask, focus []string
 	matched := false
 	diffStr := ""
-	mask = getMaskDef("")
+	var diffs []*mapnode.FieldDiff
+	mask = getMaskDef(resApiGroup, resKind, self.maskDefs)
 
 	orgObj := []byte(message)
 	orgNode, err := mapnode.NewFromYamlBytes(orgObj)
 	if err != nil {
 		logger.Error(fmt.Sprintf("Error in loading orgNode: %s", err.Error()))
-		return false, ""
+		return false, "", nil
 	}
 
 	focus = []string{}
@@ -397,11 +400,65 @@ func (self *ResourceVerifier) MatchMessage(message, reqObj []byte, protectAttrs,
 	}
 
 	// CASE1: direct matching
-	matched, diffStr = matchContents(orgObj, reqObj, focus, mask, allowDiffPatterns, excludeDiffValue)
+	matched, diffStr, diffs = matchContents(orgObj, reqObj, focus, mask, allowDiffPatterns, excludeDiffValue)
 	if matched {
 		logger.Debug("matched directly")
 	}
 
+	// the signed manifest is defaulted in process first; this works for cluster scope resources too and no API request is needed except for apply/patch
+	// OpenAPI schema is used only for `default` in schema (e.g. CRD), and built-in defaults are applied even without it
+	var schemas openapi.Resources
+	if !matched {
+		schemas, err = kubeutil.GetOpenAPISchema()
+		if err != nil {
+			logger.Debug(fmt.Sprintf("OpenAPI schema is not available for local defaulting: %s", err.Error()))
+		}
+	}
+	localMask := getMaskDef(resApiGroup, resKind, self.maskDefs)
+	localMask = append(localMask, addMask...)
+	localMask = append(localMask, "status") // the requested object may have status like `status: {}`. this will be ignored.
+
+	// CASE2: local defaulting for create or for update by edit/replace
+	if !matched {
+		matched, diffStr, diffs = matchDefaultedContents(orgObj, reqObj, schemas, focus, localMask, allowDiffPatterns, excludeDiffValue)
+		if matched {
+			logger.Debug("matched by DefaultObject()")
+		}
+	}
+	// CASE3: local defaulting for update by apply
+	var applyPatchedBytes []byte
+	if !matched {
+		reqNode, _ := mapnode.NewFromBytes(reqObj)
+		reqNamespace := reqNode.GetString("metadata.namespace")
+		_, applyPatchedBytes, err = kubeutil.GetApplyPatchBytes(orgObj, reqNamespace)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Error in getting patched bytes: %s", err.Error()))
+		} else {
+			matched, diffStr, diffs = matchDefaultedContents(applyPatchedBytes, reqObj, schemas, focus, localMask, allowDiffPatterns, excludeDiffValue)
+			if matched {
+				logger.Debug("matched by GetApplyPatchBytes() and DefaultObject()")
+			}
+		}
+	}
+	// CASE4: local defaulting for update by patch
+	var mergePatchedBytes []byte
+	if !matched && signType == SignedResourceTypePatch {
+		mergePatchedBytes, err = kubeutil.StrategicMergePatch(reqObj, orgObj, "")
+		if err != nil {
+			logger.Error(fmt.Sprintf("Error in getting patched bytes: %s", err.Error()))
+		} else {
+			matched, diffStr, diffs = matchDefaultedContents(mergePatchedBytes, reqObj, schemas, focus, localMask, allowDiffPatterns, excludeDiffValue)
+			if matched {
+				logger.Debug("matched by StrategicMergePatch() and DefaultObject()")
+			}
+		}
+	}
+
+	// DryRun below is only a fallback for the defaults which are not known in IShield, and it can be disabled in ShieldConfig
+	if matched || !self.dryRunFallback {
+		return matched, diffStr, diffs
+	}
+
 	// do not attempt to DryRun for all Cluster scope resources
 	// because ishield-sa does not have a role for creating "any" resource at cluster scope
 	// currently IShield tries dry-run only for CRD request among cluster scope resources
@@ -413,121 +470,235 @@ func (self *ResourceVerifier) MatchMessage(message, reqObj []byte, protectAttrs,
 			addMask = append(addMask, "spec.versions")
 			addMask = append(addMask, "spec.version")
 		} else {
-			return matched, diffStr
+			return matched, diffStr, diffs
 		}
 	}
 
-	// CASE2: DryRun for create or for update by edit/replace
+	// all DryRun trials below share the time budget for dryRun
+	dryRunCtx, cancel := withCheckTimeout(ctx, config.CheckDryRun)
+	defer cancel()
+
+	mask = getMaskDef(resApiGroup, resKind, self.maskDefs)
+	mask = append(mask, addMask...)
+	mask = append(mask, "metadata.name") // DryRunCreate() uses name like `<name>-dry-run` to avoid already exists error
+	mask = append(mask, "status")        // DryRunCreate() may generate different status. this will be ignored.
+
+	// CASE5: DryRun for create or for update by edit/replace
 	if !matched {
 		nsMaskedOrgBytes := orgNode.Mask([]string{"metadata.namespace"}).ToYaml()
-		simObj, err := kubeutil.DryRunCreate([]byte(nsMaskedOrgBytes), self.dryRunNamespace)
+		simObj, err := kubeutil.DryRunCreateWithContext(dryRunCtx, []byte(nsMaskedOrgBytes), self.dryRunNamespace)
 		if err != nil {
+			checkTimedOut(dryRunCtx, config.CheckDryRun)
 			logger.Error(fmt.Sprintf("Error in DryRunCreate: %s", err.Error()))
-			return false, ""
+			return false, "", nil
 		}
-		mask = getMaskDef("")
-		mask = append(mask, addMask...)
-		mask = append(mask, "metadata.name") // DryRunCreate() uses name like `<name>-dry-run` to avoid already exists error
-		mask = append(mask, "status")        // DryRunCreate() may generate different status. this will be ignored.
-
-		matched, diffStr = matchContents(simObj, reqObj, focus, mask, allowDiffPatterns, excludeDiffValue)
+		matched, diffStr, diffs = matchContents(simObj, reqObj, focus, mask, allowDiffPatterns, excludeDiffValue)
 		if matched {
 			logger.Debug("matched by DryRunCreate()")
 		}
 	}
-	// CASE3: DryRun for update by apply
-	if !matched {
-		reqNode, _ := mapnode.NewFromBytes(reqObj)
-		reqNamespace := reqNode.GetString("metadata.namespace")
-		_, patchedBytes, err := kubeutil.GetApplyPatchBytes(orgObj, reqNamespace)
-		if err != nil {
-			logger.Error(fmt.Sprintf("Error in getting patched bytes: %s", err.Error()))
-			return false, ""
-		}
-		patchedNode, _ := mapnode.NewFromBytes(patchedBytes)
+	// CASE6: DryRun for update by apply
+	if !matched && applyPatchedBytes != nil {
+		patchedNode, _ := mapnode.NewFromBytes(applyPatchedBytes)
 		nsMaskedPatchedNode := patchedNode.Mask([]string{"metadata.namespace"})
-		simPatchedObj, err := kubeutil.DryRunCreate([]byte(nsMaskedPatchedNode.ToYaml()), self.dryRunNamespace)
+		simPatchedObj, err := kubeutil.DryRunCreateWithContext(dryRunCtx, []byte(nsMaskedPatchedNode.ToYaml()), self.dryRunNamespace)
 		if err != nil {
+			checkTimedOut(dryRunCtx, config.CheckDryRun)
 			logger.Error(fmt.Sprintf("Error in DryRunCreate for Patch: %s", err.Error()))
-			return false, ""
+			return false, "", nil
 		}
-		mask = getMaskDef("")
-		mask = append(mask, addMask...)
-		mask = append(mask, "metadata.name") // DryRunCreate() uses name like `<name>-dry-run` to avoid already exists error
-		mask = append(mask, "status")        // DryRunCreate() may generate different status. this will be ignored.
-		matched, diffStr = matchContents(simPatchedObj, reqObj, focus, mask, allowDiffPatterns, excludeDiffValue)
+		matched, diffStr, diffs = matchContents(simPatchedObj, reqObj, focus, mask, allowDiffPatterns, excludeDiffValue)
 		if matched {
 			logger.Debug("matched by GetApplyPatchBytes()")
 		}
 	}
-	// CASE4: DryRun for update by patch
-	if !matched && signType == SignedResourceTypePatch {
-		patchedBytes, err := kubeutil.StrategicMergePatch(reqObj, orgObj, "")
-		if err != nil {
-			logger.Error(fmt.Sprintf("Error in getting patched bytes: %s", err.Error()))
-			return false, ""
-		}
-		patchedNode, _ := mapnode.NewFromBytes(patchedBytes)
+	// CASE7: DryRun for update by patch
+	if !matched && mergePatchedBytes != nil {
+		patchedNode, _ := mapnode.NewFromBytes(mergePatchedBytes)
 		nsMaskedPatchedNode := patchedNode.Mask([]string{"metadata.namespace"})
-		simPatchedObj, err := kubeutil.DryRunCreate([]byte(nsMaskedPatchedNode.ToYaml()), self.dryRunNamespace)
+		simPatchedObj, err := kubeutil.DryRunCreateWithContext(dryRunCtx, []byte(nsMaskedPatchedNode.ToYaml()), self.dryRunNamespace)
 		if err != nil {
+			checkTimedOut(dryRunCtx, config.CheckDryRun)
 			logger.Error(fmt.Sprintf("Error in DryRunCreate for Patch: %s", err.Error()))
-			return false, ""
+			return false, "", nil
 		}
-		mask = getMaskDef("")
-		mask = append(mask, addMask...)
-		mask = append(mask, "metadata.name") // DryRunCreate() uses name like `<name>-dry-run` to avoid already exists error
-		mask = append(mask, "status")        // DryRunCreate() may generate different status. this will be ignored.
-		matched, diffStr = matchContents(simPatchedObj, reqObj, focus, mask, allowDiffPatterns, excludeDiffValue)
+		matched, diffStr, diffs = matchContents(simPatchedObj, reqObj, focus, mask, allowDiffPatterns, excludeDiffValue)
 		if matched {
 			logger.Debug("matched by StrategicMergePatch()")
 		}
 	}
-	return matched, diffStr
+	return matched, diffStr, diffs
+}
+
+// MatchMessageWithServerSideApply compares the signed manifest with the fields which are applied by the field manager with server-side apply.
+// managedFields can be set by any client, so it is used only to select the fields to compare, and never to trust fields:
+// the manifest must consist of the fields applied by the field manager, and other fields in the requested object must be identical with
+// the defaults of the signed manifest (masks and ignoreAttrs are applied as usual).
+// Fields owned by a trusted manager are not compared if the request (reqc) is sent by a user who is trusted for the manager.
+// In other requests, they must not be changed from the old object, or they are compared with the signed manifest if there is
+// no old object (e.g. CREATE, or no request for an existing resource).
+func (self *ResourceVerifier) MatchMessageWithServerSideApply(message, reqObj []byte, reqc *common.RequestContext, resApiGroup, resKind, fieldManager string, trustedManagers []*common.TrustedFieldManager, protectAttrs, ignoreAttrs []*common.AttrsPattern, allowDiffPatterns []*mapnode.DiffPattern, excludeDiffValue bool) (bool, string, []*mapnode.FieldDiff) {
+	orgNode, err := mapnode.NewFromYamlBytes(message)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error in loading orgNode: %s", err.Error()))
+		return false, "", nil
+	}
+	reqNode, err := mapnode.NewFromBytes(reqObj)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error in loading reqNode: %s", err.Error()))
+		return false, "", nil
+	}
+	var oldNode *mapnode.Node
+	if reqc != nil && reqc.OldObject != "" {
+		if oldNode, err = mapnode.NewFromBytes([]byte(reqc.OldObject)); err != nil {
+			logger.Error(fmt.Sprintf("Error in loading oldNode: %s", err.Error()))
+			return false, "", nil
+		}
+	}
+	appliedFields, found := kubeutil.GetAppliedFieldSet(reqNode.ToMap(), fieldManager)
+	if !found {
+		return false, fmt.Sprintf("the object is not applied by field manager `%s` with server-side apply", fieldManager), nil
+	}
+
+	focus := []string{}
+	for _, attrs := range protectAttrs {
+		focus = append(focus, attrs.Attrs...)
+	}
+	mask := getMaskDef(resApiGroup, resKind, self.maskDefs)
+	if len(focus) == 0 {
+		for _, attrs := range ignoreAttrs {
+			mask = append(mask, attrs.Attrs...)
+		}
+	}
+
+	appliedOrg, notApplied := kubeutil.ProjectFieldSet(orgNode.Mask(mask).ToMap(), appliedFields)
+	if len(notApplied) > 0 {
+		return false, fmt.Sprintf("fields in the signed manifest are not applied by field manager `%s`: %s", fieldManager, strings.Join(notApplied, ", ")), nil
+	}
+	appliedReq, _ := kubeutil.ProjectFieldSet(reqNode.Mask(mask).ToMap(), appliedFields)
+	appliedOrgBytes, _ := json.Marshal(appliedOrg)
+	appliedReqBytes, _ := json.Marshal(appliedReq)
+
+	matched, diffStr, diffs := matchContents(appliedOrgBytes, appliedReqBytes, focus, mask, allowDiffPatterns, excludeDiffValue)
+	if !matched {
+		return matched, diffStr, diffs
+	}
+
+	// fields owned by trusted managers are not compared with the manifest if the request is trusted for the managers,
+	// or if they are compared with the old object below
+	trustedForRequest, notTrustedForRequest := splitTrustedFieldManagers(trustedManagers, reqc)
+	skippedManagers := trustedForRequest
+	if oldNode != nil {
+		skippedManagers = append(skippedManagers, notTrustedForRequest...)
+	}
+
+	// fields which are not applied by the manager are compared with the defaulted manifest, except the skipped ones
+	schemas, err := kubeutil.GetOpenAPISchema()
+	if err != nil {
+		logger.Debug(fmt.Sprintf("OpenAPI schema is not available for local defaulting: %s", err.Error()))
+	}
+	defaultedOrgBytes, err := kubeutil.DefaultObject(message, schemas)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error in DefaultObject: %s", err.Error()))
+		return false, "", nil
+	}
+	defaultedOrgNode, err := mapnode.NewFromBytes(defaultedOrgBytes)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error in loading defaulted orgNode: %s", err.Error()))
+		return false, "", nil
+	}
+	skippedFields := kubeutil.GetManagedFieldSet(reqNode.ToMap(), skippedManagers)
+	otherFields := kubeutil.GetOtherManagedFieldSet(reqNode.ToMap(), append([]string{fieldManager}, skippedManagers...))
+	otherOrgBytes, _ := json.Marshal(kubeutil.RemoveFieldSet(defaultedOrgNode.ToMap(), skippedFields, otherFields))
+	otherReqBytes, _ := json.Marshal(kubeutil.RemoveFieldSet(reqNode.ToMap(), skippedFields, otherFields))
+
+	matched, diffStr, diffs = matchContents(otherOrgBytes, otherReqBytes, focus, mask, allowDiffPatterns, excludeDiffValue)
+	if !matched {
+		diffStr = fmt.Sprintf("fields which are neither applied by field manager `%s` nor owned by trusted managers are changed: %s", fieldManager, diffStr)
+		return matched, diffStr, diffs
+	}
+
+	// fields owned by trusted managers must not be changed by a request which is not trusted for the managers
+	if oldNode != nil && len(notTrustedForRequest) > 0 {
+		ownedFields := kubeutil.GetManagedFieldSet(reqNode.ToMap(), notTrustedForRequest)
+		ownedOld, _ := kubeutil.ProjectFieldSet(oldNode.Mask(mask).ToMap(), ownedFields)
+		ownedReq, _ := kubeutil.ProjectFieldSet(reqNode.Mask(mask).ToMap(), ownedFields)
+		ownedOldBytes, _ := json.Marshal(ownedOld)
+		ownedReqBytes, _ := json.Marshal(ownedReq)
+		matched, diffStr, diffs = matchContents(ownedOldBytes, ownedReqBytes, focus, mask, allowDiffPatterns, excludeDiffValue)
+		if !matched {
+			diffStr = fmt.Sprintf("fields owned by trusted managers %s are changed by a request which is not trusted for them: %s", strings.Join(notTrustedForRequest, ", "), diffStr)
+			return matched, diffStr, diffs
+		}
+	}
+	logger.Debug(fmt.Sprintf("matched with fields applied by `%s`", fieldManager))
+	return matched, diffStr, diffs
+}
+
+// splitTrustedFieldManagers returns names of the trusted managers which match with the request, and names of the others.
+// No manager matches if there is no request (reqc is nil).
+func splitTrustedFieldManagers(trustedManagers []*common.TrustedFieldManager, reqc *common.RequestContext) ([]string, []string) {
+	trusted := []string{}
+	notTrusted := []string{}
+	for _, m := range trustedManagers {
+		if m == nil || m.Name == "" {
+			continue
+		}
+		if m.MatchWithRequest(reqc) {
+			trusted = append(trusted, m.Name)
+		} else {
+			notTrusted = append(notTrusted, m.Name)
+		}
+	}
+	return trusted, notTrusted
+}
+
+// matchDefaultedContents compares the object defaulted by DefaultObject() with the requested object
+func matchDefaultedContents(orgObj, reqObj []byte, schemas openapi.Resources, focus, mask []string, allowDiffPatterns []*mapnode.DiffPattern, excludeDiffValue bool) (bool, string, []*mapnode.FieldDiff) {
+	defaultedObj, err := kubeutil.DefaultObject(orgObj, schemas)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error in DefaultObject: %s", err.Error()))
+		return false, "", nil
+	}
+	return matchContents(defaultedObj, reqObj, focus, mask, allowDiffPatterns, excludeDiffValue)
 }
 
 func (self *ResourceVerifier) IsPatchWithScopeKey(orgObj, rawObj []byte, scope string, excludeDiffValue bool) bool {
 	var mask []string
-	mask = getMaskDef("")
+	mask = getMaskDef("", "", self.maskDefs)
 	scopeKeys := mapnode.SplitCommaSeparatedKeys(scope)
 	mask = append(mask, scopeKeys...)
-	matched, _ := matchContents(orgObj, rawObj, nil, mask, nil, excludeDiffValue)
+	matched, _, _ := matchContents(orgObj, rawObj, nil, mask, nil, excludeDiffValue)
 	return matched
 }
 
-func getMaskDef(kind string) []string {
-	maskDefBytes := []byte(`
-		{
-		}
-	`)
-	var maskDef map[string][]string
-	err := json.Unmarshal(maskDefBytes, &maskDef)
-	if err != nil {
-		logger.Error(err)
-		return []string{}
-	}
-	maskDef["*"] = CommonMessageMask
+// getMaskDef returns CommonMessageMask and the masks for the kind in the mask table (masks for "*" are applied to all kinds)
+func getMaskDef(apiGroup, kind string, maskDefs map[string][]string) []string {
+	masks := []string{}
+	masks = append(masks, CommonMessageMask...)
+	masks = append(masks, getKindMask(apiGroup, kind, maskDefs)...)
+	return masks
+}
 
+func getKindMask(apiGroup, kind string, maskDefs map[string][]string) []string {
 	masks := []string{}
-	masks = append(masks, maskDef["*"]...)
-	maskForKind, ok := maskDef[kind]
-	if !ok {
-		return masks
+	masks = append(masks, maskDefs["*"]...)
+	if kind != "" && kind != "*" {
+		masks = append(masks, maskDefs[config.MaskDefinitionKey(apiGroup, kind)]...)
 	}
-	masks = append(masks, maskForKind...)
 	return masks
 }
 
-func matchContents(orgObj, reqObj []byte, focus, mask []string, allowDiffPatterns []*mapnode.DiffPattern, excludeDiffValue bool) (bool, string) {
+func matchContents(orgObj, reqObj []byte, focus, mask []string, allowDiffPatterns []*mapnode.DiffPattern, excludeDiffValue bool) (bool, string, []*mapnode.FieldDiff) {
 	orgNode, err := mapnode.NewFromYamlBytes(orgObj)
 	if err != nil {
 		logger.Error("Failed to load original message as *Node", string(orgObj))
-		return false, ""
+		return false, "", nil
 	}
 	reqNode, err := mapnode.NewFromBytes(reqObj)
 	if err != nil {
 		logger.Error("Failed to load requested object as *Node", string(reqObj))
-		return false, ""
+		return false, "", nil
 	}
 
 	matched := false
@@ -541,13 +712,15 @@ func matchContents(orgObj, reqObj []byte, focus, mask []string, allowDiffPattern
 		reqNodeToCompare = reqNode.Mask(mask)
 	}
 
-	dr := orgNodeToCompare.Diff(reqNodeToCompare)
+	dr := orgNodeToCompare.DiffWithListKeys(reqNodeToCompare, getListKeyFunc(reqNode))
 	if dr != nil && len(allowDiffPatterns) > 0 {
 		dr = dr.Remove(allowDiffPatterns)
 	}
 	diffStr := ""
+	var diffs []*mapnode.FieldDiff
 
-	if dr == nil {
+	// all differences may be removed by allowDiffPatterns
+	if dr == nil || dr.Size() == 0 {
 		matched = true
 	}
 
@@ -557,9 +730,30 @@ func matchContents(orgObj, reqObj []byte, focus, mask []string, allowDiffPattern
 		} else {
 			diffStr = dr.String()
 		}
+		diffs = dr.FieldDiffs(excludeDiffValue)
 	}
 
-	return matched, diffStr
+	return matched, diffStr, diffs
+}
+
+// getListKeyFunc returns merge keys of lists in the built-in table, and ones in the strategic merge patch metadata of the object kind
+// for other lists (e.g. lists in custom resources). The built-in table has priority because some keys in it identify items more strictly
+// than the strategic merge patch (e.g. containerPort and protocol for container ports).
+func getListKeyFunc(obj *mapnode.Node) mapnode.ListKeyFunc {
+	schemas, err := kubeutil.GetOpenAPISchema()
+	if err != nil {
+		return mapnode.DefaultListKey
+	}
+	schemaListKey := kubeutil.GetListMergeKeyFunc(obj.GetString("apiVersion"), obj.GetString("kind"), schemas)
+	if schemaListKey == nil {
+		return mapnode.DefaultListKey
+	}
+	return func(listPath string) string {
+		if key := mapnode.DefaultListKey(listPath); key != "" {
+			return key
+		}
+		return schemaListKey(listPath)
+	}
 }
 
 func GenerateMessageFromRawObj(rawObj []byte, filter, mutableAttrs string) string {
@@ -602,10 +796,11 @@ func makeAllowDiffPatterns(resc *common.ResourceContext, kustomizeList []*common
 		return nil
 	}
 
+	// the diff is from the signed base manifest to the requested object
 	key := "metadata.name"
 	values := map[string]interface{}{
-		"before": name,
-		"after":  kustomizedName,
+		"before": kustomizedName,
+		"after":  name,
 	}
 	allowDiffPattern := &mapnode.DiffPattern{
 		Key:    key,
@@ -614,9 +809,70 @@ func makeAllowDiffPatterns(resc *common.ResourceContext, kustomizeList []*common
 	return []*mapnode.DiffPattern{allowDiffPattern}
 }
 
+// kustomizeMessages returns the messages to be compared with the requested object in order; the untransformed message first,
+// and the message transformed by labels, annotations, images and replicas if any pattern has them.
+func kustomizeMessages(message, namespace string, kustomizeList []*common.KustomizePattern) []string {
+	messages := []string{kustomizeMessage(message, namespace, kustomizeList, false)}
+	for _, pattern := range kustomizeList {
+		if pattern != nil && pattern.HasTransformers() {
+			messages = append(messages, kustomizeMessage(message, namespace, kustomizeList, true))
+			break
+		}
+	}
+	return messages
+}
+
+// kustomizeMessage returns the message transformed by the kustomize patterns; namespace is overwritten with the requested one
+// if allowNamespaceChange is true, and labels, annotations, images and replicas are applied if transform is true.
+// Name prefix / suffix are handled by makeAllowDiffPatterns().
+func kustomizeMessage(message, namespace string, kustomizeList []*common.KustomizePattern, transform bool) string {
+	allowNSChange := false
+	patterns := []*common.KustomizePattern{}
+	for _, pattern := range kustomizeList {
+		if pattern == nil {
+			continue
+		}
+		if pattern.AllowNamespaceChange {
+			allowNSChange = true
+		}
+		if transform && pattern.HasTransformers() {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if !allowNSChange && len(patterns) == 0 {
+		return message
+	}
+	messageNode, err := mapnode.NewFromYamlBytes([]byte(message))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error in loading message for kustomize patterns: %s", err.Error()))
+		return message
+	}
+	if allowNSChange {
+		overwriteJson := fmt.Sprintf(`{"metadata":{"namespace":"%s"}}`, namespace)
+		overwriteNode, _ := mapnode.NewFromBytes([]byte(overwriteJson))
+		if newMessageNode, err := messageNode.Merge(overwriteNode); err == nil {
+			messageNode = newMessageNode
+		}
+	}
+	if len(patterns) == 0 {
+		return messageNode.ToYaml()
+	}
+	obj := messageNode.ToMap()
+	for _, pattern := range patterns {
+		obj = pattern.Transform(obj)
+	}
+	transformedNode, err := mapnode.NewFromMap(obj)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error in applying kustomize transformers: %s", err.Error()))
+		return message
+	}
+	return transformedNode.ToYaml()
+}
+
 type SigVerifyResult struct {
 	Error  *common.CheckError
 	Signer *common.SignerInfo
+	Diff   []*mapnode.FieldDiff // differences between the signed manifest and the requested object, if they are not identical
 }
 
 /**********************************************
@@ -630,7 +886,7 @@ type HelmVerifier struct {
 	KeyPathList []string
 }
 
-func (self *HelmVerifier) Verify(sig *GeneralSignature, resc *common.ResourceContext, signingProfile rspapi.ResourceSigningProfile) (*SigVerifyResult, []string, error) {
+func (self *HelmVerifier) Verify(ctx context.Context, sig *GeneralSignature, resc *common.ResourceContext, reqc *common.RequestContext, signingProfile rspapi.ResourceSigningProfile) (*SigVerifyResult, []string, error) {
 	var vcerr *common.CheckError
 	var vsinfo *common.SignerInfo
 	var retErr error
@@ -0,0 +1,349 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/config"
+	mapnode "github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
+)
+
+const testSignedDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: sample-app
+  namespace: sample-ns
+spec:
+  selector:
+    matchLabels:
+      app: sample-app
+  template:
+    metadata:
+      labels:
+        app: sample-app
+    spec:
+      containers:
+      - name: app
+        image: registry.example.com/sample-app:1.0.0
+`
+
+const testRequestedDeployment = `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app","namespace":"sample-ns","uid":"7b0a3c4e-4a52-4a3b-9a7c-2f0c0f3f6c11","generation":1,"creationTimestamp":"2021-05-10T01:23:45Z"},` +
+	`"spec":{"replicas":1,"selector":{"matchLabels":{"app":"sample-app"}},"template":{"metadata":{"creationTimestamp":null,"labels":{"app":"sample-app"}},` +
+	`"spec":{"containers":[{"name":"app","image":"registry.example.com/sample-app:1.0.0","resources":{},"terminationMessagePath":"/dev/termination-log","terminationMessagePolicy":"File","imagePullPolicy":"IfNotPresent"}],` +
+	`"restartPolicy":"Always","terminationGracePeriodSeconds":30,"dnsPolicy":"ClusterFirst","securityContext":{},"schedulerName":"default-scheduler"}},` +
+	`"strategy":{"type":"RollingUpdate","rollingUpdate":{"maxUnavailable":"25%","maxSurge":"25%"}},"revisionHistoryLimit":10,"progressDeadlineSeconds":600},"status":{}}`
+
+func TestMatchMessageWithLocalDefaulting(t *testing.T) {
+	// dryrun is disabled, so the message should be matched without any resource creation
+	verifier := &ResourceVerifier{dryRunNamespace: "", dryRunFallback: false}
+
+	matched, diffStr, _ := verifier.MatchMessage(context.Background(), []byte(testSignedDeployment), []byte(testRequestedDeployment), nil, nil, nil, "Namespaced", "apps", "Deployment", SignedResourceTypeResource, false)
+	if !matched {
+		t.Errorf("the signed manifest should match the defaulted request; diff: %s", diffStr)
+	}
+
+	changedRequest := strings.Replace(testRequestedDeployment, "sample-app:1.0.0", "sample-app:1.0.1", 1)
+	matched, diffStr, diffs := verifier.MatchMessage(context.Background(), []byte(testSignedDeployment), []byte(changedRequest), nil, nil, nil, "Namespaced", "apps", "Deployment", SignedResourceTypeResource, false)
+	if matched {
+		t.Errorf("the signed manifest should not match the changed request")
+	} else if !strings.Contains(diffStr, "image") {
+		t.Errorf("diff should contain the changed image, but got %s", diffStr)
+	}
+	expectedDiff := &mapnode.FieldDiff{Path: "spec.template.spec.containers.0.image", Change: mapnode.ChangeTypeChanged, Before: "registry.example.com/sample-app:1.0.0", After: "registry.example.com/sample-app:1.0.1"}
+	if len(diffs) != 1 || !reflect.DeepEqual(diffs[0], expectedDiff) {
+		diffsBytes, _ := json.Marshal(diffs)
+		t.Errorf("structured diff should have only the changed image, but got %s", string(diffsBytes))
+	}
+
+	// values are not included if excludeDiffValue is true
+	_, _, diffs = verifier.MatchMessage(context.Background(), []byte(testSignedDeployment), []byte(changedRequest), nil, nil, nil, "Namespaced", "apps", "Deployment", SignedResourceTypeResource, true)
+	if len(diffs) != 1 || diffs[0].Before != nil || diffs[0].After != nil {
+		t.Errorf("structured diff should not have values if excludeDiffValue is true")
+	}
+}
+
+const testAppliedDeployment = `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app","namespace":"sample-ns","uid":"7b0a3c4e-4a52-4a3b-9a7c-2f0c0f3f6c11","generation":1,"creationTimestamp":"2021-05-10T01:23:45Z",` +
+	`"annotations":{"deployment.kubernetes.io/revision":"1"},"labels":{"app":"sample-app"},` +
+	`"managedFields":[` +
+	`{"manager":"gitops-controller","operation":"Apply","apiVersion":"apps/v1","fieldsType":"FieldsV1","fieldsV1":{"f:metadata":{"f:labels":{"f:app":{}}},"f:spec":{"f:selector":{"f:matchLabels":{"f:app":{}}},` +
+	`"f:template":{"f:metadata":{"f:labels":{"f:app":{}}},"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{".":{},"f:image":{},"f:name":{}}}}}}}},` +
+	`{"manager":"hpa-controller","operation":"Update","apiVersion":"apps/v1","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:replicas":{}}}},` +
+	`{"manager":"sidecar-injector","operation":"Update","apiVersion":"apps/v1","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"sidecar\"}":{".":{},"f:image":{},"f:name":{}}}}}}}},` +
+	`{"manager":"kube-controller-manager","operation":"Update","apiVersion":"apps/v1","fieldsType":"FieldsV1","fieldsV1":{"f:metadata":{"f:annotations":{".":{},"f:deployment.kubernetes.io/revision":{}}},"f:status":{}}}]},` +
+	`"spec":{"replicas":3,"selector":{"matchLabels":{"app":"sample-app"}},"template":{"metadata":{"creationTimestamp":null,"labels":{"app":"sample-app"}},` +
+	`"spec":{"containers":[{"name":"app","image":"registry.example.com/sample-app:1.0.0","resources":{},"terminationMessagePath":"/dev/termination-log","terminationMessagePolicy":"File","imagePullPolicy":"IfNotPresent"},` +
+	`{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0","resources":{},"terminationMessagePath":"/dev/termination-log","terminationMessagePolicy":"File","imagePullPolicy":"IfNotPresent"}],` +
+	`"restartPolicy":"Always","terminationGracePeriodSeconds":30,"dnsPolicy":"ClusterFirst","securityContext":{},"schedulerName":"default-scheduler"}},` +
+	`"strategy":{"type":"RollingUpdate","rollingUpdate":{"maxUnavailable":"25%","maxSurge":"25%"}},"revisionHistoryLimit":10,"progressDeadlineSeconds":600},"status":{}}`
+
+func TestMatchMessageWithServerSideApply(t *testing.T) {
+	verifier := &ResourceVerifier{}
+	signed := strings.Replace(testSignedDeployment, "  name: sample-app\n  namespace", "  name: sample-app\n  labels:\n    app: sample-app\n  namespace", 1)
+	trusted := []*common.TrustedFieldManager{}
+	trustedStr := `[{"name":"hpa-controller","match":[{"username":"system:serviceaccount:kube-system:horizontal-pod-autoscaler"}]},` +
+		`{"name":"sidecar-injector","match":[{"usergroup":"system:serviceaccounts:sidecar-system"}]},` +
+		`{"name":"kube-controller-manager","match":[{"username":"system:serviceaccount:kube-system:deployment-controller"}]}]`
+	_ = json.Unmarshal([]byte(trustedStr), &trusted)
+	newRequest := func(userName string, userGroups []string, oldObj string) *common.RequestContext {
+		return &common.RequestContext{Operation: "UPDATE", Kind: "Deployment", Namespace: "sample-ns", Name: "sample-app", UserName: userName, UserGroups: userGroups, OldObject: oldObj}
+	}
+	scaledFrom1 := strings.Replace(testAppliedDeployment, `"spec":{"replicas":3`, `"spec":{"replicas":1`, 1)
+	hpaRequest := newRequest("system:serviceaccount:kube-system:horizontal-pod-autoscaler", nil, scaledFrom1)
+
+	// replicas are changed by the trusted requester, and other fields owned by trusted managers are not changed
+	matched, diffStr, _ := verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(testAppliedDeployment), hpaRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false)
+	if !matched {
+		t.Errorf("the signed manifest should match the fields applied by the manager; diff: %s", diffStr)
+	}
+
+	// the same change by a requester who is not trusted for hpa-controller
+	userRequest := newRequest("sample-user", []string{"system:authenticated"}, scaledFrom1)
+	if matched, diffStr, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(testAppliedDeployment), userRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); matched || !strings.Contains(diffStr, "spec.replicas") {
+		t.Errorf("spec.replicas is changed by a requester who is not trusted, but got matched: %v, %s", matched, diffStr)
+	}
+	// fields owned by trusted managers can be kept as is by any requester
+	userRequest = newRequest("sample-user", []string{"system:authenticated"}, testAppliedDeployment)
+	if matched, diffStr, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(testAppliedDeployment), userRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); !matched {
+		t.Errorf("fields owned by trusted managers are not changed, but got diff: %s", diffStr)
+	}
+	// the requester is trusted by the user group
+	sidecarChanged := strings.Replace(testAppliedDeployment, "sidecar:1.0.0", "sidecar:1.0.1", 1)
+	sidecarRequest := newRequest("system:serviceaccount:sidecar-system:injector", []string{"system:serviceaccounts", "system:serviceaccounts:sidecar-system"}, testAppliedDeployment)
+	if matched, diffStr, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(sidecarChanged), sidecarRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); !matched {
+		t.Errorf("the sidecar is changed by a requester in the trusted group, but got diff: %s", diffStr)
+	}
+	if matched, _, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(sidecarChanged), hpaRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); matched {
+		t.Errorf("the sidecar changed by a requester who is not trusted for sidecar-injector should be denied")
+	}
+
+	// fields owned by trusted managers are compared with the manifest if there is no old object or no request
+	createRequest := newRequest("system:serviceaccount:kube-system:horizontal-pod-autoscaler", nil, "")
+	createRequest.Operation = "CREATE"
+	for _, reqc := range []*common.RequestContext{createRequest, nil} {
+		if matched, _, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(testAppliedDeployment), reqc, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); matched {
+			t.Errorf("fields owned by trusted managers should be compared if there is no old object")
+		}
+	}
+
+	// fields owned by other managers are compared if the managers are not trusted
+	if matched, diffStr, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(testAppliedDeployment), hpaRequest, "apps", "Deployment", "gitops-controller", nil, nil, nil, nil, false); matched || !strings.Contains(diffStr, "spec.replicas") {
+		t.Errorf("spec.replicas is changed by a manager which is not trusted, but got matched: %v, %s", matched, diffStr)
+	}
+
+	changed := strings.Replace(testAppliedDeployment, "sample-app:1.0.0", "sample-app:1.0.1", 1)
+	if matched, _, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(changed), hpaRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); matched {
+		t.Errorf("the signed manifest should not match the changed image")
+	}
+
+	// the manifest has a field which is not applied by the manager
+	withReplicas := strings.Replace(signed, "spec:\n  selector", "spec:\n  replicas: 3\n  selector", 1)
+	if matched, diffStr, _ = verifier.MatchMessageWithServerSideApply([]byte(withReplicas), []byte(testAppliedDeployment), hpaRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); matched || !strings.Contains(diffStr, "spec.replicas") {
+		t.Errorf("spec.replicas is not applied by the manager, but got matched: %v, %s", matched, diffStr)
+	}
+
+	if matched, _, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(testAppliedDeployment), hpaRequest, "apps", "Deployment", "kubectl", trusted, nil, nil, nil, false); matched {
+		t.Errorf("the object is not applied by kubectl")
+	}
+
+	// another manager adds hostNetwork, which is denied even though the field is not applied by the manager
+	hostNetwork := strings.Replace(testAppliedDeployment, `"restartPolicy":"Always"`, `"hostNetwork":true,"restartPolicy":"Always"`, 1)
+	hostNetwork = strings.Replace(hostNetwork, `"managedFields":[`, `"managedFields":[{"manager":"another-manager","operation":"Update","apiVersion":"apps/v1","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:template":{"f:spec":{"f:hostNetwork":{}}}}}},`, 1)
+	if matched, diffStr, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(hostNetwork), hpaRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); matched || !strings.Contains(diffStr, "spec.template.spec.hostNetwork") {
+		t.Errorf("hostNetwork added by another manager should be denied, but got matched: %v, %s", matched, diffStr)
+	}
+	// also if a requester claims that the field is owned by a trusted manager in managedFields
+	spoofed := strings.Replace(hostNetwork, `"manager":"another-manager"`, `"manager":"hpa-controller"`, 1)
+	userRequest = newRequest("sample-user", []string{"system:authenticated"}, testAppliedDeployment)
+	if matched, diffStr, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(spoofed), userRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); matched || !strings.Contains(diffStr, "spec.template.spec.hostNetwork") {
+		t.Errorf("hostNetwork claimed as a field of hpa-controller by a requester who is not trusted should be denied, but got matched: %v, %s", matched, diffStr)
+	}
+	// also if the field is not recorded in managedFields
+	hostNetwork = strings.Replace(testAppliedDeployment, `"restartPolicy":"Always"`, `"hostNetwork":true,"restartPolicy":"Always"`, 1)
+	if matched, _, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(hostNetwork), hpaRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); matched {
+		t.Errorf("hostNetwork which is not owned by any manager should be denied")
+	}
+	// the field is not compared if it is in ignoreAttrs
+	ignoreAttrs := []*common.AttrsPattern{{Attrs: []string{"spec.template.spec.hostNetwork"}}}
+	if matched, diffStr, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(hostNetwork), hpaRequest, "apps", "Deployment", "gitops-controller", trusted, nil, ignoreAttrs, nil, false); !matched {
+		t.Errorf("hostNetwork in ignoreAttrs should not be compared; diff: %s", diffStr)
+	}
+	// a field which is changed by another manager in the item owned by a trusted manager is also compared
+	privileged := strings.Replace(testAppliedDeployment, `"image":"registry.example.com/sidecar:1.0.0"`, `"image":"registry.example.com/sidecar:1.0.0","securityContext":{"privileged":true}`, 1)
+	privileged = strings.Replace(privileged, `"managedFields":[`, `"managedFields":[{"manager":"another-manager","operation":"Update","apiVersion":"apps/v1","fieldsType":"FieldsV1","fieldsV1":{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"sidecar\"}":{"f:securityContext":{"f:privileged":{}}}}}}}}},`, 1)
+	if matched, _, _ = verifier.MatchMessageWithServerSideApply([]byte(signed), []byte(privileged), sidecarRequest, "apps", "Deployment", "gitops-controller", trusted, nil, nil, nil, false); matched {
+		t.Errorf("privileged set by another manager in the sidecar should be denied")
+	}
+}
+
+const testSignedService = `apiVersion: v1
+kind: Service
+metadata:
+  name: sample-svc
+  namespace: sample-ns
+spec:
+  type: ClusterIP
+  selector:
+    app: sample-app
+  ports:
+  - port: 80
+    protocol: TCP
+    targetPort: 8080
+`
+
+const testRequestedService = `{"apiVersion":"v1","kind":"Service","metadata":{"name":"sample-svc","namespace":"sample-ns","uid":"0c2a1b7e-2f65-4f4e-8d3b-6b8f1e2d9a10"},` +
+	`"spec":{"type":"ClusterIP","clusterIP":"10.96.12.34","clusterIPs":["10.96.12.34"],"selector":{"app":"sample-app"},"sessionAffinity":"None",` +
+	`"ports":[{"port":80,"protocol":"TCP","targetPort":8080}]},"status":{"loadBalancer":{}}}`
+
+func TestMatchMessageWithMaskDefinitions(t *testing.T) {
+	// clusterIP is assigned by API server, so it does not match without the mask for Service
+	verifier := &ResourceVerifier{dryRunFallback: false}
+	if matched, _, _ := verifier.MatchMessage(context.Background(), []byte(testSignedService), []byte(testRequestedService), nil, nil, nil, "Namespaced", "", "Service", SignedResourceTypeResource, false); matched {
+		t.Errorf("clusterIP should not be masked without mask definitions")
+	}
+
+	shieldConfig := &config.ShieldConfig{}
+	verifier = &ResourceVerifier{dryRunFallback: false, maskDefs: shieldConfig.GetMaskDefinitions()}
+	if matched, diffStr, _ := verifier.MatchMessage(context.Background(), []byte(testSignedService), []byte(testRequestedService), nil, nil, nil, "Namespaced", "", "Service", SignedResourceTypeResource, false); !matched {
+		t.Errorf("clusterIP should be masked by the default mask definitions; diff: %s", diffStr)
+	}
+
+	// the mask for a kind is not applied to the same kind in another group
+	customService := strings.Replace(testRequestedService, `"apiVersion":"v1"`, `"apiVersion":"example.com/v1"`, 1)
+	if matched, _, _ := verifier.MatchMessage(context.Background(), []byte(testSignedService), []byte(customService), nil, nil, nil, "Namespaced", "example.com", "Service", SignedResourceTypeResource, false); matched {
+		t.Errorf("clusterIP should not be masked for Service in another group")
+	}
+
+	// masks in the config replace the defaults of the kind
+	changed := strings.Replace(testRequestedService, `"sessionAffinity":"None"`, `"sessionAffinity":"ClientIP"`, 1)
+	if matched, _, _ := verifier.MatchMessage(context.Background(), []byte(testSignedService), []byte(changed), nil, nil, nil, "Namespaced", "", "Service", SignedResourceTypeResource, false); matched {
+		t.Errorf("sessionAffinity should not be masked by the default mask definitions")
+	}
+	shieldConfig.MaskDefinitions = map[string][]string{"Service": {"spec.clusterIP", "spec.clusterIPs", "spec.sessionAffinity"}}
+	verifier = &ResourceVerifier{dryRunFallback: false, maskDefs: shieldConfig.GetMaskDefinitions()}
+	if matched, diffStr, _ := verifier.MatchMessage(context.Background(), []byte(testSignedService), []byte(changed), nil, nil, nil, "Namespaced", "", "Service", SignedResourceTypeResource, false); !matched {
+		t.Errorf("sessionAffinity should be masked by the mask definitions in the config; diff: %s", diffStr)
+	}
+	shieldConfig.MaskDefinitions = map[string][]string{"Service": {}}
+	verifier = &ResourceVerifier{dryRunFallback: false, maskDefs: shieldConfig.GetMaskDefinitions()}
+	if matched, _, _ := verifier.MatchMessage(context.Background(), []byte(testSignedService), []byte(testRequestedService), nil, nil, nil, "Namespaced", "", "Service", SignedResourceTypeResource, false); matched {
+		t.Errorf("clusterIP should not be masked if the default masks are disabled in the config")
+	}
+}
+
+func TestMatchMessageWithKustomizePatterns(t *testing.T) {
+	// output.yaml is generated from base.yaml with kustomization.yaml by `kustomize build`
+	baseBytes, err := ioutil.ReadFile("./testdata/kustomize/base.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputBytes, err := ioutil.ReadFile("./testdata/kustomize/output.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bases := map[string]string{}
+	for _, doc := range strings.Split(string(baseBytes), "\n---\n") {
+		node, _ := mapnode.NewFromYamlBytes([]byte(doc))
+		bases[node.GetString("kind")] = doc
+	}
+
+	var kust *common.KustomizePattern
+	kustBytes := []byte(`{"match":[{"kind":"Deployment,Service,CronJob"}],"namePrefix":"prod-","allowNamespaceChange":true,` +
+		`"commonLabels":{"env":"prod"},"commonAnnotations":{"owner":"platform-team"},` +
+		`"images":[{"name":"registry.example.com/sample-app","newTag":"1.2.0"},` +
+		`{"name":"registry.example.com/sample-cleanup","newName":"mirror.example.com/sample-cleanup","digest":"sha256:4f1b0a7c3e9d2b8a6f5c4d3e2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f7e6d5c4b3a"}],` +
+		`"replicas":[{"name":"sample-app","count":3}]}`)
+	if err = json.Unmarshal(kustBytes, &kust); err != nil {
+		t.Fatal(err)
+	}
+	kustomizeList := []*common.KustomizePattern{kust}
+
+	verifier := &ResourceVerifier{dryRunFallback: false}
+	match := func(base, output string) (bool, string) {
+		outputNode, _ := mapnode.NewFromYamlBytes([]byte(output))
+		resc := &common.ResourceContext{
+			Name:       outputNode.GetString("metadata.name"),
+			Namespace:  outputNode.GetString("metadata.namespace"),
+			ApiVersion: outputNode.GetString("apiVersion"),
+			Kind:       outputNode.GetString("kind"),
+		}
+		allowDiffPatterns := makeAllowDiffPatterns(resc, kustomizeList)
+		matched, diffStr := false, ""
+		for _, message := range kustomizeMessages(base, resc.Namespace, kustomizeList) {
+			if matched, diffStr, _ = verifier.MatchMessage(context.Background(), []byte(message), []byte(outputNode.ToJson()), nil, nil, allowDiffPatterns, "Namespaced", resc.ApiGroup, resc.Kind, SignedResourceTypeResource, false); matched {
+				break
+			}
+		}
+		return matched, diffStr
+	}
+
+	outputs := strings.Split(string(outputBytes), "\n---\n")
+	if len(outputs) != 3 {
+		t.Fatalf("expected 3 resources in kustomize output, but got %d", len(outputs))
+	}
+	for _, output := range outputs {
+		outputNode, _ := mapnode.NewFromYamlBytes([]byte(output))
+		kind := outputNode.GetString("kind")
+		if matched, diffStr := match(bases[kind], output); !matched {
+			t.Errorf("signed base %s should match with kustomize output; diff: %s", kind, diffStr)
+		}
+		// the base does not match without kustomize patterns except name prefix and namespace
+		noTransformers := []*common.KustomizePattern{{NamePrefix: kust.NamePrefix, AllowNamespaceChange: true}}
+		resc := &common.ResourceContext{Name: outputNode.GetString("metadata.name"), Namespace: "prod", Kind: kind}
+		message := kustomizeMessage(bases[kind], "prod", noTransformers, true)
+		if matched, _, _ := verifier.MatchMessage(context.Background(), []byte(message), []byte(outputNode.ToJson()), nil, nil, makeAllowDiffPatterns(resc, noTransformers), "Namespaced", "", kind, SignedResourceTypeResource, false); matched {
+			t.Errorf("signed base %s should not match with kustomize output without transformers", kind)
+		}
+	}
+
+	// the untransformed base is still accepted, e.g. when it is deployed with only the name prefix and the namespace
+	for _, kind := range []string{"Deployment", "Service"} {
+		baseNode, _ := mapnode.NewFromYamlBytes([]byte(bases[kind]))
+		overwrite, _ := mapnode.NewFromBytes([]byte(fmt.Sprintf(`{"metadata":{"name":"prod-%s","namespace":"prod"}}`, baseNode.GetString("metadata.name"))))
+		untransformed, _ := baseNode.Merge(overwrite)
+		if messages := kustomizeMessages(bases[kind], "prod", kustomizeList); len(messages) != 2 {
+			t.Errorf("expected 2 messages for kustomize patterns with transformers, but got %d", len(messages))
+		}
+		if matched, diffStr := match(bases[kind], untransformed.ToYaml()); !matched {
+			t.Errorf("signed base %s should match with the untransformed object; diff: %s", kind, diffStr)
+		}
+	}
+
+	// changes which are not in the overlay are detected
+	deployment := outputs[1]
+	for _, changed := range []string{
+		strings.Replace(deployment, "sample-app:1.2.0", "sample-app:1.3.0", 1),
+		strings.Replace(deployment, "replicas: 3", "replicas: 5", 1),
+		strings.Replace(deployment, "    owner: platform-team", "    owner: someone-else", 1),
+		strings.Replace(deployment, "      env: prod\n  template", "      env: prod\n      tier: backend\n  template", 1),
+		strings.Replace(deployment, "image: busybox:1.33", "image: busybox:1.34", 1),
+	} {
+		if changed == deployment {
+			t.Fatalf("test input is not changed")
+		}
+		if matched, _ := match(bases["Deployment"], changed); matched {
+			t.Errorf("signed base should not match with changed kustomize output: %s", changed)
+		}
+	}
+}
@@ -36,6 +36,8 @@ type SignerConfigLoader struct {
 	interval        time.Duration
 	shieldNamespace string
 
+	local bool // if true, Data is given in advance and never loaded from cluster
+
 	Client *sigconfclient.ApisV1alpha1Client
 	Data   *sigconfapi.SignerConfig
 }
@@ -53,7 +55,7 @@ func NewSignerConfigLoader(shieldNamespace string) *SignerConfigLoader {
 }
 
 func (self *SignerConfigLoader) GetData(doK8sApiCall bool) *sigconfapi.SignerConfig {
-	if self.Data == nil {
+	if self.Data == nil && !self.local {
 		self.Load(doK8sApiCall)
 	}
 	return self.Data
@@ -0,0 +1,154 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	config "github.com/IBM/integrity-enforcer/shield/pkg/config"
+)
+
+/**********************************************
+
+				Request Deadline
+
+***********************************************/
+
+type requestDeadlineKey struct{}
+
+// requestDeadline is stored in the context of an admission request.
+// It provides per-check budgets and records the checks which ran out of time.
+type requestDeadline struct {
+	timeout  *config.TimeoutConfig
+	mu       sync.Mutex
+	timedOut []string
+}
+
+// newRequestContext returns a context which has the deadline for the whole admission request
+func newRequestContext(timeout *config.TimeoutConfig) (context.Context, context.CancelFunc) {
+	deadline := &requestDeadline{timeout: timeout}
+	ctx := context.WithValue(context.Background(), requestDeadlineKey{}, deadline)
+	return context.WithTimeout(ctx, timeout.RequestTimeout())
+}
+
+func getRequestDeadline(ctx context.Context) *requestDeadline {
+	if ctx == nil {
+		return nil
+	}
+	deadline, _ := ctx.Value(requestDeadlineKey{}).(*requestDeadline)
+	return deadline
+}
+
+// withCheckTimeout returns a context for a single check; the budget is set by ShieldConfig and never exceeds the request deadline
+func withCheckTimeout(ctx context.Context, checkName string) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	deadline := getRequestDeadline(ctx)
+	if deadline == nil {
+		return context.WithCancel(ctx)
+	}
+	budget := deadline.timeout.CheckTimeout(checkName)
+	if budget <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, budget)
+}
+
+// checkTimedOut returns true if the check context ran out of time, and records the check name
+func checkTimedOut(ctx context.Context, checkName string) bool {
+	if ctx == nil || ctx.Err() != context.DeadlineExceeded {
+		return false
+	}
+	if deadline := getRequestDeadline(ctx); deadline != nil {
+		deadline.mu.Lock()
+		deadline.timedOut = append(deadline.timedOut, checkName)
+		deadline.mu.Unlock()
+	}
+	return true
+}
+
+// timeoutMark returns the number of timeouts recorded so far; this is used with timedOutChecksSince()
+func timeoutMark(ctx context.Context) int {
+	deadline := getRequestDeadline(ctx)
+	if deadline == nil {
+		return 0
+	}
+	deadline.mu.Lock()
+	defer deadline.mu.Unlock()
+	return len(deadline.timedOut)
+}
+
+// timedOutChecksSince returns names of the checks which ran out of time after the mark.
+// "request" is returned if the request deadline is exceeded without any recorded check.
+func timedOutChecksSince(ctx context.Context, mark int) []string {
+	deadline := getRequestDeadline(ctx)
+	if deadline == nil {
+		return nil
+	}
+	deadline.mu.Lock()
+	defer deadline.mu.Unlock()
+	checks := []string{}
+	if mark < len(deadline.timedOut) {
+		for _, c := range deadline.timedOut[mark:] {
+			if !contains(checks, c) {
+				checks = append(checks, c)
+			}
+		}
+	}
+	if len(checks) == 0 && ctx.Err() == context.DeadlineExceeded {
+		checks = append(checks, "request")
+	}
+	return checks
+}
+
+// timeoutDecision makes a DecisionResult when a timeout decided the outcome.
+// The request is allowed only if all matched profiles are FailOpen, otherwise it is denied.
+// nil is returned if no check timed out.
+func timeoutDecision(checks []string, profiles []rspapi.ResourceSigningProfile) *DecisionResult {
+	if len(checks) == 0 {
+		return nil
+	}
+	failOpen := len(profiles) > 0
+	var denyRSP *rspapi.ResourceSigningProfile
+	for i := range profiles {
+		if !profiles[i].FailOpen() {
+			failOpen = false
+			denyRSP = &profiles[i]
+			break
+		}
+	}
+	msg := fmt.Sprintf("%s (timed out: %s)", common.ReasonCodeMap[common.REASON_TIMEOUT].Message, strings.Join(checks, ","))
+	if failOpen {
+		return &DecisionResult{
+			Type:       common.DecisionAllow,
+			ReasonCode: common.REASON_TIMEOUT,
+			Message:    msg + "; allowed by FailOpen policy",
+		}
+	}
+	return &DecisionResult{
+		Type:       common.DecisionDeny,
+		ReasonCode: common.REASON_TIMEOUT,
+		Message:    msg,
+		denyRSP:    denyRSP,
+	}
+}
@@ -0,0 +1,64 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"testing"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/config"
+)
+
+func TestTimeoutDecision(t *testing.T) {
+	reqContext, cancel := newRequestContext(&config.TimeoutConfig{DryRun: "1ms"})
+	defer cancel()
+
+	failOpenProfile := rspapi.ResourceSigningProfile{Spec: rspapi.ResourceSigningProfileSpec{FailurePolicy: rspapi.FailurePolicyFailOpen}}
+	failClosedProfile := rspapi.ResourceSigningProfile{}
+
+	mark := timeoutMark(reqContext)
+	if tdr := timeoutDecision(timedOutChecksSince(reqContext, mark), []rspapi.ResourceSigningProfile{failClosedProfile}); tdr != nil {
+		t.Errorf("Test failed for timeoutDecision() without timeout; expected: nil, actual: %s", tdr.String())
+	}
+
+	checkCtx, checkCancel := withCheckTimeout(reqContext, config.CheckDryRun)
+	<-checkCtx.Done()
+	timedOut := checkTimedOut(checkCtx, config.CheckDryRun)
+	checkCancel()
+	if !timedOut {
+		t.Errorf("Test failed for checkTimedOut(); expected: true, actual: false")
+	}
+	if reqContext.Err() != nil {
+		t.Errorf("Test failed for withCheckTimeout(); request context should not be done by a check timeout")
+	}
+
+	tdr := timeoutDecision(timedOutChecksSince(reqContext, mark), []rspapi.ResourceSigningProfile{failOpenProfile})
+	if tdr == nil || !tdr.isAllowed() || tdr.ReasonCode != common.REASON_TIMEOUT {
+		t.Errorf("Test failed for timeoutDecision() with FailOpen profile; actual: %v", tdr)
+	}
+
+	tdr = timeoutDecision(timedOutChecksSince(reqContext, mark), []rspapi.ResourceSigningProfile{failOpenProfile, failClosedProfile})
+	if tdr == nil || !tdr.isDenied() || tdr.ReasonCode != common.REASON_TIMEOUT {
+		t.Errorf("Test failed for timeoutDecision() with FailClosed profile; actual: %v", tdr)
+	}
+
+	// timeouts before the mark are not counted
+	if checks := timedOutChecksSince(reqContext, timeoutMark(reqContext)); len(checks) != 0 {
+		t.Errorf("Test failed for timedOutChecksSince(); expected: [], actual: %v", checks)
+	}
+}
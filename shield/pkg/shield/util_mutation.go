@@ -17,6 +17,7 @@
 package shield
 
 import (
+	"fmt"
 	"strings"
 
 	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
@@ -28,14 +29,16 @@ type MutationChecker interface {
 	Eval(reqc *common.RequestContext, reqobj *common.RequestObject, signingProfile rspapi.ResourceSigningProfile) (*common.MutationEvalResult, error)
 }
 
-type ConcreteMutationChecker struct{}
+type ConcreteMutationChecker struct {
+	maskDefs map[string][]string // fields ignored in mutation check by kind
+}
 
-func NewMutationChecker() MutationChecker {
-	return &ConcreteMutationChecker{}
+func NewMutationChecker(maskDefs map[string][]string) MutationChecker {
+	return &ConcreteMutationChecker{maskDefs: maskDefs}
 }
 
-func MutationCheck(reqc *common.RequestContext, reqobj *common.RequestObject) (*common.MutationEvalResult, error) {
-	checker := NewMutationChecker()
+func MutationCheck(reqc *common.RequestContext, reqobj *common.RequestObject, maskDefs map[string][]string) (*common.MutationEvalResult, error) {
+	checker := NewMutationChecker(maskDefs)
 	dummyProf := rspapi.ResourceSigningProfile{}
 	return checker.Eval(reqc, reqobj, dummyProf)
 }
@@ -55,6 +58,7 @@ func (self *ConcreteMutationChecker) Eval(reqc *common.RequestContext, reqobj *c
 		"metadata.resourceVersion",
 		"status",
 	}
+	mask = append(mask, getKindMask(reqc.ApiGroup, reqc.Kind, self.maskDefs)...)
 
 	maResult := &common.MutationEvalResult{
 		IsMutated: false,
@@ -117,6 +121,7 @@ func (self *ConcreteMutationChecker) Eval(reqc *common.RequestContext, reqobj *c
 		maResult.IsMutated = mr.IsMutated
 		maResult.Diff = mr.Diff
 		maResult.Filtered = mr.Filtered
+		maResult.Violations = mr.Violations
 		maResult.Checked = mr.Checked
 		maResult.Error = &common.CheckError{
 			Error:  mr.Error,
@@ -127,6 +132,7 @@ func (self *ConcreteMutationChecker) Eval(reqc *common.RequestContext, reqobj *c
 		maResult.IsMutated = mr.IsMutated
 		maResult.Diff = mr.Diff
 		maResult.Filtered = mr.Filtered
+		maResult.Violations = mr.Violations
 		maResult.Checked = mr.Checked
 		maResult.Error = &common.CheckError{
 			Error:  mr.Error,
@@ -151,6 +157,7 @@ type MAResult struct {
 	Diff        string
 	Filtered    string
 	MatchedKeys []string
+	Violations  []string
 	Checked     bool
 	Msg         string
 	Error       error
@@ -207,6 +214,8 @@ func GetMAResult(ma4kInput *Ma4kInput, rules []*common.AttrsPattern, excludeDiff
 
 	allMaskKeys := generateMaskKeys(rules,
 		namespace, name, kind, username, userGroups)
+	// list selectors like `containers[name=app]` are resolved with both objects, because the index may be different
+	allMaskKeys = mapnode.ExpandKeys(allMaskKeys, oldObject, newObject)
 
 	// diff
 	dr := oldObject.Diff(newObject)
@@ -221,8 +230,12 @@ func GetMAResult(ma4kInput *Ma4kInput, rules []*common.AttrsPattern, excludeDiff
 		filtered, unfiltered, matchedKeys = dr.Filter(allMaskKeys)
 	}
 
+	// changes allowed by ignoreAttrs must have the values allowed by their constraints
+	violations := checkValueConstraints(rules, filtered,
+		namespace, name, kind, username, userGroups, oldObject, newObject)
+
 	// make result
-	if unfiltered.Size() == 0 {
+	if unfiltered.Size() == 0 && len(violations) == 0 {
 		mr.IsMutated = false
 		mr.Checked = true
 	} else {
@@ -241,18 +254,17 @@ func GetMAResult(ma4kInput *Ma4kInput, rules []*common.AttrsPattern, excludeDiff
 	mr.Diff = diffStr
 	mr.Filtered = filteredStr
 	mr.MatchedKeys = matchedKeys
+	mr.Violations = violations
 	msg := MutationMessage(ma4kInput.Name, unfiltered.Items)
+	if len(violations) > 0 {
+		msg = fmt.Sprintf("changes in %s are not allowed: %s", ma4kInput.Name, strings.Join(violations, "; "))
+	}
 	mr.Msg = msg
 	return mr, nil
 }
 
 func generateMaskKeys(rules []*common.AttrsPattern, namespace, name, kind, username string, usergroups []string) []string {
-	reqFields := map[string]string{}
-	reqFields["Namespace"] = namespace
-	reqFields["Name"] = name
-	reqFields["Kind"] = kind
-	reqFields["UserName"] = username
-	reqFields["UserGroups"] = strings.Join(usergroups, ",")
+	reqFields := maReqFields(namespace, name, kind, username, usergroups)
 
 	maskKey := []string{}
 	for _, rule := range rules {
@@ -262,3 +274,48 @@ func generateMaskKeys(rules []*common.AttrsPattern, namespace, name, kind, usern
 	}
 	return maskKey
 }
+
+// checkValueConstraints returns messages for changes in filtered diff whose new values are not allowed by any matched rule.
+// A change is allowed if a rule without `values` covers the attr, or if the new value satisfies `values` of a rule covering it.
+func checkValueConstraints(rules []*common.AttrsPattern, filtered *mapnode.DiffResult, namespace, name, kind, username string, usergroups []string, oldObject, newObject *mapnode.Node) []string {
+	violations := []string{}
+	if filtered == nil || filtered.Size() == 0 {
+		return violations
+	}
+	reqFields := maReqFields(namespace, name, kind, username, usergroups)
+	allowed := map[string]bool{}
+	errs := map[string][]string{}
+	for _, rule := range rules {
+		if !rule.MatchWith(reqFields) {
+			continue
+		}
+		keys := mapnode.ExpandKeys(rule.Attrs, oldObject, newObject)
+		covered, _, _ := filtered.Filter(keys)
+		for _, item := range covered.Items {
+			if rule.Values == nil {
+				allowed[item.Key] = true
+			} else if err := rule.Values.Check(item.Values["after"]); err != nil {
+				errs[item.Key] = append(errs[item.Key], err.Error())
+			} else {
+				allowed[item.Key] = true
+			}
+		}
+	}
+	for _, item := range filtered.Items {
+		if allowed[item.Key] || len(errs[item.Key]) == 0 {
+			continue
+		}
+		violations = append(violations, fmt.Sprintf("%s: %s", item.Key, strings.Join(errs[item.Key], ", ")))
+	}
+	return violations
+}
+
+func maReqFields(namespace, name, kind, username string, usergroups []string) map[string]string {
+	reqFields := map[string]string{}
+	reqFields["Namespace"] = namespace
+	reqFields["Name"] = name
+	reqFields["Kind"] = kind
+	reqFields["UserName"] = username
+	reqFields["UserGroups"] = strings.Join(usergroups, ",")
+	return reqFields
+}
@@ -0,0 +1,198 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package shield
+
+import (
+	"fmt"
+	"testing"
+
+	rspapi "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
+	common "github.com/IBM/integrity-enforcer/shield/pkg/common"
+	"github.com/IBM/integrity-enforcer/shield/pkg/config"
+)
+
+func TestMutationCheckWithMaskDefinitions(t *testing.T) {
+	reqc := &common.RequestContext{Operation: "UPDATE", Kind: "ServiceAccount", Namespace: "sample-ns", Name: "sample-sa"}
+	// a token secret is added to the service account by the controller
+	reqobj := &common.RequestObject{
+		RawOldObject: []byte(`{"apiVersion":"v1","kind":"ServiceAccount","metadata":{"name":"sample-sa","namespace":"sample-ns"}}`),
+		RawObject:    []byte(`{"apiVersion":"v1","kind":"ServiceAccount","metadata":{"name":"sample-sa","namespace":"sample-ns"},"secrets":[{"name":"sample-sa-token-x7k2p"}]}`),
+	}
+
+	result, err := NewMutationChecker(nil).Eval(reqc, reqobj, rspapi.ResourceSigningProfile{})
+	if err != nil || !result.IsMutated {
+		t.Errorf("secrets should be a mutation without mask definitions; %v", err)
+	}
+
+	shieldConfig := &config.ShieldConfig{}
+	result, err = NewMutationChecker(shieldConfig.GetMaskDefinitions()).Eval(reqc, reqobj, rspapi.ResourceSigningProfile{})
+	if err != nil || result.IsMutated {
+		t.Errorf("secrets should be masked by the default mask definitions; diff: %s", result.Diff)
+	}
+}
+
+func TestMutationCheckWithValueConstraints(t *testing.T) {
+	hpaUser := "system:serviceaccount:kube-system:horizontal-pod-autoscaler"
+	certManagerUser := "system:serviceaccount:cert-manager:cert-manager"
+	// HPA may change replicas only within 2..20, and cert-manager may only update the certificate
+	profile := testLintProfile(t, "test-rsp", "sample-ns", `{"ignoreAttrs":[`+
+		`{"match":[{"kind":"Deployment","username":"`+hpaUser+`"}],"attrs":["spec.replicas"],"values":{"min":"2","max":"20"}},`+
+		`{"match":[{"kind":"Secret","username":"`+certManagerUser+`"}],"attrs":["data.\"tls.crt\""],"values":{"pattern":"[A-Za-z0-9+/=]+"}}]}`)
+	deployment := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app","namespace":"sample-ns"},"spec":{"replicas":%s,"template":{"spec":{"containers":[{"name":"app","image":"%s"}]}}}}`
+	secret := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"sample-tls","namespace":"sample-ns"},"data":{"tls.crt":"%s","tls.key":"%s"}}`
+
+	testCases := []struct {
+		name       string
+		kind       string
+		userName   string
+		oldObj     string
+		newObj     string
+		mutated    bool
+		violations int
+	}{
+		{
+			name:     "replicas in range by HPA",
+			kind:     "Deployment",
+			userName: hpaUser,
+			oldObj:   fmt.Sprintf(deployment, "3", "app:1.0.0"),
+			newObj:   fmt.Sprintf(deployment, "10", "app:1.0.0"),
+		},
+		{
+			name:       "replicas out of range by HPA",
+			kind:       "Deployment",
+			userName:   hpaUser,
+			oldObj:     fmt.Sprintf(deployment, "3", "app:1.0.0"),
+			newObj:     fmt.Sprintf(deployment, "25", "app:1.0.0"),
+			mutated:    true,
+			violations: 1,
+		},
+		{
+			// the allowance is only for HPA, so it is a mutation to be verified with signature (not a violation)
+			name:     "replicas by other user",
+			kind:     "Deployment",
+			userName: "sample-user",
+			oldObj:   fmt.Sprintf(deployment, "3", "app:1.0.0"),
+			newObj:   fmt.Sprintf(deployment, "10", "app:1.0.0"),
+			mutated:  true,
+		},
+		{
+			name:     "image changed by HPA",
+			kind:     "Deployment",
+			userName: hpaUser,
+			oldObj:   fmt.Sprintf(deployment, "3", "app:1.0.0"),
+			newObj:   fmt.Sprintf(deployment, "10", "app:1.0.1"),
+			mutated:  true,
+		},
+		{
+			name:     "tls.crt updated by cert-manager",
+			kind:     "Secret",
+			userName: certManagerUser,
+			oldObj:   fmt.Sprintf(secret, "b2xkLWNlcnQ=", "a2V5"),
+			newObj:   fmt.Sprintf(secret, "bmV3LWNlcnQ=", "a2V5"),
+		},
+		{
+			name:       "tls.crt with invalid value by cert-manager",
+			kind:       "Secret",
+			userName:   certManagerUser,
+			oldObj:     fmt.Sprintf(secret, "b2xkLWNlcnQ=", "a2V5"),
+			newObj:     fmt.Sprintf(secret, "not base64!", "a2V5"),
+			mutated:    true,
+			violations: 1,
+		},
+		{
+			name:     "tls.key updated by cert-manager",
+			kind:     "Secret",
+			userName: certManagerUser,
+			oldObj:   fmt.Sprintf(secret, "b2xkLWNlcnQ=", "a2V5"),
+			newObj:   fmt.Sprintf(secret, "b2xkLWNlcnQ=", "bmV3LWtleQ=="),
+			mutated:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		reqc := &common.RequestContext{Operation: "UPDATE", Kind: tc.kind, Namespace: "sample-ns", Name: "sample", UserName: tc.userName}
+		reqobj := &common.RequestObject{RawOldObject: []byte(tc.oldObj), RawObject: []byte(tc.newObj)}
+		result, err := NewMutationChecker(nil).Eval(reqc, reqobj, profile)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		if result.IsMutated != tc.mutated {
+			t.Errorf("[%s] expected mutated: %v, actual: %v; diff: %s", tc.name, tc.mutated, result.IsMutated, result.Diff)
+		}
+		if len(result.Violations) != tc.violations {
+			t.Errorf("[%s] expected %d violations, but got %v", tc.name, tc.violations, result.Violations)
+		}
+	}
+
+	// the change is allowed if another rule allows it without constraint
+	profile.Spec.IgnoreAttrs = append(profile.Spec.IgnoreAttrs, testLintProfile(t, "test-rsp", "sample-ns", `{"ignoreAttrs":[{"match":[{"kind":"Deployment"}],"attrs":["spec.replicas"]}]}`).Spec.IgnoreAttrs...)
+	reqc := &common.RequestContext{Operation: "UPDATE", Kind: "Deployment", Namespace: "sample-ns", Name: "sample", UserName: hpaUser}
+	reqobj := &common.RequestObject{RawOldObject: []byte(fmt.Sprintf(deployment, "3", "app:1.0.0")), RawObject: []byte(fmt.Sprintf(deployment, "25", "app:1.0.0"))}
+	if result, _ := NewMutationChecker(nil).Eval(reqc, reqobj, profile); result.IsMutated || len(result.Violations) != 0 {
+		t.Errorf("replicas should be allowed by the rule without constraint, but got violations %v", result.Violations)
+	}
+}
+
+func TestMutationCheckWithReorderedLists(t *testing.T) {
+	deployment := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app","namespace":"sample-ns"},"spec":{"template":{"spec":{"initContainers":[%s],"containers":[%s]}}}}`
+	initDB := `{"name":"init-db","image":"db-init:1.0.0"}`
+	initSchema := `{"name":"init-schema","image":"schema-init:1.0.0"}`
+	app := `{"name":"app","image":"app:1.0.0"}`
+	sidecar := `{"name":"sidecar","image":"sidecar:1.0.0"}`
+
+	testCases := []struct {
+		name    string
+		oldObj  string
+		newObj  string
+		mutated bool
+	}{
+		{
+			name:    "no change",
+			oldObj:  fmt.Sprintf(deployment, initDB+","+initSchema, app+","+sidecar),
+			newObj:  fmt.Sprintf(deployment, initDB+","+initSchema, app+","+sidecar),
+			mutated: false,
+		},
+		{
+			// init containers run in the order, so the unsigned reorder must be verified
+			name:    "initContainers reordered",
+			oldObj:  fmt.Sprintf(deployment, initDB+","+initSchema, app+","+sidecar),
+			newObj:  fmt.Sprintf(deployment, initSchema+","+initDB, app+","+sidecar),
+			mutated: true,
+		},
+		{
+			// the mutation check compares lists by position, so any reorder is a mutation
+			name:    "containers reordered",
+			oldObj:  fmt.Sprintf(deployment, initDB+","+initSchema, app+","+sidecar),
+			newObj:  fmt.Sprintf(deployment, initDB+","+initSchema, sidecar+","+app),
+			mutated: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		reqc := &common.RequestContext{Operation: "UPDATE", Kind: "Deployment", Namespace: "sample-ns", Name: "sample-app", UserName: "sample-user"}
+		reqobj := &common.RequestObject{RawOldObject: []byte(tc.oldObj), RawObject: []byte(tc.newObj)}
+		result, err := NewMutationChecker(nil).Eval(reqc, reqobj, rspapi.ResourceSigningProfile{})
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		if result.IsMutated != tc.mutated {
+			t.Errorf("[%s] expected mutated: %v, actual: %v; diff: %s", tc.name, tc.mutated, result.IsMutated, result.Diff)
+		}
+	}
+}
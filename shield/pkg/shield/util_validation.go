@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 
+	crsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/clusterresourcesigningprofile/v1alpha1"
 	hrm "github.com/IBM/integrity-enforcer/shield/pkg/apis/helmreleasemetadata/v1alpha1"
 	rsig "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesignature/v1alpha1"
 	rsp "github.com/IBM/integrity-enforcer/shield/pkg/apis/resourcesigningprofile/v1alpha1"
@@ -42,6 +43,12 @@ func ValidateResource(reqc *common.RequestContext, reqobj *common.RequestObject,
 			return false, fmt.Sprintf("Format validation failed; %s", err.Error())
 		}
 		return ok, ""
+	} else if reqc.Kind == common.ClusterProfileCustomResourceKind {
+		ok, err := ValidateClusterResourceSigningProfile(reqc, reqobj)
+		if err != nil {
+			return false, fmt.Sprintf("Format validation failed; %s", err.Error())
+		}
+		return ok, ""
 	} else if reqc.Kind == common.SignatureCustomResourceKind {
 		ok, err := ValidateResourceSignature(reqc, reqobj)
 		if err != nil {
@@ -81,6 +88,29 @@ func ValidateResourceSigningProfile(reqc *common.RequestContext, reqobj *common.
 	if reqc.Namespace != shieldNamespace && data.Spec.TargetNamespaceSelector != nil {
 		return false, fmt.Errorf("%s.Spec.TargetNamespaceSelector is allowed only for %s in %s.", common.ProfileCustomResourceKind, common.ProfileCustomResourceKind, shieldNamespace)
 	}
+	if reqc.Namespace != shieldNamespace && data.Spec.Fragment {
+		return false, fmt.Errorf("%s.Spec.Fragment is allowed only for %s in %s.", common.ProfileCustomResourceKind, common.ProfileCustomResourceKind, shieldNamespace)
+	}
+	allRules := append([]*common.Rule{}, data.Spec.ProtectRules...)
+	allRules = append(allRules, data.Spec.IgnoreRules...)
+	allRules = append(allRules, data.Spec.ForceCheckRules...)
+	for _, r := range allRules {
+		patterns := append([]*common.RequestPatternWithNamespace{}, r.Match...)
+		patterns = append(patterns, r.Exclude...)
+		for _, m := range patterns {
+			if err := m.Validate(); err != nil {
+				return false, fmt.Errorf("%s has an invalid rule %s; %s", common.ProfileCustomResourceKind, r.String(), err.Error())
+			}
+		}
+		for _, c := range r.Conditions {
+			if err := c.Validate(); err != nil {
+				return false, fmt.Errorf("%s has an invalid rule %s; %s", common.ProfileCustomResourceKind, r.String(), err.Error())
+			}
+		}
+	}
+	if err := validateMatchMode(data.Spec.MatchMode, data.Spec.FieldManager, data.Spec.TrustedFieldManagers); err != nil {
+		return false, fmt.Errorf("%s has an invalid match mode; %s", common.ProfileCustomResourceKind, err.Error())
+	}
 	if reqc.Namespace != shieldNamespace {
 		rules := data.Spec.ProtectRules
 		rules = append(rules, data.Spec.IgnoreRules...)
@@ -107,6 +137,65 @@ func ValidateResourceSigningProfile(reqc *common.RequestContext, reqobj *common.
 	return true, nil
 }
 
+func ValidateClusterResourceSigningProfile(reqc *common.RequestContext, reqobj *common.RequestObject) (bool, error) {
+	var data *crsp.ClusterResourceSigningProfile
+	dec := json.NewDecoder(bytes.NewReader(reqobj.RawObject))
+	dec.DisallowUnknownFields() // Force errors if data has undefined fields
+
+	if err := dec.Decode(&data); err != nil {
+		return false, err
+	}
+	allRules := append([]*common.Rule{}, data.Spec.ProtectRules...)
+	allRules = append(allRules, data.Spec.IgnoreRules...)
+	allRules = append(allRules, data.Spec.ForceCheckRules...)
+	for _, r := range allRules {
+		patterns := append([]*common.RequestPatternWithNamespace{}, r.Match...)
+		patterns = append(patterns, r.Exclude...)
+		for _, m := range patterns {
+			if err := m.Validate(); err != nil {
+				return false, fmt.Errorf("%s has an invalid rule %s; %s", common.ClusterProfileCustomResourceKind, r.String(), err.Error())
+			}
+		}
+		for _, c := range r.Conditions {
+			if err := c.Validate(); err != nil {
+				return false, fmt.Errorf("%s has an invalid rule %s; %s", common.ClusterProfileCustomResourceKind, r.String(), err.Error())
+			}
+		}
+	}
+	if err := validateMatchMode(data.Spec.MatchMode, data.Spec.FieldManager, data.Spec.TrustedFieldManagers); err != nil {
+		return false, fmt.Errorf("%s has an invalid match mode; %s", common.ClusterProfileCustomResourceKind, err.Error())
+	}
+	return true, nil
+}
+
+func validateMatchMode(matchMode rsp.MatchModeType, fieldManager string, trustedFieldManagers []*common.TrustedFieldManager) error {
+	switch matchMode {
+	case "", rsp.MatchModeDefault:
+		if fieldManager != "" {
+			return fmt.Errorf("fieldManager is used only with matchMode `%s`", rsp.MatchModeServerSideApply)
+		}
+		if len(trustedFieldManagers) > 0 {
+			return fmt.Errorf("trustedFieldManagers is used only with matchMode `%s`", rsp.MatchModeServerSideApply)
+		}
+	case rsp.MatchModeServerSideApply:
+		if fieldManager == "" {
+			return fmt.Errorf("fieldManager is required for matchMode `%s`", rsp.MatchModeServerSideApply)
+		}
+		for _, m := range trustedFieldManagers {
+			if m == nil || m.Name == "" || m.Name == fieldManager {
+				return fmt.Errorf("trustedFieldManagers must not have an empty name or fieldManager `%s`", fieldManager)
+			}
+			// the manager name in managedFields can be set by any client, so the requests for the manager must be specified
+			if len(m.Match) == 0 {
+				return fmt.Errorf("trusted field manager `%s` must have `match` for the requests (e.g. `username`) which can change its fields", m.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown matchMode `%s`", matchMode)
+	}
+	return nil
+}
+
 func ValidateResourceSignature(reqc *common.RequestContext, reqobj *common.RequestObject) (bool, error) {
 	var data *rsig.ResourceSignature
 	dec := json.NewDecoder(bytes.NewReader(reqobj.RawObject))
@@ -0,0 +1,413 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package kubeutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	oapi "k8s.io/kube-openapi/pkg/util/proto"
+	"k8s.io/kubectl/pkg/util/openapi"
+)
+
+type defaulterFunc func(obj map[string]interface{})
+
+// builtinDefaulters are the defaults which API server sets on create for common built-in kinds.
+// These are not published in OpenAPI schema, so they are defined here in the same way as the defaulters in k8s.io/kubernetes.
+var builtinDefaulters = map[schema.GroupVersionKind]defaulterFunc{
+	{Group: "", Version: "v1", Kind: "Pod"}:                                          setPodDefaults,
+	{Group: "", Version: "v1", Kind: "Service"}:                                      setServiceDefaults,
+	{Group: "", Version: "v1", Kind: "Secret"}:                                       setSecretDefaults,
+	{Group: "", Version: "v1", Kind: "ReplicationController"}:                        setReplicationControllerDefaults,
+	{Group: "apps", Version: "v1", Kind: "Deployment"}:                               setDeploymentDefaults,
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"}:                              setStatefulSetDefaults,
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"}:                                setDaemonSetDefaults,
+	{Group: "apps", Version: "v1", Kind: "ReplicaSet"}:                               setReplicaSetDefaults,
+	{Group: "batch", Version: "v1", Kind: "Job"}:                                     setJobDefaults,
+	{Group: "batch", Version: "v1beta1", Kind: "CronJob"}:                            setCronJobDefaults,
+	{Group: "batch", Version: "v1", Kind: "CronJob"}:                                 setCronJobDefaults,
+	{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}: setCRDDefaults,
+}
+
+// HasBuiltinDefaulter returns true if the defaults for the kind are known in IShield
+func HasBuiltinDefaulter(gvk schema.GroupVersionKind) bool {
+	_, ok := builtinDefaulters[gvk]
+	return ok
+}
+
+// DefaultObject returns the object (yaml or json) with the default values which API server would set on create, without any API call.
+// The known built-in defaults are set first, and then `default` values in OpenAPI schema (e.g. the structural schema of CRD) are set if schemas is not nil.
+// Fields set by admission controllers or allocated by API server (e.g. `spec.clusterIP`) are not set.
+func DefaultObject(objBytes []byte, schemas openapi.Resources) ([]byte, error) {
+	objJsonBytes, err := yaml.YAMLToJSON(objBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Error in converting YamlToJson; %s", err.Error())
+	}
+	var obj map[string]interface{}
+	err = json.Unmarshal(objJsonBytes, &obj)
+	if err != nil {
+		return nil, fmt.Errorf("Error in Unmarshal into map; %s", err.Error())
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("object is empty")
+	}
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+
+	if defaulter, ok := builtinDefaulters[gvk]; ok {
+		defaulter(obj)
+	}
+	if schemas != nil {
+		if s := schemas.LookupResource(gvk); s != nil {
+			setSchemaDefaults(obj, s)
+		}
+	}
+	return json.Marshal(obj)
+}
+
+// setSchemaDefaults sets `default` in the schema to the fields which are not in the value, and returns the defaulted value
+func setSchemaDefaults(value interface{}, s oapi.Schema) interface{} {
+	if value == nil || s == nil {
+		return value
+	}
+	switch ts := s.(type) {
+	case *oapi.Ref:
+		return setSchemaDefaults(value, ts.SubSchema())
+	case *oapi.Kind:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		for name, fieldSchema := range ts.Fields {
+			fieldValue, found := obj[name]
+			if !found {
+				def := fieldSchema.GetDefault()
+				if def == nil {
+					continue
+				}
+				fieldValue = copyJSONValue(def)
+			}
+			obj[name] = setSchemaDefaults(fieldValue, fieldSchema)
+		}
+		return obj
+	case *oapi.Map:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		for key, v := range obj {
+			obj[key] = setSchemaDefaults(v, ts.SubType)
+		}
+		return obj
+	case *oapi.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return value
+		}
+		for i, v := range items {
+			items[i] = setSchemaDefaults(v, ts.SubType)
+		}
+		return items
+	}
+	return value
+}
+
+// copyJSONValue returns a copy of the default value in schema so that the schema is not modified via the object
+func copyJSONValue(v interface{}) interface{} {
+	vB, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var copied interface{}
+	if err := json.Unmarshal(vB, &copied); err != nil {
+		return v
+	}
+	return copied
+}
+
+/**********************************************
+
+				Built-in defaulters
+
+***********************************************/
+
+func setPodDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	setPodSpecDefaults(spec)
+	// `enableServiceLinks` is defaulted only for Pod, not for pod template
+	setDefault(spec, "enableServiceLinks", true)
+}
+
+func setServiceDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	svcType := setDefault(spec, "type", "ClusterIP")
+	if svcType == "ExternalName" {
+		return
+	}
+	setDefault(spec, "sessionAffinity", "None")
+	if svcType == "NodePort" || svcType == "LoadBalancer" {
+		setDefault(spec, "externalTrafficPolicy", "Cluster")
+	}
+	for _, port := range getMapList(spec, "ports") {
+		setDefault(port, "protocol", "TCP")
+		if _, ok := port["targetPort"]; !ok {
+			port["targetPort"] = port["port"]
+		}
+	}
+}
+
+func setSecretDefaults(obj map[string]interface{}) {
+	setDefault(obj, "type", "Opaque")
+}
+
+func setReplicationControllerDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	setDefault(spec, "replicas", 1)
+	setPodTemplateDefaults(spec)
+	// selector is defaulted to the labels of pod template
+	if _, ok := spec["selector"]; !ok {
+		if template, ok := spec["template"].(map[string]interface{}); ok {
+			if meta, ok := template["metadata"].(map[string]interface{}); ok && meta["labels"] != nil {
+				spec["selector"] = copyJSONValue(meta["labels"])
+			}
+		}
+	}
+}
+
+func setDeploymentDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	setDefault(spec, "replicas", 1)
+	setDefault(spec, "revisionHistoryLimit", 10)
+	setDefault(spec, "progressDeadlineSeconds", 600)
+	strategy := getOrCreateMap(spec, "strategy")
+	if setDefault(strategy, "type", "RollingUpdate") == "RollingUpdate" {
+		rollingUpdate := getOrCreateMap(strategy, "rollingUpdate")
+		setDefault(rollingUpdate, "maxUnavailable", "25%")
+		setDefault(rollingUpdate, "maxSurge", "25%")
+	}
+	setPodTemplateDefaults(spec)
+}
+
+func setStatefulSetDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	setDefault(spec, "replicas", 1)
+	setDefault(spec, "revisionHistoryLimit", 10)
+	setDefault(spec, "podManagementPolicy", "OrderedReady")
+	updateStrategy := getOrCreateMap(spec, "updateStrategy")
+	if setDefault(updateStrategy, "type", "RollingUpdate") == "RollingUpdate" {
+		rollingUpdate := getOrCreateMap(updateStrategy, "rollingUpdate")
+		setDefault(rollingUpdate, "partition", 0)
+	}
+	for _, pvc := range getMapList(spec, "volumeClaimTemplates") {
+		pvcSpec := getOrCreateMap(pvc, "spec")
+		setDefault(pvcSpec, "volumeMode", "Filesystem")
+		getOrCreateMap(pvc, "status")["phase"] = "Pending"
+	}
+	setPodTemplateDefaults(spec)
+}
+
+func setDaemonSetDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	setDefault(spec, "revisionHistoryLimit", 10)
+	updateStrategy := getOrCreateMap(spec, "updateStrategy")
+	if setDefault(updateStrategy, "type", "RollingUpdate") == "RollingUpdate" {
+		rollingUpdate := getOrCreateMap(updateStrategy, "rollingUpdate")
+		setDefault(rollingUpdate, "maxUnavailable", 1)
+	}
+	setPodTemplateDefaults(spec)
+}
+
+func setReplicaSetDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	setDefault(spec, "replicas", 1)
+	setPodTemplateDefaults(spec)
+}
+
+func setJobDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	setJobSpecDefaults(spec)
+}
+
+func setJobSpecDefaults(spec map[string]interface{}) {
+	_, hasCompletions := spec["completions"]
+	_, hasParallelism := spec["parallelism"]
+	if !hasCompletions && !hasParallelism {
+		spec["completions"] = 1
+		spec["parallelism"] = 1
+	}
+	setDefault(spec, "parallelism", 1)
+	setDefault(spec, "backoffLimit", 6)
+	setPodTemplateDefaults(spec)
+}
+
+func setCronJobDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	setDefault(spec, "concurrencyPolicy", "Allow")
+	setDefault(spec, "suspend", false)
+	setDefault(spec, "successfulJobsHistoryLimit", 3)
+	setDefault(spec, "failedJobsHistoryLimit", 1)
+	jobTemplate := getOrCreateMap(spec, "jobTemplate")
+	setNullCreationTimestamp(jobTemplate)
+	setJobSpecDefaults(getOrCreateMap(jobTemplate, "spec"))
+}
+
+func setCRDDefaults(obj map[string]interface{}) {
+	spec := getOrCreateMap(obj, "spec")
+	conversion := getOrCreateMap(spec, "conversion")
+	setDefault(conversion, "strategy", "None")
+	names, ok := spec["names"].(map[string]interface{})
+	if ok {
+		if kind, ok := names["kind"].(string); ok {
+			setDefault(names, "singular", strings.ToLower(kind))
+			setDefault(names, "listKind", kind+"List")
+		}
+	}
+}
+
+func setPodTemplateDefaults(spec map[string]interface{}) {
+	template, ok := spec["template"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	setNullCreationTimestamp(template)
+	setPodSpecDefaults(getOrCreateMap(template, "spec"))
+}
+
+func setPodSpecDefaults(podSpec map[string]interface{}) {
+	setDefault(podSpec, "restartPolicy", "Always")
+	setDefault(podSpec, "terminationGracePeriodSeconds", 30)
+	setDefault(podSpec, "dnsPolicy", "ClusterFirst")
+	setDefault(podSpec, "schedulerName", "default-scheduler")
+	getOrCreateMap(podSpec, "securityContext")
+	if sa, ok := podSpec["serviceAccountName"]; ok {
+		setDefault(podSpec, "serviceAccount", sa)
+	}
+	for _, key := range []string{"initContainers", "containers"} {
+		for _, container := range getMapList(podSpec, key) {
+			setContainerDefaults(container)
+		}
+	}
+	for _, volume := range getMapList(podSpec, "volumes") {
+		setVolumeDefaults(volume)
+	}
+}
+
+func setContainerDefaults(container map[string]interface{}) {
+	setDefault(container, "terminationMessagePath", "/dev/termination-log")
+	setDefault(container, "terminationMessagePolicy", "File")
+	image, _ := container["image"].(string)
+	setDefault(container, "imagePullPolicy", defaultImagePullPolicy(image))
+	getOrCreateMap(container, "resources")
+	for _, port := range getMapList(container, "ports") {
+		setDefault(port, "protocol", "TCP")
+	}
+	for _, env := range getMapList(container, "env") {
+		if valueFrom, ok := env["valueFrom"].(map[string]interface{}); ok {
+			if fieldRef, ok := valueFrom["fieldRef"].(map[string]interface{}); ok {
+				setDefault(fieldRef, "apiVersion", "v1")
+			}
+		}
+	}
+	for _, key := range []string{"livenessProbe", "readinessProbe", "startupProbe"} {
+		if probe, ok := container[key].(map[string]interface{}); ok {
+			setProbeDefaults(probe)
+		}
+	}
+}
+
+func setProbeDefaults(probe map[string]interface{}) {
+	setDefault(probe, "timeoutSeconds", 1)
+	setDefault(probe, "periodSeconds", 10)
+	setDefault(probe, "successThreshold", 1)
+	setDefault(probe, "failureThreshold", 3)
+	if httpGet, ok := probe["httpGet"].(map[string]interface{}); ok {
+		setDefault(httpGet, "path", "/")
+		setDefault(httpGet, "scheme", "HTTP")
+	}
+}
+
+func setVolumeDefaults(volume map[string]interface{}) {
+	// default mode 0644
+	for _, key := range []string{"configMap", "secret", "downwardAPI", "projected"} {
+		if source, ok := volume[key].(map[string]interface{}); ok {
+			setDefault(source, "defaultMode", 420)
+		}
+	}
+	if hostPath, ok := volume["hostPath"].(map[string]interface{}); ok {
+		setDefault(hostPath, "type", "")
+	}
+}
+
+// defaultImagePullPolicy is `Always` for an image with `latest` tag or without tag, otherwise `IfNotPresent`
+func defaultImagePullPolicy(image string) string {
+	if strings.Contains(image, "@") {
+		return "IfNotPresent"
+	}
+	name := image
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	i := strings.LastIndex(name, ":")
+	if i < 0 || name[i+1:] == "latest" {
+		return "Always"
+	}
+	return "IfNotPresent"
+}
+
+// setNullCreationTimestamp sets `creationTimestamp: null` in the metadata of template in the same way as API server
+func setNullCreationTimestamp(template map[string]interface{}) {
+	meta := getOrCreateMap(template, "metadata")
+	if _, ok := meta["creationTimestamp"]; !ok {
+		meta["creationTimestamp"] = nil
+	}
+}
+
+// setDefault sets the value if the key is not found, and returns the current value
+func setDefault(obj map[string]interface{}, key string, value interface{}) interface{} {
+	if current, ok := obj[key]; ok {
+		return current
+	}
+	obj[key] = value
+	return value
+}
+
+func getOrCreateMap(obj map[string]interface{}, key string) map[string]interface{} {
+	if m, ok := obj[key].(map[string]interface{}); ok {
+		return m
+	}
+	m := map[string]interface{}{}
+	obj[key] = m
+	return m
+}
+
+func getMapList(obj map[string]interface{}, key string) []map[string]interface{} {
+	items, ok := obj[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	list := []map[string]interface{}{}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			list = append(list, m)
+		}
+	}
+	return list
+}
@@ -0,0 +1,101 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package kubeutil
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/mapnode"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	oapi "k8s.io/kube-openapi/pkg/util/proto"
+)
+
+type testSchemaResources struct {
+	schemas map[schema.GroupVersionKind]oapi.Schema
+}
+
+func (self *testSchemaResources) LookupResource(gvk schema.GroupVersionKind) oapi.Schema {
+	return self.schemas[gvk]
+}
+
+func TestDefaultObject(t *testing.T) {
+	objBytes, err := ioutil.ReadFile("testdata/sample_deployment.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBytes, err := ioutil.ReadFile("testdata/sample_deployment_defaulted.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaulted, err := DefaultObject(objBytes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultedNode, _ := mapnode.NewFromBytes(defaulted)
+	expectedNode, _ := mapnode.NewFromBytes(expectedBytes)
+	// fields set by API server other than defaulting
+	mask := []string{"metadata.uid", "metadata.generation", "metadata.creationTimestamp", "metadata.managedFields", "status"}
+	if dr := defaultedNode.Mask(mask).Diff(expectedNode.Mask(mask)); dr != nil {
+		t.Errorf("defaulted object is not identical with the one created by API server; diff: %s", dr.String())
+	}
+
+	// values in the object are not overwritten
+	defaulted, _ = DefaultObject([]byte(`{"apiVersion":"v1","kind":"Service","metadata":{"name":"svc"},"spec":{"type":"NodePort","ports":[{"port":80,"targetPort":8080}]}}`), nil)
+	svcNode, _ := mapnode.NewFromBytes(defaulted)
+	if svcNode.GetString("spec.type") != "NodePort" || svcNode.GetString("spec.externalTrafficPolicy") != "Cluster" || svcNode.GetString("spec.sessionAffinity") != "None" {
+		t.Errorf("service is not defaulted correctly: %s", string(defaulted))
+	}
+
+	if policy := defaultImagePullPolicy("registry.example.com:5000/app"); policy != "Always" {
+		t.Errorf("imagePullPolicy should be Always for an image without tag, but got %s", policy)
+	}
+}
+
+func TestDefaultObjectWithSchema(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Sample"}
+	itemSchema := &oapi.Kind{
+		Fields: map[string]oapi.Schema{
+			"name":  &oapi.Primitive{Type: "string"},
+			"ready": &oapi.Primitive{Type: "boolean", BaseSchema: oapi.BaseSchema{Default: false}},
+		},
+	}
+	specSchema := &oapi.Kind{
+		Fields: map[string]oapi.Schema{
+			"replicas": &oapi.Primitive{Type: "integer", BaseSchema: oapi.BaseSchema{Default: 1}},
+			"mode":     &oapi.Primitive{Type: "string", BaseSchema: oapi.BaseSchema{Default: "auto"}},
+			"items":    &oapi.Array{SubType: itemSchema},
+			"options":  &oapi.Kind{Fields: map[string]oapi.Schema{"retry": &oapi.Primitive{Type: "integer", BaseSchema: oapi.BaseSchema{Default: 3}}}, BaseSchema: oapi.BaseSchema{Default: map[string]interface{}{}}},
+		},
+	}
+	schemas := &testSchemaResources{schemas: map[schema.GroupVersionKind]oapi.Schema{
+		gvk: &oapi.Kind{Fields: map[string]oapi.Schema{"spec": specSchema}},
+	}}
+
+	defaulted, err := DefaultObject([]byte(`{"apiVersion":"example.com/v1","kind":"Sample","metadata":{"name":"sample"},"spec":{"mode":"manual","items":[{"name":"a"},{"name":"b","ready":true}]}}`), schemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, _ := mapnode.NewFromBytes(defaulted)
+	if node.GetString("spec.replicas") != "1" || node.GetString("spec.mode") != "manual" || node.GetString("spec.options.retry") != "3" {
+		t.Errorf("schema defaults are not set correctly: %s", string(defaulted))
+	}
+	items, _ := node.GetNodeByJSONPath("$.spec.items[*].ready")
+	if items == nil || items.String() != "[false,true]" {
+		t.Errorf("schema defaults in array are not set correctly: %s", string(defaulted))
+	}
+}
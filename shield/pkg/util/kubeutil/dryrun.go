@@ -32,15 +32,18 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
-	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	oapi "k8s.io/kube-openapi/pkg/util/proto"
 	"k8s.io/kubectl/pkg/scheme"
 	"k8s.io/kubectl/pkg/util"
-	"k8s.io/kubectl/pkg/util/openapi"
 )
 
 func DryRunCreate(objBytes []byte, namespace string) ([]byte, error) {
+	return DryRunCreateWithContext(context.Background(), objBytes, namespace)
+}
+
+// DryRunCreateWithContext is the same as DryRunCreate(), but the API request is canceled when the context is done
+func DryRunCreateWithContext(ctx context.Context, objBytes []byte, namespace string) ([]byte, error) {
 	config, err := GetKubeConfig()
 	if err != nil {
 		return nil, fmt.Errorf("Error in getting k8s config; %s", err.Error())
@@ -109,9 +112,9 @@ func DryRunCreate(objBytes []byte, namespace string) ([]byte, error) {
 
 	var simObj *unstructured.Unstructured
 	if namespace == "" {
-		simObj, err = gvClient.Create(context.Background(), obj, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		simObj, err = gvClient.Create(ctx, obj, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
 	} else {
-		simObj, err = gvClient.Namespace(namespace).Create(context.Background(), obj, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		simObj, err = gvClient.Namespace(namespace).Create(ctx, obj, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
 	}
 	if err != nil {
 		return nil, fmt.Errorf("Error in creating resource; %s, gvk: %s", err.Error(), gvk)
@@ -163,19 +166,32 @@ func StrategicMergePatch(objBytes, patchBytes []byte, namespace string) ([]byte,
 	if err != nil {
 		return nil, fmt.Errorf("Error in converting current obj to json; %s", err.Error())
 	}
-	creator := scheme.Scheme
-	if !creator.Recognizes(gvk) {
-		creator.AddKnownTypeWithName(gvk, obj)
+	// use the patch strategy in OpenAPI schema if available, which is shared with GetApplyPatchBytes(); otherwise use the one in go struct
+	var lookupPatchMeta strategicpatch.LookupPatchMeta
+	if openAPISchema, err := GetOpenAPISchema(); err == nil && openAPISchema != nil {
+		if schema := openAPISchema.LookupResource(gvk); schema != nil {
+			lookupPatchMeta = strategicpatch.PatchMetaFromOpenAPI{Schema: schema}
+		}
 	}
-	mocObj, err := creator.New(gvk)
-	if err != nil {
-		return nil, fmt.Errorf("Error in getting moc obj; %s", err.Error())
+	if lookupPatchMeta == nil {
+		creator := scheme.Scheme
+		if !creator.Recognizes(gvk) {
+			creator.AddKnownTypeWithName(gvk, obj)
+		}
+		mocObj, err := creator.New(gvk)
+		if err != nil {
+			return nil, fmt.Errorf("Error in getting moc obj; %s", err.Error())
+		}
+		lookupPatchMeta, err = strategicpatch.NewPatchMetaFromStruct(mocObj)
+		if err != nil {
+			return nil, fmt.Errorf("Error in getting patch meta; %s", err.Error())
+		}
 	}
 	patchJsonBytes, err := yaml.YAMLToJSON(patchBytes)
 	if err != nil {
 		return nil, fmt.Errorf("Error in converting patchBytes to json; %s", err.Error())
 	}
-	patchedBytes, err := strategicpatch.StrategicMergePatch(currentObjBytes, patchJsonBytes, mocObj)
+	patchedBytes, err := strategicpatch.StrategicMergePatchUsingLookupPatchMeta(currentObjBytes, patchJsonBytes, lookupPatchMeta)
 	if err != nil {
 		return nil, fmt.Errorf("Error in getting patched obj bytes; %s", err.Error())
 	}
@@ -191,17 +207,9 @@ func GetApplyPatchBytes(objBytes []byte, namespace string) ([]byte, []byte, erro
 	if err != nil {
 		return nil, nil, fmt.Errorf("Error in creating DynamicClient; %s", err.Error())
 	}
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
-	if err != nil {
-		return nil, nil, fmt.Errorf("Error in creating DiscoveryClient; %s", err.Error())
-	}
-	openAPISchemaDoc, err := discoveryClient.OpenAPISchema()
-	if err != nil {
-		return nil, nil, fmt.Errorf("Failed to get OpenAPISchema Document; %s", err.Error())
-	}
-	openAPISchema, err := openapi.NewOpenAPIData(openAPISchemaDoc)
+	openAPISchema, err := GetOpenAPISchema()
 	if err != nil {
-		return nil, nil, fmt.Errorf("Failed to get OpenAPISchema; %s", err.Error())
+		return nil, nil, err
 	}
 
 	obj := &unstructured.Unstructured{}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -30,6 +31,8 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
 )
 
 var cfg *rest.Config
@@ -108,15 +111,23 @@ func GetAPIResources() ([]metav1.APIResource, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error in creating discovery client; %s", err.Error())
 	}
+	return getAPIResources(discoveryClient)
+}
 
+// getAPIResources returns preferred resources of all groups. If only some groups fail in discovery
+// (e.g. an unavailable aggregated API), resources in the other groups are returned.
+func getAPIResources(discoveryClient discovery.ServerResourcesInterface) ([]metav1.APIResource, error) {
 	apiResourceLists, err := discoveryClient.ServerPreferredResources()
 	if err != nil {
-		return nil, fmt.Errorf("Error in getting server preferred resources; %s", err.Error())
+		if !discovery.IsGroupDiscoveryFailedError(err) || len(apiResourceLists) == 0 {
+			return nil, fmt.Errorf("Error in getting server preferred resources; %s", err.Error())
+		}
+		logger.Warn(fmt.Sprintf("Resources in some groups are skipped; %s", err.Error()))
 	}
 
 	resources := []metav1.APIResource{}
 	for _, apiResourceList := range apiResourceLists {
-		if len(apiResourceList.APIResources) == 0 {
+		if apiResourceList == nil || len(apiResourceList.APIResources) == 0 {
 			continue
 		}
 		gv, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
@@ -184,3 +195,85 @@ func GetResource(apiVersion, kind, namespace, name string) (*unstructured.Unstru
 	}
 	return resource, nil
 }
+
+// IsListable returns false for subresources and resources which do not support `list`
+func IsListable(apiResource metav1.APIResource) bool {
+	if strings.Contains(apiResource.Name, "/") {
+		return false
+	}
+	for _, verb := range apiResource.Verbs {
+		if verb == "list" {
+			return true
+		}
+	}
+	return false
+}
+
+// ListResources returns all resources of the APIResource (e.g. one in GetAPIResources()).
+// If namespace is empty, namespaced resources in all namespaces are listed.
+func ListResources(apiResource metav1.APIResource, namespace string) ([]unstructured.Unstructured, error) {
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Error in getting k8s config; %s", err.Error())
+	}
+
+	dyClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("Error in creating DynamicClient; %s", err.Error())
+	}
+
+	gvr := schema.GroupVersionResource{
+		Group:    apiResource.Group,
+		Version:  apiResource.Version,
+		Resource: apiResource.Name,
+	}
+	var list *unstructured.UnstructuredList
+	if apiResource.Namespaced && namespace != "" {
+		list, err = dyClient.Resource(gvr).Namespace(namespace).List(context.Background(), metav1.ListOptions{})
+	} else {
+		list, err = dyClient.Resource(gvr).List(context.Background(), metav1.ListOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error in listing resources; %s", err.Error())
+	}
+	return list.Items, nil
+}
+
+// ListResourcesInPages lists resources of the APIResource in chunks of `limit` items, and calls handle() for each chunk,
+// so that a large number of resources is not loaded into memory at once.
+// If namespace is empty, namespaced resources in all namespaces are listed.
+func ListResourcesInPages(apiResource metav1.APIResource, namespace string, limit int64, handle func([]unstructured.Unstructured) error) error {
+	config, err := GetKubeConfig()
+	if err != nil {
+		return fmt.Errorf("Error in getting k8s config; %s", err.Error())
+	}
+
+	dyClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("Error in creating DynamicClient; %s", err.Error())
+	}
+
+	gvr := schema.GroupVersionResource{
+		Group:    apiResource.Group,
+		Version:  apiResource.Version,
+		Resource: apiResource.Name,
+	}
+	var resourceClient dynamic.ResourceInterface = dyClient.Resource(gvr)
+	if apiResource.Namespaced && namespace != "" {
+		resourceClient = dyClient.Resource(gvr).Namespace(namespace)
+	}
+	continueToken := ""
+	for {
+		list, err := resourceClient.List(context.Background(), metav1.ListOptions{Limit: limit, Continue: continueToken})
+		if err != nil {
+			return fmt.Errorf("Error in listing resources; %s", err.Error())
+		}
+		if err := handle(list.Items); err != nil {
+			return err
+		}
+		continueToken = list.GetContinue()
+		if continueToken == "" {
+			return nil
+		}
+	}
+}
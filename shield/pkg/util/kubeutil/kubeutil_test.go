@@ -17,12 +17,15 @@
 package kubeutil
 
 import (
+	"errors"
 	"io/ioutil"
 	"testing"
 
 	"github.com/ghodss/yaml"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 )
 
@@ -64,3 +67,61 @@ func TestMatchLabels(t *testing.T) {
 		t.Error("TestMatchLabels failed; label should be matched with the test object")
 	}
 }
+
+type testDiscoveryClient struct {
+	discovery.ServerResourcesInterface
+	lists []*metav1.APIResourceList
+	err   error
+}
+
+func (self *testDiscoveryClient) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return self.lists, self.err
+}
+
+func TestGetAPIResources(t *testing.T) {
+	lists := []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"get", "list"}},
+				{Name: "bindings", Kind: "Binding", Namespaced: true},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"get", "list"}},
+			},
+		},
+	}
+	groupFailed := &discovery.ErrGroupDiscoveryFailed{
+		Groups: map[schema.GroupVersion]error{{Group: "metrics.k8s.io", Version: "v1beta1"}: errors.New("service unavailable")},
+	}
+
+	testCases := []struct {
+		name          string
+		client        *testDiscoveryClient
+		expectedError bool
+		expectedCount int
+	}{
+		{name: "all groups discovered", client: &testDiscoveryClient{lists: lists}, expectedCount: 2},
+		{name: "some groups failed", client: &testDiscoveryClient{lists: lists, err: groupFailed}, expectedCount: 2},
+		{name: "all groups failed", client: &testDiscoveryClient{err: groupFailed}, expectedError: true},
+		{name: "other error", client: &testDiscoveryClient{lists: lists, err: errors.New("unauthorized")}, expectedError: true},
+	}
+	for _, tc := range testCases {
+		resources, err := getAPIResources(tc.client)
+		if (err != nil) != tc.expectedError {
+			t.Errorf("[%s] expected error: %v, actual: %v", tc.name, tc.expectedError, err)
+			continue
+		}
+		if len(resources) != tc.expectedCount {
+			t.Errorf("[%s] expected %d resources, actual %d", tc.name, tc.expectedCount, len(resources))
+		}
+		for _, r := range resources {
+			if r.Kind == "Deployment" && (r.Group != "apps" || r.Version != "v1") {
+				t.Errorf("[%s] unexpected group version %s/%s of %s", tc.name, r.Group, r.Version, r.Kind)
+			}
+		}
+	}
+}
@@ -0,0 +1,287 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package kubeutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const managedFieldsOperationApply = "Apply"
+
+// prefixes of the keys in FieldsV1 format of managedFields
+const (
+	fieldsV1Field = "f:"
+	fieldsV1Key   = "k:"
+	fieldsV1Value = "v:"
+	fieldsV1Index = "i:"
+	fieldsV1Self  = "."
+)
+
+// GetAppliedFieldSet returns the set of fields which are applied by the field manager with server-side apply, in FieldsV1 format.
+// If the manager applied the object with multiple API versions, the union of them is returned. false is returned if the manager has not applied the object.
+// `apiVersion`, `kind` and `metadata.name` are always in the set.
+func GetAppliedFieldSet(obj map[string]interface{}, fieldManager string) (map[string]interface{}, bool) {
+	fieldSet, found := getFieldSet(obj, func(manager, operation string) bool {
+		return manager == fieldManager && operation == managedFieldsOperationApply
+	})
+	if found {
+		// these fields are not recorded in managedFields, but every applied configuration has them
+		mergeFieldSet(fieldSet, map[string]interface{}{
+			fieldsV1Field + "apiVersion": map[string]interface{}{},
+			fieldsV1Field + "kind":       map[string]interface{}{},
+			fieldsV1Field + "metadata": map[string]interface{}{
+				fieldsV1Field + "name": map[string]interface{}{},
+			},
+		})
+	}
+	return fieldSet, found
+}
+
+// GetManagedFieldSet returns the union of the fields which are managed by any of the field managers with any operation, in FieldsV1 format.
+func GetManagedFieldSet(obj map[string]interface{}, fieldManagers []string) map[string]interface{} {
+	fieldSet, _ := getFieldSet(obj, func(manager, operation string) bool {
+		return containsManager(fieldManagers, manager)
+	})
+	return fieldSet
+}
+
+// GetOtherManagedFieldSet returns the union of the fields which are managed by field managers other than the specified ones, in FieldsV1 format.
+func GetOtherManagedFieldSet(obj map[string]interface{}, fieldManagers []string) map[string]interface{} {
+	fieldSet, _ := getFieldSet(obj, func(manager, operation string) bool {
+		return !containsManager(fieldManagers, manager)
+	})
+	return fieldSet
+}
+
+func getFieldSet(obj map[string]interface{}, filter func(manager, operation string) bool) (map[string]interface{}, bool) {
+	meta, _ := obj["metadata"].(map[string]interface{})
+	entries, _ := meta["managedFields"].([]interface{})
+	fieldSet := map[string]interface{}{}
+	found := false
+	for _, entryIf := range entries {
+		entry, ok := entryIf.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		manager, _ := entry["manager"].(string)
+		operation, _ := entry["operation"].(string)
+		if !filter(manager, operation) {
+			continue
+		}
+		fields, ok := entry["fieldsV1"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		mergeFieldSet(fieldSet, fields)
+		found = true
+	}
+	return fieldSet, found
+}
+
+func containsManager(fieldManagers []string, manager string) bool {
+	for _, m := range fieldManagers {
+		if m == manager {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeFieldSet(dst, src map[string]interface{}) {
+	for key, srcChild := range src {
+		srcChildSet, _ := srcChild.(map[string]interface{})
+		dstChildSet, ok := dst[key].(map[string]interface{})
+		if !ok {
+			dstChildSet = map[string]interface{}{}
+			dst[key] = dstChildSet
+		}
+		mergeFieldSet(dstChildSet, srcChildSet)
+	}
+}
+
+// ProjectFieldSet returns the value which has only the fields in the field set, and the paths of fields in the value which are not in the set.
+// Items in a list are identified by the keys (`k:`), the value (`v:`) or the index (`i:`) in the same way as server-side apply.
+func ProjectFieldSet(value interface{}, fieldSet map[string]interface{}) (interface{}, []string) {
+	return projectFieldSet(value, fieldSet, "")
+}
+
+func projectFieldSet(value interface{}, fieldSet map[string]interface{}, path string) (interface{}, []string) {
+	// the whole value is owned if the set has no child
+	if !hasChildFields(fieldSet) {
+		return value, nil
+	}
+	notInSet := []string{}
+	switch tv := value.(type) {
+	case map[string]interface{}:
+		projected := map[string]interface{}{}
+		for key, child := range tv {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			childSetIf, ok := fieldSet[fieldsV1Field+key]
+			if !ok {
+				notInSet = append(notInSet, childPath)
+				continue
+			}
+			childSet, _ := childSetIf.(map[string]interface{})
+			projectedChild, childNotInSet := projectFieldSet(child, childSet, childPath)
+			projected[key] = projectedChild
+			notInSet = append(notInSet, childNotInSet...)
+		}
+		return projected, notInSet
+	case []interface{}:
+		projected := []interface{}{}
+		for i, item := range tv {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			itemSet, ok := findListItemSet(fieldSet, item, i)
+			if !ok {
+				notInSet = append(notInSet, itemPath)
+				continue
+			}
+			projectedItem, itemNotInSet := projectFieldSet(item, itemSet, itemPath)
+			projected = append(projected, projectedItem)
+			notInSet = append(notInSet, itemNotInSet...)
+		}
+		return projected, notInSet
+	}
+	return value, nil
+}
+
+// RemoveFieldSet returns the value without the fields in the field set, e.g. the fields owned by trusted managers.
+// A list item which is owned as a whole (`.`) is removed with all of its fields, unless any field in the item is in keepSet
+// (e.g. the fields owned by other managers). A map or a list which becomes empty by the removal is also removed.
+func RemoveFieldSet(value interface{}, fieldSet, keepSet map[string]interface{}) interface{} {
+	if len(fieldSet) == 0 {
+		return value
+	}
+	removed, _ := removeFieldSet(value, fieldSet, keepSet)
+	return removed
+}
+
+// removeFieldSet returns true if the whole value is removed; keepSet is nil if no field in the value is in it
+func removeFieldSet(value interface{}, fieldSet, keepSet map[string]interface{}) (interface{}, bool) {
+	if !hasChildFields(fieldSet) {
+		if keepSet != nil {
+			return value, false
+		}
+		return nil, true
+	}
+	switch tv := value.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, child := range tv {
+			childSet, ok := fieldSet[fieldsV1Field+key].(map[string]interface{})
+			if !ok {
+				result[key] = child
+				continue
+			}
+			childKeepSet, _ := keepSet[fieldsV1Field+key].(map[string]interface{})
+			if resultChild, removed := removeFieldSet(child, childSet, childKeepSet); !removed {
+				result[key] = resultChild
+			}
+		}
+		if len(tv) > 0 && len(result) == 0 {
+			return nil, true
+		}
+		return result, false
+	case []interface{}:
+		result := []interface{}{}
+		for i, item := range tv {
+			itemSet, ok := findListItemSet(fieldSet, item, i)
+			if !ok {
+				result = append(result, item)
+				continue
+			}
+			itemKeepSet, _ := findListItemSet(keepSet, item, i)
+			if _, ownedAsWhole := itemSet[fieldsV1Self]; ownedAsWhole && itemKeepSet == nil {
+				continue
+			}
+			if resultItem, removed := removeFieldSet(item, itemSet, itemKeepSet); !removed {
+				result = append(result, resultItem)
+			}
+		}
+		if len(tv) > 0 && len(result) == 0 {
+			return nil, true
+		}
+		return result, false
+	}
+	return value, false
+}
+
+func hasChildFields(fieldSet map[string]interface{}) bool {
+	for key := range fieldSet {
+		if key != fieldsV1Self {
+			return true
+		}
+	}
+	return false
+}
+
+// findListItemSet returns the field set for the list item
+func findListItemSet(fieldSet map[string]interface{}, item interface{}, index int) (map[string]interface{}, bool) {
+	for key, childSetIf := range fieldSet {
+		childSet, _ := childSetIf.(map[string]interface{})
+		switch {
+		case strings.HasPrefix(key, fieldsV1Key):
+			var keyFields map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(key, fieldsV1Key)), &keyFields); err != nil {
+				continue
+			}
+			itemMap, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			matched := true
+			for name, keyValue := range keyFields {
+				if !jsonEqual(itemMap[name], keyValue) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return childSet, true
+			}
+		case strings.HasPrefix(key, fieldsV1Value):
+			var setValue interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(key, fieldsV1Value)), &setValue); err != nil {
+				continue
+			}
+			if jsonEqual(item, setValue) {
+				return childSet, true
+			}
+		case strings.HasPrefix(key, fieldsV1Index):
+			if i, err := strconv.Atoi(strings.TrimPrefix(key, fieldsV1Index)); err == nil && i == index {
+				return childSet, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// jsonEqual compares values in json, so that numbers are compared regardless of the go type (e.g. int64 and float64)
+func jsonEqual(a, b interface{}) bool {
+	aB, err1 := json.Marshal(a)
+	bB, err2 := json.Marshal(b)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return string(aB) == string(bB)
+}
@@ -0,0 +1,71 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package kubeutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestProjectFieldSet(t *testing.T) {
+	var obj, fieldSet map[string]interface{}
+	_ = json.Unmarshal([]byte(`{"spec":{"finalizers":["a","b"],"ports":[{"port":80,"protocol":"TCP","targetPort":8080},{"port":443,"protocol":"TCP"}],"args":["--v=1"],"type":"ClusterIP"}}`), &obj)
+	_ = json.Unmarshal([]byte(`{"f:spec":{"f:finalizers":{"v:\"a\"":{}},"f:ports":{"k:{\"port\":80,\"protocol\":\"TCP\"}":{".":{},"f:port":{},"f:protocol":{}}},"f:args":{"i:0":{}}}}`), &fieldSet)
+
+	projected, notInSet := ProjectFieldSet(obj, fieldSet)
+	expected := map[string]interface{}{"spec": map[string]interface{}{
+		"finalizers": []interface{}{"a"},
+		"ports":      []interface{}{map[string]interface{}{"port": float64(80), "protocol": "TCP"}},
+		"args":       []interface{}{"--v=1"},
+	}}
+	if !reflect.DeepEqual(projected, expected) {
+		t.Errorf("projected value is not correct; expected: %v, actual: %v", expected, projected)
+	}
+	expectedNotInSet := map[string]bool{"spec.finalizers[1]": true, "spec.ports[0].targetPort": true, "spec.ports[1]": true, "spec.type": true}
+	if len(notInSet) != len(expectedNotInSet) {
+		t.Errorf("fields not in the set are not correct; actual: %v", notInSet)
+	}
+	for _, path := range notInSet {
+		if !expectedNotInSet[path] {
+			t.Errorf("unexpected field not in the set: %s", path)
+		}
+	}
+}
+
+func TestRemoveFieldSet(t *testing.T) {
+	var obj, fieldSet, keepSet map[string]interface{}
+	_ = json.Unmarshal([]byte(`{"metadata":{"annotations":{"revision":"1"}},"spec":{"replicas":3,"containers":[{"name":"app","image":"app:1.0"},{"name":"sidecar","image":"sidecar:1.0"},{"name":"proxy","image":"proxy:1.0","privileged":true}]}}`), &obj)
+	_ = json.Unmarshal([]byte(`{"f:metadata":{"f:annotations":{".":{},"f:revision":{}}},"f:spec":{"f:replicas":{},"f:containers":{"k:{\"name\":\"sidecar\"}":{".":{},"f:image":{},"f:name":{}},"k:{\"name\":\"proxy\"}":{".":{},"f:image":{},"f:name":{}}}}}`), &fieldSet)
+	_ = json.Unmarshal([]byte(`{"f:spec":{"f:containers":{"k:{\"name\":\"proxy\"}":{"f:privileged":{}}}}}`), &keepSet)
+
+	// the sidecar is removed as a whole, but the proxy has a field in keepSet
+	removed := RemoveFieldSet(obj, fieldSet, keepSet)
+	expected := map[string]interface{}{"spec": map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "app", "image": "app:1.0"},
+			map[string]interface{}{"privileged": true},
+		},
+	}}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Errorf("removed value is not correct; expected: %v, actual: %v", expected, removed)
+	}
+
+	if removed = RemoveFieldSet(obj, map[string]interface{}{}, nil); !reflect.DeepEqual(removed, obj) {
+		t.Errorf("nothing should be removed with the empty field set")
+	}
+}
@@ -0,0 +1,289 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package kubeutil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/logger"
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/metrics"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	k8scache "k8s.io/client-go/tools/cache"
+	"k8s.io/kubectl/pkg/util/openapi"
+)
+
+const (
+	defaultOpenAPISchemaRefreshInterval = time.Minute * 10
+	// OpenAPI schema of a changed CRD is published by API server asynchronously, so the refresh waits for a while
+	openAPISchemaCRDChangeDelay = time.Second * 3
+	// a failed load is not retried within this interval, so that the schema is not downloaded for each request while API server is unavailable
+	openAPISchemaRetryInterval = time.Second * 10
+)
+
+const (
+	SchemaRefreshInitial  = "initial"
+	SchemaRefreshCRD      = "crd"
+	SchemaRefreshInterval = "interval"
+)
+
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+/**********************************************
+
+				OpenAPISchemaCache
+
+***********************************************/
+
+// OpenAPISchemaCache holds OpenAPI schema of the cluster, which is used for local defaulting, strategic merge patch and apply patch.
+// The schema is loaded in Start() (or at the first use if not started), and refreshed in background when the interval has passed
+// or CRDs are changed. Readers get the current (possibly stale) schema without waiting for the refresh.
+type OpenAPISchemaCache struct {
+	load     func() (openapi.Resources, error)
+	interval time.Duration
+
+	mu          sync.RWMutex
+	resources   openapi.Resources
+	loadedAt    time.Time
+	lastErr     error
+	lastFailure time.Time
+
+	// loadMu serializes loads; readers wait for it only when no schema has been loaded yet
+	loadMu     sync.Mutex
+	refreshing int32 // 1 while a background refresh started by Get() is running
+	refresh    chan struct{}
+}
+
+var defaultOpenAPISchemaCache = NewOpenAPISchemaCache(loadOpenAPISchema, defaultOpenAPISchemaRefreshInterval)
+
+func NewOpenAPISchemaCache(load func() (openapi.Resources, error), interval time.Duration) *OpenAPISchemaCache {
+	return &OpenAPISchemaCache{
+		load:     load,
+		interval: interval,
+		refresh:  make(chan struct{}, 1),
+	}
+}
+
+// DefaultOpenAPISchemaCache returns the cache shared by all checks in the process
+func DefaultOpenAPISchemaCache() *OpenAPISchemaCache {
+	return defaultOpenAPISchemaCache
+}
+
+// GetOpenAPISchema returns OpenAPI schema of the cluster from the shared cache
+func GetOpenAPISchema() (openapi.Resources, error) {
+	return defaultOpenAPISchemaCache.Get()
+}
+
+// Get returns the cached schema. It loads the schema only if it has not been loaded yet; if the schema is older than the interval,
+// it is returned as it is and refreshed in background.
+func (self *OpenAPISchemaCache) Get() (openapi.Resources, error) {
+	self.mu.RLock()
+	resources, loadedAt := self.resources, self.loadedAt
+	self.mu.RUnlock()
+	if resources == nil {
+		return self.reload(SchemaRefreshInitial, false)
+	}
+	if time.Since(loadedAt) >= self.interval && atomic.CompareAndSwapInt32(&self.refreshing, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&self.refreshing, 0)
+			_, _ = self.reload(SchemaRefreshInterval, false)
+		}()
+	}
+	return resources, nil
+}
+
+// reload loads the schema. If force is false, the schema loaded by another goroutine meanwhile is used, and a failed load is not retried for a while.
+// When a load failed, the old schema is kept and returned if any.
+func (self *OpenAPISchemaCache) reload(trigger string, force bool) (openapi.Resources, error) {
+	self.loadMu.Lock()
+	defer self.loadMu.Unlock()
+
+	self.mu.RLock()
+	current, loadedAt, lastErr, lastFailure := self.resources, self.loadedAt, self.lastErr, self.lastFailure
+	self.mu.RUnlock()
+	if !force {
+		if current != nil && time.Since(loadedAt) < self.interval {
+			return current, nil
+		}
+		if lastErr != nil && time.Since(lastFailure) < openAPISchemaRetryInterval {
+			if current != nil {
+				return current, nil
+			}
+			return nil, lastErr
+		}
+	}
+
+	resources, err := self.load()
+	now := time.Now()
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if err != nil {
+		metrics.OpenAPISchemaRefreshTotal.WithLabelValues(trigger, metrics.ResultFailure).Inc()
+		self.lastErr = err
+		self.lastFailure = now
+		if self.resources != nil {
+			logger.Warn(fmt.Sprintf("Failed to refresh OpenAPI schema, the old one is used; %s", err.Error()))
+			return self.resources, nil
+		}
+		return nil, err
+	}
+	metrics.OpenAPISchemaRefreshTotal.WithLabelValues(trigger, metrics.ResultSuccess).Inc()
+	metrics.OpenAPISchemaLastRefreshTimestamp.Set(float64(now.Unix()))
+	self.resources = resources
+	self.loadedAt = now
+	self.lastErr = nil
+	logger.Debug(fmt.Sprintf("OpenAPI schema has been loaded (trigger: %s)", trigger))
+	return resources, nil
+}
+
+// Start loads the schema, and runs a CRD informer and the worker which refreshes the schema on the interval and on CRD changes until stopCh is closed
+func (self *OpenAPISchemaCache) Start(stopCh <-chan struct{}) error {
+	if _, err := self.reload(SchemaRefreshInitial, false); err != nil {
+		logger.Warn(fmt.Sprintf("Failed to load OpenAPI schema, it is loaded again later; %s", err.Error()))
+	}
+	config, err := GetKubeConfig()
+	if err != nil {
+		return fmt.Errorf("Error in getting k8s config; %s", err.Error())
+	}
+	dyClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("Error in creating DynamicClient; %s", err.Error())
+	}
+	startedAt := time.Now()
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(dyClient, 0)
+	informer := factory.ForResource(crdGVR).Informer()
+	informer.AddEventHandler(k8scache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			// CRDs in the initial list are already in the schema
+			if crd, ok := obj.(*unstructured.Unstructured); ok && crd.GetCreationTimestamp().Time.Before(startedAt) {
+				return
+			}
+			self.onCRDChange()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCRD, ok1 := oldObj.(*unstructured.Unstructured)
+			newCRD, ok2 := newObj.(*unstructured.Unstructured)
+			// only spec changes the schema; skip status changes
+			if ok1 && ok2 && oldCRD.GetGeneration() == newCRD.GetGeneration() {
+				return
+			}
+			self.onCRDChange()
+		},
+		DeleteFunc: func(obj interface{}) { self.onCRDChange() },
+	})
+	go self.run(stopCh)
+	factory.Start(stopCh)
+	logger.Info("OpenAPI schema watcher has been started.")
+	return nil
+}
+
+// onCRDChange requests the worker to refresh the schema; multiple changes are coalesced into one refresh
+func (self *OpenAPISchemaCache) onCRDChange() {
+	select {
+	case self.refresh <- struct{}{}:
+	default:
+	}
+}
+
+func (self *OpenAPISchemaCache) run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(self.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			_, _ = self.reload(SchemaRefreshInterval, true)
+		case <-self.refresh:
+			select {
+			case <-stopCh:
+				return
+			case <-time.After(openAPISchemaCRDChangeDelay):
+			}
+			_, _ = self.reload(SchemaRefreshCRD, true)
+		}
+	}
+}
+
+func loadOpenAPISchema() (openapi.Resources, error) {
+	config, err := GetKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Error in getting k8s config; %s", err.Error())
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("Error in creating DiscoveryClient; %s", err.Error())
+	}
+	openAPISchemaDoc, err := discoveryClient.OpenAPISchema()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get OpenAPISchema Document; %s", err.Error())
+	}
+	openAPISchema, err := openapi.NewOpenAPIData(openAPISchemaDoc)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get OpenAPISchema; %s", err.Error())
+	}
+	return openAPISchema, nil
+}
+
+// GetListMergeKeyFunc returns a function which returns the merge key of the list at the path (list indexes are "*", e.g. `spec.template.spec.containers.*.env`)
+// in the strategic merge patch metadata of the kind. nil is returned if the schema of the kind is not found.
+func GetListMergeKeyFunc(apiVersion, kind string, schemas openapi.Resources) func(listPath string) string {
+	if schemas == nil {
+		return nil
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil
+	}
+	resourceSchema := schemas.LookupResource(gv.WithKind(kind))
+	if resourceSchema == nil {
+		return nil
+	}
+	return func(listPath string) string {
+		var patchMeta strategicpatch.LookupPatchMeta = strategicpatch.PatchMetaFromOpenAPI{Schema: resourceSchema}
+		fields := strings.Split(listPath, ".")
+		for i := 0; i < len(fields); i++ {
+			isLast := i == len(fields)-1
+			if isLast || fields[i+1] == "*" {
+				itemMeta, sliceMeta, err := patchMeta.LookupPatchMetadataForSlice(fields[i])
+				if err != nil {
+					return ""
+				}
+				if isLast {
+					return sliceMeta.GetPatchMergeKey()
+				}
+				patchMeta = itemMeta
+				i++ // skip "*" for the list index
+				continue
+			}
+			fieldMeta, _, err := patchMeta.LookupPatchMetadataForStruct(fields[i])
+			if err != nil {
+				return ""
+			}
+			patchMeta = fieldMeta
+		}
+		return ""
+	}
+}
@@ -0,0 +1,118 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package kubeutil
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/integrity-enforcer/shield/pkg/util/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/kubectl/pkg/util/openapi"
+)
+
+func TestOpenAPISchemaCache(t *testing.T) {
+	loadCount := 0
+	var loadErr error
+	load := func() (openapi.Resources, error) {
+		loadCount++
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return &testSchemaResources{}, nil
+	}
+	schemaCache := NewOpenAPISchemaCache(load, time.Hour)
+	successCount := func(trigger string) float64 {
+		return testutil.ToFloat64(metrics.OpenAPISchemaRefreshTotal.WithLabelValues(trigger, metrics.ResultSuccess))
+	}
+	initialCount := successCount(SchemaRefreshInitial)
+	crdCount := successCount(SchemaRefreshCRD)
+
+	// loaded only once
+	for i := 0; i < 3; i++ {
+		if resources, err := schemaCache.Get(); err != nil || resources == nil {
+			t.Errorf("failed to get schema; %v", err)
+		}
+	}
+	if loadCount != 1 || successCount(SchemaRefreshInitial) != initialCount+1 {
+		t.Errorf("schema should be loaded only once, but loaded %d times", loadCount)
+	}
+
+	// refresh on CRD change
+	if _, err := schemaCache.reload(SchemaRefreshCRD, true); err != nil || loadCount != 2 || successCount(SchemaRefreshCRD) != crdCount+1 {
+		t.Errorf("schema should be refreshed on CRD change; %v", err)
+	}
+
+	// the old schema is used if refresh failed
+	loadErr = errors.New("API server is not available")
+	if resources, err := schemaCache.reload(SchemaRefreshInterval, true); err != nil || resources == nil {
+		t.Errorf("the old schema should be used; %v", err)
+	}
+
+	// a failed initial load is not retried for each request
+	loadCount = 0
+	schemaCache = NewOpenAPISchemaCache(load, time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := schemaCache.Get(); err == nil {
+			t.Errorf("an error is expected when schema is not available")
+		}
+	}
+	if loadCount != 1 {
+		t.Errorf("a failed load should not be retried immediately, but loaded %d times", loadCount)
+	}
+}
+
+func TestOpenAPISchemaCacheStaleRead(t *testing.T) {
+	var loadCount int32
+	release := make(chan struct{})
+	oldSchema, newSchema := &testSchemaResources{}, &testSchemaResources{}
+	load := func() (openapi.Resources, error) {
+		if atomic.AddInt32(&loadCount, 1) == 1 {
+			return oldSchema, nil
+		}
+		<-release
+		return newSchema, nil
+	}
+	schemaCache := NewOpenAPISchemaCache(load, time.Millisecond)
+	if resources, err := schemaCache.Get(); err != nil || resources != oldSchema {
+		t.Fatalf("failed to get schema; %v", err)
+	}
+
+	// the stale schema is returned without waiting for the refresh, and only one refresh runs
+	time.Sleep(2 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if resources, err := schemaCache.Get(); err != nil || resources != oldSchema {
+			t.Errorf("the stale schema should be returned while refreshing; %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&loadCount); n > 2 {
+		t.Errorf("only one refresh should run at a time, but loaded %d times", n)
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if resources, _ := schemaCache.Get(); resources == newSchema {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if resources, _ := schemaCache.Get(); resources != newSchema {
+		t.Errorf("schema should be refreshed in background")
+	}
+}
@@ -0,0 +1,143 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// capture log is written by parallel admission requests, so writing and rotation are serialized
+var captureMutex sync.Mutex
+
+type CaptureLoggerConfig struct {
+	Enabled    bool
+	File       string
+	LimitSize  int64
+	MaxBackups int
+}
+
+// CaptureLogger writes captured requests to a file.
+// When the file exceeds the limit size, it is rotated to `<file>.1`, `<file>.2`, ... and the oldest one is removed.
+type CaptureLogger struct {
+	enabled    bool
+	file       string
+	limitSize  int64
+	maxBackups int
+}
+
+func InitCaptureLogger(config CaptureLoggerConfig) *CaptureLogger {
+	captureLogger := &CaptureLogger{
+		enabled:    config.Enabled,
+		file:       config.File,
+		limitSize:  config.LimitSize,
+		maxBackups: config.MaxBackups,
+	}
+	return captureLogger
+}
+
+func backupFileName(file string, index int) string {
+	return fmt.Sprintf("%s.%d", file, index)
+}
+
+// CaptureLogFiles returns the capture log file and its backups, from the oldest one
+func CaptureLogFiles(file string, maxBackups int) []string {
+	files := []string{}
+	for i := maxBackups; i >= 1; i-- {
+		fname := backupFileName(file, i)
+		if _, err := os.Stat(fname); err == nil {
+			files = append(files, fname)
+		}
+	}
+	if _, err := os.Stat(file); err == nil {
+		files = append(files, file)
+	}
+	return files
+}
+
+func (cpLogger *CaptureLogger) sizeCheckAndRotate() error {
+	fi, err := os.Stat(cpLogger.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Size() <= cpLogger.limitSize {
+		return nil
+	}
+	if cpLogger.maxBackups <= 0 {
+		return os.Remove(cpLogger.file)
+	}
+	oldest := backupFileName(cpLogger.file, cpLogger.maxBackups)
+	if _, err := os.Stat(oldest); err == nil {
+		if err := os.Remove(oldest); err != nil {
+			return err
+		}
+	}
+	for i := cpLogger.maxBackups - 1; i >= 1; i-- {
+		src := backupFileName(cpLogger.file, i)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := os.Rename(src, backupFileName(cpLogger.file, i+1)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(cpLogger.file, backupFileName(cpLogger.file, 1))
+}
+
+func (cpLogger *CaptureLogger) writeToFile(logBytes []byte) error {
+	captureMutex.Lock()
+	defer captureMutex.Unlock()
+
+	err := cpLogger.sizeCheckAndRotate()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(cpLogger.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640) // NOSONAR
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	line := fmt.Sprintf("%s\n", string(logBytes))
+	if _, err := f.WriteString(line); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cpLogger *CaptureLogger) SendLog(logBytes []byte) {
+	if !cpLogger.enabled {
+		return
+	}
+
+	err := cpLogger.writeToFile(logBytes)
+	if err != nil {
+		simpleLogger.WithFields(log.Fields{
+			"err": err,
+		}).Debug("Capture log file dump err")
+		return
+	}
+}
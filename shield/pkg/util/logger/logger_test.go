@@ -17,6 +17,10 @@
 package logger
 
 import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -46,3 +50,22 @@ func TestLogger(t *testing.T) {
 	ctxLogger.sizeCheckAndRotate()
 
 }
+
+func TestCaptureLoggerRotation(t *testing.T) {
+	captureFile := filepath.Join(t.TempDir(), "captured-requests.txt")
+	cpLogger := InitCaptureLogger(CaptureLoggerConfig{Enabled: true, File: captureFile, LimitSize: 10, MaxBackups: 2})
+
+	for i := 0; i < 5; i++ {
+		cpLogger.SendLog([]byte(fmt.Sprintf("captured request %d", i)))
+	}
+
+	files := CaptureLogFiles(captureFile, 2)
+	expected := []string{captureFile + ".2", captureFile + ".1", captureFile}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Test failed for CaptureLogFiles(); expected: %v, actual: %v", expected, files)
+	}
+	latest, _ := ioutil.ReadFile(captureFile)
+	if string(latest) != "captured request 4\n" {
+		t.Errorf("Test failed for capture log rotation; actual content: %s", string(latest))
+	}
+}
@@ -112,17 +112,27 @@ func (dr *DiffResult) Remove(patterns []*DiffPattern) *DiffResult {
 	return &DiffResult{Items: items}
 }
 
+// Filter splits the diff into the items under the mask keys and the others. Keys with list selectors like `foo[name=x]` must be resolved by ExpandKeys() beforehand.
 func (dr *DiffResult) Filter(maskKeys []string) (*DiffResult, *DiffResult, []string) {
-	for i, key := range maskKeys {
+	listItemKeyRe := regexp.MustCompile(`\.\d+$`)
+	patterns := []string{}
+	for _, key := range maskKeys {
+		// quoted fields like `data."tls.crt"` are compared with the diff key `data.tls.crt`
+		key = parseConcatKey(key)
+		if listItemKeyRe.MatchString(key) {
+			// a key for a list item like "foo.1" should match "foo.1" and "foo.1.bar", but not "foo.10"
+			patterns = append(patterns, key, fmt.Sprintf("%s\\.*", key))
+			continue
+		}
 		// to match diff fields with maskKey prefix, "*" is added here
-		maskKeys[i] = fmt.Sprintf("%s*", key)
+		patterns = append(patterns, fmt.Sprintf("%s*", key))
 	}
 	filtered := &DiffResult{}
 	unfiltered := &DiffResult{}
 	matchedKeys := []string{}
 	for _, dri := range dr.Items {
 		driKey := dri.Key
-		exists, matched := keyExistsInList(maskKeys, driKey)
+		exists, matched := keyExistsInList(patterns, driKey)
 		if exists {
 			filtered.Items = append(filtered.Items, dri)
 			matchedKeys = append(matchedKeys, matched)
@@ -148,6 +158,77 @@ func (d *DiffResult) String() string {
 	return d.ToJson()
 }
 
+// ChangeType is the type of a change of a field, from the `before` object to the `after` object
+type ChangeType string
+
+const (
+	ChangeTypeAdded   ChangeType = "added"
+	ChangeTypeRemoved ChangeType = "removed"
+	ChangeTypeChanged ChangeType = "changed"
+)
+
+// FieldDiff is a machine-readable form of Difference
+type FieldDiff struct {
+	Path   string      `json:"path"`
+	Change ChangeType  `json:"change"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// FieldDiffs returns the differences with change types. If excludeValue is true, values are not included (e.g. for secret data).
+func (d *DiffResult) FieldDiffs(excludeValue bool) []*FieldDiff {
+	fields := []*FieldDiff{}
+	if d == nil {
+		return fields
+	}
+	for _, di := range d.Items {
+		before := di.Values["before"]
+		after := di.Values["after"]
+		change := ChangeTypeChanged
+		if before == nil && after != nil {
+			change = ChangeTypeAdded
+		} else if before != nil && after == nil {
+			change = ChangeTypeRemoved
+		}
+		fd := &FieldDiff{Path: di.Key, Change: change}
+		if !excludeValue {
+			fd.Before = before
+			fd.After = after
+		}
+		fields = append(fields, fd)
+	}
+	return fields
+}
+
+// UnifiedDiff renders the differences like unified diff format; one hunk for each field, `-` for the value in signed manifest and `+` for the requested one.
+func UnifiedDiff(fields []*FieldDiff) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	lines := []string{"--- signed manifest", "+++ requested object"}
+	for _, fd := range fields {
+		lines = append(lines, fmt.Sprintf("@@ %s (%s) @@", fd.Path, fd.Change))
+		if fd.Before != nil {
+			lines = append(lines, "-"+diffValueString(fd.Before))
+		}
+		if fd.After != nil {
+			lines = append(lines, "+"+diffValueString(fd.After))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func diffValueString(val interface{}) string {
+	if s, ok := val.(string); ok {
+		return s
+	}
+	valBytes, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprintf("%v", val)
+	}
+	return string(valBytes)
+}
+
 func (d *DiffResult) KeyString() string {
 	keys := d.Keys()
 	keyMap := map[string][]map[string]string{
@@ -0,0 +1,60 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package mapnode
+
+import (
+	"testing"
+)
+
+func TestFieldDiffs(t *testing.T) {
+	before, _ := NewFromBytes([]byte(`{"metadata":{"name":"sample","labels":{"app":"sample"}},"spec":{"replicas":1,"paused":false}}`))
+	after, _ := NewFromBytes([]byte(`{"metadata":{"name":"sample","annotations":{"owner":"team-a"}},"spec":{"replicas":3,"paused":false}}`))
+	dr := before.Diff(after)
+
+	fields := dr.FieldDiffs(false)
+	expectedChanges := map[string]ChangeType{
+		"metadata.annotations.owner": ChangeTypeAdded,
+		"metadata.labels.app":        ChangeTypeRemoved,
+		"spec.replicas":              ChangeTypeChanged,
+	}
+	if len(fields) != len(expectedChanges) {
+		t.Errorf("expected %d diffs, but got %d; %s", len(expectedChanges), len(fields), dr.String())
+	}
+	for _, fd := range fields {
+		if expectedChanges[fd.Path] != fd.Change {
+			t.Errorf("expected change type of %s is %s, but got %s", fd.Path, expectedChanges[fd.Path], fd.Change)
+		}
+	}
+
+	unified := UnifiedDiff(fields)
+	expected := "--- signed manifest\n+++ requested object\n" +
+		"@@ metadata.annotations.owner (added) @@\n+team-a\n" +
+		"@@ metadata.labels.app (removed) @@\n-sample\n" +
+		"@@ spec.replicas (changed) @@\n-1\n+3\n"
+	if unified != expected {
+		t.Errorf("unexpected unified diff;\nexpected:\n%s\nactual:\n%s", expected, unified)
+	}
+
+	for _, fd := range dr.FieldDiffs(true) {
+		if fd.Before != nil || fd.After != nil {
+			t.Errorf("values should be excluded, but got %v, %v", fd.Before, fd.After)
+		}
+	}
+	if UnifiedDiff(nil) != "" {
+		t.Errorf("unified diff should be empty for no diff")
+	}
+}
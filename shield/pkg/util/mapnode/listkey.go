@@ -0,0 +1,223 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package mapnode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/**********************************************
+
+					List Key
+
+***********************************************/
+
+// ListKeyFunc returns the merge key of the list at the path, or "" if items in the list are compared by position.
+// List indexes in the path are replaced with "*", e.g. `spec.template.spec.containers.*.env`.
+// A key of multiple fields is separated by comma, and a field may have the default value for the items without it, e.g. `containerPort,protocol=TCP`.
+type ListKeyFunc func(listPath string) string
+
+// builtinListKeys is the merge keys of well-known lists in Kubernetes resources, by the suffix of the path.
+// These are same as the strategic merge patch, except container ports which are unique by port and protocol (e.g. DNS on 53/TCP and 53/UDP).
+var builtinListKeys = map[string]string{
+	"containers":                  "name",
+	"ephemeralContainers":         "name",
+	"env":                         "name",
+	"volumeMounts":                "mountPath",
+	"volumeDevices":               "devicePath",
+	"containers.*.ports":          "containerPort,protocol=TCP",
+	"initContainers.*.ports":      "containerPort,protocol=TCP",
+	"ephemeralContainers.*.ports": "containerPort,protocol=TCP",
+	"volumes":                     "name",
+	"imagePullSecrets":            "name",
+	"hostAliases":                 "ip",
+	"spec.ports":                  "port",
+	"secrets":                     "name",
+}
+
+// orderSensitiveLists is the lists whose order changes the behavior even though they have a merge key, by the suffix of the path.
+// The function returns true if the order of the items matters; init containers run in the order, and `$(VAR)` in env refers only to
+// the variables defined before it, so env is order sensitive only if any value has a reference.
+var orderSensitiveLists = map[string]func(items []*Node) bool{
+	"initContainers": func(items []*Node) bool { return true },
+	"env":            hasEnvVarReference,
+}
+
+// isOrderSensitiveList returns true if items in the lists at the path must be compared by position regardless of the merge key.
+// If no list is given, it returns true for the path which can be order sensitive.
+func isOrderSensitiveList(listPath string, lists ...[]*Node) bool {
+	for suffix, orderSensitive := range orderSensitiveLists {
+		if listPath != suffix && !strings.HasSuffix(listPath, "."+suffix) {
+			continue
+		}
+		for _, items := range lists {
+			if orderSensitive(items) {
+				return true
+			}
+		}
+		return len(lists) == 0
+	}
+	return false
+}
+
+// hasEnvVarReference returns true if any value of the env has a variable reference like `$(VAR)`
+func hasEnvVarReference(items []*Node) bool {
+	for _, item := range items {
+		if item == nil || !item.IsMap() {
+			continue
+		}
+		if value, ok := item.GetChild("value"); ok && value.Value != nil && strings.Contains(fmt.Sprintf("%v", value.Value.Interface()), "$(") {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultListKey returns the merge key of the list in the built-in table. A longer suffix has priority, e.g. `containers.*.ports` over `spec.ports`.
+func DefaultListKey(listPath string) string {
+	pathParts := strings.Split(listPath, ".")
+	key := ""
+	matchedLen := 0
+	for suffix, k := range builtinListKeys {
+		suffixParts := strings.Split(suffix, ".")
+		if len(suffixParts) <= matchedLen || len(suffixParts) > len(pathParts) {
+			continue
+		}
+		if strings.Join(pathParts[len(pathParts)-len(suffixParts):], ".") == suffix {
+			key = k
+			matchedLen = len(suffixParts)
+		}
+	}
+	return key
+}
+
+// ravelAligned flattens the node in the same way as Ravel(), but an item in a keyed list gets the index of the item with the same key in ref,
+// so that the lists are compared by key regardless of the order. An item which is not in ref gets an index after the items in ref.
+func (n *Node) ravelAligned(ref *Node, listKey ListKeyFunc) map[string]interface{} {
+	m := make(map[string]interface{})
+	n.recursiveRavelAligned(ref, "", "", listKey, m)
+	return m
+}
+
+func (n *Node) recursiveRavelAligned(ref *Node, currentPath, listPath string, listKey ListKeyFunc, m map[string]interface{}) {
+	if ref == nil || n.Value != nil {
+		n.recursiveRavel(currentPath, m)
+		return
+	}
+	joinPath := func(path, k string) string {
+		if path == "" {
+			return k
+		}
+		return fmt.Sprintf("%s.%s", path, k)
+	}
+
+	if n.IsSlice() {
+		items := n.GetChildrenSlice()
+		refItems := ref.GetChildrenSlice()
+		var indexes []int
+		if key := listKey(listPath); key != "" && ref.IsSlice() && !isOrderSensitiveList(listPath, refItems, items) {
+			indexes = alignListItems(refItems, items, key)
+		}
+		for i, item := range items {
+			index := i
+			if indexes != nil {
+				index = indexes[i]
+			}
+			var refItem *Node
+			if index < len(refItems) {
+				refItem = refItems[index]
+			}
+			item.recursiveRavelAligned(refItem, joinPath(currentPath, strconv.Itoa(index)), joinPath(listPath, "*"), listKey, m)
+		}
+		return
+	}
+
+	refChildren := ref.GetChildrenMap()
+	for k, v := range n.GetChildrenMap() {
+		var refChild *Node
+		if ref.IsMap() {
+			refChild = refChildren[k]
+		}
+		v.recursiveRavelAligned(refChild, joinPath(currentPath, k), joinPath(listPath, k), listKey, m)
+	}
+}
+
+// alignListItems returns the index in refItems for each item with the same key. nil is returned if any item does not have a unique key,
+// and then the list is compared by position.
+func alignListItems(refItems, items []*Node, key string) []int {
+	refIndexes, ok := indexItemsByKey(refItems, key)
+	if !ok {
+		return nil
+	}
+	if _, ok := indexItemsByKey(items, key); !ok {
+		return nil
+	}
+	indexes := []int{}
+	nextIndex := len(refItems)
+	for _, item := range items {
+		keyValue, _ := listItemKey(item, key)
+		if refIndex, found := refIndexes[keyValue]; found {
+			indexes = append(indexes, refIndex)
+		} else {
+			indexes = append(indexes, nextIndex)
+			nextIndex++
+		}
+	}
+	return indexes
+}
+
+func indexItemsByKey(items []*Node, key string) (map[string]int, bool) {
+	indexes := map[string]int{}
+	for i, item := range items {
+		keyValue, ok := listItemKey(item, key)
+		if !ok {
+			return nil, false
+		}
+		if _, duplicated := indexes[keyValue]; duplicated {
+			return nil, false
+		}
+		indexes[keyValue] = i
+	}
+	return indexes, true
+}
+
+// listItemKey returns the values of the key fields of the item. A field without the default value is required.
+func listItemKey(item *Node, key string) (string, bool) {
+	if item == nil || !item.IsMap() {
+		return "", false
+	}
+	values := []string{}
+	for _, keyField := range strings.Split(key, ",") {
+		defaultValue := ""
+		hasDefault := false
+		if i := strings.Index(keyField, "="); i >= 0 {
+			keyField, defaultValue, hasDefault = keyField[:i], keyField[i+1:], true
+		}
+		keyNode, ok := item.GetChild(keyField)
+		if !ok || keyNode.Value == nil {
+			if !hasDefault {
+				return "", false
+			}
+			values = append(values, defaultValue)
+			continue
+		}
+		values = append(values, fmt.Sprintf("%v", keyNode.Value.Interface()))
+	}
+	return strings.Join(values, ","), true
+}
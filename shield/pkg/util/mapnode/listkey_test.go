@@ -0,0 +1,224 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package mapnode
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testListKeyDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: sample-app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: registry.example.com/app:1.0.0
+        args: ["--port", "8080"]
+        env:
+        - name: LOG_LEVEL
+          value: info
+        - name: DB_HOST
+          value: db.example.com
+        - name: DB_PORT
+          value: "5432"
+        ports:
+        - containerPort: 8080
+          name: http
+        - containerPort: 8443
+          name: https
+        volumeMounts:
+        - name: config
+          mountPath: /etc/config
+        - name: cache
+          mountPath: /var/cache
+      - name: sidecar
+        image: registry.example.com/sidecar:1.0.0
+      volumes:
+      - name: config
+        configMap:
+          name: sample-config
+      - name: cache
+        emptyDir: {}
+`
+
+func TestDiffWithKeyedList(t *testing.T) {
+	testCases := []struct {
+		name         string
+		request      string
+		expectedKeys []string
+	}{
+		{
+			name:         "identical",
+			request:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app"},"spec":{"template":{"spec":{"containers":[{"name":"app","image":"registry.example.com/app:1.0.0","args":["--port","8080"],"env":[{"name":"LOG_LEVEL","value":"info"},{"name":"DB_HOST","value":"db.example.com"},{"name":"DB_PORT","value":"5432"}],"ports":[{"containerPort":8080,"name":"http"},{"containerPort":8443,"name":"https"}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"},{"name":"cache","mountPath":"/var/cache"}]},{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0"}],"volumes":[{"name":"config","configMap":{"name":"sample-config"}},{"name":"cache","emptyDir":{}}]}}}}`,
+			expectedKeys: nil,
+		},
+		{
+			// env is compared by name if no value refers to another variable
+			name:         "env reordered",
+			request:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app"},"spec":{"template":{"spec":{"containers":[{"name":"app","image":"registry.example.com/app:1.0.0","args":["--port","8080"],"env":[{"name":"DB_PORT","value":"5432"},{"name":"DB_HOST","value":"db.example.com"},{"name":"LOG_LEVEL","value":"info"}],"ports":[{"containerPort":8080,"name":"http"},{"containerPort":8443,"name":"https"}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"},{"name":"cache","mountPath":"/var/cache"}]},{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0"}],"volumes":[{"name":"config","configMap":{"name":"sample-config"}},{"name":"cache","emptyDir":{}}]}}}}`,
+			expectedKeys: nil,
+		},
+		{
+			// env is compared by position if a value has `$(VAR)`, which refers only to the variables defined before it
+			name:         "env with a variable reference reordered",
+			request:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app"},"spec":{"template":{"spec":{"containers":[{"name":"app","image":"registry.example.com/app:1.0.0","args":["--port","8080"],"env":[{"name":"DB_PORT","value":"5432"},{"name":"DB_HOST","value":"db.example.com:$(DB_PORT)"},{"name":"LOG_LEVEL","value":"info"}],"ports":[{"containerPort":8080,"name":"http"},{"containerPort":8443,"name":"https"}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"},{"name":"cache","mountPath":"/var/cache"}]},{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0"}],"volumes":[{"name":"config","configMap":{"name":"sample-config"}},{"name":"cache","emptyDir":{}}]}}}}`,
+			expectedKeys: []string{"spec.template.spec.containers.0.env.0.name", "spec.template.spec.containers.0.env.0.value", "spec.template.spec.containers.0.env.1.value", "spec.template.spec.containers.0.env.2.name", "spec.template.spec.containers.0.env.2.value"},
+		},
+		{
+			name:         "containers, ports, volumeMounts and volumes reordered",
+			request:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app"},"spec":{"template":{"spec":{"containers":[{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0"},{"name":"app","image":"registry.example.com/app:1.0.0","args":["--port","8080"],"env":[{"name":"LOG_LEVEL","value":"info"},{"name":"DB_HOST","value":"db.example.com"},{"name":"DB_PORT","value":"5432"}],"ports":[{"containerPort":8443,"name":"https"},{"containerPort":8080,"name":"http"}],"volumeMounts":[{"name":"cache","mountPath":"/var/cache"},{"name":"config","mountPath":"/etc/config"}]}],"volumes":[{"name":"cache","emptyDir":{}},{"name":"config","configMap":{"name":"sample-config"}}]}}}}`,
+			expectedKeys: nil,
+		},
+		{
+			// the diff has the index in the signed manifest
+			name:         "value changed in reordered volumes",
+			request:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app"},"spec":{"template":{"spec":{"containers":[{"name":"app","image":"registry.example.com/app:1.0.0","args":["--port","8080"],"env":[{"name":"LOG_LEVEL","value":"info"},{"name":"DB_HOST","value":"db.example.com"},{"name":"DB_PORT","value":"5432"}],"ports":[{"containerPort":8080,"name":"http"},{"containerPort":8443,"name":"https"}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"},{"name":"cache","mountPath":"/var/cache"}]},{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0"}],"volumes":[{"name":"cache","emptyDir":{}},{"name":"config","configMap":{"name":"attacker-config"}}]}}}}`,
+			expectedKeys: []string{"spec.template.spec.volumes.0.configMap.name"},
+		},
+		{
+			name:         "volumeMount added in the middle",
+			request:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app"},"spec":{"template":{"spec":{"containers":[{"name":"app","image":"registry.example.com/app:1.0.0","args":["--port","8080"],"env":[{"name":"LOG_LEVEL","value":"info"},{"name":"DB_HOST","value":"db.example.com"},{"name":"DB_PORT","value":"5432"}],"ports":[{"containerPort":8080,"name":"http"},{"containerPort":8443,"name":"https"}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"},{"name":"tmp","mountPath":"/tmp"},{"name":"cache","mountPath":"/var/cache"}]},{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0"}],"volumes":[{"name":"config","configMap":{"name":"sample-config"}},{"name":"cache","emptyDir":{}}]}}}}`,
+			expectedKeys: []string{"spec.template.spec.containers.0.volumeMounts.2.mountPath", "spec.template.spec.containers.0.volumeMounts.2.name"},
+		},
+		{
+			name:         "volume removed",
+			request:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app"},"spec":{"template":{"spec":{"containers":[{"name":"app","image":"registry.example.com/app:1.0.0","args":["--port","8080"],"env":[{"name":"LOG_LEVEL","value":"info"},{"name":"DB_HOST","value":"db.example.com"},{"name":"DB_PORT","value":"5432"}],"ports":[{"containerPort":8080,"name":"http"},{"containerPort":8443,"name":"https"}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"},{"name":"cache","mountPath":"/var/cache"}]},{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0"}],"volumes":[{"name":"cache","emptyDir":{}}]}}}}`,
+			expectedKeys: []string{"spec.template.spec.volumes.0.configMap.name", "spec.template.spec.volumes.0.name"},
+		},
+		{
+			// args is not a keyed list, so the order matters
+			name:         "args reordered",
+			request:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app"},"spec":{"template":{"spec":{"containers":[{"name":"app","image":"registry.example.com/app:1.0.0","args":["8080","--port"],"env":[{"name":"LOG_LEVEL","value":"info"},{"name":"DB_HOST","value":"db.example.com"},{"name":"DB_PORT","value":"5432"}],"ports":[{"containerPort":8080,"name":"http"},{"containerPort":8443,"name":"https"}],"volumeMounts":[{"name":"config","mountPath":"/etc/config"},{"name":"cache","mountPath":"/var/cache"}]},{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0"}],"volumes":[{"name":"config","configMap":{"name":"sample-config"}},{"name":"cache","emptyDir":{}}]}}}}`,
+			expectedKeys: []string{"spec.template.spec.containers.0.args.0", "spec.template.spec.containers.0.args.1"},
+		},
+		{
+			// duplicated keys cannot identify items, so the list is compared by position
+			name:         "duplicated volumeMount paths",
+			request:      `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"sample-app"},"spec":{"template":{"spec":{"containers":[{"name":"app","image":"registry.example.com/app:1.0.0","args":["--port","8080"],"env":[{"name":"LOG_LEVEL","value":"info"},{"name":"DB_HOST","value":"db.example.com"},{"name":"DB_PORT","value":"5432"}],"ports":[{"containerPort":8080,"name":"http"},{"containerPort":8443,"name":"https"}],"volumeMounts":[{"name":"cache","mountPath":"/var/cache"},{"name":"config","mountPath":"/var/cache"}]},{"name":"sidecar","image":"registry.example.com/sidecar:1.0.0"}],"volumes":[{"name":"config","configMap":{"name":"sample-config"}},{"name":"cache","emptyDir":{}}]}}}}`,
+			expectedKeys: []string{"spec.template.spec.containers.0.volumeMounts.0.mountPath", "spec.template.spec.containers.0.volumeMounts.0.name", "spec.template.spec.containers.0.volumeMounts.1.name"},
+		},
+	}
+
+	signed, err := NewFromYamlBytes([]byte(testListKeyDeployment))
+	if err != nil {
+		t.Fatalf("failed to load signed manifest; %s", err.Error())
+	}
+	for _, tc := range testCases {
+		request, err := NewFromBytes([]byte(tc.request))
+		if err != nil {
+			t.Errorf("[%s] failed to load request; %s", tc.name, err.Error())
+			continue
+		}
+		var actualKeys []string
+		if dr := signed.DiffWithListKeys(request, DefaultListKey); dr != nil && dr.Size() > 0 {
+			actualKeys = dr.Keys()
+		}
+		if !reflect.DeepEqual(actualKeys, tc.expectedKeys) {
+			t.Errorf("[%s] expected diff keys: %v, actual: %v", tc.name, tc.expectedKeys, actualKeys)
+		}
+	}
+}
+
+func TestDiffWithListKeys(t *testing.T) {
+	// Service ports are keyed by `port` in the built-in table
+	signed, _ := NewFromBytes([]byte(`{"kind":"Service","spec":{"ports":[{"name":"http","port":80},{"name":"https","port":443}]}}`))
+	request, _ := NewFromBytes([]byte(`{"kind":"Service","spec":{"ports":[{"name":"https","port":443},{"name":"http","port":80}]}}`))
+	if dr := signed.DiffWithListKeys(request, DefaultListKey); dr != nil && dr.Size() > 0 {
+		t.Errorf("reordered service ports should not be a diff, but got %s", dr.String())
+	}
+	if dr := signed.Diff(request); dr == nil || dr.Size() == 0 {
+		t.Errorf("Diff() should compare lists by position")
+	}
+
+	// lists in a custom resource are keyed by the given function
+	signed, _ = NewFromBytes([]byte(`{"kind":"Sample","spec":{"rules":[{"host":"a.example.com","path":"/"},{"host":"b.example.com","path":"/api"}]}}`))
+	request, _ = NewFromBytes([]byte(`{"kind":"Sample","spec":{"rules":[{"host":"b.example.com","path":"/api"},{"host":"a.example.com","path":"/"}]}}`))
+	if dr := signed.DiffWithListKeys(request, DefaultListKey); dr == nil || dr.Size() == 0 {
+		t.Errorf("rules are not in the built-in table, so reordering should be a diff")
+	}
+	listKey := func(listPath string) string {
+		if listPath == "spec.rules" {
+			return "host"
+		}
+		return ""
+	}
+	if dr := signed.DiffWithListKeys(request, listKey); dr != nil && dr.Size() > 0 {
+		t.Errorf("reordered rules should not be a diff with the list key, but got %s", dr.String())
+	}
+	if dr := signed.DiffWithListKeys(request, nil); dr == nil || dr.Size() == 0 {
+		t.Errorf("lists should be compared by position without list key")
+	}
+
+	if key := DefaultListKey("spec.template.spec.containers.*.ports"); key != "containerPort,protocol=TCP" {
+		t.Errorf("containerPort and protocol are expected for container ports, but got %s", key)
+	}
+
+	// container ports are keyed by containerPort and protocol, and protocol is TCP if it is not specified
+	signed, _ = NewFromBytes([]byte(`{"kind":"Pod","spec":{"containers":[{"name":"dns","ports":[{"containerPort":53,"name":"dns-tcp"},{"containerPort":53,"protocol":"UDP","name":"dns"}]}]}}`))
+	request, _ = NewFromBytes([]byte(`{"kind":"Pod","spec":{"containers":[{"name":"dns","ports":[{"containerPort":53,"protocol":"UDP","name":"dns"},{"containerPort":53,"protocol":"TCP","name":"dns-tcp"}]}]}}`))
+	if dr := signed.DiffWithListKeys(request, DefaultListKey); dr == nil || !reflect.DeepEqual(dr.Keys(), []string{"spec.containers.0.ports.0.protocol"}) {
+		t.Errorf("reordered ports with the same containerPort should be compared by protocol, but got %v", dr)
+	}
+	if key := DefaultListKey("spec.ports"); key != "port" {
+		t.Errorf("port is expected for service ports, but got %s", key)
+	}
+	if key := DefaultListKey("spec.template.spec.containers.*.args"); key != "" {
+		t.Errorf("args is not a keyed list, but got %s", key)
+	}
+}
+
+func TestOrderSensitiveLists(t *testing.T) {
+	// init containers run in the order, so reordering is a diff even with a list key which returns `name`
+	signed, _ := NewFromBytes([]byte(`{"kind":"Pod","spec":{"initContainers":[{"name":"init-db","image":"db-init:1.0"},{"name":"init-schema","image":"schema-init:1.0"}]}}`))
+	request, _ := NewFromBytes([]byte(`{"kind":"Pod","spec":{"initContainers":[{"name":"init-schema","image":"schema-init:1.0"},{"name":"init-db","image":"db-init:1.0"}]}}`))
+	listKey := func(listPath string) string {
+		return "name"
+	}
+	for _, lk := range []ListKeyFunc{DefaultListKey, listKey} {
+		if dr := signed.DiffWithListKeys(request, lk); dr == nil || dr.Size() == 0 {
+			t.Errorf("reordered initContainers should be a diff")
+		}
+	}
+
+	if !isOrderSensitiveList("spec.template.spec.containers.*.env") {
+		t.Errorf("env should be order sensitive")
+	}
+	container, _ := NewFromBytes([]byte(`{"env":[{"name":"DB_PORT","value":"5432"},{"name":"DB_URL","value":"db.example.com:$(DB_PORT)"}]}`))
+	env, _ := container.GetChild("env")
+	if !isOrderSensitiveList("spec.template.spec.containers.*.env", nil, env.GetChildrenSlice()) {
+		t.Errorf("env with a variable reference should be order sensitive")
+	}
+	container, _ = NewFromBytes([]byte(`{"env":[{"name":"DB_PORT","value":"5432"},{"name":"DB_PASSWORD","valueFrom":{"secretKeyRef":{"name":"db","key":"password"}}}]}`))
+	env, _ = container.GetChild("env")
+	if isOrderSensitiveList("spec.template.spec.containers.*.env", env.GetChildrenSlice(), env.GetChildrenSlice()) {
+		t.Errorf("env without variable references should not be order sensitive")
+	}
+	if !isOrderSensitiveList("spec.initContainers") {
+		t.Errorf("initContainers should be order sensitive")
+	}
+	if isOrderSensitiveList("spec.template.spec.containers") {
+		t.Errorf("containers should not be order sensitive")
+	}
+	if isOrderSensitiveList("spec.template.spec.containers.*.envFrom") {
+		t.Errorf("envFrom should not be order sensitive")
+	}
+}
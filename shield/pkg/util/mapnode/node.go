@@ -405,7 +405,8 @@ func (n *Node) GetNodeByJSONPath(jpathKey string) (*Node, bool) {
 	}
 	res, err := jsonpath.JsonPathLookup(nIf, jpathKey)
 	if err != nil {
-		logger.Error(err)
+		// the path does not exist in the node
+		logger.Debug(err)
 		return emptyNode(), false
 	}
 	found, err := json.Marshal(res)
@@ -421,22 +422,27 @@ func (n *Node) GetNodeByJSONPath(jpathKey string) (*Node, bool) {
 	return foundNode, true
 }
 
-// convert key "foo[1].bar" to "foo.1.bar" and then generate actual existing keys by generateKeyList()
+// ValidateJSONPath returns an error if the path cannot be used for GetNodeByJSONPath()
+func ValidateJSONPath(jpathKey string) error {
+	if !strings.HasPrefix(jpathKey, "$") {
+		return fmt.Errorf("JSONPath must start with `$`: %s", jpathKey)
+	}
+	if _, err := jsonpath.Compile(jpathKey); err != nil {
+		return fmt.Errorf("invalid JSONPath `%s`; %s", jpathKey, err.Error())
+	}
+	return nil
+}
+
+// convert key "foo[1].bar" to "foo.1.bar", and resolve list selectors like "foo[].bar" or "foo[name=x].bar" to actual existing keys by generateKeyList()
 func (t *Node) validateKeyList(keys []string) []string {
 	newKeys := []string{}
-	for i, key := range keys {
-		keyAlt := parseConcatKey(key)
-		if key != keyAlt {
-			keys[i] = keyAlt
-		}
-	}
 	for _, key := range keys {
-		tmpList := t.generateKeyList(key)
-		if len(tmpList) > 0 {
-			newKeys = append(newKeys, tmpList...)
-		} else {
-			newKeys = append(newKeys, key)
+		if hasListSelector(key) {
+			// a key whose selector matches no item is dropped, because it cannot match any field
+			newKeys = append(newKeys, t.generateKeyList(key)...)
+			continue
 		}
+		newKeys = append(newKeys, parseConcatKey(key))
 	}
 	return newKeys
 }
@@ -446,62 +452,16 @@ func GetConcreteKeys(keys []string, n *Node) []string {
 	return validatedKeyList
 }
 
-// expand input key "foo[].bar" to actual exsiting keys like ["foo.1.bar", "foo.2.bar"]
+// expand input key "foo[].bar" to actual exsiting keys like ["foo.1.bar", "foo.2.bar"]; see path.go for the list selectors
 func (t *Node) generateKeyList(concatKey string) []string {
-	if !strings.Contains(concatKey, "[]") {
+	if !hasListSelector(concatKey) {
 		return []string{}
 	}
-	parts := strings.Split(concatKey, "[]")
-	for i := range parts {
-		parts[i] = strings.Trim(parts[i], ".")
-	}
-	indexList := []int{}
-	finished := []bool{}
-
-	concatKeySlice := []string{}
-	for i := 0; i < len(parts)-1; i++ {
-		indexList = append(indexList, 0)
-		finished = append(finished, false)
-	}
-	allFinished := false
-	for !allFinished {
-		key := ""
-		checkKey := ""
-		for i, p := range parts {
-			key += p
-			if i < len(parts)-1 {
-				key += fmt.Sprintf(".%s.", strconv.Itoa(indexList[i]))
-				checkKey += p
-				checkKey += fmt.Sprintf(".%s.", strconv.Itoa(indexList[i]))
-			}
-		}
-		key = strings.TrimSuffix(key, ".")
-		checkKey = strings.TrimSuffix(checkKey, ".")
-		ok := t.KeyExists(checkKey)
-		notFinishedI := -1
-		for i := len(finished) - 1; i >= 0; i-- {
-			if finished[i] {
-				continue
-			} else {
-				notFinishedI = i
-				break
-			}
-		}
-		if ok {
-			concatKeySlice = append(concatKeySlice, key)
-			indexList[notFinishedI] += 1
-		} else {
-			finished[notFinishedI] = true
-			if finished[0] {
-				allFinished = true
-			}
-		}
-	}
-	return concatKeySlice
+	return t.expandPath(concatKey)
 }
 
 func (t *Node) MultipleSubNode(concatKey string) []*Node {
-	if !strings.Contains(concatKey, "[]") {
+	if !hasListSelector(concatKey) {
 		subNode := t.SubNode(concatKey)
 		return []*Node{subNode}
 	}
@@ -737,6 +697,13 @@ func (t *Node) Diff(t2 *Node) *DiffResult {
 	return dr
 }
 
+// DiffWithListKeys compares items in keyed lists by the merge key returned by listKey (e.g. DefaultListKey), while Diff() compares all lists by position.
+// Lists whose order changes the behavior (e.g. `initContainers` and `env`) are compared by position even if they have a merge key.
+func (t *Node) DiffWithListKeys(t2 *Node, listKey ListKeyFunc) *DiffResult {
+	dr := findDiffBetweenNodes(t, t2, nil, listKey)
+	return dr
+}
+
 func (t *Node) DiffSpecificType(t2 *Node, findTypeList []string) *DiffResult {
 	findType := make(map[string]bool)
 	for _, t := range findTypeList {
@@ -793,7 +760,14 @@ func extractComparableMap(m1, m2 map[string]interface{}) (map[string]interface{}
 	return nm1, nm2, typeDiffs
 }
 
+// FindDiffBetweenNodes returns differences between the nodes. Items in lists are compared by position.
+// Semantically identical values (e.g. quantities `0.5` and `500m`, or IntOrString `8080` and `"8080"`) are not reported as differences.
 func FindDiffBetweenNodes(t1, t2 *Node, findType map[string]bool) *DiffResult {
+	return findDiffBetweenNodes(t1, t2, findType, nil)
+}
+
+// if listKey is nil, all lists are compared by position
+func findDiffBetweenNodes(t1, t2 *Node, findType map[string]bool, listKey ListKeyFunc) *DiffResult {
 	if findType == nil {
 		findType = map[string]bool{
 			"create": true,
@@ -803,7 +777,13 @@ func FindDiffBetweenNodes(t1, t2 *Node, findType map[string]bool) *DiffResult {
 	}
 
 	m1 := t1.Ravel()
-	m2 := t2.Ravel()
+	var m2 map[string]interface{}
+	if listKey != nil {
+		m2 = t2.ravelAligned(t1, listKey)
+	} else {
+		m2 = t2.Ravel()
+	}
+	normalizeValues(m1, m2)
 	if reflect.DeepEqual(m1, m2) {
 		return nil
 	}
@@ -880,6 +860,21 @@ func parseConcatKey(concatKey string) string {
 	return strings.Join(splitConcatKey(concatKey), ".")
 }
 
+// ValidateConcatKey returns an error if the key cannot be used as a path in Mask() / Extract().
+// A key is a "."-separated list of fields; a field can be quoted, and can have list selectors like "[]", "[*]", "[<index>]"
+// and "[<field>=<value>]" (see path.go), and "*" matches any characters.
+func ValidateConcatKey(concatKey string) error {
+	if _, err := parsePath(concatKey); err != nil {
+		return err
+	}
+	if strings.Contains(concatKey, "*") {
+		if _, err := regexp.Compile(strings.Replace(concatKey, "*", ".*", -1)); err != nil {
+			return fmt.Errorf("invalid wildcard in key `%s`; %s", concatKey, err.Error())
+		}
+	}
+	return nil
+}
+
 func splitConcatKey(concatKey string) []string {
 	var keys []string
 	if !strings.Contains(concatKey, "\"") {
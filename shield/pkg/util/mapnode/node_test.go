@@ -193,3 +193,44 @@ spec:
 	}
 
 }
+
+func TestValidateConcatKey(t *testing.T) {
+	validKeys := []string{
+		"metadata.resourceVersion",
+		"metadata.annotations.*",
+		"metadata.annotations.argocd.argoproj.io/sync-wave",
+		"metadata.finalizers*",
+		"spec.template.spec.containers[].image",
+		"spec.template.spec.containers[0].image",
+		"imagePullSecrets.0.name",
+		`metadata.annotations."kubectl.kubernetes.io/last-applied-configuration"`,
+		"spec.template.spec.containers[*].image",
+		"spec.template.spec.containers[name=app].env[name=BUILD_ID]",
+		`spec.template.spec.containers[name="app.v1"].image`,
+		"spec.template.spec.containers[0].ports[containerPort=8080]",
+	}
+	for _, key := range validKeys {
+		if err := ValidateConcatKey(key); err != nil {
+			t.Errorf("Test failed for ValidateConcatKey(%s); expected: valid, actual: %s", key, err.Error())
+		}
+	}
+	invalidKeys := []string{
+		"",
+		"metadata..name",
+		".metadata.name",
+		"spec.containers[a].image",
+		"spec.containers[0.image",
+		`metadata.annotations."foo`,
+		"spec.(*",
+		"spec.containers[name=].image",
+		"spec.containers[=app].image",
+		"spec.containers[name=app.image",
+		"spec.containers[name=app]image",
+		"[0].image",
+	}
+	for _, key := range invalidKeys {
+		if err := ValidateConcatKey(key); err == nil {
+			t.Errorf("Test failed for ValidateConcatKey(%s); expected: invalid, actual: valid", key)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package mapnode
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+/**********************************************
+
+					Normalization
+
+***********************************************/
+
+// quantityParentFields are the fields whose values are maps of quantities, e.g. `resources.limits.cpu` and `spec.hard.pods`
+var quantityParentFields = map[string]bool{
+	"limits":               true,
+	"requests":             true,
+	"hard":                 true,
+	"used":                 true,
+	"capacity":             true,
+	"allocatable":          true,
+	"overhead":             true,
+	"podFixed":             true,
+	"default":              true,
+	"defaultRequest":       true,
+	"max":                  true,
+	"min":                  true,
+	"maxLimitRequestRatio": true,
+}
+
+// quantityFields are the fields whose values are quantities, e.g. `emptyDir.sizeLimit`
+var quantityFields = map[string]bool{
+	"sizeLimit": true,
+}
+
+// intOrStringFields are the fields of IntOrString type, where `8080` and `"8080"` are the same
+var intOrStringFields = map[string]bool{
+	"port":           true,
+	"targetPort":     true,
+	"maxSurge":       true,
+	"maxUnavailable": true,
+	"minAvailable":   true,
+}
+
+// durationFields are the fields of Duration type, where `1h` and `60m` are the same
+var durationFields = map[string]bool{
+	"duration":    true,
+	"renewBefore": true,
+	"interval":    true,
+	"timeout":     true,
+}
+
+// normalizeValues replaces a value in m2 with the value in m1 at the same key if they are semantically identical,
+// so that they are not reported as a diff. m1 and m2 are raveled maps (see Ravel()).
+func normalizeValues(m1, m2 map[string]interface{}) {
+	for k, v2 := range m2 {
+		v1, ok := m1[k]
+		if !ok || v1 == nil || v2 == nil || reflect.DeepEqual(v1, v2) {
+			continue
+		}
+		if equivalentValues(k, v1, v2) {
+			m2[k] = v1
+		}
+	}
+}
+
+// equivalentValues returns true if the values at the path are the same in Kubernetes, e.g. `cpu: 0.5` and `cpu: 500m`
+func equivalentValues(path string, v1, v2 interface{}) bool {
+	// numbers of different types (e.g. int64 and float64) are compared by value in any field
+	n1, isNum1 := numberValue(v1)
+	n2, isNum2 := numberValue(v2)
+	if isNum1 && isNum2 {
+		return n1 == n2
+	}
+
+	pathParts := strings.Split(path, ".")
+	field := pathParts[len(pathParts)-1]
+	parentField := ""
+	if len(pathParts) > 1 {
+		parentField = pathParts[len(pathParts)-2]
+	}
+
+	if quantityFields[field] || quantityParentFields[parentField] {
+		q1, ok1 := quantityValue(v1)
+		q2, ok2 := quantityValue(v2)
+		return ok1 && ok2 && q1.Cmp(q2) == 0
+	}
+	if intOrStringFields[field] {
+		s1, ok1 := scalarString(v1)
+		s2, ok2 := scalarString(v2)
+		return ok1 && ok2 && s1 == s2
+	}
+	if durationFields[field] {
+		d1, ok1 := durationValue(v1)
+		d2, ok2 := durationValue(v2)
+		return ok1 && ok2 && d1 == d2
+	}
+	return false
+}
+
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// scalarString returns the string form of a string or a number, e.g. "8080" for 8080
+func scalarString(v interface{}) (string, bool) {
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	if n, ok := numberValue(v); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+func quantityValue(v interface{}) (resource.Quantity, bool) {
+	s, ok := scalarString(v)
+	if !ok {
+		return resource.Quantity{}, false
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return resource.Quantity{}, false
+	}
+	return q, true
+}
+
+func durationValue(v interface{}) (time.Duration, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
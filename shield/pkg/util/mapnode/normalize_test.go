@@ -0,0 +1,114 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package mapnode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizedDiff(t *testing.T) {
+	testCases := []struct {
+		name         string
+		signed       string
+		request      string
+		expectedKeys []string
+	}{
+		{
+			name:    "cpu in decimal and milli",
+			signed:  `{"spec":{"containers":[{"name":"app","resources":{"limits":{"cpu":0.5},"requests":{"cpu":"0.25"}}}]}}`,
+			request: `{"spec":{"containers":[{"name":"app","resources":{"limits":{"cpu":"500m"},"requests":{"cpu":"250m"}}}]}}`,
+		},
+		{
+			name:    "memory in Gi and Mi",
+			signed:  `{"spec":{"containers":[{"name":"app","resources":{"limits":{"memory":"1Gi"}}}]}}`,
+			request: `{"spec":{"containers":[{"name":"app","resources":{"limits":{"memory":"1024Mi"}}}]}}`,
+		},
+		{
+			name:         "memory in G and Gi",
+			signed:       `{"spec":{"containers":[{"name":"app","resources":{"limits":{"memory":"1G"}}}]}}`,
+			request:      `{"spec":{"containers":[{"name":"app","resources":{"limits":{"memory":"1Gi"}}}]}}`,
+			expectedKeys: []string{"spec.containers.0.resources.limits.memory"},
+		},
+		{
+			name:    "number and string of the same quantity",
+			signed:  `{"spec":{"hard":{"pods":"10","requests.storage":"1Ti"},"volumes":[{"name":"cache","emptyDir":{"sizeLimit":"1Gi"}}]}}`,
+			request: `{"spec":{"hard":{"pods":10,"requests.storage":"1024Gi"},"volumes":[{"name":"cache","emptyDir":{"sizeLimit":"1073741824"}}]}}`,
+		},
+		{
+			name:    "IntOrString",
+			signed:  `{"spec":{"ports":[{"port":80,"targetPort":"8080"}],"strategy":{"rollingUpdate":{"maxSurge":1,"maxUnavailable":"25%"}}}}`,
+			request: `{"spec":{"ports":[{"port":"80","targetPort":8080}],"strategy":{"rollingUpdate":{"maxSurge":"1","maxUnavailable":"25%"}}}}`,
+		},
+		{
+			name:         "IntOrString with different values",
+			signed:       `{"spec":{"strategy":{"rollingUpdate":{"maxUnavailable":"25%"}}}}`,
+			request:      `{"spec":{"strategy":{"rollingUpdate":{"maxUnavailable":25}}}}`,
+			expectedKeys: []string{"spec.strategy.rollingUpdate.maxUnavailable"},
+		},
+		{
+			name:    "duration",
+			signed:  `{"spec":{"duration":"2160h","renewBefore":"1h"}}`,
+			request: `{"spec":{"duration":"2160h0m0s","renewBefore":"60m"}}`,
+		},
+		{
+			// a string field is not normalized even if the value looks like a number, a quantity or a duration
+			name:         "not normalized fields",
+			signed:       `{"metadata":{"labels":{"ttl":"1h","size":"1Gi"}},"data":{"count":"1"}}`,
+			request:      `{"metadata":{"labels":{"ttl":"60m","size":"1024Mi"}},"data":{"count":1}}`,
+			expectedKeys: []string{"data.count", "metadata.labels.size", "metadata.labels.ttl"},
+		},
+	}
+
+	for _, tc := range testCases {
+		signed, err := NewFromBytes([]byte(tc.signed))
+		if err != nil {
+			t.Errorf("[%s] failed to load signed manifest; %s", tc.name, err.Error())
+			continue
+		}
+		request, err := NewFromBytes([]byte(tc.request))
+		if err != nil {
+			t.Errorf("[%s] failed to load request; %s", tc.name, err.Error())
+			continue
+		}
+		var actualKeys []string
+		if dr := signed.Diff(request); dr != nil && dr.Size() > 0 {
+			actualKeys = dr.Keys()
+		}
+		if !reflect.DeepEqual(actualKeys, tc.expectedKeys) {
+			t.Errorf("[%s] expected diff keys: %v, actual: %v", tc.name, tc.expectedKeys, actualKeys)
+		}
+	}
+}
+
+func TestEquivalentValues(t *testing.T) {
+	if !equivalentValues("spec.replicas", int64(3), float64(3)) {
+		t.Errorf("int64 and float64 of the same value should be equivalent")
+	}
+	if equivalentValues("spec.replicas", int64(3), float64(3.5)) {
+		t.Errorf("different numbers should not be equivalent")
+	}
+	if !equivalentValues("spec.template.spec.containers.0.livenessProbe.httpGet.port", "8080", int64(8080)) {
+		t.Errorf("IntOrString port should be equivalent")
+	}
+	if equivalentValues("spec.template.spec.containers.0.livenessProbe.httpGet.port", "http", int64(8080)) {
+		t.Errorf("named port and number should not be equivalent")
+	}
+	if equivalentValues("spec.template.spec.containers.0.resources.limits.cpu", "500m", "abc") {
+		t.Errorf("invalid quantity should not be equivalent")
+	}
+}
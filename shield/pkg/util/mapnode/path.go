@@ -0,0 +1,313 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package mapnode
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**********************************************
+
+					Path
+
+***********************************************/
+
+// A path is a "."-separated list of fields like `spec.template.spec.containers`, and a field can be followed by list selectors.
+//   - `[]` or `[*]` selects all items in the list
+//   - `[<index>]` selects the item at the index
+//   - `[<field>=<value>]` selects the items whose field has the value, e.g. `containers[name=app].env[name=BUILD_ID]`
+// A field or a value can be quoted if it contains "." or brackets, e.g. `metadata.annotations."kubernetes.io/created-by"`.
+
+// pathSegment is a field or a list selector in a path
+type pathSegment struct {
+	field    string
+	selector *listSelector
+}
+
+type listSelector struct {
+	all      bool
+	index    int
+	keyField string
+	keyValue string
+}
+
+// needsObject is false for selectors which can be resolved without the object (i.e. index)
+func (s *listSelector) needsObject() bool {
+	return s.all || s.keyField != ""
+}
+
+func (s *listSelector) match(item *Node, index int) bool {
+	if s.all {
+		return true
+	}
+	if s.keyField == "" {
+		return s.index == index
+	}
+	keyNode, ok := item.GetChild(s.keyField)
+	if !ok || keyNode.Value == nil {
+		return false
+	}
+	return fmt.Sprintf("%v", keyNode.Value.Value) == s.keyValue
+}
+
+func parsePath(path string) ([]pathSegment, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, errors.New("empty key")
+	}
+	segments := []pathSegment{}
+	runes := []rune(path)
+	// fieldExpected is true at the beginning and after ".", where a field (not a selector or the end) must come
+	fieldExpected := true
+	i := 0
+	for i < len(runes) {
+		switch runes[i] {
+		case '.':
+			if fieldExpected {
+				return nil, fmt.Errorf("empty field in key `%s`", path)
+			}
+			fieldExpected = true
+			i++
+		case '[':
+			if len(segments) == 0 {
+				return nil, fmt.Errorf("list selector without field in key `%s`", path)
+			}
+			if fieldExpected {
+				return nil, fmt.Errorf("empty field in key `%s`", path)
+			}
+			end, err := findSelectorEnd(runes, i)
+			if err != nil {
+				return nil, fmt.Errorf("%s in key `%s`", err.Error(), path)
+			}
+			selector, err := parseListSelector(string(runes[i+1 : end]))
+			if err != nil {
+				return nil, fmt.Errorf("%s in key `%s`", err.Error(), path)
+			}
+			segments = append(segments, pathSegment{selector: selector})
+			i = end + 1
+			if i < len(runes) && runes[i] != '.' && runes[i] != '[' {
+				return nil, fmt.Errorf("unexpected character after list selector in key `%s`", path)
+			}
+		case ']':
+			return nil, fmt.Errorf("unbalanced bracket in key `%s`", path)
+		case '"':
+			if !fieldExpected {
+				return nil, fmt.Errorf("invalid quotation in key `%s`", path)
+			}
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("invalid quotation in key `%s`", path)
+			}
+			segments = append(segments, pathSegment{field: string(runes[i+1 : end])})
+			fieldExpected = false
+			i = end + 1
+			if i < len(runes) && runes[i] != '.' && runes[i] != '[' {
+				return nil, fmt.Errorf("invalid quotation in key `%s`", path)
+			}
+		default:
+			if !fieldExpected {
+				return nil, fmt.Errorf("unexpected character in key `%s`", path)
+			}
+			j := i
+			for j < len(runes) && runes[j] != '.' && runes[j] != '[' && runes[j] != ']' {
+				if runes[j] == '"' {
+					return nil, fmt.Errorf("invalid quotation in key `%s`", path)
+				}
+				j++
+			}
+			segments = append(segments, pathSegment{field: string(runes[i:j])})
+			fieldExpected = false
+			i = j
+		}
+	}
+	if fieldExpected {
+		return nil, fmt.Errorf("empty field in key `%s`", path)
+	}
+	return segments, nil
+}
+
+// findSelectorEnd returns the position of "]" which closes the selector starting at `start`; "]" in a quoted value is skipped
+func findSelectorEnd(runes []rune, start int) (int, error) {
+	quoted := false
+	for j := start + 1; j < len(runes); j++ {
+		switch runes[j] {
+		case '"':
+			quoted = !quoted
+		case ']':
+			if !quoted {
+				return j, nil
+			}
+		case '[':
+			if !quoted {
+				return -1, errors.New("unbalanced bracket")
+			}
+		}
+	}
+	return -1, errors.New("unbalanced bracket")
+}
+
+func parseListSelector(expr string) (*listSelector, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" || expr == "*" {
+		return &listSelector{all: true}, nil
+	}
+	if index, err := strconv.Atoi(expr); err == nil {
+		if index < 0 {
+			return nil, fmt.Errorf("invalid list index `[%s]`", expr)
+		}
+		return &listSelector{index: index}, nil
+	}
+	parts := strings.SplitN(expr, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid list index `[%s]`", expr)
+	}
+	keyField := strings.TrimSpace(parts[0])
+	keyValue := strings.TrimSpace(parts[1])
+	if keyField == "" {
+		return nil, fmt.Errorf("empty field in list selector `[%s]`", expr)
+	}
+	if keyValue == "" {
+		return nil, fmt.Errorf("empty value in list selector `[%s]`; use `\"\"` for an empty string", expr)
+	}
+	if strings.HasPrefix(keyValue, "\"") {
+		unquoted, err := strconv.Unquote(keyValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quotation in list selector `[%s]`", expr)
+		}
+		keyValue = unquoted
+	}
+	return &listSelector{keyField: keyField, keyValue: keyValue}, nil
+}
+
+// hasListSelector returns true if the key has any selector which needs the object to be resolved (e.g. `[]`, `[*]`, `[name=app]`)
+func hasListSelector(concatKey string) bool {
+	if !strings.Contains(concatKey, "[") {
+		return false
+	}
+	segments, err := parsePath(concatKey)
+	if err != nil {
+		return false
+	}
+	for _, seg := range segments {
+		if seg.selector != nil && seg.selector.needsObject() {
+			return true
+		}
+	}
+	return false
+}
+
+// expandPath resolves list selectors in the key with this node, and returns the concrete keys like `spec.containers.0.image`.
+// Fields after the last selector are not resolved, so they can have "*" in the same way as keys without selector.
+func (t *Node) expandPath(concatKey string) []string {
+	segments, err := parsePath(concatKey)
+	if err != nil {
+		return []string{}
+	}
+	return t.recursiveExpandPath(segments, []string{})
+}
+
+func (n *Node) recursiveExpandPath(segments []pathSegment, current []string) []string {
+	if !segmentsHaveSelector(segments) {
+		keyParts := append([]string{}, current...)
+		for _, seg := range segments {
+			if seg.selector != nil {
+				keyParts = append(keyParts, strconv.Itoa(seg.selector.index))
+			} else {
+				keyParts = append(keyParts, seg.field)
+			}
+		}
+		return []string{strings.Join(keyParts, ".")}
+	}
+	seg := segments[0]
+	rest := segments[1:]
+	keys := []string{}
+	if seg.selector != nil {
+		if !n.IsSlice() {
+			return keys
+		}
+		for i, item := range n.Children.([]*Node) {
+			if seg.selector.match(item, i) {
+				keys = append(keys, item.recursiveExpandPath(rest, appendKeyPart(current, strconv.Itoa(i)))...)
+			}
+		}
+		return keys
+	}
+	if strings.Contains(seg.field, "*") && n.IsMap() {
+		children := n.Children.(map[string]*Node)
+		childKeys := []string{}
+		for k := range children {
+			if isListed(k, seg.field) {
+				childKeys = append(childKeys, k)
+			}
+		}
+		sort.Strings(childKeys)
+		for _, k := range childKeys {
+			keys = append(keys, children[k].recursiveExpandPath(rest, appendKeyPart(current, k))...)
+		}
+		return keys
+	}
+	child, ok := n.GetChild(seg.field)
+	if !ok {
+		return keys
+	}
+	return child.recursiveExpandPath(rest, appendKeyPart(current, seg.field))
+}
+
+func appendKeyPart(current []string, part string) []string {
+	keyParts := append([]string{}, current...)
+	return append(keyParts, part)
+}
+
+func segmentsHaveSelector(segments []pathSegment) bool {
+	for _, seg := range segments {
+		if seg.selector != nil && seg.selector.needsObject() {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandKeys resolves list selectors in the keys with the nodes (e.g. both objects of a diff), and returns the concrete keys.
+// Keys without list selector are returned as they are.
+func ExpandKeys(keys []string, nodes ...*Node) []string {
+	expanded := []string{}
+	found := map[string]bool{}
+	for _, key := range keys {
+		if !hasListSelector(key) {
+			expanded = append(expanded, key)
+			continue
+		}
+		for _, n := range nodes {
+			if n == nil {
+				continue
+			}
+			for _, k := range n.expandPath(key) {
+				if !found[k] {
+					found[k] = true
+					expanded = append(expanded, k)
+				}
+			}
+		}
+	}
+	return expanded
+}
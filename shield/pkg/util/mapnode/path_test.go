@@ -0,0 +1,89 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package mapnode
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testPodSpecJson = `{"spec":{"containers":[` +
+	`{"name":"init","image":"registry.example.com/init:1.0.0","env":[{"name":"BUILD_ID","value":"100"}]},` +
+	`{"name":"app","image":"registry.example.com/app:1.0.0","env":[{"name":"LOG_LEVEL","value":"info"},{"name":"BUILD_ID","value":"101"}],"ports":[{"containerPort":8080}]}]}}`
+
+func TestListSelector(t *testing.T) {
+	node, _ := NewFromBytes([]byte(testPodSpecJson))
+
+	expectedKeys := map[string][]string{
+		"spec.containers[*].image":                          {"spec.containers.0.image", "spec.containers.1.image"},
+		"spec.containers[].image":                           {"spec.containers.0.image", "spec.containers.1.image"},
+		"spec.containers[name=app].env[name=BUILD_ID]":      {"spec.containers.1.env.1"},
+		`spec.containers[name="app"].env[*].value`:          {"spec.containers.1.env.0.value", "spec.containers.1.env.1.value"},
+		"spec.containers[1].ports[containerPort=8080]":      {"spec.containers.1.ports.0"},
+		"spec.containers[name=sidecar].image":               {},
+		"spec.containers[name=app].ports[containerPort=80]": {},
+	}
+	for key, expected := range expectedKeys {
+		if actual := ExpandKeys([]string{key}, node); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("ExpandKeys(%s); expected: %v, actual: %v", key, expected, actual)
+		}
+	}
+
+	// keys without list selector are not changed
+	if actual := ExpandKeys([]string{"spec.containers[0].image", "metadata.*"}, node); !reflect.DeepEqual(actual, []string{"spec.containers[0].image", "metadata.*"}) {
+		t.Errorf("keys without list selector should not be changed, but got %v", actual)
+	}
+
+	masked := node.Mask([]string{"spec.containers[name=app].env[name=BUILD_ID]"})
+	if masked.GetString("spec.containers.1.env.1.name") != "" || masked.GetString("spec.containers.0.env.0.name") != "BUILD_ID" {
+		t.Errorf("only BUILD_ID env in app container should be masked, but got %s", masked.ToJson())
+	}
+
+	extracted := node.Extract([]string{"spec.containers[name=app].image"})
+	expectedExtracted := `{"spec":{"containers":[{"image":"registry.example.com/app:1.0.0"}]}}`
+	if extracted.ToJson() != expectedExtracted {
+		t.Errorf("only image of app container should be extracted; expected: %s, actual: %s", expectedExtracted, extracted.ToJson())
+	}
+
+	if subNodes := node.MultipleSubNode("spec.containers[name=init].env[]"); len(subNodes) != 1 || subNodes[0].GetString("value") != "100" {
+		t.Errorf("MultipleSubNode() should return env of init container")
+	}
+}
+
+func TestFilterWithListSelector(t *testing.T) {
+	before, _ := NewFromBytes([]byte(testPodSpecJson))
+	after, _ := NewFromBytes([]byte(`{"spec":{"containers":[` +
+		`{"name":"init","image":"registry.example.com/init:1.0.0","env":[{"name":"BUILD_ID","value":"200"}]},` +
+		`{"name":"app","image":"registry.example.com/app:1.0.0","env":[{"name":"LOG_LEVEL","value":"info"},{"name":"BUILD_ID","value":"201"}],"ports":[{"containerPort":8080}]}]}}`))
+	dr := before.Diff(after)
+
+	maskKeys := ExpandKeys([]string{"spec.containers[name=app].env[name=BUILD_ID]"}, before, after)
+	filtered, unfiltered, _ := dr.Filter(maskKeys)
+	if filtered.Size() != 1 || filtered.Items[0].Key != "spec.containers.1.env.1.value" {
+		t.Errorf("BUILD_ID env in app container should be filtered, but got %s", filtered.String())
+	}
+	if unfiltered.Size() != 1 || unfiltered.Items[0].Key != "spec.containers.0.env.0.value" {
+		t.Errorf("BUILD_ID env in init container should not be filtered, but got %s", unfiltered.String())
+	}
+
+	// a key for a list item does not match another item whose index starts with the same digit
+	dr = &DiffResult{Items: []Difference{{Key: "spec.containers.1.image"}, {Key: "spec.containers.10.image"}}}
+	filtered, unfiltered, _ = dr.Filter([]string{"spec.containers.1"})
+	if filtered.Size() != 1 || unfiltered.Size() != 1 || unfiltered.Items[0].Key != "spec.containers.10.image" {
+		t.Errorf("spec.containers.1 should not match spec.containers.10; filtered: %s, unfiltered: %s", filtered.String(), unfiltered.String())
+	}
+}
@@ -0,0 +1,61 @@
+//
+// Copyright 2020 IBM Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ishield"
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	// OpenAPISchemaRefreshTotal is the number of OpenAPI schema loads by trigger (`initial`, `crd`, `interval`) and result
+	OpenAPISchemaRefreshTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "openapi_schema_refresh_total",
+			Help:      "Number of OpenAPI schema refreshes by trigger and result",
+		},
+		[]string{"trigger", "result"},
+	)
+	// OpenAPISchemaLastRefreshTimestamp is the time of the last successful OpenAPI schema load
+	OpenAPISchemaLastRefreshTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "openapi_schema_last_refresh_timestamp_seconds",
+			Help:      "Unix time of the last successful OpenAPI schema refresh",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(OpenAPISchemaRefreshTotal)
+	prometheus.MustRegister(OpenAPISchemaLastRefreshTimestamp)
+}
+
+// Handler returns the http handler for the metrics endpoint
+func Handler() http.Handler {
+	return promhttp.Handler()
+}